@@ -0,0 +1,305 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/gin-gonic/gin"
+)
+
+// Photos are stored as STORAGE_PATH/subscribers/photos/<subscriberId><ext>,
+// documents as STORAGE_PATH/subscribers/documents/<subscriberId>/<attachmentId><ext>.
+const (
+	subscriberPhotosFolder    = "subscribers/photos"
+	subscriberDocumentsFolder = "subscribers/documents"
+)
+
+var attachmentKinds = map[string]bool{
+	"id":      true,
+	"medical": true,
+	"form":    true,
+	"other":   true,
+}
+
+// Allowed document extensions and the content type their bytes must sniff as
+var documentContentTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+}
+
+func subscriberDocumentPath(attachment *db.SubscriberAttachment) string {
+	return filepath.Join(
+		common.StoragePath,
+		subscriberDocumentsFolder,
+		fmt.Sprintf("%d", attachment.SubscriberID),
+		fmt.Sprintf("%d%s", attachment.ID, strings.ToLower(filepath.Ext(attachment.FileName))),
+	)
+}
+
+// Removes the photo and all documents of a subscriber from storage
+func deleteSubscriberFiles(id int64) {
+	if photoErr := deleteStoredImage(subscriberPhotosFolder, id); photoErr != nil {
+		common.Logger.Printf("failed to delete subscriber photo (id: %d): %v", id, photoErr)
+	}
+
+	documentsErr := os.RemoveAll(filepath.Join(common.StoragePath, subscriberDocumentsFolder, fmt.Sprintf("%d", id)))
+	if documentsErr != nil {
+		common.Logger.Printf("failed to delete subscriber documents (id: %d): %v", id, documentsErr)
+	}
+}
+
+// Responds with 404 and returns false if the subscriber does not exist
+func subscriberExistsOrAbort(ctx *gin.Context, id int64) bool {
+	sub, queryErr := db.GetSubscriberByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscriber by ID: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	if sub == nil {
+		ctx.String(http.StatusNotFound, "Subscriber not found")
+		return false
+	}
+
+	return true
+}
+
+func UploadSubscriberPhoto(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !subscriberExistsOrAbort(ctx, id) {
+		return
+	}
+
+	if !saveUploadedImage(ctx, subscriberPhotosFolder, id) {
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func GetSubscriberPhoto(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	serveStoredImage(ctx, subscriberPhotosFolder, id)
+}
+
+func DeleteSubscriberPhoto(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	deleteErr := deleteStoredImage(subscriberPhotosFolder, id)
+	if deleteErr != nil {
+		common.Logger.Printf("failed to delete subscriber photo (id: %d): %v", id, deleteErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func GetSubscriberDocuments(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	attachments, queryErr := db.GetSubscriberAttachments(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscriber attachments: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, attachments)
+}
+
+// Expects a multipart form with the file 'document' and the field 'kind'
+func UploadSubscriberDocument(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	kind := strings.ToLower(ctx.PostForm("kind"))
+	if kind == "" {
+		kind = "other"
+	}
+
+	if !attachmentKinds[kind] {
+		ctx.String(http.StatusBadRequest, "Invalid kind: must be one of 'id', 'medical', 'form' or 'other'.")
+		return
+	}
+
+	document, formErr := ctx.FormFile("document")
+	if formErr != nil {
+		ctx.String(http.StatusBadRequest, "Required file: 'document'.")
+		return
+	}
+
+	if document.Size > 20<<20 {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "Document size is too large (max is 20 MB).")
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(document.Filename))
+
+	contentType, allowed := documentContentTypes[ext]
+	if !allowed {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "Only files with extensions '.pdf', '.png', '.jpg' and '.jpeg' are allowed.")
+		return
+	}
+
+	sniffed, sniffErr := sniffContentType(document)
+	if sniffErr != nil {
+		common.Logger.Printf("failed to check uploaded document: %v", sniffErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if sniffed != contentType {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "File content does not match its extension.")
+		return
+	}
+
+	if !subscriberExistsOrAbort(ctx, id) {
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	user := userPtr.(*db.User)
+
+	attachment := db.SubscriberAttachment{
+		SubscriberID: id,
+		UploadedByID: user.ID,
+		Kind:         kind,
+		FileName:     filepath.Base(document.Filename),
+		ContentType:  contentType,
+		Size:         document.Size,
+	}
+
+	attachmentID, queryErr := db.CreateSubscriberAttachment(db.DB, attachment)
+	if queryErr != nil {
+		common.Logger.Printf("failed to create subscriber attachment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	attachment.ID = attachmentID
+	path := subscriberDocumentPath(&attachment)
+
+	if mkdirErr := os.MkdirAll(filepath.Dir(path), 0o755); mkdirErr != nil {
+		common.Logger.Printf("failed to create documents folder (subscriber id: %d): %v", id, mkdirErr)
+		_ = db.DeleteSubscriberAttachmentByID(db.DB, attachmentID)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	uploadErr := ctx.SaveUploadedFile(document, path)
+	if uploadErr != nil {
+		common.Logger.Printf("failed to upload subscriber document (id: %d): %v", attachmentID, uploadErr)
+		_ = db.DeleteSubscriberAttachmentByID(db.DB, attachmentID)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, attachmentID)
+}
+
+func GetSubscriberDocument(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("attachmentId"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: attachmentId")
+		return
+	}
+
+	attachment, queryErr := db.GetSubscriberAttachmentByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscriber attachment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if attachment == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	bytes, readErr := os.ReadFile(subscriberDocumentPath(attachment))
+	if readErr != nil {
+		if errors.Is(readErr, fs.ErrNotExist) {
+			ctx.Status(http.StatusNotFound)
+			return
+		}
+
+		common.Logger.Printf("failed to read subscriber document (id: %d): %v", id, readErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", attachment.FileName))
+	ctx.Data(http.StatusOK, attachment.ContentType, bytes)
+}
+
+func DeleteSubscriberDocument(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("attachmentId"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: attachmentId")
+		return
+	}
+
+	attachment, queryErr := db.GetSubscriberAttachmentByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscriber attachment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if attachment == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	deleteErr := db.DeleteSubscriberAttachmentByID(db.DB, id)
+	if deleteErr != nil {
+		common.Logger.Printf("failed to delete subscriber attachment: %v", deleteErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	removeErr := os.Remove(subscriberDocumentPath(attachment))
+	if removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+		common.Logger.Printf("failed to remove subscriber document file (id: %d): %v", id, removeErr)
+	}
+
+	ctx.Status(http.StatusOK)
+}
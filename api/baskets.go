@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -86,8 +87,41 @@ func AddToUserBasket(ctx *gin.Context) {
 		return
 	}
 
-	createdId, queryErr := db.CreateProductBasket(db.DB, user.ID, productId, int(quantity))
+	// Products with variants are added by variant
+	var variantId *int64
+
+	if value := ctx.Query("variantId"); value != "" {
+		converted, convErr := strconv.ParseInt(value, 10, 64)
+		if convErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid query paramter: variantId")
+			return
+		}
+
+		variantId = &converted
+	}
+
+	createdId, queryErr := db.CreateProductBasket(db.DB, user.ID, productId, variantId, int(quantity))
 	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrProductNotFound) {
+			ctx.String(http.StatusNotFound, "Product not found")
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrVariantNotFound) {
+			ctx.String(http.StatusNotFound, "Variant not found")
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrVariantRequired) {
+			ctx.String(http.StatusBadRequest, "Missing query parameter: variantId")
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrInsufficientStock) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
 		common.Logger.Printf("failed to add product to basket: %v", queryErr)
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
@@ -105,6 +139,11 @@ func IncrementBasketQuantity(ctx *gin.Context) {
 
 	queryErr := db.IncrementBasketProductQuantityByID(db.DB, basketId)
 	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrInsufficientStock) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
 		common.Logger.Printf("failed to increment basket quantity: %v", queryErr)
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
@@ -1,12 +1,8 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"strings"
 
 	"github.com/HenryMarkle/gmserver/common"
 	"github.com/HenryMarkle/gmserver/db"
@@ -15,48 +11,11 @@ import (
 )
 
 func findBlogImage(id int64) (string, bool, error) {
-	entries, lookErr := os.ReadDir(filepath.Join(common.StoragePath, "blogs"))
-
-	if lookErr != nil {
-		return "", false, lookErr
-	}
-
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-
-		name := e.Name()
-
-		if strings.HasPrefix(name, fmt.Sprintf("%d", id)) {
-			return filepath.Join(common.StoragePath, "blogs", name), true, nil
-		}
-	}
-
-	return "", false, nil
+	return findStoredImage("blogs", id)
 }
 
 func deleteBlogImage(id int64) error {
-	entries, lookErr := os.ReadDir(filepath.Join(common.StoragePath, "blogs"))
-
-	if lookErr != nil {
-		return lookErr
-	}
-
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-
-		name := e.Name()
-
-		if strings.HasPrefix(name, fmt.Sprintf("%d", id)) {
-			os.Remove(filepath.Join(common.StoragePath, "blogs", name))
-			return nil
-		}
-	}
-
-	return nil
+	return deleteStoredImage("blogs", id)
 }
 
 func GetBlogByID(ctx *gin.Context) {
@@ -165,36 +124,7 @@ func UploadBlogImage(ctx *gin.Context) {
 		ctx.String(http.StatusBadRequest, "Invalid parameter: 'id': %v", convErr)
 	}
 
-	image, formErr := ctx.FormFile("image")
-	var imageExt string
-	if formErr != nil {
-		ctx.String(http.StatusBadRequest, "Required file: 'image'.")
-		return
-	}
-
-	if image.Size > 10<<20 {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, "Image size is too large (max is 10 MB).")
-		return
-	}
-
-	imageExt = filepath.Ext(image.Filename)
-
-	if imageExt != ".png" && imageExt != ".jpg" && imageExt != ".jpeg" {
-		ctx.AbortWithStatusJSON(http.StatusForbidden, "Only files with extensions '.png', '.jpg' and '.jpeg' are allowed.")
-		return
-	}
-
-	deleteErr := deleteBlogImage(id)
-	if deleteErr != nil {
-		common.Logger.Printf("failed to delete previous blog image (id: %d): %v", id, deleteErr)
-	}
-
-	imagePath := filepath.Join(common.StoragePath, "blogs", fmt.Sprintf("%d%s", id, imageExt))
-
-	uploadErr := ctx.SaveUploadedFile(image, imagePath)
-	if uploadErr != nil {
-		common.Logger.Printf("failed to upload blog image (id: %d): %v", id, uploadErr)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+	if !saveUploadedImage(ctx, "blogs", id) {
 		return
 	}
 
@@ -232,25 +162,5 @@ func GetBlogImageByID(ctx *gin.Context) {
 		ctx.String(http.StatusBadRequest, "Invalid parameter: 'id': %v", convErr)
 	}
 
-	path, found, lookErr := findBlogImage(id)
-
-	if lookErr != nil {
-		common.Logger.Printf("failed to find blog image (id: %d): %v", id, lookErr)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
-		return
-	}
-
-	if !found {
-		ctx.AbortWithStatus(http.StatusNotFound)
-		return
-	}
-
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		common.Logger.Printf("failed to read blog image file (id: %d): %v", id, err)
-		ctx.AbortWithStatus(http.StatusInternalServerError)
-		return
-	}
-
-	ctx.Data(http.StatusOK, fmt.Sprintf("image/%s", filepath.Ext(path)[1:]), bytes)
+	serveStoredImage(ctx, "blogs", id)
 }
@@ -0,0 +1,626 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Creates the sessions of active schedules for the given number of days
+// starting at from, skipping exception dates and sessions that already exist.
+func generateClassSessions(from time.Time, days int) (int, error) {
+	schedules, schedulesErr := db.GetClassSchedules(db.DB, true)
+	if schedulesErr != nil {
+		return 0, schedulesErr
+	}
+
+	first := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.Local)
+
+	exceptions, exceptionsErr := db.GetClassScheduleExceptions(db.DB, first.Format(common.DateLayout))
+	if exceptionsErr != nil {
+		return 0, exceptionsErr
+	}
+
+	// Dates mapped to the excepted schedule IDs, with 0 standing for all schedules
+	excepted := map[string]map[int64]bool{}
+	for _, exception := range exceptions {
+		if excepted[exception.Date] == nil {
+			excepted[exception.Date] = map[int64]bool{}
+		}
+
+		if exception.ScheduleID == nil {
+			excepted[exception.Date][0] = true
+		} else {
+			excepted[exception.Date][*exception.ScheduleID] = true
+		}
+	}
+
+	sessions := []db.ClassSession{}
+
+	for d := 0; d < days; d++ {
+		date := first.AddDate(0, 0, d)
+		dateStr := date.Format(common.DateLayout)
+
+		for i := range schedules {
+			schedule := &schedules[i]
+
+			if int(date.Weekday()) != schedule.Weekday || dateStr < schedule.ValidFrom {
+				continue
+			}
+
+			if schedule.ValidUntil != "" && dateStr > schedule.ValidUntil {
+				continue
+			}
+
+			if excepted[dateStr][0] || excepted[dateStr][schedule.ID] {
+				continue
+			}
+
+			startTime, parseErr := time.Parse("15:04", schedule.StartTime)
+			if parseErr != nil {
+				common.Logger.Printf("invalid start time of class schedule (id: %d): %v", schedule.ID, parseErr)
+				continue
+			}
+
+			startsAt := time.Date(date.Year(), date.Month(), date.Day(), startTime.Hour(), startTime.Minute(), 0, 0, time.Local)
+			endsAt := startsAt.Add(time.Duration(schedule.DurationMinutes) * time.Minute)
+
+			scheduleID := schedule.ID
+
+			sessions = append(sessions, db.ClassSession{
+				ScheduleID:  &scheduleID,
+				ClassTypeID: schedule.ClassTypeID,
+				TrainerID:   schedule.TrainerID,
+				StartsAt:    startsAt.Format(common.DateTimeLayout),
+				EndsAt:      endsAt.Format(common.DateTimeLayout),
+				Capacity:    schedule.Capacity,
+			})
+		}
+	}
+
+	return db.CreateClassSessions(db.DB, sessions)
+}
+
+func refreshClassSessions() {
+	created, genErr := generateClassSessions(time.Now(), common.ClassSessionsDaysAhead)
+	if genErr != nil {
+		common.Logger.Printf("failed to generate class sessions: %v", genErr)
+		return
+	}
+
+	if created > 0 {
+		common.Logger.Printf("generated %d class sessions", created)
+	}
+}
+
+// Keeps class sessions generated CLASS_SESSIONS_DAYS_AHEAD days ahead, checking every interval.
+// Blocks, so it's meant to be started in its own goroutine.
+func RunClassSessionGeneration(interval time.Duration) {
+	refreshClassSessions()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		refreshClassSessions()
+	}
+}
+
+func GetClassTypes(ctx *gin.Context) {
+	types, queryErr := db.GetClassTypes(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get class types: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, types)
+}
+
+func CreateClassType(ctx *gin.Context) {
+	data := dto.ClassType_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	id, queryErr := db.CreateClassType(db.DB, db.ClassType{
+		Name:            data.Name,
+		Description:     data.Description,
+		DefaultCapacity: data.DefaultCapacity,
+		DurationMinutes: data.DurationMinutes,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a class type: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func UpdateClassType(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.ClassType_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	queryErr := db.UpdateClassType(db.DB, db.ClassType{
+		ID:              id,
+		Name:            data.Name,
+		Description:     data.Description,
+		DefaultCapacity: data.DefaultCapacity,
+		DurationMinutes: data.DurationMinutes,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to update a class type: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeleteClassType(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	deleted, queryErr := db.DeleteClassTypeByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a class type: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !deleted {
+		ctx.String(http.StatusConflict, "Class type is used by schedules or sessions")
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Converts a schedule request, filling in defaults from the class type.
+// Responds and returns nil when the class type doesn't exist.
+func classScheduleFromRequest(ctx *gin.Context, data *dto.ClassSchedule_Req) *db.ClassSchedule {
+	classType, queryErr := db.GetClassTypeByID(db.DB, data.ClassTypeID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a class type: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil
+	}
+
+	if classType == nil {
+		ctx.String(http.StatusNotFound, "Class type not found")
+		return nil
+	}
+
+	if data.ValidUntil != "" && data.ValidUntil < data.ValidFrom {
+		ctx.String(http.StatusBadRequest, "validUntil is before validFrom")
+		return nil
+	}
+
+	schedule := &db.ClassSchedule{
+		ClassTypeID:     data.ClassTypeID,
+		TrainerID:       data.TrainerID,
+		Weekday:         data.Weekday,
+		StartTime:       data.StartTime,
+		DurationMinutes: data.DurationMinutes,
+		Capacity:        data.Capacity,
+		ValidFrom:       data.ValidFrom,
+		ValidUntil:      data.ValidUntil,
+	}
+
+	if schedule.DurationMinutes == 0 {
+		schedule.DurationMinutes = classType.DurationMinutes
+	}
+
+	if schedule.Capacity == 0 {
+		schedule.Capacity = classType.DefaultCapacity
+	}
+
+	return schedule
+}
+
+// Query parameter 'all' set to true includes inactive schedules
+func GetClassSchedules(ctx *gin.Context) {
+	schedules, queryErr := db.GetClassSchedules(db.DB, ctx.Query("all") != "true")
+	if queryErr != nil {
+		common.Logger.Printf("failed to get class schedules: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, schedules)
+}
+
+func CreateClassSchedule(ctx *gin.Context) {
+	data := dto.ClassSchedule_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	schedule := classScheduleFromRequest(ctx, &data)
+	if schedule == nil {
+		return
+	}
+
+	id, queryErr := db.CreateClassSchedule(db.DB, *schedule)
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a class schedule: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	refreshClassSessions()
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func UpdateClassSchedule(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.ClassSchedule_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	schedule := classScheduleFromRequest(ctx, &data)
+	if schedule == nil {
+		return
+	}
+
+	schedule.ID = id
+
+	queryErr := db.UpdateClassSchedule(db.DB, *schedule)
+	if queryErr != nil {
+		common.Logger.Printf("failed to update a class schedule: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	refreshClassSessions()
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeactivateClassSchedule(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeactivateClassSchedule(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to deactivate a class schedule: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Query parameter 'from' optionally skips earlier exceptions
+func GetClassScheduleExceptions(ctx *gin.Context) {
+	exceptions, queryErr := db.GetClassScheduleExceptions(db.DB, ctx.Query("from"))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get class schedule exceptions: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, exceptions)
+}
+
+func CreateClassScheduleException(ctx *gin.Context) {
+	data := dto.ClassScheduleException_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	id, queryErr := db.CreateClassScheduleException(db.DB, db.ClassScheduleException{
+		ScheduleID: data.ScheduleID,
+		Date:       data.Date,
+		Reason:     data.Reason,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a class schedule exception: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func DeleteClassScheduleException(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeleteClassScheduleExceptionByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a class schedule exception: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	refreshClassSessions()
+
+	ctx.Status(http.StatusOK)
+}
+
+// Query parameters 'from' and 'to' default to the coming week
+func GetClassSessions(ctx *gin.Context) {
+	now := time.Now()
+
+	from := ctx.DefaultQuery("from", now.Format(common.DateLayout))
+	to := ctx.DefaultQuery("to", now.AddDate(0, 0, 7).Format(common.DateLayout))
+
+	if len(to) == len(common.DateLayout) {
+		to += " 23:59:59"
+	}
+
+	sessions, queryErr := db.GetClassSessions(db.DB, from, to)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get class sessions: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func CancelClassSession(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	found, queryErr := db.CancelClassSession(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to cancel a class session: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		ctx.String(http.StatusNotFound, "Class session not found")
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func GetClassSessionBookings(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	bookings, queryErr := db.GetClassSessionBookings(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get class session bookings: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, bookings)
+}
+
+// Gets a session and parses its start, responding and returning nil if it's missing
+func classSessionOrAbort(ctx *gin.Context, id int64) (*db.ClassSession, time.Time) {
+	session, queryErr := db.GetClassSessionByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a class session: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil, time.Time{}
+	}
+
+	if session == nil {
+		ctx.String(http.StatusNotFound, "Class session not found")
+		return nil, time.Time{}
+	}
+
+	startsAt, parseErr := time.ParseInLocation(common.DateTimeLayout, session.StartsAt, time.Local)
+	if parseErr != nil {
+		common.Logger.Printf("failed to parse class session start (id: %d): %v", id, parseErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil, time.Time{}
+	}
+
+	return session, startsAt
+}
+
+func BookClassSession(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.BookClass_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	session, startsAt := classSessionOrAbort(ctx, id)
+	if session == nil {
+		return
+	}
+
+	if session.Cancelled {
+		ctx.String(http.StatusConflict, "Class session is cancelled")
+		return
+	}
+
+	if !time.Now().Before(startsAt) {
+		ctx.String(http.StatusConflict, "Class session has already started")
+		return
+	}
+
+	if !subscriberExistsOrAbort(ctx, data.SubscriberID) {
+		return
+	}
+
+	bookingID, status, queryErr := db.BookClassSession(db.DB, id, data.SubscriberID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to book a class session: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.BookClass_Res{ID: bookingID, Status: status})
+}
+
+// Members can't cancel a held place later than CLASS_CANCEL_CUTOFF_HOURS before
+// the session; leaving the waitlist is always allowed.
+func CancelClassBooking(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	booking, queryErr := db.GetClassBookingByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a class booking: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if booking == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if booking.Status != db.BookingBooked && booking.Status != db.BookingWaitlisted {
+		ctx.String(http.StatusConflict, "Booking is %s", booking.Status)
+		return
+	}
+
+	session, startsAt := classSessionOrAbort(ctx, booking.SessionID)
+	if session == nil {
+		return
+	}
+
+	cutoff := startsAt.Add(-time.Duration(common.ClassCancelCutoffHours) * time.Hour)
+
+	if booking.Status == db.BookingBooked && time.Now().After(cutoff) {
+		ctx.String(http.StatusForbidden, "Bookings can't be cancelled later than %d hours before the class", common.ClassCancelCutoffHours)
+		return
+	}
+
+	promoted, cancelErr := db.CancelClassBooking(db.DB, id)
+	if cancelErr != nil {
+		common.Logger.Printf("failed to cancel a class booking: %v", cancelErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if promoted != nil {
+		common.Logger.Printf("promoted class booking %d from the waitlist of session %d", *promoted, booking.SessionID)
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func MarkClassAttendance(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.ClassAttendance_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	session, startsAt := classSessionOrAbort(ctx, id)
+	if session == nil {
+		return
+	}
+
+	if session.Cancelled {
+		ctx.String(http.StatusConflict, "Class session is cancelled")
+		return
+	}
+
+	if time.Now().Before(startsAt) {
+		ctx.String(http.StatusConflict, "Class session hasn't started yet")
+		return
+	}
+
+	queryErr := db.MarkClassAttendance(db.DB, id, data.Attended)
+	if queryErr != nil {
+		common.Logger.Printf("failed to mark class attendance: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Query parameters 'from' and 'to' default to the last 30 days
+func GetClassNoShowStats(ctx *gin.Context) {
+	now := time.Now()
+
+	from := ctx.DefaultQuery("from", now.AddDate(0, 0, -30).Format(common.DateLayout))
+	to := ctx.DefaultQuery("to", now.Format(common.DateTimeLayout))
+
+	if len(to) == len(common.DateLayout) {
+		to += " 23:59:59"
+	}
+
+	stats, queryErr := db.GetNoShowStats(db.DB, from, to)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get no-show stats: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, stats)
+}
@@ -10,6 +10,35 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// Drops the comments on subscribers that are not assigned to the logged-in trainer
+func scopeCommentsOrAbort(ctx *gin.Context, comments []db.SubscriberComment) ([]db.SubscriberComment, bool) {
+	trainer, ok := scopedTrainerOrAbort(ctx)
+	if !ok {
+		return nil, false
+	}
+
+	if trainer == nil {
+		return comments, true
+	}
+
+	clients, queryErr := db.GetTrainerClientIDs(db.DB, trainer.ID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer client IDs: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	scoped := []db.SubscriberComment{}
+
+	for _, comment := range comments {
+		if clients[comment.SubscriberID] {
+			scoped = append(scoped, comment)
+		}
+	}
+
+	return scoped, true
+}
+
 func GetAllComments(ctx *gin.Context) {
 	comments, queryErr := db.GetAllComments(db.DB, 0, 0)
 	if queryErr != nil {
@@ -18,6 +47,11 @@ func GetAllComments(ctx *gin.Context) {
 		return
 	}
 
+	comments, ok := scopeCommentsOrAbort(ctx, comments)
+	if !ok {
+		return
+	}
+
 	ctx.JSON(http.StatusOK, comments)
 }
 
@@ -42,6 +76,11 @@ func GetAllCommentsOfManager(ctx *gin.Context) {
 		return
 	}
 
+	comments, ok := scopeCommentsOrAbort(ctx, comments)
+	if !ok {
+		return
+	}
+
 	ctx.JSON(http.StatusOK, comments)
 }
 
@@ -59,6 +98,10 @@ func GetAllCommentsOfSubscriber(ctx *gin.Context) {
 		return
 	}
 
+	if !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
 	comments, queryErr := db.GetAllCommentsOfSubscriberID(db.DB, id, 0, 0)
 	if queryErr != nil {
 		common.Logger.Printf("Failed to get comments: %v\n", queryErr)
@@ -78,6 +121,10 @@ func CreateComment(ctx *gin.Context) {
 		return
 	}
 
+	if !canAccessSubscriberOrAbort(ctx, data.SubscriberID) {
+		return
+	}
+
 	id, queryErr := db.CreateComment(db.DB, db.SubscriberComment{
 		Text:         data.Text,
 		SenderID:     data.SenderID,
@@ -106,7 +153,23 @@ func DeleteComment(ctx *gin.Context) {
 		return
 	}
 
-	queryErr := db.DeleteCommentByID(db.DB, id)
+	comment, queryErr := db.GetCommentByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("Failed to get comment: %v\n", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if comment == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, comment.SubscriberID) {
+		return
+	}
+
+	queryErr = db.DeleteCommentByID(db.DB, id)
 	if queryErr != nil {
 		common.Logger.Printf("Failed to delete comment: %v\n", queryErr)
 		ctx.AbortWithStatus(http.StatusInternalServerError)
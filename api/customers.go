@@ -1,13 +1,22 @@
 package api
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
+	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/HenryMarkle/gmserver/common"
 	"github.com/HenryMarkle/gmserver/db"
 	"github.com/HenryMarkle/gmserver/dto"
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
 )
 
 func GetTotalIncome(ctx *gin.Context) {
@@ -65,40 +74,83 @@ func CreateCustomer(ctx *gin.Context) {
 		return
 	}
 
-	queryErr := db.CreateSubscriber(db.DB, db.Subscriber{
-		Name:          data.Name,
-		Surname:       data.Surname,
-		StartedAt:     data.StartedAt,
-		EndsAt:        data.EndsAt,
-		Gender:        data.Gender,
-		Age:           data.Age,
-		PaymentAmount: data.PaymentAmount,
-		BucketPrice:   data.BucketPrice,
-	})
+	var redemption *db.PromoRedemption
+
+	if data.PromoCode != "" {
+		promo := usablePromoCodeOrAbort(ctx, data.PromoCode, "plans")
+		if promo == nil {
+			return
+		}
+
+		if len(promo.PlanIDs) > 0 && (data.PlanID == nil || !slices.Contains(promo.PlanIDs, *data.PlanID)) {
+			ctx.String(http.StatusBadRequest, "Promo code doesn't apply to this plan")
+			return
+		}
+
+		redemption = &db.PromoRedemption{
+			PromoCodeID:    promo.ID,
+			Target:         db.PromoTargetMembership,
+			OriginalAmount: data.BucketPrice,
+			DiscountAmount: promoDiscount(promo, data.BucketPrice),
+		}
+
+		data.BucketPrice -= redemption.DiscountAmount
+	}
+
+	var payment *db.Payment
+
+	if data.PaymentAmount > 0 {
+		payment = &db.Payment{
+			Source: db.PaymentMembership,
+			PlanID: data.PlanID,
+			Amount: data.PaymentAmount,
+			Method: paymentMethodOrDefault(data.PaymentMethod),
+		}
+
+		if userPtr, exists := ctx.Get("user"); exists {
+			payment.CreatedByID = &userPtr.(*db.User).ID
+		}
+	}
+
+	id, queryErr := db.CreateSubscriber(db.DB, db.Subscriber{
+		Name:                  data.Name,
+		Surname:               data.Surname,
+		StartedAt:             data.StartedAt,
+		EndsAt:                data.EndsAt,
+		Gender:                data.Gender,
+		Age:                   data.Age,
+		PaymentAmount:         data.PaymentAmount,
+		BucketPrice:           data.BucketPrice,
+		Phone:                 data.Phone,
+		Email:                 data.Email,
+		Address:               data.Address,
+		EmergencyContactName:  data.EmergencyContactName,
+		EmergencyContactPhone: data.EmergencyContactPhone,
+		DateOfBirth:           data.DateOfBirth,
+		Preferences:           db.CommunicationPreferences(data.Preferences),
+	}, payment, redemption)
 	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrPromoCodeExhausted) || errors.Is(queryErr, db.ErrPromoCodeMemberLimit) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
 		common.Logger.Printf("Failed to create customer: %v\n", queryErr)
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, id)
 }
 
 func GetAllCustomers(ctx *gin.Context) {
-	limitStr := ctx.Query("limit")
-
-	var limit int64
-	var convErr error
-
-	if limitStr != "" {
-		limit, convErr = strconv.ParseInt(limitStr, 10, 32)
-		if convErr != nil {
-			ctx.String(http.StatusBadRequest, "Invalid query parameter: limit")
-			return
-		}
+	filter, filterErr := parseSubscriberFilter(ctx)
+	if filterErr != nil {
+		ctx.String(http.StatusBadRequest, filterErr.Error())
+		return
 	}
 
-	subs, queryErr := db.GetAllSubscribers(db.DB, int(limit))
+	subs, queryErr := db.GetSubscribersFiltered(db.DB, filter)
 	if queryErr != nil {
 		common.Logger.Printf("Failed to get subscribers: %v\n", queryErr)
 		ctx.AbortWithStatus(http.StatusBadRequest)
@@ -123,6 +175,11 @@ func GetCustomerByID(ctx *gin.Context) {
 		return
 	}
 
+	if sub == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
 	ctx.JSON(http.StatusOK, sub)
 }
 
@@ -141,6 +198,8 @@ func DeleteCustomerByID(ctx *gin.Context) {
 		return
 	}
 
+	deleteSubscriberFiles(id)
+
 	ctx.Status(http.StatusOK)
 }
 
@@ -180,3 +239,458 @@ func UpdateCustomerByID(ctx *gin.Context) {
 
 	ctx.Status(http.StatusOK)
 }
+
+func UpdateCustomerPreferences(ctx *gin.Context) {
+	idStr := ctx.Params.ByName("id")
+	id, convErr := strconv.ParseInt(idStr, 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid paramter: id")
+		return
+	}
+
+	data := dto.CommunicationPreferences_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	queryErr := db.UpdateSubscriberPreferences(db.DB, id, db.CommunicationPreferences(data))
+	if queryErr != nil {
+		common.Logger.Printf("failed to update subscriber preferences: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func parseSubscriberFilter(ctx *gin.Context) (db.SubscriberFilter, error) {
+	filter := db.SubscriberFilter{
+		Search: ctx.Query("search"),
+		Gender: ctx.Query("gender"),
+		Status: ctx.Query("status"),
+	}
+
+	if filter.Status != "" && filter.Status != "active" && filter.Status != "expired" {
+		return filter, fmt.Errorf("Invalid query parameter: status (expected 'active' or 'expired')")
+	}
+
+	for _, bound := range []struct {
+		name string
+		dest *string
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		value := ctx.Query(bound.name)
+		if value == "" {
+			continue
+		}
+
+		normalized, parseErr := common.ParseSpreadsheetDate(value, false)
+		if parseErr != nil {
+			return filter, fmt.Errorf("Invalid query parameter: %s", bound.name)
+		}
+
+		*bound.dest = normalized
+	}
+
+	if limitStr := ctx.Query("limit"); limitStr != "" {
+		limit, convErr := strconv.ParseInt(limitStr, 10, 32)
+		if convErr != nil {
+			return filter, fmt.Errorf("Invalid query parameter: limit")
+		}
+
+		filter.Limit = int(limit)
+	}
+
+	return filter, nil
+}
+
+var subscriberSheetHeader = []string{"id", "name", "surname", "age", "gender", "startedAt", "endsAt", "paymentAmount", "bucketPrice", "createdAt",
+	"phone", "email", "address", "emergencyContactName", "emergencyContactPhone", "dateOfBirth"}
+
+// Maps normalized spreadsheet headers to CreateSubscriber_Req fields
+var subscriberImportColumns = map[string]string{
+	"name":                  "name",
+	"firstname":             "name",
+	"surname":               "surname",
+	"lastname":              "surname",
+	"age":                   "age",
+	"gender":                "gender",
+	"startedat":             "startedAt",
+	"startdate":             "startedAt",
+	"endsat":                "endsAt",
+	"enddate":               "endsAt",
+	"paymentamount":         "paymentAmount",
+	"payment":               "paymentAmount",
+	"bucketprice":           "bucketPrice",
+	"price":                 "bucketPrice",
+	"phone":                 "phone",
+	"email":                 "email",
+	"address":               "address",
+	"emergencycontactname":  "emergencyContactName",
+	"emergencycontact":      "emergencyContactName",
+	"emergencycontactphone": "emergencyContactPhone",
+	"dateofbirth":           "dateOfBirth",
+	"birthdate":             "dateOfBirth",
+}
+
+var phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+
+func normalizeSheetHeader(header string) string {
+	header = strings.ToLower(strings.TrimSpace(header))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(header)
+}
+
+// Converts a spreadsheet row to a request, reporting every problem with the row.
+// serialDates accepts Excel serial day numbers in date cells.
+func subscriberFromSheetRow(columns map[string]int, row []string, serialDates bool) (dto.CreateSubscriber_Req, []string) {
+	cell := func(field string) string {
+		index, found := columns[field]
+		if !found || index >= len(row) {
+			return ""
+		}
+
+		return strings.TrimSpace(row[index])
+	}
+
+	data := dto.ImportSubscriberRow_Req{
+		CreateSubscriber_Req: dto.CreateSubscriber_Req{
+			Email:                 cell("email"),
+			Address:               cell("address"),
+			EmergencyContactName:  cell("emergencyContactName"),
+			Phone:                 phoneSeparators.Replace(cell("phone")),
+			EmergencyContactPhone: phoneSeparators.Replace(cell("emergencyContactPhone")),
+		},
+		Name:    cell("name"),
+		Surname: cell("surname"),
+		Gender:  cell("gender"),
+	}
+
+	problems := []string{}
+
+	if age := cell("age"); age != "" {
+		parsed, convErr := strconv.ParseFloat(age, 64)
+		if convErr != nil {
+			problems = append(problems, "age: not a number")
+		} else {
+			data.Age = int(parsed)
+		}
+	}
+
+	for _, amount := range []struct {
+		field string
+		dest  *common.Money
+	}{{"paymentAmount", &data.PaymentAmount}, {"bucketPrice", &data.BucketPrice}} {
+		value := cell(amount.field)
+		if value == "" {
+			continue
+		}
+
+		parsed, convErr := common.ParseMoney(value)
+		if convErr != nil {
+			problems = append(problems, fmt.Sprintf("%s: not a number", amount.field))
+		} else {
+			*amount.dest = parsed
+		}
+	}
+
+	for _, date := range []struct {
+		field string
+		dest  *time.Time
+	}{{"startedAt", &data.StartedAt}, {"endsAt", &data.EndsAt}} {
+		value := cell(date.field)
+		if value == "" {
+			continue
+		}
+
+		normalized, parseErr := common.ParseSpreadsheetDate(value, serialDates)
+		if parseErr != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", date.field, parseErr))
+		} else {
+			*date.dest, _ = time.Parse(common.DateTimeLayout, normalized)
+		}
+	}
+
+	if dateOfBirth := cell("dateOfBirth"); dateOfBirth != "" {
+		normalized, parseErr := common.ParseSpreadsheetDate(dateOfBirth, serialDates)
+		if parseErr != nil {
+			problems = append(problems, fmt.Sprintf("dateOfBirth: %v", parseErr))
+		} else {
+			data.DateOfBirth = normalized[:len(common.DateLayout)]
+		}
+	}
+
+	if validateErr := binding.Validator.ValidateStruct(&data); validateErr != nil {
+		var fieldErrs validator.ValidationErrors
+		if errors.As(validateErr, &fieldErrs) {
+			for _, fe := range fieldErrs {
+				// A cell that didn't parse is already reported
+				if slices.ContainsFunc(problems, func(problem string) bool {
+					return strings.HasPrefix(strings.ToLower(problem), strings.ToLower(fe.Field())+":")
+				}) {
+					continue
+				}
+
+				problems = append(problems, fmt.Sprintf("%s: failed the '%s' rule", fe.Field(), fe.Tag()))
+			}
+		} else {
+			problems = append(problems, validateErr.Error())
+		}
+	}
+
+	subscriber := data.CreateSubscriber_Req
+	subscriber.Name = data.Name
+	subscriber.Surname = data.Surname
+	subscriber.Gender = data.Gender
+	subscriber.Age = data.Age
+	subscriber.PaymentAmount = data.PaymentAmount
+	subscriber.BucketPrice = data.BucketPrice
+
+	if !data.StartedAt.IsZero() {
+		subscriber.StartedAt = data.StartedAt.Format(common.DateTimeLayout)
+	}
+
+	if !data.EndsAt.IsZero() {
+		subscriber.EndsAt = data.EndsAt.Format(common.DateTimeLayout)
+	}
+
+	return subscriber, problems
+}
+
+// Imports subscribers from a CSV or XLSX file uploaded as 'file'.
+// Query parameters: 'dryRun' only validates, 'upsert' updates subscribers
+// matching the name and surname of a row instead of rejecting the row.
+func ImportCustomers(ctx *gin.Context) {
+	dryRun := ctx.Query("dryRun") == "true"
+	upsert := ctx.Query("upsert") == "true"
+
+	file, formErr := ctx.FormFile("file")
+	if formErr != nil {
+		ctx.String(http.StatusBadRequest, "Required file: 'file'.")
+		return
+	}
+
+	if file.Size > 10<<20 {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "File size is too large (max is 10 MB).")
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".csv" && ext != ".xlsx" {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "Only files with extensions '.csv' and '.xlsx' are allowed.")
+		return
+	}
+
+	opened, openErr := file.Open()
+	if openErr != nil {
+		common.Logger.Printf("failed to open uploaded subscribers file: %v", openErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	defer opened.Close()
+
+	var (
+		rows    [][]string
+		readErr error
+	)
+
+	if ext == ".csv" {
+		rows, readErr = common.ReadCSV(opened)
+	} else {
+		rows, readErr = common.ReadXLSX(opened, file.Size)
+	}
+
+	if readErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid file: %v", readErr)
+		return
+	}
+
+	if len(rows) == 0 {
+		ctx.String(http.StatusBadRequest, "Invalid file: missing header row")
+		return
+	}
+
+	columns := map[string]int{}
+	for i, header := range rows[0] {
+		if field, known := subscriberImportColumns[normalizeSheetHeader(header)]; known {
+			columns[field] = i
+		}
+	}
+
+	missing := []string{}
+	for _, required := range []string{"name", "surname", "gender", "startedAt", "endsAt"} {
+		if _, found := columns[required]; !found {
+			missing = append(missing, fmt.Sprintf("Missing column: %s", required))
+		}
+	}
+
+	if len(missing) > 0 {
+		ctx.JSON(http.StatusBadRequest, missing)
+		return
+	}
+
+	res := dto.ImportSubscribers_Res{DryRun: dryRun, Errors: []dto.ImportSubscribersRowError_Res{}}
+
+	created := []db.Subscriber{}
+	updated := []db.Subscriber{}
+	seen := map[string]int{}
+
+	for i, row := range rows[1:] {
+		rowNumber := i + 2
+
+		empty := true
+		for _, value := range row {
+			if strings.TrimSpace(value) != "" {
+				empty = false
+				break
+			}
+		}
+
+		if empty {
+			continue
+		}
+
+		res.Rows++
+
+		data, problems := subscriberFromSheetRow(columns, row, ext == ".xlsx")
+
+		key := strings.ToLower(data.Name + "\x00" + data.Surname)
+		if previous, duplicate := seen[key]; duplicate && len(problems) == 0 {
+			problems = append(problems, fmt.Sprintf("duplicate of row %d", previous))
+		}
+
+		var existingID *int64
+		if len(problems) == 0 {
+			var queryErr error
+			existingID, queryErr = db.FindSubscriberIDByNaturalKey(db.DB, data.Name, data.Surname)
+			if queryErr != nil {
+				common.Logger.Printf("failed to look up an imported subscriber: %v", queryErr)
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+
+			if existingID != nil && !upsert {
+				problems = append(problems, "a subscriber with the same name and surname already exists")
+			}
+		}
+
+		if len(problems) > 0 {
+			res.Failed++
+			res.Errors = append(res.Errors, dto.ImportSubscribersRowError_Res{Row: rowNumber, Errors: problems})
+			continue
+		}
+
+		seen[key] = rowNumber
+
+		sub := db.Subscriber{
+			Name:                  data.Name,
+			Surname:               data.Surname,
+			StartedAt:             data.StartedAt,
+			EndsAt:                data.EndsAt,
+			Gender:                data.Gender,
+			Age:                   data.Age,
+			PaymentAmount:         data.PaymentAmount,
+			BucketPrice:           data.BucketPrice,
+			Phone:                 data.Phone,
+			Email:                 data.Email,
+			Address:               data.Address,
+			EmergencyContactName:  data.EmergencyContactName,
+			EmergencyContactPhone: data.EmergencyContactPhone,
+			DateOfBirth:           data.DateOfBirth,
+		}
+
+		if existingID != nil {
+			sub.ID = int(*existingID)
+			updated = append(updated, sub)
+		} else {
+			created = append(created, sub)
+		}
+	}
+
+	res.Created = len(created)
+	res.Updated = len(updated)
+
+	if !dryRun && (len(created) > 0 || len(updated) > 0) {
+		queryErr := db.ImportSubscribers(db.DB, created, updated)
+		if queryErr != nil {
+			common.Logger.Printf("failed to import subscribers: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+// Exports the subscribers matching the same filters as GetAllCustomers.
+// Query parameter 'format' is either 'csv' (default) or 'xlsx'.
+func ExportCustomers(ctx *gin.Context) {
+	format := ctx.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: format (expected 'csv' or 'xlsx')")
+		return
+	}
+
+	filter, filterErr := parseSubscriberFilter(ctx)
+	if filterErr != nil {
+		ctx.String(http.StatusBadRequest, filterErr.Error())
+		return
+	}
+
+	subs, queryErr := db.GetSubscribersFiltered(db.DB, filter)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscribers for export: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([][]string, 0, len(subs)+1)
+	rows = append(rows, subscriberSheetHeader)
+
+	for _, sub := range subs {
+		rows = append(rows, []string{
+			strconv.Itoa(sub.ID),
+			sub.Name,
+			sub.Surname,
+			strconv.Itoa(sub.Age),
+			sub.Gender,
+			sub.StartedAt,
+			sub.EndsAt,
+			sub.PaymentAmount.String(),
+			sub.BucketPrice.String(),
+			sub.CreatedAt,
+			sub.Phone,
+			sub.Email,
+			sub.Address,
+			sub.EmergencyContactName,
+			sub.EmergencyContactPhone,
+			sub.DateOfBirth,
+		})
+	}
+
+	buffer := bytes.Buffer{}
+
+	var (
+		writeErr    error
+		contentType string
+	)
+
+	if format == "csv" {
+		writeErr = common.WriteCSV(&buffer, rows)
+		contentType = "text/csv"
+	} else {
+		writeErr = common.WriteXLSX(&buffer, "Subscribers", rows)
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+
+	if writeErr != nil {
+		common.Logger.Printf("failed to write subscribers export: %v", writeErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=subscribers.%s", format))
+	ctx.Data(http.StatusOK, contentType, buffer.Bytes())
+}
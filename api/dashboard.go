@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -222,6 +223,8 @@ func GetHomeProducts(ctx *gin.Context) {
 		return
 	}
 
+	attachProductDetails(products)
+
 	ctx.JSON(http.StatusOK, products)
 }
 
@@ -240,7 +243,15 @@ func GetProductByID(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, product)
+	if product == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	withDetails := []db.Product{*product}
+	attachProductDetails(withDetails)
+
+	ctx.JSON(http.StatusOK, withDetails[0])
 }
 
 func CreateHomeProduct(ctx *gin.Context) {
@@ -267,7 +278,76 @@ func CreateHomeProduct(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, id)
 }
-func DeleteHomeProduct(ctx *gin.Context) {}
+
+// Moves the listed products to the trash, all or none
+func DeleteHomeProduct(ctx *gin.Context) {
+	data := dto.ProductIDs_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	result, bulkErr := db.DeleteProducts(db.DB, data.ProductIDs)
+	respondBulk(ctx, "delete products", result, bulkErr)
+}
+
+// Brings the listed products back from the trash, all or none
+func RestoreHomeProducts(ctx *gin.Context) {
+	data := dto.ProductIDs_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	result, bulkErr := db.RestoreProducts(db.DB, data.ProductIDs)
+	respondBulk(ctx, "restore products", result, bulkErr)
+}
+
+func UpdateProductPrices(ctx *gin.Context) {
+	data := dto.UpdateProductPrices_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	change := db.PriceChange{Mode: data.Mode, Percent: data.Percent, Amount: data.Amount}
+
+	result, bulkErr := db.UpdateProductPrices(db.DB, data.ProductIDs, change)
+	respondBulk(ctx, "update product prices", result, bulkErr)
+}
+
+// Responds with the per-item report of a bulk change; 409 when an item failed and nothing was changed
+func respondBulk(ctx *gin.Context, action string, result *db.BulkResult, bulkErr error) {
+	if bulkErr != nil {
+		if errors.Is(bulkErr, db.ErrCategoryNotFound) {
+			ctx.String(http.StatusNotFound, "Category not found")
+			return
+		}
+
+		if errors.Is(bulkErr, db.ErrBulkEmpty) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", bulkErr)
+			return
+		}
+
+		common.Logger.Printf("Failed to %s: %v\n", action, bulkErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !result.Applied {
+		ctx.JSON(http.StatusConflict, result)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, result)
+}
+
 func UpdateHomeProduct(ctx *gin.Context) {
 	data := dto.UpdateProduct_Req{}
 
@@ -329,6 +409,10 @@ func GetCategoryProducts(ctx *gin.Context) {
 		return
 	}
 
+	for _, category := range catProd {
+		attachProductDetails(category.Products)
+	}
+
 	ctx.JSON(http.StatusOK, catProd)
 }
 
@@ -369,7 +453,15 @@ func GetProductsOfCategory(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, products)
+	if products == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	withDetails := []db.Product{*products}
+	attachProductDetails(withDetails)
+
+	ctx.JSON(http.StatusOK, withDetails[0])
 }
 
 func CreateProductCategory(ctx *gin.Context) {
@@ -432,6 +524,79 @@ func DeleteProductCategoryByName(ctx *gin.Context) {
 }
 
 func MoveProductToCategory(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.MoveProduct_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	result, bulkErr := db.MoveProductsToCategory(db.DB, []int64{id}, data.CategoryID)
+	if bulkErr == nil && !result.Applied {
+		reason := result.Items[0].Error
+
+		if reason == db.ErrProductNotFound.Error() {
+			ctx.String(http.StatusNotFound, "Product not found")
+			return
+		}
+
+		ctx.String(http.StatusConflict, "Conflict: %s", reason)
+		return
+	}
+
+	respondBulk(ctx, "move a product to a category", result, bulkErr)
+}
+
+// Moves the listed products to one category, all or none
+func MoveProductsToCategory(ctx *gin.Context) {
+	data := dto.MoveProducts_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	result, bulkErr := db.MoveProductsToCategory(db.DB, data.ProductIDs, data.CategoryID)
+	respondBulk(ctx, "move products to a category", result, bulkErr)
+}
+
+func RenameProductCategories(ctx *gin.Context) {
+	data := dto.RenameProductCategories_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	categories := make([]db.ProductCategory, 0, len(data.Categories))
+	for _, category := range data.Categories {
+		categories = append(categories, db.ProductCategory{ID: category.ID, Name: category.Name})
+	}
+
+	result, bulkErr := db.RenameProductCategories(db.DB, categories)
+	respondBulk(ctx, "rename product categories", result, bulkErr)
+}
+
+func ReorderProductCategories(ctx *gin.Context) {
+	data := dto.ReorderProductCategories_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	result, bulkErr := db.ReorderProductCategories(db.DB, data.CategoryIDs)
+	respondBulk(ctx, "reorder product categories", result, bulkErr)
 }
 
 func DeleteProductsOfCategory(ctx *gin.Context) {
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+func paymentMethodOrDefault(method string) string {
+	if method == "" {
+		return "cash"
+	}
+
+	return method
+}
+
+// Query parameters 'from' and 'to' are inclusive dates, defaulting to the current month so far.
+// Responds with 400 and returns false if they're invalid.
+func dateRangeOrAbort(ctx *gin.Context) (string, string, bool) {
+	now := time.Now()
+
+	from := ctx.DefaultQuery("from", time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local).Format(common.DateLayout))
+	to := ctx.DefaultQuery("to", now.Format(common.DateLayout))
+
+	for name, value := range map[string]string{"from": from, "to": to} {
+		if _, parseErr := time.Parse(common.DateLayout, value); parseErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid query parameter: %s (expected format %s)", name, common.DateLayout)
+			return "", "", false
+		}
+	}
+
+	if to < from {
+		ctx.String(http.StatusBadRequest, "'to' is before 'from'")
+		return "", "", false
+	}
+
+	return from, to, true
+}
+
+// Query parameter 'subscriberId' optionally limits the payments to a subscriber
+func GetPayments(ctx *gin.Context) {
+	from, to, ok := dateRangeOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	subscriberID, _ := strconv.ParseInt(ctx.Query("subscriberId"), 10, 64)
+
+	payments, queryErr := db.GetPayments(db.DB, from, to, subscriberID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get payments: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, payments)
+}
+
+func CreatePayment(ctx *gin.Context) {
+	data := dto.Payment_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if data.SubscriberID != nil && !subscriberExistsOrAbort(ctx, *data.SubscriberID) {
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, queryErr := db.CreatePayment(db.DB, db.Payment{
+		Source:       data.Source,
+		SubscriberID: data.SubscriberID,
+		PlanID:       data.PlanID,
+		Amount:       data.Amount,
+		Method:       paymentMethodOrDefault(data.Method),
+		PaidAt:       data.PaidAt,
+		Notes:        data.Notes,
+		CreatedByID:  &userPtr.(*db.User).ID,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a payment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func DeletePayment(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeletePaymentByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a payment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Query parameter 'category' optionally limits the expenses to a category
+func GetExpenses(ctx *gin.Context) {
+	from, to, ok := dateRangeOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	expenses, queryErr := db.GetExpenses(db.DB, from, to, ctx.Query("category"))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get expenses: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, expenses)
+}
+
+func CreateExpense(ctx *gin.Context) {
+	data := dto.Expense_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, queryErr := db.CreateExpense(db.DB, db.Expense{
+		Category:    data.Category,
+		Amount:      data.Amount,
+		Description: data.Description,
+		SpentAt:     data.SpentAt,
+		CreatedByID: &userPtr.(*db.User).ID,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to create an expense: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func UpdateExpense(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.Expense_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	existing, queryErr := db.GetExpenseByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get an expense: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	updateErr := db.UpdateExpense(db.DB, db.Expense{
+		ID:          id,
+		Category:    data.Category,
+		Amount:      data.Amount,
+		Description: data.Description,
+		SpentAt:     data.SpentAt,
+	})
+	if updateErr != nil {
+		common.Logger.Printf("failed to update an expense: %v", updateErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeleteExpense(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeleteExpenseByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete an expense: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Keeps concurrent sales from alerting of the same product twice
+var lowStockAlertMutex sync.Mutex
+
+// Announces products that fell to their low stock threshold to admins, once until they're restocked.
+// Meant to run in its own goroutine after stock goes out.
+func sendLowStockAlerts() {
+	lowStockAlertMutex.Lock()
+	defer lowStockAlertMutex.Unlock()
+
+	products, queryErr := db.GetProductsDueForLowStockAlert(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get products due for low stock alert: %v", queryErr)
+		return
+	}
+
+	if len(products) == 0 {
+		return
+	}
+
+	admins, queryErr := db.GetAdminUserIDs(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get admin user IDs: %v", queryErr)
+		return
+	}
+
+	if len(admins) == 0 {
+		return
+	}
+
+	for _, product := range products {
+		text := fmt.Sprintf("%s is running low: %d left in stock", product.Name, product.Stock)
+		if product.Stock == 0 {
+			text = fmt.Sprintf("%s is out of stock", product.Name)
+		}
+
+		if _, annErr := db.CreateAnnouncementToUserIDs(db.DB, text, admins...); annErr != nil {
+			common.Logger.Printf("failed to announce low stock (product: %d): %v", product.ID, annErr)
+			continue
+		}
+
+		if markErr := db.MarkLowStockAlertSent(db.DB, product.ID); markErr != nil {
+			common.Logger.Printf("%v", markErr)
+		}
+	}
+}
+
+func GetLowStockProducts(ctx *gin.Context) {
+	products, queryErr := db.GetLowStockProducts(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get low stock products: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, products)
+}
+
+func GetStockMovements(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	movements, queryErr := db.GetStockMovements(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get stock movements: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, movements)
+}
+
+// Responds with the recorded movement, including the resulting stock
+func CreateStockMovement(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.StockMovement_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	quantity := data.Quantity
+
+	switch data.Kind {
+	case db.StockAdjustment:
+		if data.Reason == "" {
+			ctx.String(http.StatusBadRequest, "Invalid data: adjustments need a reason")
+			return
+		}
+	case db.StockSale:
+		if quantity < 0 {
+			ctx.String(http.StatusBadRequest, "Invalid data: quantity must be positive")
+			return
+		}
+
+		quantity = -quantity
+	default:
+		if quantity < 0 {
+			ctx.String(http.StatusBadRequest, "Invalid data: quantity must be positive")
+			return
+		}
+	}
+
+	movement, queryErr := db.CreateStockMovement(db.DB, db.StockMovement{
+		ProductID:   id,
+		VariantID:   data.VariantID,
+		Kind:        data.Kind,
+		Quantity:    quantity,
+		Reason:      data.Reason,
+		CreatedByID: &userPtr.(*db.User).ID,
+	})
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrProductNotFound) {
+			ctx.String(http.StatusNotFound, "Product not found")
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrVariantNotFound) {
+			ctx.String(http.StatusNotFound, "Variant not found")
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrVariantRequired) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", queryErr)
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrInsufficientStock) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to create a stock movement: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if quantity < 0 {
+		go sendLowStockAlerts()
+	}
+
+	ctx.JSON(http.StatusOK, movement)
+}
+
+func SetLowStockThreshold(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.LowStockThreshold_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	queryErr := db.SetProductLowStockThreshold(db.DB, id, data.Threshold)
+	if queryErr != nil {
+		common.Logger.Printf("failed to set low stock threshold: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	go sendLowStockAlerts()
+
+	ctx.Status(http.StatusOK)
+}
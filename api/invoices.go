@@ -0,0 +1,614 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// The gym's name and contacts from the landing page, snapshotted on every invoice
+func invoiceSeller() (string, string) {
+	info, queryErr := db.GetLandingPageInfo(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get landing page info for an invoice: %v", queryErr)
+		return "", ""
+	}
+
+	details := []string{}
+
+	for _, contact := range []struct{ label, value string }{
+		{"Email", info.EmailContact},
+		{"WhatsApp", info.WhatsappContact},
+		{"Instagram", info.InstigramContact},
+		{"Facebook", info.FacebookContact},
+		{"Twitter", info.TwitterContact},
+	} {
+		if contact.value != "" {
+			details = append(details, fmt.Sprintf("%s: %s", contact.label, contact.value))
+		}
+	}
+
+	return info.Title, strings.Join(details, "\n")
+}
+
+func subscriberBillingDetails(sub *db.Subscriber) string {
+	details := []string{}
+
+	for _, value := range []string{sub.Address, sub.Phone, sub.Email} {
+		if value != "" {
+			details = append(details, value)
+		}
+	}
+
+	return strings.Join(details, "\n")
+}
+
+// Computes the line amounts and the invoice totals
+func totalInvoice(invoice *db.Invoice) {
+	invoice.Subtotal, invoice.TaxTotal = 0, 0
+
+	for i := range invoice.Lines {
+		line := &invoice.Lines[i]
+
+		line.Amount = line.UnitPrice.Mul(line.Quantity)
+		line.TaxAmount = line.Amount.Percent(line.TaxRate)
+
+		invoice.Subtotal += line.Amount
+		invoice.TaxTotal += line.TaxAmount
+	}
+
+	invoice.Total = invoice.Subtotal + invoice.TaxTotal
+}
+
+// Fails with common.ErrPDFUnprintable if any text of the invoice would be misprinted, as issued invoices can't change
+func checkInvoiceText(invoice *db.Invoice) error {
+	texts := []string{invoice.SellerName, invoice.SellerDetails, invoice.BilledTo, invoice.BilledToDetails, invoice.Notes, common.InvoiceFooter,
+		common.FormatMoneyIn(invoice.Total, invoice.Currency)}
+
+	for _, line := range invoice.Lines {
+		texts = append(texts, line.Description)
+	}
+
+	for _, text := range texts {
+		if checkErr := common.PDFCheckText(text); checkErr != nil {
+			return checkErr
+		}
+	}
+
+	return nil
+}
+
+// Fills in the issue date, the seller and the currency, then issues the invoice.
+// Responds with the created ID, with 400 if its text can't be printed,
+// or with 409 if the payment or the credited invoice doesn't allow it.
+func issueInvoice(ctx *gin.Context, invoice db.Invoice) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+
+	invoice.Year = now.Year()
+	invoice.IssuedAt = now.Format(common.DateTimeLayout)
+	invoice.Currency = common.Currency
+	invoice.SellerName, invoice.SellerDetails = invoiceSeller()
+	invoice.CreatedByID = &userPtr.(*db.User).ID
+
+	if checkErr := checkInvoiceText(&invoice); checkErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", checkErr)
+		return
+	}
+
+	id, queryErr := db.CreateInvoice(db.DB, invoice)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrInvoiceNotIssued) || errors.Is(queryErr, db.ErrPaymentInvoiced) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to create an invoice: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+// Query parameter 'subscriberId' optionally limits the invoices to a subscriber
+func GetInvoices(ctx *gin.Context) {
+	from, to, ok := dateRangeOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	subscriberID, _ := strconv.ParseInt(ctx.Query("subscriberId"), 10, 64)
+
+	invoices, queryErr := db.GetInvoices(db.DB, from, to, subscriberID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get invoices: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invoices)
+}
+
+func GetInvoice(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	invoice, queryErr := db.GetInvoiceByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get an invoice: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if invoice == nil {
+		ctx.String(http.StatusNotFound, "Invoice not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, invoice)
+}
+
+func CreateInvoice(ctx *gin.Context) {
+	data := dto.Invoice_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	invoice := db.Invoice{
+		Series:          "INV",
+		SubscriberID:    data.SubscriberID,
+		BilledTo:        data.BilledTo,
+		BilledToDetails: data.BilledToDetails,
+		PaymentMethod:   data.PaymentMethod,
+		Notes:           data.Notes,
+	}
+
+	if data.SubscriberID != nil {
+		sub, queryErr := db.GetSubscriberByID(db.DB, *data.SubscriberID)
+		if queryErr != nil {
+			common.Logger.Printf("failed to get subscriber by ID: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if sub == nil {
+			ctx.String(http.StatusNotFound, "Subscriber not found")
+			return
+		}
+
+		if invoice.BilledTo == "" {
+			invoice.BilledTo = strings.TrimSpace(sub.Name + " " + sub.Surname)
+		}
+
+		if invoice.BilledToDetails == "" {
+			invoice.BilledToDetails = subscriberBillingDetails(sub)
+		}
+	}
+
+	if invoice.BilledTo == "" {
+		ctx.String(http.StatusBadRequest, "Invalid data: billedTo or subscriberId is required")
+		return
+	}
+
+	if data.PaymentMethod != "" {
+		invoice.PaidAt = data.PaidAt
+		if invoice.PaidAt == "" {
+			invoice.PaidAt = time.Now().Format(common.DateTimeLayout)
+		}
+	}
+
+	for _, line := range data.Lines {
+		taxRate := common.InvoiceTaxRate
+		if line.TaxRate != nil {
+			taxRate = *line.TaxRate
+		}
+
+		invoice.Lines = append(invoice.Lines, db.InvoiceLine{
+			Description: line.Description,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			TaxRate:     taxRate,
+		})
+	}
+
+	totalInvoice(&invoice)
+	issueInvoice(ctx, invoice)
+}
+
+// Issues a paid invoice (a receipt) for a payment of the ledger.
+// Payments are tax-inclusive, so the line is priced so that its total matches the amount paid.
+func CreatePaymentInvoice(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	payment, queryErr := db.GetPaymentByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a payment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if payment == nil {
+		ctx.String(http.StatusNotFound, "Payment not found")
+		return
+	}
+
+	invoice := db.Invoice{
+		Series:        "INV",
+		SubscriberID:  payment.SubscriberID,
+		PaymentID:     &payment.ID,
+		PaymentMethod: payment.Method,
+		PaidAt:        payment.PaidAt,
+		BilledTo:      "Customer",
+	}
+
+	if payment.SubscriberID != nil {
+		sub, queryErr := db.GetSubscriberByIDWithDeleted(db.DB, int(*payment.SubscriberID))
+		if queryErr != nil {
+			common.Logger.Printf("failed to get subscriber by ID: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if sub != nil {
+			invoice.BilledTo = strings.TrimSpace(sub.Name + " " + sub.Surname)
+			invoice.BilledToDetails = subscriberBillingDetails(sub)
+		}
+	}
+
+	description := map[string]string{
+		"membership": "Membership",
+		"product":    "Products",
+		"pt":         "Personal training",
+	}[payment.Source]
+
+	if description == "" {
+		description = "Payment"
+	}
+
+	if payment.Notes != "" {
+		description += " - " + payment.Notes
+	}
+
+	taxRate := common.InvoiceTaxRate
+
+	// The tax is whatever remains of the amount after the net price is rounded
+	net := payment.Amount.Mul(1 / (1 + taxRate/100))
+
+	invoice.Lines = []db.InvoiceLine{{Description: description, Quantity: 1, UnitPrice: net, TaxRate: taxRate}}
+
+	totalInvoice(&invoice)
+
+	invoice.Lines[0].TaxAmount = payment.Amount - net
+	invoice.TaxTotal = invoice.Lines[0].TaxAmount
+	invoice.Total = payment.Amount
+
+	issueInvoice(ctx, invoice)
+}
+
+func VoidInvoice(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.VoidInvoice_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if checkErr := common.PDFCheckText(data.Reason); checkErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", checkErr)
+		return
+	}
+
+	queryErr := db.VoidInvoice(db.DB, id, data.Reason)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrInvoiceNotIssued) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to void an invoice: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Issues a credit note reversing the whole invoice
+func CreateCreditNote(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.CreditNote_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	original, queryErr := db.GetInvoiceByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get an invoice: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if original == nil {
+		ctx.String(http.StatusNotFound, "Invoice not found")
+		return
+	}
+
+	if original.Series != "INV" {
+		ctx.String(http.StatusBadRequest, "Only invoices can be credited")
+		return
+	}
+
+	note := db.Invoice{
+		Series:            "CN",
+		SubscriberID:      original.SubscriberID,
+		CreditedInvoiceID: &original.ID,
+		BilledTo:          original.BilledTo,
+		BilledToDetails:   original.BilledToDetails,
+		PaymentMethod:     original.PaymentMethod,
+		Notes:             data.Reason,
+	}
+
+	if note.PaymentMethod != "" {
+		note.PaidAt = time.Now().Format(common.DateTimeLayout)
+	}
+
+	for _, line := range original.Lines {
+		note.Lines = append(note.Lines, db.InvoiceLine{
+			Description: line.Description,
+			Quantity:    -line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			TaxRate:     line.TaxRate,
+		})
+	}
+
+	totalInvoice(&note)
+	issueInvoice(ctx, note)
+}
+
+// Lays out an invoice or a credit note on A4 pages.
+// creditedNumber is the number of the invoice a credit note reverses.
+func renderInvoicePDF(invoice *db.Invoice, creditedNumber string) ([]byte, error) {
+	const (
+		left      = 50.0
+		right     = common.PDFPageWidth - 50
+		bottom    = common.PDFPageHeight - 70
+		rowHeight = 16.0
+	)
+
+	pdf := common.NewPDF()
+	pdf.Footer = common.InvoiceFooter
+
+	// In the currency the invoice was issued in
+	formatAmount := func(amount common.Money) string {
+		return common.FormatMoneyIn(amount, invoice.Currency)
+	}
+
+	y := 50.0
+
+	if common.InvoiceLogoPath != "" {
+		file, openErr := os.Open(common.InvoiceLogoPath)
+		if openErr != nil {
+			common.Logger.Printf("failed to open invoice logo: %v", openErr)
+		} else {
+			logo, _, decodeErr := image.Decode(file)
+			file.Close()
+
+			if decodeErr != nil {
+				common.Logger.Printf("failed to decode invoice logo: %v", decodeErr)
+			} else if drawErr := pdf.Image(logo, left, y, 150, 60); drawErr != nil {
+				common.Logger.Printf("failed to draw invoice logo: %v", drawErr)
+			} else {
+				y += 75
+			}
+		}
+	}
+
+	title := "INVOICE"
+	if invoice.Series == "CN" {
+		title = "CREDIT NOTE"
+	}
+
+	pdf.TextRight(right, 65, 20, true, title)
+	pdf.TextRight(right, 85, 10, false, invoice.Number)
+	pdf.TextRight(right, 100, 10, false, "Date: "+invoice.IssuedAt[:min(len(invoice.IssuedAt), 10)])
+
+	switch invoice.Status {
+	case "void":
+		pdf.TextRight(right, 122, 16, true, "VOID")
+	case "credited":
+		pdf.TextRight(right, 122, 12, true, "CREDITED")
+	}
+
+	if invoice.SellerName != "" {
+		y += 12
+		pdf.Text(left, y, 12, true, invoice.SellerName)
+	}
+
+	for _, detail := range strings.Split(invoice.SellerDetails, "\n") {
+		if detail != "" {
+			y += 12
+			pdf.Text(left, y, 9, false, detail)
+		}
+	}
+
+	y = max(y, 130) + 30
+
+	pdf.Text(left, y, 10, true, "Bill to")
+	y += 14
+	pdf.Text(left, y, 10, false, invoice.BilledTo)
+
+	for _, detail := range strings.Split(invoice.BilledToDetails, "\n") {
+		if detail != "" {
+			y += 12
+			pdf.Text(left, y, 9, false, detail)
+		}
+	}
+
+	header := func() {
+		pdf.Text(left, y, 9, true, "Description")
+		pdf.TextRight(340, y, 9, true, "Qty")
+		pdf.TextRight(420, y, 9, true, "Unit price")
+		pdf.TextRight(470, y, 9, true, "Tax %")
+		pdf.TextRight(right, y, 9, true, "Amount")
+		pdf.Line(left, y+5, right, y+5, 0.5)
+		y += rowHeight + 4
+	}
+
+	y += 30
+	header()
+
+	for _, line := range invoice.Lines {
+		if y > bottom {
+			pdf.AddPage()
+			y = 50
+			header()
+		}
+
+		pdf.Text(left, y, 9, false, common.PDFTruncate(line.Description, 240, 9, false))
+		pdf.TextRight(340, y, 9, false, strconv.FormatFloat(line.Quantity, 'f', -1, 64))
+		pdf.TextRight(420, y, 9, false, formatAmount(line.UnitPrice))
+		pdf.TextRight(470, y, 9, false, strconv.FormatFloat(line.TaxRate, 'f', -1, 64))
+		pdf.TextRight(right, y, 9, false, formatAmount(line.Amount))
+		y += rowHeight
+	}
+
+	// Totals and the payment summary take about 120 points
+	if y+120 > bottom {
+		pdf.AddPage()
+		y = 50
+	}
+
+	pdf.Line(left, y-rowHeight+5, right, y-rowHeight+5, 0.5)
+	y += 6
+
+	for _, total := range []struct {
+		label  string
+		amount common.Money
+		bold   bool
+	}{
+		{"Subtotal", invoice.Subtotal, false},
+		{"Tax", invoice.TaxTotal, false},
+		{"Total", invoice.Total, true},
+	} {
+		pdf.TextRight(420, y, 10, total.bold, total.label)
+		pdf.TextRight(right, y, 10, total.bold, formatAmount(total.amount))
+		y += rowHeight
+	}
+
+	y += 20
+
+	switch {
+	case invoice.Series == "CN" && invoice.PaymentMethod != "":
+		pdf.Text(left, y, 10, false, fmt.Sprintf("Refunded by %s", invoice.PaymentMethod))
+	case invoice.Series == "CN":
+		pdf.Text(left, y, 10, false, "Deducted from the amount due")
+	case invoice.PaymentMethod != "":
+		pdf.Text(left, y, 10, false, fmt.Sprintf("Paid by %s on %s", invoice.PaymentMethod, invoice.PaidAt[:min(len(invoice.PaidAt), 10)]))
+	default:
+		pdf.Text(left, y, 10, false, "Payment due")
+	}
+
+	if creditedNumber != "" {
+		y += 14
+		pdf.Text(left, y, 10, false, "Credits invoice "+creditedNumber)
+	}
+
+	if invoice.Notes != "" {
+		y += 14
+		pdf.Text(left, y, 9, false, common.PDFTruncate(invoice.Notes, right-left, 9, false))
+	}
+
+	if invoice.Status == "void" && invoice.VoidReason != "" {
+		y += 14
+		pdf.Text(left, y, 9, false, common.PDFTruncate("Void: "+invoice.VoidReason, right-left, 9, false))
+	}
+
+	return pdf.Bytes()
+}
+
+// Renders the invoice on every download from its stored snapshot
+func GetInvoicePDF(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	invoice, queryErr := db.GetInvoiceByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get an invoice: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if invoice == nil {
+		ctx.String(http.StatusNotFound, "Invoice not found")
+		return
+	}
+
+	creditedNumber := ""
+
+	if invoice.CreditedInvoiceID != nil {
+		credited, queryErr := db.GetInvoiceByID(db.DB, *invoice.CreditedInvoiceID)
+		if queryErr != nil {
+			common.Logger.Printf("failed to get a credited invoice: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if credited != nil {
+			creditedNumber = credited.Number
+		}
+	}
+
+	document, renderErr := renderInvoicePDF(invoice, creditedNumber)
+	if renderErr != nil {
+		common.Logger.Printf("failed to render an invoice: %v", renderErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", invoice.Number))
+	ctx.Data(http.StatusOK, "application/pdf", document)
+}
@@ -0,0 +1,286 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow(10, float64(places))
+	return math.Round(value*factor) / factor
+}
+
+// Every value present in a measurement keyed by its chart series name
+func measurementValues(m *db.SubscriberMeasurement) map[string]float64 {
+	values := map[string]float64{}
+
+	standard := []struct {
+		name  string
+		value *float64
+	}{
+		{"weight", m.Weight},
+		{"height", m.Height},
+		{"bodyFat", m.BodyFat},
+		{"chest", m.Chest},
+		{"waist", m.Waist},
+		{"hips", m.Hips},
+		{"arm", m.Arm},
+		{"thigh", m.Thigh},
+		{"bmi", m.BMI},
+	}
+
+	for _, field := range standard {
+		if field.value != nil {
+			values[field.name] = *field.value
+		}
+	}
+
+	for _, metric := range m.Metrics {
+		values[metric.Name] = metric.Value
+	}
+
+	return values
+}
+
+// Fills in BMI and the change from the previous measurement.
+// Measurements must be ordered from oldest to newest; when a measurement
+// has no height, the last recorded one is used for BMI.
+func computeMeasurementStats(measurements []db.SubscriberMeasurement) {
+	var (
+		lastHeight *float64
+		previous   map[string]float64
+	)
+
+	for i := range measurements {
+		m := &measurements[i]
+
+		if m.Height != nil {
+			lastHeight = m.Height
+		}
+
+		if m.Weight != nil && lastHeight != nil && *lastHeight > 0 {
+			meters := *lastHeight / 100
+			bmi := roundTo(*m.Weight/(meters*meters), 1)
+			m.BMI = &bmi
+		}
+
+		values := measurementValues(m)
+
+		m.Deltas = map[string]float64{}
+		for name, value := range values {
+			if before, found := previous[name]; found {
+				m.Deltas[name] = roundTo(value-before, 2)
+			}
+		}
+
+		previous = values
+	}
+}
+
+func measurementFromRequest(data *dto.SubscriberMeasurement_Req) db.SubscriberMeasurement {
+	metrics := make([]db.MeasurementMetric, 0, len(data.Metrics))
+	for _, metric := range data.Metrics {
+		metrics = append(metrics, db.MeasurementMetric(metric))
+	}
+
+	return db.SubscriberMeasurement{
+		MeasuredAt: data.MeasuredAt,
+		Weight:     data.Weight,
+		Height:     data.Height,
+		BodyFat:    data.BodyFat,
+		Chest:      data.Chest,
+		Waist:      data.Waist,
+		Hips:       data.Hips,
+		Arm:        data.Arm,
+		Thigh:      data.Thigh,
+		Notes:      data.Notes,
+		Metrics:    metrics,
+	}
+}
+
+// Query parameters 'from' and 'to' optionally bound the measurement date
+func GetSubscriberMeasurements(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	measurements, queryErr := db.GetSubscriberMeasurements(db.DB, id, ctx.Query("from"), ctx.Query("to"))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscriber measurements: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	computeMeasurementStats(measurements)
+
+	ctx.JSON(http.StatusOK, measurements)
+}
+
+func GetSubscriberMeasurementChart(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	measurements, queryErr := db.GetSubscriberMeasurements(db.DB, id, ctx.Query("from"), ctx.Query("to"))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscriber measurements: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	computeMeasurementStats(measurements)
+
+	res := dto.MeasurementChart_Res{
+		Series: map[string][]dto.ChartPoint_Res{},
+		Change: map[string]float64{},
+	}
+
+	for i := range measurements {
+		for name, value := range measurementValues(&measurements[i]) {
+			res.Series[name] = append(res.Series[name], dto.ChartPoint_Res{
+				Date:  measurements[i].MeasuredAt,
+				Value: value,
+			})
+		}
+	}
+
+	for name, points := range res.Series {
+		res.Change[name] = roundTo(points[len(points)-1].Value-points[0].Value, 2)
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func CreateSubscriberMeasurement(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.SubscriberMeasurement_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !subscriberExistsOrAbort(ctx, id) || !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	measurement := measurementFromRequest(&data)
+	measurement.SubscriberID = id
+	measurement.RecordedByID = userPtr.(*db.User).ID
+
+	measurementID, queryErr := db.CreateSubscriberMeasurement(db.DB, measurement)
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a measurement: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, measurementID)
+}
+
+func UpdateSubscriberMeasurement(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.SubscriberMeasurement_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	existing, queryErr := db.GetSubscriberMeasurementByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a measurement: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, existing.SubscriberID) {
+		return
+	}
+
+	measurement := measurementFromRequest(&data)
+	measurement.ID = id
+
+	updateErr := db.UpdateSubscriberMeasurement(db.DB, measurement)
+	if updateErr != nil {
+		common.Logger.Printf("failed to update a measurement: %v", updateErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeleteSubscriberMeasurement(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	existing, queryErr := db.GetSubscriberMeasurementByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a measurement: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, existing.SubscriberID) {
+		return
+	}
+
+	queryErr = db.DeleteSubscriberMeasurementByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a measurement: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
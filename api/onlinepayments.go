@@ -0,0 +1,328 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Webhook bodies are small; anything larger isn't from the provider
+const maxWebhookSize = 1 << 20
+
+// Responds with 503 and returns false if no payment provider is set up
+func onlinePaymentsOrAbort(ctx *gin.Context) bool {
+	if common.Payments == nil {
+		ctx.String(http.StatusServiceUnavailable, "Online payments are not set up")
+		return false
+	}
+
+	return true
+}
+
+// Records a pending intent and opens a checkout session for it with the payment provider
+func startOnlinePayment(ctx *gin.Context, intent db.PaymentIntent, description string) {
+	intent.Provider = common.Payments.Name()
+	intent.Currency = common.Currency
+	// Stored as charged, so the webhook's amount matches it
+	intent.Amount = common.RoundToCurrency(intent.Amount, intent.Currency)
+
+	if intent.Amount <= 0 {
+		ctx.String(http.StatusBadRequest, "Amount is too small to be charged in %s", intent.Currency)
+		return
+	}
+
+	id, queryErr := db.CreatePaymentIntent(db.DB, intent)
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a payment intent: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	session, providerErr := common.Payments.CreateCheckout(common.CheckoutRequest{
+		Reference:   strconv.FormatInt(id, 10),
+		Amount:      intent.Amount,
+		Currency:    intent.Currency,
+		Description: description,
+		SuccessURL:  common.PaymentSuccessURL,
+		CancelURL:   common.PaymentCancelURL,
+	})
+	if providerErr != nil {
+		common.Logger.Printf("failed to create a checkout session: %v", providerErr)
+
+		if settleErr := db.SettlePaymentIntent(db.DB, id, common.PaymentFailed, 0); settleErr != nil {
+			common.Logger.Printf("%v", settleErr)
+		}
+
+		ctx.String(http.StatusBadGateway, "Payment provider is unavailable")
+		return
+	}
+
+	queryErr = db.SetPaymentIntentSession(db.DB, id, *session)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.OnlinePayment_Res{IntentID: id, SessionID: session.ID, URL: session.URL})
+}
+
+// Opens a checkout for the subscriber's bucketPrice, to be paid by the subscriber online
+func CreateMembershipCheckout(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	if !onlinePaymentsOrAbort(ctx) {
+		return
+	}
+
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	subscriber, queryErr := db.GetSubscriberByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get subscriber by ID: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if subscriber == nil {
+		ctx.String(http.StatusNotFound, "Subscriber not found")
+		return
+	}
+
+	if subscriber.BucketPrice <= 0 {
+		ctx.String(http.StatusBadRequest, "Subscriber has nothing to pay")
+		return
+	}
+
+	startOnlinePayment(ctx, db.PaymentIntent{
+		Purpose:      db.IntentMembership,
+		SubscriberID: &id,
+		Amount:       subscriber.BucketPrice,
+		CreatedByID:  &userPtr.(*db.User).ID,
+	}, fmt.Sprintf("Membership of %s %s", subscriber.Name, subscriber.Surname))
+}
+
+// Opens a checkout for one of the user's pending orders
+func PayUserOrder(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	if !onlinePaymentsOrAbort(ctx) {
+		return
+	}
+
+	user := userPtr.(*db.User)
+
+	order := userOrderOrAbort(ctx, user)
+	if order == nil {
+		return
+	}
+
+	if order.Status != db.OrderPending {
+		ctx.String(http.StatusConflict, "Conflict: only pending orders can be paid")
+		return
+	}
+
+	if order.Total <= 0 {
+		ctx.String(http.StatusBadRequest, "Order has nothing to pay")
+		return
+	}
+
+	startOnlinePayment(ctx, db.PaymentIntent{
+		Purpose:     db.IntentOrder,
+		OrderID:     &order.ID,
+		Amount:      order.Total,
+		CreatedByID: &user.ID,
+	}, fmt.Sprintf("Order #%d", order.ID))
+}
+
+// Verifies a webhook and settles the payment it reports.
+// Events that can't be matched to a payment are acknowledged so the provider doesn't keep redelivering them.
+func settleWebhook(ctx *gin.Context, payload []byte, header http.Header) {
+	event, parseErr := common.Payments.ParseWebhook(payload, header)
+	if parseErr != nil {
+		if errors.Is(parseErr, common.ErrInvalidWebhook) {
+			ctx.String(http.StatusBadRequest, "Invalid signature")
+			return
+		}
+
+		common.Logger.Printf("failed to parse a payment webhook: %v", parseErr)
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", parseErr)
+		return
+	}
+
+	if event == nil {
+		ctx.Status(http.StatusOK)
+		return
+	}
+
+	intent, queryErr := db.GetPaymentIntentBySession(db.DB, common.Payments.Name(), event.SessionID)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if intent == nil {
+		common.Logger.Printf("received a payment webhook of an unknown session (session: %s, reference: %s)", event.SessionID, event.Reference)
+		ctx.Status(http.StatusOK)
+		return
+	}
+
+	status := event.Status
+
+	// Money charged differently than asked for isn't credited; staff find it among intents under review
+	if status == common.PaymentSucceeded && (event.Amount != intent.Amount || !strings.EqualFold(event.Currency, intent.Currency)) {
+		common.Logger.Printf("payment intent %d was charged %s %s instead of %s %s", intent.ID, event.Amount, event.Currency, intent.Amount, intent.Currency)
+		status = db.IntentReview
+	}
+
+	queryErr = db.SettlePaymentIntent(db.DB, intent.ID, status, event.Amount)
+	if queryErr != nil && !errors.Is(queryErr, db.ErrPaymentIntentSettled) {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Called by the payment provider
+func PaymentWebhook(ctx *gin.Context) {
+	if !onlinePaymentsOrAbort(ctx) {
+		return
+	}
+
+	payload, readErr := io.ReadAll(io.LimitReader(ctx.Request.Body, maxWebhookSize))
+	if readErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", readErr)
+		return
+	}
+
+	settleWebhook(ctx, payload, ctx.Request.Header)
+}
+
+// Responds with 404 unless payments go through the fake provider
+func fakeProviderOrAbort(ctx *gin.Context) *common.FakeProvider {
+	fake, ok := common.Payments.(*common.FakeProvider)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return nil
+	}
+
+	return fake
+}
+
+// The checkout page of the fake provider
+func GetFakeCheckout(ctx *gin.Context) {
+	fake := fakeProviderOrAbort(ctx)
+	if fake == nil {
+		return
+	}
+
+	request, status, found := fake.Session(ctx.Params.ByName("sessionId"))
+	if !found {
+		ctx.String(http.StatusNotFound, "Session not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"reference":   request.Reference,
+		"amount":      request.Amount,
+		"currency":    request.Currency,
+		"description": request.Description,
+		"status":      status,
+	})
+}
+
+// Completes a checkout of the fake provider as a payer would, delivering its webhook to PaymentWebhook.
+// Meant for development only; the caller must send FAKE_PAYMENT_SECRET in the Fake-Secret header.
+func SettleFakeCheckout(ctx *gin.Context) {
+	fake := fakeProviderOrAbort(ctx)
+	if fake == nil {
+		return
+	}
+
+	if !fake.Authorized(ctx.GetHeader("Fake-Secret")) {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	data := dto.FakePayment_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	payload, header, settleErr := fake.Settle(ctx.Params.ByName("sessionId"), data.Status)
+	if settleErr != nil {
+		ctx.String(http.StatusConflict, "Conflict: %v", settleErr)
+		return
+	}
+
+	settleWebhook(ctx, payload, header)
+}
+
+// Query parameter 'status' optionally limits the intents to a status
+func GetPaymentIntents(ctx *gin.Context) {
+	status := ctx.Query("status")
+
+	switch status {
+	case "", db.IntentPending, common.PaymentSucceeded, common.PaymentFailed, common.PaymentExpired, db.IntentReview:
+	default:
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: status")
+		return
+	}
+
+	intents, queryErr := db.GetPaymentIntents(db.DB, status)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, intents)
+}
+
+func GetPaymentIntent(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	intent, queryErr := db.GetPaymentIntentByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if intent == nil {
+		ctx.String(http.StatusNotFound, "Payment not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, intent)
+}
@@ -0,0 +1,315 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Statuses listed in the staff queue by default
+var openOrderStatuses = []string{db.OrderPending, db.OrderPaid, db.OrderReady}
+
+// Responds with 404 unless the order exists and was placed by user
+func userOrderOrAbort(ctx *gin.Context, user *db.User) *db.Order {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return nil
+	}
+
+	order, queryErr := db.GetOrderByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get an order: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil
+	}
+
+	if order == nil || order.CustomerID == nil || *order.CustomerID != user.ID {
+		ctx.String(http.StatusNotFound, "Order not found")
+		return nil
+	}
+
+	return order
+}
+
+// Places an order for the products in the user's basket at their current prices and empties the basket
+func Checkout(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	user := userPtr.(*db.User)
+
+	data := dto.Checkout_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	var promo *db.PromoCode
+
+	if data.PromoCode != "" {
+		if promo = usablePromoCodeOrAbort(ctx, data.PromoCode, "products"); promo == nil {
+			return
+		}
+	}
+
+	basket, queryErr := db.GetAllBasketProductsOfUser_WithProducts(db.DB, user.ID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get basket of user: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if len(basket) == 0 {
+		ctx.String(http.StatusBadRequest, "Basket is empty")
+		return
+	}
+
+	for _, item := range basket {
+		if item.Product == nil {
+			ctx.String(http.StatusConflict, "Conflict: product %d is no longer available", item.ProductID)
+			return
+		}
+
+		if item.VariantID != nil && (item.Variant == nil || !item.Variant.Active) {
+			ctx.String(http.StatusConflict, "Conflict: variant %d is no longer available", *item.VariantID)
+			return
+		}
+	}
+
+	price := priceBasket(basket, promo)
+
+	order := db.Order{
+		CustomerID: &user.ID,
+		Subtotal:   price.Subtotal,
+		Discount:   price.Discount,
+		Total:      price.Total,
+		Notes:      data.Notes,
+	}
+
+	for _, line := range price.Lines {
+		productID := line.ProductID
+
+		order.Lines = append(order.Lines, db.OrderLine{
+			ProductID:   &productID,
+			VariantID:   line.VariantID,
+			ProductName: line.Name,
+			Quantity:    line.Quantity,
+			UnitPrice:   line.UnitPrice,
+			Amount:      line.Amount,
+			Discounted:  line.Discounted,
+		})
+	}
+
+	var redemption *db.PromoRedemption
+
+	if promo != nil {
+		redemption = &db.PromoRedemption{
+			PromoCodeID:    promo.ID,
+			Target:         db.PromoTargetProducts,
+			OriginalAmount: price.Subtotal,
+			DiscountAmount: price.Discount,
+		}
+	}
+
+	id, queryErr := db.CreateOrder(db.DB, order, basket, redemption)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrBasketChanged) || errors.Is(queryErr, db.ErrInsufficientStock) || errors.Is(queryErr, db.ErrProductNotFound) ||
+			errors.Is(queryErr, db.ErrVariantNotFound) || errors.Is(queryErr, db.ErrVariantRequired) ||
+			errors.Is(queryErr, db.ErrPromoCodeExhausted) || errors.Is(queryErr, db.ErrPromoCodeMemberLimit) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to check out basket: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	go sendLowStockAlerts()
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func GetUserOrders(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	orders, queryErr := db.GetOrdersOfCustomer(db.DB, userPtr.(*db.User).ID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get orders of user: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, orders)
+}
+
+func GetUserOrder(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	order := userOrderOrAbort(ctx, userPtr.(*db.User))
+	if order == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// Customers can only cancel their orders before paying
+func CancelUserOrder(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	data := dto.CancelOrder_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	order := userOrderOrAbort(ctx, userPtr.(*db.User))
+	if order == nil {
+		return
+	}
+
+	if order.Status != db.OrderPending {
+		ctx.String(http.StatusConflict, "Conflict: only pending orders can be cancelled")
+		return
+	}
+
+	queryErr := db.CancelPendingOrder(db.DB, order.ID, data.Reason)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrOrderStatus) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to cancel an order: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Query parameter 'status' lists the orders in a single status instead of the open ones
+func GetOrderQueue(ctx *gin.Context) {
+	statuses := openOrderStatuses
+
+	if status := ctx.Query("status"); status != "" {
+		switch status {
+		case db.OrderPending, db.OrderPaid, db.OrderReady, db.OrderCompleted, db.OrderCancelled:
+			statuses = []string{status}
+		default:
+			ctx.String(http.StatusBadRequest, "Invalid query parameter: status")
+			return
+		}
+	}
+
+	orders, queryErr := db.GetOrderQueue(db.DB, statuses...)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get order queue: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, orders)
+}
+
+func GetOrder(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	order, queryErr := db.GetOrderByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get an order: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if order == nil {
+		ctx.String(http.StatusNotFound, "Order not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, order)
+}
+
+// Moves an order along pending, paid, ready and completed, or cancels it
+func UpdateOrderStatus(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.OrderStatus_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	order, queryErr := db.GetOrderByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get an order: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if order == nil {
+		ctx.String(http.StatusNotFound, "Order not found")
+		return
+	}
+
+	payment := &db.Payment{
+		Method:      paymentMethodOrDefault(data.PaymentMethod),
+		CreatedByID: &userPtr.(*db.User).ID,
+	}
+
+	queryErr = db.SetOrderStatus(db.DB, id, data.Status, payment, data.Reason)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrOrderStatus) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to set order status: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,409 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+const periodLayout = "2006-01"
+
+// Hours worked per week, keyed by the date of the week's Monday.
+// Open entries are not counted.
+func weeklyHours(entries []db.TimeEntry) map[string]float64 {
+	weeks := map[string]float64{}
+
+	for _, entry := range entries {
+		if entry.ClockOut == "" {
+			continue
+		}
+
+		clockIn, inErr := time.ParseInLocation(common.DateTimeLayout, entry.ClockIn, time.Local)
+		clockOut, outErr := time.ParseInLocation(common.DateTimeLayout, entry.ClockOut, time.Local)
+		if inErr != nil || outErr != nil || !clockOut.After(clockIn) {
+			common.Logger.Printf("skipping invalid time entry (id: %d)", entry.ID)
+			continue
+		}
+
+		monday := clockIn.AddDate(0, 0, -((int(clockIn.Weekday()) + 6) % 7))
+		weeks[monday.Format(common.DateLayout)] += clockOut.Sub(clockIn).Hours()
+	}
+
+	return weeks
+}
+
+// Calculates the payslips of every active user for a period (YYYY-MM).
+//
+// Hours are grouped by week and anything over PAYROLL_WEEKLY_HOURS in a week is overtime,
+// paid at PAYROLL_OVERTIME_MULTIPLIER times the hourly rate. Hourly staff are paid their regular hours,
+// monthly staff their salary, with overtime at the hourly equivalent of the salary.
+// Only the part of a week inside the period counts towards that week.
+func computePayroll(period string) ([]db.Payslip, common.Money, error) {
+	start, parseErr := time.ParseInLocation(periodLayout, period, time.Local)
+	if parseErr != nil {
+		return nil, 0, parseErr
+	}
+
+	end := start.AddDate(0, 1, 0)
+
+	users, queryErr := db.GetAllUsers(db.DB)
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+
+	rates, queryErr := db.GetStaffPayRates(db.DB)
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+
+	entries, queryErr := db.GetTimeEntries(db.DB, 0, start.Format(common.DateLayout), end.Format(common.DateLayout))
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+
+	adjustments, queryErr := db.GetPayAdjustments(db.DB, period, 0)
+	if queryErr != nil {
+		return nil, 0, queryErr
+	}
+
+	userEntries := map[int64][]db.TimeEntry{}
+	for _, entry := range entries {
+		userEntries[entry.UserID] = append(userEntries[entry.UserID], entry)
+	}
+
+	slips := []db.Payslip{}
+	total := common.Money(0)
+
+	for _, user := range users {
+		// Staff that started after the period aren't paid for it
+		if user.StartDate >= end.Format(common.DateLayout) {
+			continue
+		}
+
+		rate, found := rates[user.ID]
+		if !found {
+			rate = db.StaffPayRate{UserID: user.ID, PayType: db.PayMonthly}
+		}
+
+		userID := user.ID
+
+		slip := db.Payslip{
+			UserID:   &userID,
+			UserName: user.Name,
+			PayType:  rate.PayType,
+		}
+
+		for _, hours := range weeklyHours(userEntries[user.ID]) {
+			slip.HoursWorked += hours
+
+			if limit := float64(common.PayrollWeeklyHours); limit > 0 && hours > limit {
+				slip.RegularHours += limit
+				slip.OvertimeHours += hours - limit
+			} else {
+				slip.RegularHours += hours
+			}
+		}
+
+		hourlyRate := rate.HourlyRate
+
+		if rate.PayType == db.PayHourly {
+			slip.BasePay = hourlyRate.Mul(slip.RegularHours)
+		} else {
+			slip.BasePay = common.MoneyFromFloat(float64(user.Salary))
+
+			if common.PayrollWeeklyHours > 0 {
+				hourlyRate = slip.BasePay.Mul(12 / (52 * float64(common.PayrollWeeklyHours)))
+			}
+		}
+
+		slip.OvertimePay = hourlyRate.Mul(slip.OvertimeHours * common.PayrollOvertimeMultiplier)
+
+		for _, adjustment := range adjustments {
+			if adjustment.UserID != user.ID {
+				continue
+			}
+
+			if adjustment.Kind == db.AdjustmentDeduction {
+				slip.Deductions += adjustment.Amount
+			} else {
+				slip.Bonuses += adjustment.Amount
+			}
+		}
+
+		slip.HoursWorked = roundTo(slip.HoursWorked, 2)
+		slip.RegularHours = roundTo(slip.RegularHours, 2)
+		slip.OvertimeHours = roundTo(slip.OvertimeHours, 2)
+		slip.NetPay = slip.BasePay + slip.OvertimePay + slip.Bonuses - slip.Deductions
+
+		total += slip.NetPay
+		slips = append(slips, slip)
+	}
+
+	sort.Slice(slips, func(i, j int) bool { return slips[i].UserName < slips[j].UserName })
+
+	return slips, total, nil
+}
+
+func GetStaffPayRates(ctx *gin.Context) {
+	rates, queryErr := db.GetStaffPayRates(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get staff pay rates: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	list := []db.StaffPayRate{}
+	for _, rate := range rates {
+		list = append(list, rate)
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].UserID < list[j].UserID })
+
+	ctx.JSON(http.StatusOK, list)
+}
+
+func SetStaffPayRate(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.StaffPayRate_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if data.PayType == db.PayHourly && data.HourlyRate <= 0 {
+		ctx.String(http.StatusBadRequest, "Hourly staff need a positive hourly rate")
+		return
+	}
+
+	if !userExistsOrAbort(ctx, id) {
+		return
+	}
+
+	queryErr := db.SetStaffPayRate(db.DB, db.StaffPayRate{
+		UserID:     id,
+		PayType:    data.PayType,
+		HourlyRate: data.HourlyRate,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to set staff pay rate: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Query parameter 'period' (YYYY-MM) is required, 'userId' is optional
+func GetPayAdjustments(ctx *gin.Context) {
+	period := ctx.Query("period")
+	if _, parseErr := time.Parse(periodLayout, period); parseErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: period")
+		return
+	}
+
+	userID, _ := strconv.ParseInt(ctx.Query("userId"), 10, 64)
+
+	adjustments, queryErr := db.GetPayAdjustments(db.DB, period, userID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get pay adjustments: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, adjustments)
+}
+
+func CreatePayAdjustment(ctx *gin.Context) {
+	data := dto.PayAdjustment_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !userExistsOrAbort(ctx, data.UserID) {
+		return
+	}
+
+	ran, queryErr := db.PayrollRunExists(db.DB, data.Period)
+	if queryErr != nil {
+		common.Logger.Printf("failed to check payroll run: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if ran {
+		ctx.String(http.StatusConflict, "Payroll has already been run for %s", data.Period)
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, queryErr := db.CreatePayAdjustment(db.DB, db.PayAdjustment{
+		UserID:      data.UserID,
+		Period:      data.Period,
+		Kind:        data.Kind,
+		Amount:      data.Amount,
+		Reason:      data.Reason,
+		CreatedByID: userPtr.(*db.User).ID,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a pay adjustment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func DeletePayAdjustment(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeletePayAdjustmentByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a pay adjustment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Calculates the payroll of a period without storing it.
+// Query parameter 'period' (YYYY-MM) defaults to the current month.
+func GetPayrollPreview(ctx *gin.Context) {
+	period := ctx.DefaultQuery("period", time.Now().Format(periodLayout))
+	if _, parseErr := time.Parse(periodLayout, period); parseErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: period")
+		return
+	}
+
+	slips, total, computeErr := computePayroll(period)
+	if computeErr != nil {
+		common.Logger.Printf("failed to compute payroll: %v", computeErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, db.PayrollPreview{
+		Period:   period,
+		Total:    total,
+		Payslips: slips,
+	})
+}
+
+func CreatePayrollRun(ctx *gin.Context) {
+	data := dto.PayrollRun_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	slips, total, computeErr := computePayroll(data.Period)
+	if computeErr != nil {
+		common.Logger.Printf("failed to compute payroll: %v", computeErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	id, queryErr := db.CreatePayrollRun(db.DB, db.PayrollRun{
+		Period:      data.Period,
+		Total:       total,
+		CreatedByID: userPtr.(*db.User).ID,
+		Payslips:    slips,
+	})
+
+	switch {
+	case errors.Is(queryErr, db.ErrPayrollRunExists):
+		ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+		return
+	case queryErr != nil:
+		common.Logger.Printf("failed to create a payroll run: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func GetPayrollRuns(ctx *gin.Context) {
+	runs, queryErr := db.GetPayrollRuns(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get payroll runs: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, runs)
+}
+
+func GetPayrollRunByID(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	run, queryErr := db.GetPayrollRunByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a payroll run: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if run == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, run)
+}
+
+// Deleting a run allows the period to be run again
+func DeletePayrollRun(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeletePayrollRunByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a payroll run: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
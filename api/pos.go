@@ -0,0 +1,542 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+func OpenCashDrawer(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	data := dto.OpenCashDrawer_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	id, queryErr := db.OpenCashDrawer(db.DB, userPtr.(*db.User).ID, data.OpeningFloat)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrDrawerOpen) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to open cash drawer: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+// Responds with the open session, including the cash expected in the drawer so far
+func GetOpenCashDrawer(ctx *gin.Context) {
+	session, queryErr := db.GetOpenCashDrawer(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if session == nil {
+		ctx.String(http.StatusNotFound, "No cash drawer session is open")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, session)
+}
+
+// Closes out the open session with the cash counted in the drawer.
+// Responds with the closeout: the totals by payment method and the expected cash against the counted one.
+func CloseCashDrawer(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	data := dto.CloseCashDrawer_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	session, queryErr := db.GetOpenCashDrawer(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if session == nil {
+		ctx.String(http.StatusConflict, "Conflict: %v", db.ErrNoOpenDrawer)
+		return
+	}
+
+	queryErr = db.CloseCashDrawer(db.DB, session.ID, userPtr.(*db.User).ID, data.CountedCash, data.Notes)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrNoOpenDrawer) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to close cash drawer: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	closed, queryErr := db.GetCashDrawerSessionByID(db.DB, session.ID)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, closed)
+}
+
+// Query parameters 'from' and 'to' are inclusive dates the sessions were opened within
+func GetCashDrawerSessions(ctx *gin.Context) {
+	from, to, ok := dateRangeOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	sessions, queryErr := db.GetCashDrawerSessions(db.DB, from, to)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func GetCashDrawerSession(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	session, queryErr := db.GetCashDrawerSessionByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if session == nil {
+		ctx.String(http.StatusNotFound, "Cash drawer session not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, session)
+}
+
+func GetCashDrawerSales(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	sales, queryErr := db.GetPosSalesOfDrawer(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sales)
+}
+
+// Prices an item of a sale at current prices. Responds with 400 or 404 and returns nil if it can't be sold.
+func posSaleLineOrAbort(ctx *gin.Context, item dto.PosItem_Req, subscriber *db.Subscriber) *db.PosSaleLine {
+	line := db.PosSaleLine{Kind: item.Kind, Quantity: 1}
+
+	switch item.Kind {
+	case db.PosLineProduct:
+		product, queryErr := db.GetProductByID(db.DB, *item.ProductID)
+		if queryErr != nil {
+			if errors.Is(queryErr, db.ErrProductNotFound) {
+				ctx.String(http.StatusNotFound, "Product %d not found", *item.ProductID)
+				return nil
+			}
+
+			common.Logger.Printf("%v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return nil
+		}
+
+		line.ProductID = &product.ID
+		line.Description = product.Name
+		line.UnitPrice = product.Price
+
+		if item.Quantity > 0 {
+			line.Quantity = item.Quantity
+		}
+
+		if item.VariantID != nil {
+			variant, queryErr := db.GetProductVariantByID(db.DB, *item.VariantID)
+			if queryErr != nil {
+				common.Logger.Printf("%v", queryErr)
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+				return nil
+			}
+
+			if variant == nil || variant.ProductID != product.ID || !variant.Active {
+				ctx.String(http.StatusNotFound, "Variant %d not found", *item.VariantID)
+				return nil
+			}
+
+			line.VariantID = &variant.ID
+			line.Description = fmt.Sprintf("%s (%s)", product.Name, variant.Name)
+
+			if variant.Price != nil {
+				line.UnitPrice = *variant.Price
+			}
+		}
+	case db.PosLineMembership:
+		if subscriber == nil {
+			ctx.String(http.StatusBadRequest, "Invalid data: membership renewals need a subscriber")
+			return nil
+		}
+
+		days := item.Days
+
+		line.Days = &days
+		line.Description = fmt.Sprintf("Membership renewal (%d days)", days)
+		line.UnitPrice = subscriber.BucketPrice
+
+		if item.PlanID != nil {
+			plan, queryErr := db.GetPlanByID(db.DB, *item.PlanID)
+			if queryErr != nil {
+				if errors.Is(queryErr, sql.ErrNoRows) {
+					ctx.String(http.StatusNotFound, "Plan not found")
+					return nil
+				}
+
+				common.Logger.Printf("%v", queryErr)
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+				return nil
+			}
+
+			line.PlanID = item.PlanID
+			line.Description = fmt.Sprintf("%s (%d days)", plan.Title, days)
+			line.UnitPrice = plan.Price
+		}
+
+		if item.Price != nil {
+			line.UnitPrice = *item.Price
+		}
+	}
+
+	line.Amount = line.UnitPrice * common.Money(line.Quantity)
+
+	return &line
+}
+
+// Splits the total over the payments, taking change out of the cash tendered.
+// Responds with 400 and returns false if they don't cover the total or only non-cash payments go over it.
+func posPaymentsOrAbort(ctx *gin.Context, sale *db.PosSale, payments []dto.PosPayment_Req) bool {
+	paid, cash := common.Money(0), common.Money(0)
+
+	for _, payment := range payments {
+		paid += payment.Amount
+
+		if payment.Method == "cash" {
+			cash += payment.Amount
+		}
+	}
+
+	if paid < sale.Total {
+		ctx.String(http.StatusBadRequest, "Invalid data: payments are %s short of the total", common.FormatMoney(sale.Total-paid))
+		return false
+	}
+
+	sale.ChangeGiven = paid - sale.Total
+
+	if sale.ChangeGiven > cash {
+		ctx.String(http.StatusBadRequest, "Invalid data: only cash can be paid over the total")
+		return false
+	}
+
+	change := sale.ChangeGiven
+	applied := make([]common.Money, len(payments))
+
+	// Change comes out of the last cash tendered
+	for i := len(payments) - 1; i >= 0; i-- {
+		applied[i] = payments[i].Amount
+
+		if payments[i].Method == "cash" && change > 0 {
+			taken := min(change, applied[i])
+			applied[i] -= taken
+			change -= taken
+		}
+	}
+
+	for i, payment := range payments {
+		if applied[i] > 0 {
+			sale.Payments = append(sale.Payments, db.PosSalePayment{Method: payment.Method, Amount: applied[i]})
+		}
+	}
+
+	return true
+}
+
+// Sells products and membership renewals at the counter in the open cash drawer session.
+// Responds with the sale, including the change to give back and the renewed membership end dates.
+func CreatePosSale(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	data := dto.PosSale_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	sale := db.PosSale{
+		SubscriberID: data.SubscriberID,
+		CustomerName: data.CustomerName,
+		CreatedByID:  &userPtr.(*db.User).ID,
+	}
+
+	var subscriber *db.Subscriber
+
+	if data.SubscriberID != nil {
+		var queryErr error
+
+		subscriber, queryErr = db.GetSubscriberByID(db.DB, *data.SubscriberID)
+		if queryErr != nil {
+			common.Logger.Printf("failed to get subscriber by ID: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if subscriber == nil {
+			ctx.String(http.StatusNotFound, "Subscriber not found")
+			return
+		}
+
+		sale.CustomerName = strings.TrimSpace(subscriber.Name + " " + subscriber.Surname)
+	}
+
+	sellsProducts := false
+
+	for _, item := range data.Items {
+		line := posSaleLineOrAbort(ctx, item, subscriber)
+		if line == nil {
+			return
+		}
+
+		sellsProducts = sellsProducts || line.Kind == db.PosLineProduct
+
+		sale.Lines = append(sale.Lines, *line)
+		sale.Total += line.Amount
+	}
+
+	if !posPaymentsOrAbort(ctx, &sale, data.Payments) {
+		return
+	}
+
+	id, queryErr := db.CreatePosSale(db.DB, sale)
+	if queryErr != nil {
+		switch {
+		case errors.Is(queryErr, db.ErrNoOpenDrawer), errors.Is(queryErr, db.ErrInsufficientStock):
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+		case errors.Is(queryErr, db.ErrSubscriberNotFound), errors.Is(queryErr, db.ErrProductNotFound), errors.Is(queryErr, db.ErrVariantNotFound):
+			ctx.String(http.StatusNotFound, "%v", queryErr)
+		case errors.Is(queryErr, db.ErrVariantRequired):
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", queryErr)
+		default:
+			common.Logger.Printf("failed to create a sale: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	if sellsProducts {
+		go sendLowStockAlerts()
+	}
+
+	created, queryErr := db.GetPosSaleByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, created)
+}
+
+// Responds with 404 unless the sale exists
+func posSaleOrAbort(ctx *gin.Context) *db.PosSale {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return nil
+	}
+
+	sale, queryErr := db.GetPosSaleByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("%v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil
+	}
+
+	if sale == nil {
+		ctx.String(http.StatusNotFound, "Sale not found")
+		return nil
+	}
+
+	return sale
+}
+
+func GetPosSale(ctx *gin.Context) {
+	sale := posSaleOrAbort(ctx)
+	if sale == nil {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sale)
+}
+
+// Lays out a sale's receipt on a page
+func renderReceiptPDF(sale *db.PosSale) ([]byte, error) {
+	const (
+		left      = 50.0
+		right     = common.PDFPageWidth - 50
+		bottom    = common.PDFPageHeight - 70
+		rowHeight = 16.0
+	)
+
+	pdf := common.NewPDF()
+	pdf.Footer = common.InvoiceFooter
+
+	sellerName, sellerDetails := invoiceSeller()
+
+	y := 60.0
+
+	if sellerName != "" {
+		pdf.Text(left, y, 14, true, sellerName)
+		y += 4
+	}
+
+	for _, detail := range strings.Split(sellerDetails, "\n") {
+		if detail != "" {
+			y += 12
+			pdf.Text(left, y, 9, false, detail)
+		}
+	}
+
+	pdf.TextRight(right, 60, 16, true, "RECEIPT")
+	pdf.TextRight(right, 78, 10, false, fmt.Sprintf("#%d", sale.ID))
+	pdf.TextRight(right, 92, 10, false, sale.CreatedAt)
+
+	customer := sale.CustomerName
+	if customer == "" {
+		customer = "Walk-in"
+	}
+
+	y = max(y, 92) + 30
+	pdf.Text(left, y, 10, false, "Customer: "+customer)
+	y += 30
+
+	header := func() {
+		pdf.Text(left, y, 9, true, "Item")
+		pdf.TextRight(380, y, 9, true, "Qty")
+		pdf.TextRight(460, y, 9, true, "Unit price")
+		pdf.TextRight(right, y, 9, true, "Amount")
+		pdf.Line(left, y+5, right, y+5, 0.5)
+		y += rowHeight + 4
+	}
+
+	header()
+
+	for _, line := range sale.Lines {
+		if y > bottom {
+			pdf.AddPage()
+			y = 50
+			header()
+		}
+
+		pdf.Text(left, y, 9, false, common.PDFTruncate(line.Description, 280, 9, false))
+		pdf.TextRight(380, y, 9, false, strconv.Itoa(line.Quantity))
+		pdf.TextRight(460, y, 9, false, common.FormatMoney(line.UnitPrice))
+		pdf.TextRight(right, y, 9, false, common.FormatMoney(line.Amount))
+		y += rowHeight
+
+		if line.EndsAt != "" {
+			pdf.Text(left+10, y, 8, false, "Membership valid until "+line.EndsAt[:min(len(line.EndsAt), 10)])
+			y += rowHeight
+		}
+	}
+
+	// The total, payments and change take about 60 points plus a row per payment
+	if y+60+float64(len(sale.Payments))*rowHeight > bottom {
+		pdf.AddPage()
+		y = 50
+	}
+
+	pdf.Line(left, y-rowHeight+5, right, y-rowHeight+5, 0.5)
+	y += 6
+
+	pdf.TextRight(460, y, 10, true, "Total")
+	pdf.TextRight(right, y, 10, true, common.FormatMoney(sale.Total))
+	y += rowHeight + 6
+
+	for _, payment := range sale.Payments {
+		pdf.TextRight(460, y, 9, false, "Paid by "+payment.Method)
+		pdf.TextRight(right, y, 9, false, common.FormatMoney(payment.Amount))
+		y += rowHeight
+	}
+
+	if sale.ChangeGiven > 0 {
+		pdf.TextRight(460, y, 9, false, "Change")
+		pdf.TextRight(right, y, 9, false, common.FormatMoney(sale.ChangeGiven))
+	}
+
+	return pdf.Bytes()
+}
+
+func GetPosSaleReceipt(ctx *gin.Context) {
+	sale := posSaleOrAbort(ctx)
+	if sale == nil {
+		return
+	}
+
+	document, renderErr := renderReceiptPDF(sale)
+	if renderErr != nil {
+		common.Logger.Printf("failed to render a receipt: %v", renderErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=receipt-%d.pdf", sale.ID))
+	ctx.Data(http.StatusOK, "application/pdf", document)
+}
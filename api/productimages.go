@@ -0,0 +1,209 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+func productImagesFolder(productID int64) string {
+	return filepath.Join("products", strconv.FormatInt(productID, 10))
+}
+
+func productImageURL(productID, imageID int64) string {
+	return fmt.Sprintf("/v1/dashboard/product/%d/images/%d", productID, imageID)
+}
+
+func deleteProductImageFiles(productID int64) {
+	if removeErr := os.RemoveAll(filepath.Join(common.StoragePath, productImagesFolder(productID))); removeErr != nil {
+		common.Logger.Printf("failed to delete product images (id: %d): %v", productID, removeErr)
+	}
+}
+
+// Fills in the images of products with their URLs.
+// Failures are only logged so products are still listed without images.
+func attachProductImages(products []db.Product) {
+	if attachErr := db.AttachProductImages(db.DB, products); attachErr != nil {
+		common.Logger.Printf("failed to attach product images: %v", attachErr)
+		return
+	}
+
+	for i := range products {
+		for j := range products[i].Images {
+			image := &products[i].Images[j]
+			image.URL = productImageURL(image.ProductID, image.ID)
+
+			if image.Primary {
+				products[i].ImageURL = image.URL
+			}
+		}
+	}
+}
+
+// Responds with 400 and returns false if a path parameter is invalid
+func productImageParamsOrAbort(ctx *gin.Context) (int64, int64, bool) {
+	productID, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return 0, 0, false
+	}
+
+	imageID, convErr := strconv.ParseInt(ctx.Params.ByName("imageId"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: imageId")
+		return 0, 0, false
+	}
+
+	return productID, imageID, true
+}
+
+func GetProductImages(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	images, queryErr := db.GetProductImages(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get product images: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	for i := range images {
+		images[i].URL = productImageURL(images[i].ProductID, images[i].ID)
+	}
+
+	ctx.JSON(http.StatusOK, images)
+}
+
+func GetProductImage(ctx *gin.Context) {
+	productID, imageID, ok := productImageParamsOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	serveStoredImage(ctx, productImagesFolder(productID), imageID)
+}
+
+// Adds the form file 'image' to the product's gallery. Form value 'primary' set to true makes it the primary image.
+func UploadProductImage(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	imageID, queryErr := db.CreateProductImage(db.DB, id)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrProductNotFound) {
+			ctx.String(http.StatusNotFound, "Product not found")
+			return
+		}
+
+		common.Logger.Printf("failed to create a product image: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !saveUploadedImage(ctx, productImagesFolder(id), imageID) {
+		if _, deleteErr := db.DeleteProductImage(db.DB, id, imageID); deleteErr != nil {
+			common.Logger.Printf("failed to delete a product image without a file: %v", deleteErr)
+		}
+
+		return
+	}
+
+	if primary, _ := strconv.ParseBool(ctx.PostForm("primary")); primary {
+		if _, queryErr := db.SetPrimaryProductImage(db.DB, id, imageID); queryErr != nil {
+			common.Logger.Printf("failed to set primary product image: %v", queryErr)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, imageID)
+}
+
+func DeleteProductImage(ctx *gin.Context) {
+	productID, imageID, ok := productImageParamsOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	found, queryErr := db.DeleteProductImage(db.DB, productID, imageID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a product image: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		ctx.String(http.StatusNotFound, "Image not found")
+		return
+	}
+
+	if deleteErr := deleteStoredImage(productImagesFolder(productID), imageID); deleteErr != nil {
+		common.Logger.Printf("failed to delete product image file (id: %d): %v", imageID, deleteErr)
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func SetPrimaryProductImage(ctx *gin.Context) {
+	productID, imageID, ok := productImageParamsOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	found, queryErr := db.SetPrimaryProductImage(db.DB, productID, imageID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to set primary product image: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		ctx.String(http.StatusNotFound, "Image not found")
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func ReorderProductImages(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.ReorderProductImages_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	queryErr := db.ReorderProductImages(db.DB, id, data.ImageIDs)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrProductImageOrder) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to reorder product images: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
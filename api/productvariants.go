@@ -0,0 +1,169 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Fills in the images and active variants of products.
+// Failures are only logged so products are still listed without them.
+func attachProductDetails(products []db.Product) {
+	attachProductImages(products)
+
+	if attachErr := db.AttachProductVariants(db.DB, products); attachErr != nil {
+		common.Logger.Printf("failed to attach product variants: %v", attachErr)
+	}
+}
+
+// Responds with 400 and returns false if a path parameter is invalid
+func productVariantParamsOrAbort(ctx *gin.Context) (int64, int64, bool) {
+	productID, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return 0, 0, false
+	}
+
+	variantID, convErr := strconv.ParseInt(ctx.Params.ByName("variantId"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: variantId")
+		return 0, 0, false
+	}
+
+	return productID, variantID, true
+}
+
+func productVariantFromReq(data dto.ProductVariant_Req) db.ProductVariant {
+	return db.ProductVariant{
+		Name:       data.Name,
+		SKU:        data.SKU,
+		Price:      data.Price,
+		Active:     data.Active == nil || *data.Active,
+		Attributes: data.Attributes,
+	}
+}
+
+// Query parameter 'inactive' set to true includes deactivated variants
+func GetProductVariants(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	inactive, _ := strconv.ParseBool(ctx.Query("inactive"))
+
+	variants, queryErr := db.GetProductVariants(db.DB, id, inactive)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get product variants: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, variants)
+}
+
+// Variants start out of stock; stock is added with stock movements
+func CreateProductVariant(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.ProductVariant_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	variant := productVariantFromReq(data)
+	variant.ProductID = id
+
+	variantID, queryErr := db.CreateProductVariant(db.DB, variant)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrProductNotFound) {
+			ctx.String(http.StatusNotFound, "Product not found")
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrVariantSKUTaken) || errors.Is(queryErr, db.ErrProductHasStock) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to create a product variant: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, variantID)
+}
+
+// Replaces the variant's name, SKU, price, attributes and active state
+func UpdateProductVariant(ctx *gin.Context) {
+	productID, variantID, ok := productVariantParamsOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	data := dto.ProductVariant_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	variant := productVariantFromReq(data)
+	variant.ID = variantID
+	variant.ProductID = productID
+
+	queryErr := db.UpdateProductVariant(db.DB, variant)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrVariantNotFound) {
+			ctx.String(http.StatusNotFound, "Variant not found")
+			return
+		}
+
+		if errors.Is(queryErr, db.ErrVariantSKUTaken) || errors.Is(queryErr, db.ErrProductHasStock) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to update a product variant: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Deactivates the variant and removes it from baskets; past orders keep referring to it
+func DeleteProductVariant(ctx *gin.Context) {
+	productID, variantID, ok := productVariantParamsOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	found, queryErr := db.DeactivateProductVariant(db.DB, productID, variantID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a product variant: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		ctx.String(http.StatusNotFound, "Variant not found")
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,347 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Responds with 400 and returns false if the request is inconsistent
+func promoCodeFromReqOrAbort(ctx *gin.Context, data *dto.PromoCode_Req) (db.PromoCode, bool) {
+	promo := db.PromoCode{
+		Code:             strings.ToUpper(strings.TrimSpace(data.Code)),
+		Description:      data.Description,
+		DiscountType:     data.DiscountType,
+		DiscountPercent:  data.DiscountPercent,
+		DiscountAmount:   data.DiscountAmount,
+		AppliesTo:        data.AppliesTo,
+		ValidFrom:        data.ValidFrom,
+		ValidUntil:       data.ValidUntil,
+		MaxUses:          data.MaxUses,
+		MaxUsesPerMember: data.MaxUsesPerMember,
+		Active:           data.Active == nil || *data.Active,
+		PlanIDs:          data.PlanIDs,
+		CategoryIDs:      data.CategoryIDs,
+	}
+
+	if promo.AppliesTo == "" {
+		promo.AppliesTo = "all"
+	}
+
+	if promo.Code == "" {
+		ctx.String(http.StatusBadRequest, "Invalid data: code is empty")
+		return promo, false
+	}
+
+	if promo.DiscountType == "percent" {
+		promo.DiscountAmount = 0
+
+		if promo.DiscountPercent <= 0 {
+			ctx.String(http.StatusBadRequest, "Invalid data: discountPercent must be positive")
+			return promo, false
+		}
+	} else {
+		promo.DiscountPercent = 0
+
+		if promo.DiscountAmount <= 0 {
+			ctx.String(http.StatusBadRequest, "Invalid data: discountAmount must be positive")
+			return promo, false
+		}
+	}
+
+	if promo.ValidFrom != "" && promo.ValidUntil != "" && promo.ValidUntil < promo.ValidFrom {
+		ctx.String(http.StatusBadRequest, "Invalid data: validUntil is before validFrom")
+		return promo, false
+	}
+
+	if (promo.AppliesTo == "products" && len(promo.PlanIDs) > 0) || (promo.AppliesTo == "plans" && len(promo.CategoryIDs) > 0) {
+		ctx.String(http.StatusBadRequest, "Invalid data: restrictions don't match appliesTo")
+		return promo, false
+	}
+
+	return promo, true
+}
+
+// Looks up a code and checks it can be used now for target (plans or products).
+// Usage limits are checked again when the code is redeemed.
+func usablePromoCodeOrAbort(ctx *gin.Context, code, target string) *db.PromoCode {
+	promo, queryErr := db.GetPromoCodeByCode(db.DB, strings.ToUpper(strings.TrimSpace(code)))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a promo code: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil
+	}
+
+	now := time.Now().Format(common.DateTimeLayout)
+
+	switch {
+	case promo == nil || !promo.Active:
+		ctx.String(http.StatusNotFound, "Promo code not found")
+		return nil
+	case promo.ValidFrom != "" && now < promo.ValidFrom, promo.ValidUntil != "" && now > promo.ValidUntil:
+		ctx.String(http.StatusBadRequest, "Promo code is not valid at this time")
+		return nil
+	case promo.AppliesTo != "all" && promo.AppliesTo != target:
+		ctx.String(http.StatusBadRequest, "Promo code doesn't apply to %s", target)
+		return nil
+	case promo.MaxUses > 0 && promo.Uses >= promo.MaxUses:
+		ctx.String(http.StatusConflict, "Conflict: %v", db.ErrPromoCodeExhausted)
+		return nil
+	}
+
+	return promo
+}
+
+// Never more than amount
+func promoDiscount(promo *db.PromoCode, amount common.Money) common.Money {
+	if promo.DiscountType == "percent" {
+		return amount.Percent(promo.DiscountPercent)
+	}
+
+	return min(promo.DiscountAmount, amount)
+}
+
+// Prices the products of a basket, discounting the lines in the code's categories when promo isn't nil
+func priceBasket(basket []db.ProductBasket, promo *db.PromoCode) dto.BasketPrice_Res {
+	price := dto.BasketPrice_Res{Lines: []dto.BasketPriceLine_Res{}}
+	eligible := common.Money(0)
+
+	for _, item := range basket {
+		if item.Product == nil {
+			continue
+		}
+
+		line := dto.BasketPriceLine_Res{
+			BasketID:  item.ID,
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Name:      item.Product.Name,
+			Quantity:  item.Quantity,
+			UnitPrice: item.Product.Price,
+		}
+
+		if item.Variant != nil {
+			line.Name = fmt.Sprintf("%s (%s)", item.Product.Name, item.Variant.Name)
+
+			if item.Variant.Price != nil {
+				line.UnitPrice = *item.Variant.Price
+			}
+		}
+
+		line.Amount = line.UnitPrice * common.Money(item.Quantity)
+
+		if promo != nil && (len(promo.CategoryIDs) == 0 || slices.Contains(promo.CategoryIDs, item.Product.CategoryID)) {
+			line.Discounted = true
+			eligible += line.Amount
+		}
+
+		price.Subtotal += line.Amount
+		price.Lines = append(price.Lines, line)
+	}
+
+	if promo != nil {
+		price.PromoCode = promo.Code
+		price.Discount = promoDiscount(promo, eligible)
+	}
+
+	price.Total = price.Subtotal - price.Discount
+
+	return price
+}
+
+func GetPromoCodes(ctx *gin.Context) {
+	promos, queryErr := db.GetPromoCodes(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get promo codes: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, promos)
+}
+
+func GetPromoCode(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	promo, queryErr := db.GetPromoCodeByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a promo code: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if promo == nil {
+		ctx.String(http.StatusNotFound, "Promo code not found")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, promo)
+}
+
+func CreatePromoCode(ctx *gin.Context) {
+	data := dto.PromoCode_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	promo, ok := promoCodeFromReqOrAbort(ctx, &data)
+	if !ok {
+		return
+	}
+
+	existing, queryErr := db.GetPromoCodeByCode(db.DB, promo.Code)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a promo code: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing != nil {
+		ctx.String(http.StatusConflict, "Conflict: promo code already exists")
+		return
+	}
+
+	id, queryErr := db.CreatePromoCode(db.DB, promo)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrPromoCodeTarget) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to create a promo code: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func UpdatePromoCode(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.PromoCode_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	promo, ok := promoCodeFromReqOrAbort(ctx, &data)
+	if !ok {
+		return
+	}
+
+	promo.ID = id
+
+	existing, queryErr := db.GetPromoCodeByCode(db.DB, promo.Code)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a promo code: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing != nil && existing.ID != id {
+		ctx.String(http.StatusConflict, "Conflict: promo code already exists")
+		return
+	}
+
+	queryErr = db.UpdatePromoCode(db.DB, promo)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrPromoCodeTarget) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to update a promo code: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeletePromoCode(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeletePromoCodeByID(db.DB, id)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrPromoCodeRedeemed) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to delete a promo code: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func GetPromoRedemptions(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	redemptions, queryErr := db.GetPromoRedemptions(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get promo redemptions: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, redemptions)
+}
+
+// Query parameter 'promoCode' optionally applies a discount code
+func GetUserBasketPrice(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	var promo *db.PromoCode
+
+	if code := ctx.Query("promoCode"); code != "" {
+		if promo = usablePromoCodeOrAbort(ctx, code, "products"); promo == nil {
+			return
+		}
+	}
+
+	basket, queryErr := db.GetAllBasketProductsOfUser_WithProducts(db.DB, userPtr.(*db.User).ID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get basket of user: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, priceBasket(basket, promo))
+}
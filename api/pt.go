@@ -0,0 +1,631 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Free slots are offered at this granularity
+const ptSlotStep = 15 * time.Minute
+
+// Whether a session fits entirely in one of the weekly availability windows
+func withinAvailability(windows []db.TrainerAvailability, start, end time.Time) bool {
+	if start.YearDay() != end.Add(-time.Nanosecond).YearDay() {
+		return false
+	}
+
+	startTime := start.Format("15:04")
+	endTime := end.Format("15:04")
+
+	for _, window := range windows {
+		if window.Weekday == int(start.Weekday()) && startTime >= window.StartTime && endTime <= window.EndTime {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Responds with 404 and returns false if the trainer does not exist
+func trainerExistsOrAbort(ctx *gin.Context, id int64) bool {
+	trainer, queryErr := db.GetTrainerByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer by ID: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	if trainer == nil {
+		ctx.String(http.StatusNotFound, "Trainer not found")
+		return false
+	}
+
+	return true
+}
+
+func GetTrainerAvailability(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	windows, queryErr := db.GetTrainerAvailability(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer availability: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, windows)
+}
+
+func ReplaceTrainerAvailability(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.ReplaceTrainerAvailability_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	windows := make([]db.TrainerAvailability, 0, len(data.Windows))
+	for _, window := range data.Windows {
+		if window.EndTime <= window.StartTime {
+			ctx.String(http.StatusBadRequest, "Availability window ends before it starts: %s-%s", window.StartTime, window.EndTime)
+			return
+		}
+
+		windows = append(windows, db.TrainerAvailability{
+			Weekday:   window.Weekday,
+			StartTime: window.StartTime,
+			EndTime:   window.EndTime,
+		})
+	}
+
+	if !trainerExistsOrAbort(ctx, id) {
+		return
+	}
+
+	queryErr := db.ReplaceTrainerAvailability(db.DB, id, windows)
+	if queryErr != nil {
+		common.Logger.Printf("failed to replace trainer availability: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Query parameters 'from' and 'to' default to the coming month
+func GetTrainerTimeBlocks(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	now := time.Now()
+
+	from := ctx.DefaultQuery("from", now.Format(common.DateLayout))
+	to := ctx.DefaultQuery("to", now.AddDate(0, 1, 0).Format(common.DateLayout))
+
+	blocks, queryErr := db.GetTrainerTimeBlocks(db.DB, id, from, to)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer time blocks: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blocks)
+}
+
+func CreateTrainerTimeBlock(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.TrainerTimeBlock_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if data.EndsAt <= data.StartsAt {
+		ctx.String(http.StatusBadRequest, "Time block ends before it starts")
+		return
+	}
+
+	if !trainerExistsOrAbort(ctx, id) {
+		return
+	}
+
+	blockID, queryErr := db.CreateTrainerTimeBlock(db.DB, db.TrainerTimeBlock{
+		TrainerID: id,
+		StartsAt:  data.StartsAt,
+		EndsAt:    data.EndsAt,
+		Reason:    data.Reason,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a trainer time block: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, blockID)
+}
+
+func DeleteTrainerTimeBlock(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeleteTrainerTimeBlockByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a trainer time block: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Lists the start times on a date (query parameter 'date') at which a session of
+// 'minutes' (defaults to PT_SESSION_MINUTES) can be booked with a trainer.
+func GetTrainerFreeSlots(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	date, parseErr := time.ParseInLocation(common.DateLayout, ctx.Query("date"), time.Local)
+	if parseErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: date")
+		return
+	}
+
+	minutes, convErr := strconv.Atoi(ctx.DefaultQuery("minutes", strconv.Itoa(common.PTSessionMinutes)))
+	if convErr != nil || minutes < 15 {
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: minutes")
+		return
+	}
+
+	duration := time.Duration(minutes) * time.Minute
+	dayEnd := date.AddDate(0, 0, 1)
+
+	windows, queryErr := db.GetTrainerAvailability(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer availability: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	blocks, queryErr := db.GetTrainerTimeBlocks(db.DB, id, date.Format(common.DateTimeLayout), dayEnd.Format(common.DateTimeLayout))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer time blocks: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	sessions, queryErr := db.GetPTSessions(db.DB, id, 0, date.Add(-24*time.Hour).Format(common.DateTimeLayout), dayEnd.Format(common.DateTimeLayout))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get PT sessions: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	type busyRange struct{ start, end string }
+
+	busy := make([]busyRange, 0, len(blocks)+len(sessions))
+	for _, block := range blocks {
+		busy = append(busy, busyRange{block.StartsAt, block.EndsAt})
+	}
+
+	for _, session := range sessions {
+		if session.Status == db.PTSessionBooked || session.Status == db.PTSessionCompleted {
+			busy = append(busy, busyRange{session.StartsAt, session.EndsAt})
+		}
+	}
+
+	now := time.Now()
+	slots := []string{}
+
+	for start := date; !start.Add(duration).After(dayEnd); start = start.Add(ptSlotStep) {
+		end := start.Add(duration)
+
+		if start.Before(now) || !withinAvailability(windows, start, end) {
+			continue
+		}
+
+		startStr := start.Format(common.DateTimeLayout)
+		endStr := end.Format(common.DateTimeLayout)
+
+		free := true
+		for _, r := range busy {
+			if startStr < r.end && endStr > r.start {
+				free = false
+				break
+			}
+		}
+
+		if free {
+			slots = append(slots, startStr)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, slots)
+}
+
+func GetSubscriberPTPackages(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	packages, queryErr := db.GetSubscriberPTPackages(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get PT packages: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, packages)
+}
+
+func CreatePTPackage(ctx *gin.Context) {
+	data := dto.PTPackage_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !subscriberExistsOrAbort(ctx, data.SubscriberID) {
+		return
+	}
+
+	if data.TrainerID != nil && !trainerExistsOrAbort(ctx, *data.TrainerID) {
+		return
+	}
+
+	pkg := db.PTPackage{
+		SubscriberID:   data.SubscriberID,
+		TrainerID:      data.TrainerID,
+		TotalSessions:  data.TotalSessions,
+		SessionMinutes: data.SessionMinutes,
+		Price:          data.Price,
+		ExpiresAt:      data.ExpiresAt,
+	}
+
+	if pkg.SessionMinutes == 0 {
+		pkg.SessionMinutes = common.PTSessionMinutes
+	}
+
+	var payment *db.Payment
+
+	if pkg.Price > 0 {
+		payment = &db.Payment{
+			Source: db.PaymentPT,
+			Amount: pkg.Price,
+			Method: paymentMethodOrDefault(data.PaymentMethod),
+		}
+
+		if userPtr, exists := ctx.Get("user"); exists {
+			payment.CreatedByID = &userPtr.(*db.User).ID
+		}
+	}
+
+	id, queryErr := db.CreatePTPackage(db.DB, pkg, payment)
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a PT package: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+// Optional query parameters: 'trainerId', 'subscriberId', 'from' and 'to' (default to the coming week)
+func GetPTSessions(ctx *gin.Context) {
+	var trainerID, subscriberID int64
+
+	if value := ctx.Query("trainerId"); value != "" {
+		parsed, convErr := strconv.ParseInt(value, 10, 64)
+		if convErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid query parameter: trainerId")
+			return
+		}
+
+		trainerID = parsed
+	}
+
+	if value := ctx.Query("subscriberId"); value != "" {
+		parsed, convErr := strconv.ParseInt(value, 10, 64)
+		if convErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid query parameter: subscriberId")
+			return
+		}
+
+		subscriberID = parsed
+	}
+
+	now := time.Now()
+
+	from := ctx.DefaultQuery("from", now.Format(common.DateLayout))
+	to := ctx.DefaultQuery("to", now.AddDate(0, 0, 7).Format(common.DateLayout))
+
+	if len(to) == len(common.DateLayout) {
+		to += " 23:59:59"
+	}
+
+	sessions, queryErr := db.GetPTSessions(db.DB, trainerID, subscriberID, from, to)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get PT sessions: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sessions)
+}
+
+func BookPTSession(ctx *gin.Context) {
+	data := dto.BookPTSession_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	startsAt, parseErr := time.ParseInLocation(common.DateTimeLayout, data.StartsAt, time.Local)
+	if parseErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid startsAt")
+		return
+	}
+
+	if startsAt.Before(time.Now()) {
+		ctx.String(http.StatusBadRequest, "Sessions can't be booked in the past")
+		return
+	}
+
+	if !trainerExistsOrAbort(ctx, data.TrainerID) || !subscriberExistsOrAbort(ctx, data.SubscriberID) {
+		return
+	}
+
+	minutes := data.DurationMinutes
+
+	if data.PackageID != nil {
+		pkg, queryErr := db.GetPTPackageByID(db.DB, *data.PackageID)
+		if queryErr != nil {
+			common.Logger.Printf("failed to get a PT package: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if pkg == nil || pkg.SubscriberID != data.SubscriberID {
+			ctx.String(http.StatusNotFound, "Package not found for this subscriber")
+			return
+		}
+
+		if pkg.TrainerID != nil && *pkg.TrainerID != data.TrainerID {
+			ctx.String(http.StatusConflict, "Package is for a different trainer")
+			return
+		}
+
+		if pkg.ExpiresAt != "" && startsAt.Format(common.DateLayout) > pkg.ExpiresAt {
+			ctx.String(http.StatusConflict, "Package expires before the session")
+			return
+		}
+
+		if minutes == 0 {
+			minutes = pkg.SessionMinutes
+		}
+	}
+
+	if minutes == 0 {
+		minutes = common.PTSessionMinutes
+	}
+
+	endsAt := startsAt.Add(time.Duration(minutes) * time.Minute)
+
+	windows, queryErr := db.GetTrainerAvailability(db.DB, data.TrainerID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer availability: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !withinAvailability(windows, startsAt, endsAt) {
+		ctx.String(http.StatusConflict, "Trainer is not available at that time")
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, bookErr := db.CreatePTSession(db.DB, db.PTSession{
+		TrainerID:    data.TrainerID,
+		SubscriberID: data.SubscriberID,
+		PackageID:    data.PackageID,
+		BookedByID:   userPtr.(*db.User).ID,
+		StartsAt:     startsAt.Format(common.DateTimeLayout),
+		EndsAt:       endsAt.Format(common.DateTimeLayout),
+		Notes:        data.Notes,
+	})
+
+	switch {
+	case errors.Is(bookErr, db.ErrPTTrainerBlocked),
+		errors.Is(bookErr, db.ErrPTTrainerBusy),
+		errors.Is(bookErr, db.ErrPTSubscriberBusy),
+		errors.Is(bookErr, db.ErrPTPackageExhausted):
+		ctx.String(http.StatusConflict, "Conflict: %v", bookErr)
+		return
+	case bookErr != nil:
+		common.Logger.Printf("failed to book a PT session: %v", bookErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+// Closes a booked session, responding 404 when it doesn't exist and 409 when it's already closed
+func closePTSession(ctx *gin.Context, status string, charge common.Money, consume bool) bool {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return false
+	}
+
+	session, queryErr := db.GetPTSessionByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a PT session: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	if session == nil {
+		ctx.Status(http.StatusNotFound)
+		return false
+	}
+
+	closed, queryErr := db.ClosePTSession(db.DB, id, status, charge, consume)
+	if queryErr != nil {
+		common.Logger.Printf("failed to close a PT session: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	if !closed {
+		ctx.String(http.StatusConflict, "Session is not booked")
+		return false
+	}
+
+	return true
+}
+
+// Uses up a session of the package, if any
+func CompletePTSession(ctx *gin.Context) {
+	if closePTSession(ctx, db.PTSessionCompleted, 0, true) {
+		ctx.Status(http.StatusOK)
+	}
+}
+
+// Charged and counted like a late cancellation
+func MarkPTSessionNoShow(ctx *gin.Context) {
+	if closePTSession(ctx, db.PTSessionNoShow, common.PTLateCancelFee, common.PTLateCancelConsumesSession) {
+		ctx.Status(http.StatusOK)
+	}
+}
+
+// Records the payment of a late cancellation or no-show charge in the income ledger
+func PayPTSessionCharge(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.PayPTSessionCharge_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	payment := db.Payment{Method: paymentMethodOrDefault(data.Method)}
+
+	if userPtr, exists := ctx.Get("user"); exists {
+		payment.CreatedByID = &userPtr.(*db.User).ID
+	}
+
+	found, queryErr := db.PayPTSessionCharge(db.DB, id, payment)
+	if errors.Is(queryErr, db.ErrPTChargeNotOwed) {
+		ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+		return
+	}
+
+	if queryErr != nil {
+		common.Logger.Printf("failed to pay a PT session charge: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Cancelling later than PT_LATE_CANCEL_HOURS before the session charges
+// PT_LATE_CANCEL_FEE and, if PT_LATE_CANCEL_CONSUMES_SESSION is set, uses up a package session.
+func CancelPTSession(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	session, queryErr := db.GetPTSessionByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a PT session: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if session == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	startsAt, parseErr := time.ParseInLocation(common.DateTimeLayout, session.StartsAt, time.Local)
+	if parseErr != nil {
+		common.Logger.Printf("failed to parse PT session start (id: %d): %v", id, parseErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	res := dto.CancelPTSession_Res{
+		Late: time.Now().After(startsAt.Add(-time.Duration(common.PTLateCancelHours) * time.Hour)),
+	}
+
+	status := db.PTSessionCancelled
+
+	if res.Late {
+		status = db.PTSessionLateCancelled
+		res.Charge = common.PTLateCancelFee
+		res.SessionConsumed = common.PTLateCancelConsumesSession && session.PackageID != nil
+	}
+
+	if closePTSession(ctx, status, res.Charge, res.SessionConsumed) {
+		ctx.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,381 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+var reportBuckets = map[string]bool{"day": true, "week": true, "month": true}
+
+var incomeGroups = map[string]bool{"source": true, "method": true, "plan": true}
+
+// The longest range a report covers in each bucket size, in years, as every bucket is computed
+var reportMaxYears = map[string]int{"day": 3, "week": 10, "month": 30}
+
+// Like dateRangeOrAbort, with the query parameter 'bucket' (day, week or month, day by default).
+// Responds with 400 if the range is longer than the bucket size allows.
+func reportRangeOrAbort(ctx *gin.Context) (string, string, string, bool) {
+	from, to, ok := dateRangeOrAbort(ctx)
+	if !ok {
+		return "", "", "", false
+	}
+
+	bucket := ctx.DefaultQuery("bucket", "day")
+	if !reportBuckets[bucket] {
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: bucket (must be one of 'day', 'week' or 'month')")
+		return "", "", "", false
+	}
+
+	start, _ := time.Parse(common.DateLayout, from)
+	end, _ := time.Parse(common.DateLayout, to)
+
+	if start.AddDate(reportMaxYears[bucket], 0, 0).Before(end) {
+		ctx.String(http.StatusBadRequest, "Range is too long (max is %d years with %s buckets)", reportMaxYears[bucket], bucket)
+		return "", "", "", false
+	}
+
+	return from, to, bucket, true
+}
+
+// The bucket a day falls in, formatted the same way the database buckets are
+func bucketOf(day time.Time, bucket string) string {
+	switch bucket {
+	case "week":
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7)).Format(common.DateLayout)
+	case "month":
+		return day.Format(periodLayout)
+	}
+
+	return day.Format(common.DateLayout)
+}
+
+// Start of every bucket overlapping the days from 'start' to 'end', both inclusive
+func bucketStarts(start, end time.Time, bucket string) []time.Time {
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.Local)
+
+	switch bucket {
+	case "week":
+		first = first.AddDate(0, 0, -((int(first.Weekday()) + 6) % 7))
+	case "month":
+		first = first.AddDate(0, 0, 1-first.Day())
+	}
+
+	starts := []time.Time{}
+
+	for t := first; !t.After(end); t = nextBucket(t, bucket) {
+		starts = append(starts, t)
+	}
+
+	return starts
+}
+
+func nextBucket(start time.Time, bucket string) time.Time {
+	switch bucket {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	}
+
+	return start.AddDate(0, 0, 1)
+}
+
+// Salary costs per bucket, spreading each month's payroll evenly over its days.
+// Months that have been run use the run's total. The current month is estimated with computePayroll
+// until it's run; other months without a run cost nothing, as today's staff and salaries don't apply to them.
+func salaryBuckets(from, to, bucket string) (map[string]common.Money, error) {
+	start, startErr := time.ParseInLocation(common.DateLayout, from, time.Local)
+	if startErr != nil {
+		return nil, startErr
+	}
+
+	end, endErr := time.ParseInLocation(common.DateLayout, to, time.Local)
+	if endErr != nil {
+		return nil, endErr
+	}
+
+	runs, queryErr := db.GetPayrollRunTotals(db.DB, start.Format(periodLayout), end.Format(periodLayout))
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	monthly := map[string]common.Money{}
+	buckets := map[string]common.Money{}
+	current := time.Now().Format(periodLayout)
+
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		period := day.Format(periodLayout)
+
+		total, found := monthly[period]
+		if !found {
+			if runTotal, ran := runs[period]; ran {
+				total = runTotal
+			} else if period == current {
+				_, estimate, computeErr := computePayroll(period)
+				if computeErr != nil {
+					return nil, computeErr
+				}
+
+				total = estimate
+			}
+
+			monthly[period] = total
+		}
+
+		// Each day gets the difference of the month's running shares, so the days add up to the total exactly
+		daysInMonth := common.Money(time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.Local).Day())
+		dayOfMonth := common.Money(day.Day())
+
+		buckets[bucketOf(day, bucket)] += total*dayOfMonth/daysInMonth - total*(dayOfMonth-1)/daysInMonth
+	}
+
+	return buckets, nil
+}
+
+// Groups the amounts by period, ordered by period
+func toReportBuckets(amounts []db.AmountBucket) []dto.ReportBucket_Res {
+	byPeriod := map[string]*dto.ReportBucket_Res{}
+	periods := []string{}
+
+	for _, amount := range amounts {
+		bucket, found := byPeriod[amount.Period]
+		if !found {
+			bucket = &dto.ReportBucket_Res{Period: amount.Period, Breakdown: map[string]common.Money{}}
+			byPeriod[amount.Period] = bucket
+			periods = append(periods, amount.Period)
+		}
+
+		bucket.Breakdown[amount.Key] += amount.Amount
+		bucket.Total += amount.Amount
+	}
+
+	sort.Strings(periods)
+
+	res := []dto.ReportBucket_Res{}
+	for _, period := range periods {
+		bucket := byPeriod[period]
+		bucket.FormattedTotal = common.FormatMoney(bucket.Total)
+
+		res = append(res, *bucket)
+	}
+
+	return res
+}
+
+// Expense buckets including salaries
+func expenseAmounts(from, to, bucket string) ([]db.AmountBucket, error) {
+	amounts, queryErr := db.GetExpenseBuckets(db.DB, from, to, bucket)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	salaries, salaryErr := salaryBuckets(from, to, bucket)
+	if salaryErr != nil {
+		return nil, salaryErr
+	}
+
+	for period, amount := range salaries {
+		if amount != 0 {
+			amounts = append(amounts, db.AmountBucket{Period: period, Key: "salaries", Amount: amount})
+		}
+	}
+
+	return amounts, nil
+}
+
+// Income per bucket, broken down by the query parameter 'groupBy' (source, method or plan, source by default)
+func GetIncomeReport(ctx *gin.Context) {
+	from, to, bucket, ok := reportRangeOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	group := ctx.DefaultQuery("groupBy", "source")
+	if !incomeGroups[group] {
+		ctx.String(http.StatusBadRequest, "Invalid query parameter: groupBy (must be one of 'source', 'method' or 'plan')")
+		return
+	}
+
+	amounts, queryErr := db.GetIncomeBuckets(db.DB, from, to, bucket, group)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get income report: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toReportBuckets(amounts))
+}
+
+// Expenses per bucket, broken down by category, salaries included
+func GetExpenseReport(ctx *gin.Context) {
+	from, to, bucket, ok := reportRangeOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	amounts, queryErr := expenseAmounts(from, to, bucket)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get expense report: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, toReportBuckets(amounts))
+}
+
+func GetProfitLossReport(ctx *gin.Context) {
+	from, to, bucket, ok := reportRangeOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	income, queryErr := db.GetIncomeBuckets(db.DB, from, to, bucket, "source")
+	if queryErr != nil {
+		common.Logger.Printf("failed to get income for profit and loss: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	expenses, queryErr := expenseAmounts(from, to, bucket)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get expenses for profit and loss: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	res := dto.ProfitLoss_Res{
+		From:               from,
+		To:                 to,
+		IncomeBySource:     map[string]common.Money{},
+		ExpensesByCategory: map[string]common.Money{},
+		Buckets:            []dto.ProfitLossBucket_Res{},
+	}
+
+	byPeriod := map[string]*dto.ProfitLossBucket_Res{}
+	periods := []string{}
+
+	bucketFor := func(period string) *dto.ProfitLossBucket_Res {
+		pl, found := byPeriod[period]
+		if !found {
+			pl = &dto.ProfitLossBucket_Res{Period: period}
+			byPeriod[period] = pl
+			periods = append(periods, period)
+		}
+
+		return pl
+	}
+
+	for _, amount := range income {
+		res.Income += amount.Amount
+		res.IncomeBySource[amount.Key] += amount.Amount
+		bucketFor(amount.Period).Income += amount.Amount
+	}
+
+	for _, amount := range expenses {
+		res.Expenses += amount.Amount
+		res.ExpensesByCategory[amount.Key] += amount.Amount
+		bucketFor(amount.Period).Expenses += amount.Amount
+	}
+
+	sort.Strings(periods)
+
+	for _, period := range periods {
+		pl := byPeriod[period]
+
+		res.Buckets = append(res.Buckets, dto.ProfitLossBucket_Res{
+			Period:   period,
+			Income:   pl.Income,
+			Expenses: pl.Expenses,
+			Profit:   pl.Income - pl.Expenses,
+
+			FormattedProfit: common.FormatMoney(pl.Income - pl.Expenses),
+		})
+	}
+
+	res.Profit = res.Income - res.Expenses
+	res.Currency = common.Currency
+	res.FormattedIncome = common.FormatMoney(res.Income)
+	res.FormattedExpenses = common.FormatMoney(res.Expenses)
+	res.FormattedProfit = common.FormatMoney(res.Profit)
+
+	ctx.JSON(http.StatusOK, res)
+}
+
+func newKPI(value, previous float64) dto.KPI_Res {
+	kpi := dto.KPI_Res{
+		Value:    roundTo(value, 2),
+		Previous: roundTo(previous, 2),
+	}
+
+	if previous != 0 {
+		change := roundTo((value-previous)/previous*100, 2)
+		kpi.Change = &change
+	}
+
+	return kpi
+}
+
+func GetKPIs(ctx *gin.Context) {
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	// The same point of the previous month, clamped to its last day
+	previousStart := monthStart.AddDate(0, -1, 0)
+	previousAt := previousStart.Add(now.Sub(monthStart))
+	if !previousAt.Before(monthStart) {
+		previousAt = monthStart.Add(-time.Second)
+	}
+
+	current, previous, queryErr := db.GetKPIs(db.DB,
+		db.KPIWindow{Start: monthStart.Format(common.DateTimeLayout), At: now.Format(common.DateTimeLayout)},
+		db.KPIWindow{Start: previousStart.Format(common.DateTimeLayout), At: previousAt.Format(common.DateTimeLayout)},
+	)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get KPIs: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	perMember := func(snapshot db.KPISnapshot) float64 {
+		if snapshot.ActiveMembers == 0 {
+			return 0
+		}
+
+		return snapshot.MonthlyRecurringRevenue.Float() / float64(snapshot.ActiveMembers)
+	}
+
+	retention := func(snapshot db.KPISnapshot) float64 {
+		if snapshot.RetentionBase == 0 {
+			return 0
+		}
+
+		return float64(snapshot.Retained) / float64(snapshot.RetentionBase) * 100
+	}
+
+	ctx.JSON(http.StatusOK, dto.KPIs_Res{
+		PeriodStart:             monthStart.Format(common.DateLayout),
+		PreviousPeriodStart:     previousStart.Format(common.DateLayout),
+		ActiveMembers:           newKPI(float64(current.ActiveMembers), float64(previous.ActiveMembers)),
+		NewMembers:              newKPI(float64(current.NewMembers), float64(previous.NewMembers)),
+		ExpiringIn7Days:         newKPI(float64(current.ExpiringSoon), float64(previous.ExpiringSoon)),
+		MonthlyRecurringRevenue: newKPI(current.MonthlyRecurringRevenue.Float(), previous.MonthlyRecurringRevenue.Float()),
+		AverageRevenuePerMember: newKPI(perMember(current), perMember(previous)),
+		RetentionRate:           newKPI(retention(current), retention(previous)),
+		Income:                  newKPI(current.Income.Float(), previous.Income.Float()),
+	})
+}
+
+// The gym's currency settings, for clients formatting amounts themselves
+func GetCurrency(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dto.Currency_Res{
+		Code:    common.Currency,
+		Locale:  common.CurrencyLocale,
+		Example: common.FormatMoney(common.MoneyFromFloat(1234.5)),
+	})
+}
@@ -0,0 +1,365 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Responds with 404 and returns false if the user does not exist
+func userExistsOrAbort(ctx *gin.Context, id int64) bool {
+	user, queryErr := db.GetUserByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get user by ID: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	if user == nil {
+		ctx.String(http.StatusNotFound, "User not found")
+		return false
+	}
+
+	return true
+}
+
+// Query parameters 'from' and 'to' default to the current week, 'userId' to all users
+func GetShifts(ctx *gin.Context) {
+	userID, _ := strconv.ParseInt(ctx.Query("userId"), 10, 64)
+
+	now := time.Now()
+	monday := now.AddDate(0, 0, -((int(now.Weekday()) + 6) % 7))
+
+	from := ctx.DefaultQuery("from", monday.Format(common.DateLayout))
+	to := ctx.DefaultQuery("to", monday.AddDate(0, 0, 7).Format(common.DateLayout))
+
+	shifts, queryErr := db.GetShifts(db.DB, userID, from, to)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get shifts: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, shifts)
+}
+
+// Upcoming shifts of the logged-in user
+func GetMyShifts(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	now := time.Now()
+
+	shifts, queryErr := db.GetShifts(db.DB, userPtr.(*db.User).ID, now.Format(common.DateTimeLayout), now.AddDate(0, 0, 28).Format(common.DateTimeLayout))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get shifts: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, shifts)
+}
+
+func CreateShift(ctx *gin.Context) {
+	data := dto.Shift_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if data.EndsAt <= data.StartsAt {
+		ctx.String(http.StatusBadRequest, "Shift ends before it starts")
+		return
+	}
+
+	if !userExistsOrAbort(ctx, data.UserID) {
+		return
+	}
+
+	id, queryErr := db.CreateShift(db.DB, db.Shift{
+		UserID:   data.UserID,
+		StartsAt: data.StartsAt,
+		EndsAt:   data.EndsAt,
+		Notes:    data.Notes,
+	})
+
+	switch {
+	case errors.Is(queryErr, db.ErrShiftOverlap):
+		ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+		return
+	case queryErr != nil:
+		common.Logger.Printf("failed to create a shift: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func UpdateShift(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.Shift_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if data.EndsAt <= data.StartsAt {
+		ctx.String(http.StatusBadRequest, "Shift ends before it starts")
+		return
+	}
+
+	existing, queryErr := db.GetShiftByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a shift: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if !userExistsOrAbort(ctx, data.UserID) {
+		return
+	}
+
+	updateErr := db.UpdateShift(db.DB, db.Shift{
+		ID:       id,
+		UserID:   data.UserID,
+		StartsAt: data.StartsAt,
+		EndsAt:   data.EndsAt,
+		Notes:    data.Notes,
+	})
+
+	switch {
+	case errors.Is(updateErr, db.ErrShiftOverlap):
+		ctx.String(http.StatusConflict, "Conflict: %v", updateErr)
+		return
+	case updateErr != nil:
+		common.Logger.Printf("failed to update a shift: %v", updateErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeleteShift(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeleteShiftByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a shift: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func ClockIn(ctx *gin.Context) {
+	data := dto.ClockIn_Req{}
+
+	// The body is optional
+	if ctx.Request.ContentLength > 0 {
+		bindErr := ctx.ShouldBindJSON(&data)
+		if bindErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+			return
+		}
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, queryErr := db.ClockIn(db.DB, userPtr.(*db.User).ID, time.Now().Format(common.DateTimeLayout), data.Notes)
+
+	switch {
+	case errors.Is(queryErr, db.ErrAlreadyClockedIn):
+		ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+		return
+	case queryErr != nil:
+		common.Logger.Printf("failed to clock in: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func ClockOut(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	clockedOut, queryErr := db.ClockOut(db.DB, userPtr.(*db.User).ID, time.Now().Format(common.DateTimeLayout))
+	if queryErr != nil {
+		common.Logger.Printf("failed to clock out: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !clockedOut {
+		ctx.String(http.StatusConflict, "You are not clocked in")
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Responds with the open time entry of the logged-in user, or null if they're not clocked in
+func GetClockStatus(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	entry, queryErr := db.GetOpenTimeEntry(db.DB, userPtr.(*db.User).ID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get open time entry: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entry)
+}
+
+// Query parameters 'from' and 'to' default to the current month.
+// Admins may pass 'userId' (all users by default), everyone else only sees their own entries.
+func GetTimeEntries(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	user := userPtr.(*db.User)
+
+	userID := user.ID
+	if user.Permission == 1 {
+		userID, _ = strconv.ParseInt(ctx.Query("userId"), 10, 64)
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	from := ctx.DefaultQuery("from", monthStart.Format(common.DateLayout))
+	to := ctx.DefaultQuery("to", monthStart.AddDate(0, 1, 0).Format(common.DateLayout))
+
+	entries, queryErr := db.GetTimeEntries(db.DB, userID, from, to)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get time entries: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, entries)
+}
+
+func UpdateTimeEntry(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.TimeEntry_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if data.ClockOut != "" && data.ClockOut <= data.ClockIn {
+		ctx.String(http.StatusBadRequest, "Clock out is before clock in")
+		return
+	}
+
+	existing, queryErr := db.GetTimeEntryByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a time entry: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if data.ClockOut == "" && existing.ClockOut != "" {
+		open, queryErr := db.GetOpenTimeEntry(db.DB, existing.UserID)
+		if queryErr != nil {
+			common.Logger.Printf("failed to get open time entry: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if open != nil {
+			ctx.String(http.StatusConflict, "User already has an open time entry")
+			return
+		}
+	}
+
+	updateErr := db.UpdateTimeEntry(db.DB, db.TimeEntry{
+		ID:       id,
+		ClockIn:  data.ClockIn,
+		ClockOut: data.ClockOut,
+		Notes:    data.Notes,
+	})
+	if updateErr != nil {
+		common.Logger.Printf("failed to update a time entry: %v", updateErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeleteTimeEntry(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeleteTimeEntryByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a time entry: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
@@ -1,7 +1,12 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -10,6 +15,20 @@ import (
 	"github.com/HenryMarkle/gmserver/dto"
 )
 
+const trainerPhotosFolder = "trainers"
+
+func trimSpecialties(specialties []string) []string {
+	trimmed := []string{}
+
+	for _, name := range specialties {
+		if name = strings.TrimSpace(name); name != "" {
+			trimmed = append(trimmed, name)
+		}
+	}
+
+	return trimmed
+}
+
 func GetTrainers(ctx *gin.Context) {
 	trainers, queryErr := db.GetAllTrainers(db.DB)
 	if queryErr != nil {
@@ -18,6 +37,11 @@ func GetTrainers(ctx *gin.Context) {
 		return
 	}
 
+	// The linked staff accounts are internal
+	for i := range trainers {
+		trainers[i].UserID = nil
+	}
+
 	ctx.JSON(http.StatusOK, trainers)
 }
 
@@ -37,6 +61,9 @@ func CreateTrainer(ctx *gin.Context) {
 		Instigram:   data.Facebook,
 		Facebook:    data.Facebook,
 		Twitter:     data.Twitter,
+
+		DisplayOrder: data.DisplayOrder,
+		Specialties:  trimSpecialties(data.Specialties),
 	})
 
 	if queryErr != nil {
@@ -65,6 +92,9 @@ func ReplaceTrainerById(ctx *gin.Context) {
 		Facebook:    data.Facebook,
 		Twitter:     data.Twitter,
 		ID:          data.ID,
+
+		DisplayOrder: data.DisplayOrder,
+		Specialties:  trimSpecialties(data.Specialties),
 	})
 	if queryErr != nil {
 		common.Logger.Printf("Failed to update a trainer: %v\n", queryErr)
@@ -91,5 +121,525 @@ func DeleteTrainerById(ctx *gin.Context) {
 		return
 	}
 
+	deleteErr := deleteStoredImage(trainerPhotosFolder, id)
+	if deleteErr != nil {
+		common.Logger.Printf("failed to delete trainer photo (id: %d): %v", id, deleteErr)
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Returns the trainer the logged-in user is limited to, or nil if they can see every subscriber.
+// Admins and staff without a trainer profile are not limited.
+func scopedTrainerOrAbort(ctx *gin.Context) (*db.Trainer, bool) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return nil, false
+	}
+
+	user := userPtr.(*db.User)
+
+	if user.Permission == 1 {
+		return nil, true
+	}
+
+	trainer, queryErr := db.GetTrainerByUserID(db.DB, user.ID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer by user ID: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return trainer, true
+}
+
+// Responds with 403 and returns false if the logged-in user is a trainer
+// and the subscriber is not assigned to them
+func canAccessSubscriberOrAbort(ctx *gin.Context, subscriberID int64) bool {
+	trainer, ok := scopedTrainerOrAbort(ctx)
+	if !ok {
+		return false
+	}
+
+	if trainer == nil {
+		return true
+	}
+
+	assigned, queryErr := db.IsTrainerClient(db.DB, trainer.ID, subscriberID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to check trainer client: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	if !assigned {
+		ctx.String(http.StatusForbidden, "Subscriber is not assigned to you")
+		return false
+	}
+
+	return true
+}
+
+func LinkTrainerUser(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.LinkTrainerUser_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !trainerExistsOrAbort(ctx, id) {
+		return
+	}
+
+	if data.UserID != nil {
+		user, queryErr := db.GetUserByID(db.DB, *data.UserID)
+		if queryErr != nil {
+			common.Logger.Printf("failed to get user by ID: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if user == nil {
+			ctx.String(http.StatusNotFound, "User not found")
+			return
+		}
+
+		linked, queryErr := db.GetTrainerByUserID(db.DB, *data.UserID)
+		if queryErr != nil {
+			common.Logger.Printf("failed to get trainer by user ID: %v", queryErr)
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+
+		if linked != nil && linked.ID != id {
+			ctx.String(http.StatusConflict, "User is already linked to another trainer")
+			return
+		}
+	}
+
+	queryErr := db.SetTrainerUser(db.DB, id, data.UserID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to link trainer user: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func GetTrainerClients(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	clients, queryErr := db.GetSubscribersFiltered(db.DB, db.SubscriberFilter{TrainerID: id})
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer clients: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, clients)
+}
+
+func AssignTrainerClient(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.AssignTrainerClient_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !trainerExistsOrAbort(ctx, id) || !subscriberExistsOrAbort(ctx, data.SubscriberID) {
+		return
+	}
+
+	queryErr := db.AssignTrainerClient(db.DB, id, data.SubscriberID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to assign trainer client: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func UnassignTrainerClient(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	subscriberID, convErr := strconv.ParseInt(ctx.Params.ByName("subscriberId"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: subscriberId")
+		return
+	}
+
+	queryErr := db.UnassignTrainerClient(db.DB, id, subscriberID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to unassign trainer client: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Lists the subscribers assigned to the trainer linked to the logged-in user
+func GetMyClients(ctx *gin.Context) {
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	trainer, queryErr := db.GetTrainerByUserID(db.DB, userPtr.(*db.User).ID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer by user ID: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if trainer == nil {
+		ctx.String(http.StatusNotFound, "No trainer profile is linked to your account")
+		return
+	}
+
+	clients, queryErr := db.GetSubscribersFiltered(db.DB, db.SubscriberFilter{
+		TrainerID: trainer.ID,
+		Search:    ctx.Query("search"),
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer clients: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, clients)
+}
+
+// Public profile, lists only certifications that haven't expired
+func GetTrainerProfile(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	trainer, queryErr := db.GetTrainerByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer by ID: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if trainer == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	certs, queryErr := db.GetTrainerCertifications(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer certifications: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	today := time.Now().Format(common.DateLayout)
+	trainer.Certifications = []db.TrainerCertification{}
+
+	for _, cert := range certs {
+		if cert.ExpiresAt == "" || cert.ExpiresAt >= today {
+			trainer.Certifications = append(trainer.Certifications, cert)
+		}
+	}
+
+	// The linked staff account is internal
+	trainer.UserID = nil
+
+	ctx.JSON(http.StatusOK, trainer)
+}
+
+func ReorderTrainers(ctx *gin.Context) {
+	data := dto.ReorderTrainers_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	queryErr := db.ReorderTrainers(db.DB, data.IDs)
+	if queryErr != nil {
+		common.Logger.Printf("failed to reorder trainers: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func UploadTrainerPhoto(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !trainerExistsOrAbort(ctx, id) {
+		return
+	}
+
+	if !saveUploadedImage(ctx, trainerPhotosFolder, id) {
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func GetTrainerPhoto(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	serveStoredImage(ctx, trainerPhotosFolder, id)
+}
+
+func DeleteTrainerPhoto(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	deleteErr := deleteStoredImage(trainerPhotosFolder, id)
+	if deleteErr != nil {
+		common.Logger.Printf("failed to delete trainer photo (id: %d): %v", id, deleteErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Responds with 400 and returns false if the dates are malformed or out of order
+func validCertificationDatesOrAbort(ctx *gin.Context, data *dto.TrainerCertification_Req) bool {
+	for name, value := range map[string]string{"issuedAt": data.IssuedAt, "expiresAt": data.ExpiresAt} {
+		if value == "" {
+			continue
+		}
+
+		if _, parseErr := time.Parse(common.DateLayout, value); parseErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid %s: expected format %s", name, common.DateLayout)
+			return false
+		}
+	}
+
+	if data.IssuedAt != "" && data.ExpiresAt != "" && data.ExpiresAt < data.IssuedAt {
+		ctx.String(http.StatusBadRequest, "expiresAt must not be before issuedAt")
+		return false
+	}
+
+	return true
+}
+
+func GetTrainerCertifications(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	certs, queryErr := db.GetTrainerCertifications(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trainer certifications: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, certs)
+}
+
+func CreateTrainerCertification(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.TrainerCertification_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !validCertificationDatesOrAbort(ctx, &data) || !trainerExistsOrAbort(ctx, id) {
+		return
+	}
+
+	certID, queryErr := db.CreateTrainerCertification(db.DB, db.TrainerCertification{
+		TrainerID: id,
+		Name:      data.Name,
+		Issuer:    data.Issuer,
+		IssuedAt:  data.IssuedAt,
+		ExpiresAt: data.ExpiresAt,
+	})
+	if queryErr != nil {
+		common.Logger.Printf("failed to create a trainer certification: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, certID)
+}
+
+func UpdateTrainerCertification(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.TrainerCertification_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !validCertificationDatesOrAbort(ctx, &data) {
+		return
+	}
+
+	existing, queryErr := db.GetTrainerCertificationByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a trainer certification: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	updateErr := db.UpdateTrainerCertification(db.DB, db.TrainerCertification{
+		ID:        id,
+		Name:      data.Name,
+		Issuer:    data.Issuer,
+		IssuedAt:  data.IssuedAt,
+		ExpiresAt: data.ExpiresAt,
+	})
+	if updateErr != nil {
+		common.Logger.Printf("failed to update a trainer certification: %v", updateErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
 	ctx.Status(http.StatusOK)
 }
+
+func DeleteTrainerCertification(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.DeleteTrainerCertificationByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a trainer certification: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Announces certifications expiring within CERTIFICATION_REMINDER_DAYS to admins
+// and to the trainer's own account, once per certification
+func sendCertificationReminders() {
+	before := time.Now().AddDate(0, 0, common.CertificationReminderDays).Format(common.DateLayout)
+
+	certs, queryErr := db.GetCertificationsDueForReminder(db.DB, before)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get certifications due for reminder: %v", queryErr)
+		return
+	}
+
+	if len(certs) == 0 {
+		return
+	}
+
+	admins, queryErr := db.GetAdminUserIDs(db.DB)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get admin user IDs: %v", queryErr)
+		return
+	}
+
+	for _, cert := range certs {
+		trainer, queryErr := db.GetTrainerByID(db.DB, cert.TrainerID)
+		if queryErr != nil || trainer == nil {
+			common.Logger.Printf("failed to get trainer of certification (id: %d): %v", cert.ID, queryErr)
+			continue
+		}
+
+		recipients := admins
+		if trainer.UserID != nil && !slices.Contains(admins, *trainer.UserID) {
+			recipients = append([]int64{*trainer.UserID}, admins...)
+		}
+
+		if len(recipients) == 0 {
+			continue
+		}
+
+		text := fmt.Sprintf("%s's certification '%s' expires on %s", trainer.Name, cert.Name, cert.ExpiresAt)
+
+		if _, annErr := db.CreateAnnouncementToUserIDs(db.DB, text, recipients...); annErr != nil {
+			common.Logger.Printf("failed to announce certification expiry (id: %d): %v", cert.ID, annErr)
+			continue
+		}
+
+		if markErr := db.MarkCertificationReminderSent(db.DB, cert.ID); markErr != nil {
+			common.Logger.Printf("%v", markErr)
+		}
+	}
+}
+
+// Checks for expiring certifications every interval.
+// Blocks, so it's meant to be started in its own goroutine.
+func RunCertificationReminders(interval time.Duration) {
+	sendCertificationReminders()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		sendCertificationReminders()
+	}
+}
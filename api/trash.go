@@ -0,0 +1,181 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+func GetTrash(ctx *gin.Context) {
+	entity := ctx.Params.ByName("entity")
+
+	var (
+		items    interface{}
+		queryErr error
+	)
+
+	switch entity {
+	case "customers":
+		items, queryErr = db.GetSubscribersFiltered(db.DB, db.SubscriberFilter{Deleted: true})
+	case "plans":
+		items, queryErr = db.GetDeletedPlans(db.DB)
+	case "products":
+		items, queryErr = db.GetDeletedProducts(db.DB)
+	case "comments":
+		items, queryErr = db.GetDeletedComments(db.DB)
+	case "users":
+		var users []db.User
+		users, queryErr = db.GetDeletedUsers(db.DB)
+
+		dtoUsers := make([]dto.DeletedUser_Res, 0, len(users))
+		for _, user := range users {
+			dtoUsers = append(dtoUsers, dto.DeletedUser_Res{
+				User_Res: dto.User_Res{
+					ID:         user.ID,
+					Email:      user.Email,
+					Name:       user.Name,
+					Gender:     user.Gender,
+					StartDate:  user.StartDate,
+					Permission: user.Permission,
+					Salary:     user.Salary,
+					Age:        user.Age,
+				},
+				DeletedAt: user.DeletedAt,
+			})
+		}
+
+		items = dtoUsers
+	default:
+		ctx.String(http.StatusNotFound, "Unknown trash: %s", entity)
+		return
+	}
+
+	if queryErr != nil {
+		common.Logger.Printf("failed to get trash (%s): %v", entity, queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, items)
+}
+
+func RestoreTrashItem(ctx *gin.Context) {
+	entity := ctx.Params.ByName("entity")
+	if !db.IsTrashEntity(entity) {
+		ctx.String(http.StatusNotFound, "Unknown trash: %s", entity)
+		return
+	}
+
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	restored, queryErr := db.RestoreDeleted(db.DB, entity, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to restore from trash: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !restored {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func PurgeTrashItem(ctx *gin.Context) {
+	entity := ctx.Params.ByName("entity")
+	if !db.IsTrashEntity(entity) {
+		ctx.String(http.StatusNotFound, "Unknown trash: %s", entity)
+		return
+	}
+
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	purged, queryErr := db.PurgeDeletedByID(db.DB, entity, id)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrNotPurgeable) {
+			ctx.String(http.StatusConflict, "Conflict: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to purge from trash: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !purged {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if entity == "customers" {
+		deleteSubscriberFiles(id)
+	}
+
+	if entity == "products" {
+		deleteProductImageFiles(id)
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func purgeExpiredTrash() {
+	before := time.Now().AddDate(0, 0, -common.TrashRetentionDays).Format(common.DateTimeLayout)
+
+	for _, entity := range db.TrashEntities {
+		purged, purgeErr := db.PurgeDeletedBefore(db.DB, entity, before)
+		if purgeErr != nil {
+			common.Logger.Printf("failed to purge expired trash (%s): %v", entity, purgeErr)
+			continue
+		}
+
+		if entity == "customers" {
+			for _, id := range purged {
+				deleteSubscriberFiles(id)
+			}
+		}
+
+		if entity == "products" {
+			for _, id := range purged {
+				deleteProductImageFiles(id)
+			}
+		}
+
+		if len(purged) > 0 {
+			common.Logger.Printf("purged %d expired %s from trash", len(purged), entity)
+		}
+	}
+}
+
+// Permanently deletes records soft-deleted longer than TRASH_RETENTION_DAYS, checking every interval.
+// Blocks, so it's meant to be started in its own goroutine.
+func RunTrashPurge(interval time.Duration) {
+	if common.TrashRetentionDays <= 0 {
+		common.Logger.Println("TRASH_RETENTION_DAYS is not positive, trash will not be purged")
+		return
+	}
+
+	purgeExpiredTrash()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purgeExpiredTrash()
+	}
+}
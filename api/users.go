@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/HenryMarkle/gmserver/common"
 	"github.com/HenryMarkle/gmserver/db"
@@ -58,10 +59,11 @@ func GetCurrentUserId(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, userPtr.ID)
 }
 
+// Total payroll of the current month, including hourly pay, overtime and adjustments
 func GetTotalSalaries(ctx *gin.Context) {
-	sum, queryErr := db.GetTotalSalaries(db.DB)
-	if queryErr != nil {
-		common.Logger.Printf("Failed to get total salaries: %v\n", queryErr)
+	_, sum, computeErr := computePayroll(time.Now().Format(periodLayout))
+	if computeErr != nil {
+		common.Logger.Printf("Failed to get total salaries: %v\n", computeErr)
 		ctx.AbortWithStatus(500)
 		return
 	}
@@ -177,8 +179,129 @@ func GetUserByEmail(ctx *gin.Context) {
 		Age:        user.Age,
 	})
 }
-func GetUsersLeftChartData(ctx *gin.Context)    {}
-func GetUsersCreatedChartData(ctx *gin.Context) {}
+
+type membershipSpan struct {
+	createdAt time.Time
+	leftAt    time.Time
+}
+
+// Memberships overlapping the report range, with the buckets of the range
+func membershipsOrAbort(ctx *gin.Context) ([]membershipSpan, []time.Time, string, bool) {
+	from, to, bucket, ok := reportRangeOrAbort(ctx)
+	if !ok {
+		return nil, nil, "", false
+	}
+
+	start, _ := time.ParseInLocation(common.DateLayout, from, time.Local)
+	end, _ := time.ParseInLocation(common.DateLayout, to, time.Local)
+
+	starts := bucketStarts(start, end, bucket)
+
+	rows, queryErr := db.GetMembershipSpans(db.DB, starts[0].Format(common.DateTimeLayout), nextBucket(starts[len(starts)-1], bucket).Format(common.DateTimeLayout))
+	if queryErr != nil {
+		common.Logger.Printf("Failed to get membership spans: %v\n", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return nil, nil, "", false
+	}
+
+	spans := []membershipSpan{}
+
+	for _, row := range rows {
+		createdAt, createdErr := time.ParseInLocation(common.DateTimeLayout, row.CreatedAt, time.Local)
+		leftAt, leftErr := time.ParseInLocation(common.DateTimeLayout, row.LeftAt, time.Local)
+		if createdErr != nil || leftErr != nil {
+			common.Logger.Printf("Skipping membership with invalid dates (%s, %s)\n", row.CreatedAt, row.LeftAt)
+			continue
+		}
+
+		spans = append(spans, membershipSpan{createdAt: createdAt, leftAt: leftAt})
+	}
+
+	return spans, starts, bucket, true
+}
+
+// Members that joined at or before t and had not left by then
+func activeAt(spans []membershipSpan, t time.Time) int {
+	count := 0
+
+	for _, span := range spans {
+		if !span.createdAt.After(t) && span.leftAt.After(t) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Members who left per bucket and the churn rate against the members active at the bucket's start.
+// Members leave when their membership ends or they are deleted, whichever comes first.
+// Query parameters are the same as the other reports, every bucket of the range is included.
+func GetUsersLeftChartData(ctx *gin.Context) {
+	spans, starts, bucket, ok := membershipsOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	points := []dto.MemberChurnPoint_Res{}
+
+	for _, start := range starts {
+		end := nextBucket(start, bucket)
+
+		point := dto.MemberChurnPoint_Res{
+			Period:        bucketOf(start, bucket),
+			ActiveAtStart: activeAt(spans, start),
+		}
+
+		for _, span := range spans {
+			if !span.leftAt.Before(start) && span.leftAt.Before(end) && !span.leftAt.After(now) {
+				point.Left++
+			}
+		}
+
+		if point.ActiveAtStart > 0 {
+			point.ChurnRate = roundTo(float64(point.Left)/float64(point.ActiveAtStart)*100, 2)
+		}
+
+		points = append(points, point)
+	}
+
+	ctx.JSON(http.StatusOK, points)
+}
+
+// New members per bucket and the active members at the end of each bucket.
+// Query parameters are the same as the other reports, every bucket of the range is included.
+func GetUsersCreatedChartData(ctx *gin.Context) {
+	spans, starts, bucket, ok := membershipsOrAbort(ctx)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	points := []dto.MemberGrowthPoint_Res{}
+
+	for _, start := range starts {
+		end := nextBucket(start, bucket)
+
+		point := dto.MemberGrowthPoint_Res{Period: bucketOf(start, bucket)}
+
+		for _, span := range spans {
+			if !span.createdAt.Before(start) && span.createdAt.Before(end) {
+				point.New++
+			}
+		}
+
+		if end.After(now) {
+			point.Active = activeAt(spans, now)
+		} else {
+			point.Active = activeAt(spans, end.Add(-time.Second))
+		}
+
+		points = append(points, point)
+	}
+
+	ctx.JSON(http.StatusOK, points)
+}
 func GetUserById(ctx *gin.Context) {
 	idStr := ctx.Query("id")
 
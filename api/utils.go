@@ -1,10 +1,19 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
+	"github.com/HenryMarkle/gmserver/common"
 	"github.com/gin-gonic/gin"
 )
 
@@ -39,3 +48,151 @@ func NonEmptyQueryInt64OrAbort(ctx *gin.Context, params ...string) []int64 {
 
 	return values
 }
+
+// Images are stored as STORAGE_PATH/<folder>/<id><ext>
+
+func findStoredImage(folder string, id int64) (string, bool, error) {
+	entries, lookErr := os.ReadDir(filepath.Join(common.StoragePath, folder))
+
+	if lookErr != nil {
+		if errors.Is(lookErr, fs.ErrNotExist) {
+			return "", false, nil
+		}
+
+		return "", false, lookErr
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		name := e.Name()
+
+		if strings.TrimSuffix(name, filepath.Ext(name)) == fmt.Sprintf("%d", id) {
+			return filepath.Join(common.StoragePath, folder, name), true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+func deleteStoredImage(folder string, id int64) error {
+	path, found, lookErr := findStoredImage(folder, id)
+	if lookErr != nil {
+		return lookErr
+	}
+
+	if found {
+		return os.Remove(path)
+	}
+
+	return nil
+}
+
+var imageContentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+}
+
+// The content type of an uploaded file, sniffed from its first 512 bytes
+func sniffContentType(upload *multipart.FileHeader) (string, error) {
+	file, openErr := upload.Open()
+	if openErr != nil {
+		return "", fmt.Errorf("failed to open uploaded file: %w", openErr)
+	}
+
+	defer file.Close()
+
+	head := make([]byte, 512)
+
+	n, readErr := io.ReadFull(file, head)
+	if readErr != nil && !errors.Is(readErr, io.ErrUnexpectedEOF) && !errors.Is(readErr, io.EOF) {
+		return "", fmt.Errorf("failed to read uploaded file: %w", readErr)
+	}
+
+	return http.DetectContentType(head[:n]), nil
+}
+
+// Replaces the stored image of an ID with the uploaded form file 'image'.
+// Responds and returns false when the upload is missing or invalid.
+func saveUploadedImage(ctx *gin.Context, folder string, id int64) bool {
+	image, formErr := ctx.FormFile("image")
+	if formErr != nil {
+		ctx.String(http.StatusBadRequest, "Required file: 'image'.")
+		return false
+	}
+
+	if image.Size > 10<<20 {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "Image size is too large (max is 10 MB).")
+		return false
+	}
+
+	imageExt := strings.ToLower(filepath.Ext(image.Filename))
+
+	contentType, allowed := imageContentTypes[imageExt]
+	if !allowed {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "Only files with extensions '.png', '.jpg' and '.jpeg' are allowed.")
+		return false
+	}
+
+	sniffed, sniffErr := sniffContentType(image)
+	if sniffErr != nil {
+		common.Logger.Printf("failed to check uploaded image (%s, id: %d): %v", folder, id, sniffErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	if sniffed != contentType {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, "File content does not match its extension.")
+		return false
+	}
+
+	deleteErr := deleteStoredImage(folder, id)
+	if deleteErr != nil {
+		common.Logger.Printf("failed to delete previous image (%s, id: %d): %v", folder, id, deleteErr)
+	}
+
+	if mkdirErr := os.MkdirAll(filepath.Join(common.StoragePath, folder), 0o755); mkdirErr != nil {
+		common.Logger.Printf("failed to create image folder (%s): %v", folder, mkdirErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	imagePath := filepath.Join(common.StoragePath, folder, fmt.Sprintf("%d%s", id, imageExt))
+
+	uploadErr := ctx.SaveUploadedFile(image, imagePath)
+	if uploadErr != nil {
+		common.Logger.Printf("failed to upload image (%s, id: %d): %v", folder, id, uploadErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// Responds with the stored image of an ID, or 404 if there is none
+func serveStoredImage(ctx *gin.Context, folder string, id int64) {
+	path, found, lookErr := findStoredImage(folder, id)
+
+	if lookErr != nil {
+		common.Logger.Printf("failed to find image (%s, id: %d): %v", folder, id, lookErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		common.Logger.Printf("failed to read image file (%s, id: %d): %v", folder, id, err)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Data(http.StatusOK, mime.TypeByExtension(filepath.Ext(path)), bytes)
+}
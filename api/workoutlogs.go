@@ -0,0 +1,215 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Epley formula; a single rep is its own max.
+func estimateOneRepMax(weight float64, reps int) float64 {
+	if reps <= 1 {
+		return weight
+	}
+
+	return roundTo(weight*(1+float64(reps)/30), 2)
+}
+
+func LogWorkoutSets(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.LogWorkoutSets_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	if !subscriberExistsOrAbort(ctx, id) || !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	loggedBy := userPtr.(*db.User).ID
+
+	sets := make([]db.WorkoutSet, 0, len(data.Sets))
+	for _, set := range data.Sets {
+		sets = append(sets, db.WorkoutSet{
+			SubscriberID:       id,
+			ExerciseID:         data.ExerciseID,
+			LoggedByID:         loggedBy,
+			PerformedAt:        data.PerformedAt,
+			Reps:               set.Reps,
+			Weight:             set.Weight,
+			RPE:                set.RPE,
+			EstimatedOneRepMax: estimateOneRepMax(set.Weight, set.Reps),
+		})
+	}
+
+	logged, queryErr := db.CreateWorkoutSets(db.DB, sets)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrExerciseNotFound) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to log workout sets: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, logged)
+}
+
+// Optional query parameters: 'exerciseId', 'from' and 'to'
+func GetWorkoutSets(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	var exerciseID int64
+
+	if exerciseStr := ctx.Query("exerciseId"); exerciseStr != "" {
+		parsed, parseErr := strconv.ParseInt(exerciseStr, 10, 64)
+		if parseErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid query parameter: exerciseId")
+			return
+		}
+
+		exerciseID = parsed
+	}
+
+	sets, queryErr := db.GetWorkoutSets(db.DB, id, exerciseID, ctx.Query("from"), ctx.Query("to"))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get workout sets: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, sets)
+}
+
+func DeleteWorkoutSet(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	set, setErr := db.GetWorkoutSetByID(db.DB, id)
+	if setErr != nil {
+		common.Logger.Printf("failed to get a workout set: %v", setErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if set == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, set.SubscriberID) {
+		return
+	}
+
+	queryErr := db.DeleteWorkoutSetByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a workout set: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func GetPersonalRecords(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	records, queryErr := db.GetPersonalRecords(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get personal records: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, records)
+}
+
+// Optional query parameters: 'from' and 'to'
+func GetWeeklyVolume(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	volumes, queryErr := db.GetWeeklyVolume(db.DB, id, ctx.Query("from"), ctx.Query("to"))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get weekly volume: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, volumes)
+}
+
+// Optional query parameters: 'from' and 'to'
+func GetExerciseHistory(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	if !canAccessSubscriberOrAbort(ctx, id) {
+		return
+	}
+
+	exerciseID, convErr := strconv.ParseInt(ctx.Params.ByName("exerciseId"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: exerciseId")
+		return
+	}
+
+	points, queryErr := db.GetExerciseHistory(db.DB, id, exerciseID, ctx.Query("from"), ctx.Query("to"))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get exercise history: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, points)
+}
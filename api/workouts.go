@@ -0,0 +1,408 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+	"github.com/HenryMarkle/gmserver/db"
+	"github.com/HenryMarkle/gmserver/dto"
+	"github.com/gin-gonic/gin"
+)
+
+// Converts a program request, responding with 400 and returning nil when
+// days fall outside the program's weeks or repeat.
+func workoutProgramFromRequest(ctx *gin.Context, data *dto.WorkoutProgram_Req) *db.WorkoutProgram {
+	program := &db.WorkoutProgram{
+		Name:        data.Name,
+		Description: data.Description,
+		Weeks:       data.Weeks,
+		IsTemplate:  data.IsTemplate,
+		Days:        make([]db.WorkoutProgramDay, 0, len(data.Days)),
+	}
+
+	seen := map[[2]int]bool{}
+
+	for _, day := range data.Days {
+		if day.Week > data.Weeks {
+			ctx.String(http.StatusBadRequest, "Day %d of week %d is outside of the program's %d weeks", day.Day, day.Week, data.Weeks)
+			return nil
+		}
+
+		key := [2]int{day.Week, day.Day}
+		if seen[key] {
+			ctx.String(http.StatusBadRequest, "Day %d of week %d is repeated", day.Day, day.Week)
+			return nil
+		}
+
+		seen[key] = true
+
+		exercises := make([]db.WorkoutProgramExercise, 0, len(day.Exercises))
+		for _, exercise := range day.Exercises {
+			exercises = append(exercises, db.WorkoutProgramExercise{
+				ExerciseID:  exercise.ExerciseID,
+				Sets:        exercise.Sets,
+				Reps:        exercise.Reps,
+				RestSeconds: exercise.RestSeconds,
+				Load:        exercise.Load,
+				Notes:       exercise.Notes,
+			})
+		}
+
+		program.Days = append(program.Days, db.WorkoutProgramDay{
+			Week:      day.Week,
+			Day:       day.Day,
+			Title:     day.Title,
+			Exercises: exercises,
+		})
+	}
+
+	return program
+}
+
+// Query parameter 'templates' set to true lists templates only
+func GetWorkoutPrograms(ctx *gin.Context) {
+	programs, queryErr := db.GetWorkoutPrograms(db.DB, ctx.Query("templates") == "true")
+	if queryErr != nil {
+		common.Logger.Printf("failed to get workout programs: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, programs)
+}
+
+func GetWorkoutProgramByID(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	program, queryErr := db.GetWorkoutProgramByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a workout program: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if program == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, program)
+}
+
+func CreateWorkoutProgram(ctx *gin.Context) {
+	data := dto.WorkoutProgram_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	program := workoutProgramFromRequest(ctx, &data)
+	if program == nil {
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	program.CreatedByID = userPtr.(*db.User).ID
+
+	id, queryErr := db.CreateWorkoutProgram(db.DB, *program)
+	if queryErr != nil {
+		if errors.Is(queryErr, db.ErrExerciseNotFound) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", queryErr)
+			return
+		}
+
+		common.Logger.Printf("failed to create a workout program: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func ReplaceWorkoutProgram(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.WorkoutProgram_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	program := workoutProgramFromRequest(ctx, &data)
+	if program == nil {
+		return
+	}
+
+	existing, queryErr := db.GetWorkoutProgramByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a workout program: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if existing == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	program.ID = id
+
+	replaceErr := db.ReplaceWorkoutProgram(db.DB, *program)
+	if replaceErr != nil {
+		if errors.Is(replaceErr, db.ErrExerciseNotFound) {
+			ctx.String(http.StatusBadRequest, "Invalid data: %v", replaceErr)
+			return
+		}
+
+		common.Logger.Printf("failed to replace a workout program: %v", replaceErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func DeleteWorkoutProgram(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	deleted, queryErr := db.DeleteWorkoutProgramByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to delete a workout program: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if !deleted {
+		ctx.String(http.StatusConflict, "Workout program is assigned to subscribers")
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Copies a program with all of its days and exercises
+func CloneWorkoutProgram(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	data := dto.CloneWorkoutProgram_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	program, queryErr := db.GetWorkoutProgramByID(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a workout program: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if program == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	if data.Name != "" {
+		program.Name = data.Name
+	}
+
+	program.IsTemplate = data.IsTemplate
+	program.CreatedByID = userPtr.(*db.User).ID
+
+	cloneID, createErr := db.CreateWorkoutProgram(db.DB, *program)
+	if createErr != nil {
+		common.Logger.Printf("failed to clone a workout program: %v", createErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cloneID)
+}
+
+func AssignWorkoutProgram(ctx *gin.Context) {
+	data := dto.AssignWorkoutProgram_Req{}
+
+	bindErr := ctx.ShouldBindJSON(&data)
+	if bindErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid data: %v", bindErr)
+		return
+	}
+
+	program, queryErr := db.GetWorkoutProgramByID(db.DB, data.ProgramID)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get a workout program: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if program == nil {
+		ctx.String(http.StatusNotFound, "Workout program not found")
+		return
+	}
+
+	if !subscriberExistsOrAbort(ctx, data.SubscriberID) {
+		return
+	}
+
+	userPtr, exists := ctx.Get("user")
+	if !exists {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+
+	id, assignErr := db.AssignWorkoutProgram(db.DB, db.WorkoutAssignment{
+		ProgramID:    data.ProgramID,
+		SubscriberID: data.SubscriberID,
+		AssignedByID: userPtr.(*db.User).ID,
+		StartDate:    data.StartDate,
+	})
+	if assignErr != nil {
+		common.Logger.Printf("failed to assign a workout program: %v", assignErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, id)
+}
+
+func GetSubscriberWorkoutAssignments(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	assignments, queryErr := db.GetSubscriberWorkoutAssignments(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to get workout assignments: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, assignments)
+}
+
+func EndWorkoutAssignment(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	queryErr := db.EndWorkoutAssignment(db.DB, id)
+	if queryErr != nil {
+		common.Logger.Printf("failed to end a workout assignment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// Resolves the workout of a subscriber's active program for a date
+// (query parameter 'date', defaults to today).
+func GetTodayWorkout(ctx *gin.Context) {
+	id, convErr := strconv.ParseInt(ctx.Params.ByName("id"), 10, 64)
+	if convErr != nil {
+		ctx.String(http.StatusBadRequest, "Invalid parameter: id")
+		return
+	}
+
+	date := time.Now()
+
+	if dateStr := ctx.Query("date"); dateStr != "" {
+		parsed, parseErr := time.ParseInLocation(common.DateLayout, dateStr, time.Local)
+		if parseErr != nil {
+			ctx.String(http.StatusBadRequest, "Invalid query parameter: date")
+			return
+		}
+
+		date = parsed
+	}
+
+	assignment, queryErr := db.GetActiveWorkoutAssignment(db.DB, id, date.Format(common.DateLayout))
+	if queryErr != nil {
+		common.Logger.Printf("failed to get active workout assignment: %v", queryErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if assignment == nil {
+		ctx.Status(http.StatusNotFound)
+		return
+	}
+
+	start, parseErr := time.ParseInLocation(common.DateLayout, assignment.StartDate, time.Local)
+	if parseErr != nil {
+		common.Logger.Printf("failed to parse workout assignment start date (id: %d): %v", assignment.ID, parseErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	today := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	elapsed := int(today.Sub(start).Hours()+12) / 24
+
+	res := db.TodayWorkout{
+		Assignment: assignment,
+		Week:       elapsed/7 + 1,
+		Day:        elapsed%7 + 1,
+	}
+
+	program, programErr := db.GetWorkoutProgramByID(db.DB, assignment.ProgramID)
+	if programErr != nil {
+		common.Logger.Printf("failed to get a workout program: %v", programErr)
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	if program == nil || res.Week > program.Weeks {
+		res.Finished = true
+		ctx.JSON(http.StatusOK, res)
+		return
+	}
+
+	for i := range program.Days {
+		if program.Days[i].Week == res.Week && program.Days[i].Day == res.Day {
+			res.Workout = &program.Days[i]
+			break
+		}
+	}
+
+	ctx.JSON(http.StatusOK, res)
+}
@@ -1 +1,7 @@
 package common
+
+// DATETIME columns are read and written as strings in these layouts.
+const (
+	DateTimeLayout = "2006-01-02 15:04:05"
+	DateLayout     = "2006-01-02"
+)
@@ -1 +1,321 @@
 package common
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReadCSV reads all records of a CSV file, tolerating rows of uneven length.
+func ReadCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	rows, readErr := reader.ReadAll()
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", readErr)
+	}
+
+	return rows, nil
+}
+
+func WriteCSV(w io.Writer, rows [][]string) error {
+	writer := csv.NewWriter(w)
+
+	writeErr := writer.WriteAll(rows)
+	if writeErr != nil {
+		return fmt.Errorf("failed to write CSV: %w", writeErr)
+	}
+
+	return nil
+}
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Relationships []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+
+	builder := strings.Builder{}
+	for _, r := range t.Runs {
+		builder.WriteString(r.T)
+	}
+
+	return builder.String()
+}
+
+type xlsxSharedStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readZipXML(files map[string]*zip.File, name string, dest interface{}) (bool, error) {
+	file, found := files[name]
+	if !found {
+		return false, nil
+	}
+
+	reader, openErr := file.Open()
+	if openErr != nil {
+		return true, openErr
+	}
+
+	defer reader.Close()
+
+	return true, xml.NewDecoder(reader).Decode(dest)
+}
+
+// xlsxColumnIndex converts a cell reference such as "C12" to a zero-based column index.
+func xlsxColumnIndex(ref string) int {
+	index := 0
+
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+
+		index = index*26 + int(r-'A'+1)
+	}
+
+	return index - 1
+}
+
+// ReadXLSX reads the cells of the first worksheet of an XLSX workbook as text.
+func ReadXLSX(r io.ReaderAt, size int64) ([][]string, error) {
+	archive, zipErr := zip.NewReader(r, size)
+	if zipErr != nil {
+		return nil, fmt.Errorf("failed to open XLSX archive: %w", zipErr)
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	sheetPath := "xl/worksheets/sheet1.xml"
+
+	workbook := xlsxWorkbook{}
+	rels := xlsxRelationships{}
+
+	_, workbookErr := readZipXML(files, "xl/workbook.xml", &workbook)
+	_, relsErr := readZipXML(files, "xl/_rels/workbook.xml.rels", &rels)
+
+	if workbookErr == nil && relsErr == nil && len(workbook.Sheets) > 0 {
+		for _, rel := range rels.Relationships {
+			if rel.ID == workbook.Sheets[0].RID {
+				if strings.HasPrefix(rel.Target, "/") {
+					sheetPath = strings.TrimPrefix(rel.Target, "/")
+				} else {
+					sheetPath = path.Join("xl", rel.Target)
+				}
+				break
+			}
+		}
+	}
+
+	shared := xlsxSharedStrings{}
+	if _, sharedErr := readZipXML(files, "xl/sharedStrings.xml", &shared); sharedErr != nil {
+		return nil, fmt.Errorf("failed to read XLSX shared strings: %w", sharedErr)
+	}
+
+	sheet := xlsxSheet{}
+	found, sheetErr := readZipXML(files, sheetPath, &sheet)
+	if !found {
+		return nil, errors.New("XLSX workbook has no worksheets")
+	}
+
+	if sheetErr != nil {
+		return nil, fmt.Errorf("failed to read XLSX worksheet: %w", sheetErr)
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+
+	for _, row := range sheet.Rows {
+		values := []string{}
+
+		for i, cell := range row.Cells {
+			column := i
+			if cell.Ref != "" {
+				column = xlsxColumnIndex(cell.Ref)
+			}
+
+			for len(values) <= column {
+				values = append(values, "")
+			}
+
+			switch cell.Type {
+			case "s":
+				index, convErr := strconv.Atoi(cell.Value)
+				if convErr == nil && index >= 0 && index < len(shared.Items) {
+					values[column] = shared.Items[index].String()
+				}
+			case "inlineStr":
+				values[column] = cell.Inline.String()
+			default:
+				values[column] = cell.Value
+			}
+		}
+
+		rows = append(rows, values)
+	}
+
+	return rows, nil
+}
+
+var xlsxNumberPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?$`)
+
+func xlsxColumnName(index int) string {
+	name := ""
+
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+
+	return name
+}
+
+// WriteXLSX writes the rows as a single-sheet XLSX workbook.
+// Plain decimal values are stored as numbers, everything else as inline text.
+func WriteXLSX(w io.Writer, sheetName string, rows [][]string) error {
+	archive := zip.NewWriter(w)
+
+	escape := func(s string) string {
+		builder := strings.Builder{}
+		_ = xml.EscapeText(&builder, []byte(s))
+		return builder.String()
+	}
+
+	sheet := strings.Builder{}
+	sheet.WriteString(xml.Header)
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+
+	for r, row := range rows {
+		fmt.Fprintf(&sheet, `<row r="%d">`, r+1)
+
+		for c, value := range row {
+			ref := fmt.Sprintf("%s%d", xlsxColumnName(c), r+1)
+
+			if xlsxNumberPattern.MatchString(value) {
+				fmt.Fprintf(&sheet, `<c r="%s"><v>%s</v></c>`, ref, value)
+			} else {
+				fmt.Fprintf(&sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(value))
+			}
+		}
+
+		sheet.WriteString(`</row>`)
+	}
+
+	sheet.WriteString(`</sheetData></worksheet>`)
+
+	parts := []struct {
+		name    string
+		content string
+	}{
+		{
+			"[Content_Types].xml",
+			xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+				`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+				`<Default Extension="xml" ContentType="application/xml"/>` +
+				`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+				`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+				`</Types>`,
+		},
+		{
+			"_rels/.rels",
+			xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+				`</Relationships>`,
+		},
+		{
+			"xl/workbook.xml",
+			xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+				`<sheets><sheet name="` + escape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		},
+		{
+			"xl/_rels/workbook.xml.rels",
+			xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+				`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+				`</Relationships>`,
+		},
+		{"xl/worksheets/sheet1.xml", sheet.String()},
+	}
+
+	for _, part := range parts {
+		writer, createErr := archive.Create(part.name)
+		if createErr != nil {
+			return fmt.Errorf("failed to create XLSX part '%s': %w", part.name, createErr)
+		}
+
+		if _, writeErr := io.WriteString(writer, part.content); writeErr != nil {
+			return fmt.Errorf("failed to write XLSX part '%s': %w", part.name, writeErr)
+		}
+	}
+
+	if closeErr := archive.Close(); closeErr != nil {
+		return fmt.Errorf("failed to finish XLSX archive: %w", closeErr)
+	}
+
+	return nil
+}
+
+// ParseSpreadsheetDate normalizes dates found in imported spreadsheets to DateTimeLayout.
+// Excel serial day numbers are only accepted with serials, as XLSX cells hold dates that way.
+func ParseSpreadsheetDate(value string, serials bool) (string, error) {
+	value = strings.TrimSpace(value)
+
+	if serial, convErr := strconv.ParseFloat(value, 64); convErr == nil && serials {
+		base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		return base.Add(time.Duration(serial * 24 * float64(time.Hour))).Round(time.Second).Format(DateTimeLayout), nil
+	}
+
+	layouts := []string{DateTimeLayout, DateLayout, time.RFC3339, "2006-01-02T15:04:05", "2006/01/02"}
+
+	for _, layout := range layouts {
+		parsed, parseErr := time.Parse(layout, value)
+		if parseErr == nil {
+			return parsed.Format(DateTimeLayout), nil
+		}
+	}
+
+	return "", fmt.Errorf("unrecognized date: '%s'", value)
+}
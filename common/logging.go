@@ -3,6 +3,9 @@ package common
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"testing"
 
 	"github.com/joho/godotenv"
 )
@@ -12,8 +15,86 @@ var (
 	DbConnectionString string
 
 	StoragePath string
+
+	// Soft-deleted records older than this are purged permanently
+	TrashRetentionDays int
+
+	// Class sessions are generated from schedules this many days ahead
+	ClassSessionsDaysAhead int
+	// Bookings can't be cancelled by members later than this before a session starts
+	ClassCancelCutoffHours int
+
+	// Default length of a personal training session
+	PTSessionMinutes int
+	// Cancelling a personal training session later than this before it starts is a late cancellation
+	PTLateCancelHours int
+	// Charged for a late cancellation or a no-show
+	PTLateCancelFee Money
+	// Whether a late cancellation or a no-show uses up a session of the package
+	PTLateCancelConsumesSession bool
+
+	// Staff are reminded of trainer certifications this many days before they expire
+	CertificationReminderDays int
+
+	// Hours worked in a week beyond this are paid as overtime
+	PayrollWeeklyHours int
+	// Overtime hours are paid at the hourly rate times this
+	PayrollOvertimeMultiplier float64
+
+	// PNG or JPEG drawn at the top of invoices, none if empty
+	InvoiceLogoPath string
+	// Printed at the bottom of every invoice page
+	InvoiceFooter string
+	// Percentage applied to invoice lines that don't specify one
+	InvoiceTaxRate float64
 )
 
+var (
+	// ISO 4217 code of the gym's currency, such as USD
+	Currency string
+	// Language tag amounts are formatted for, such as en-US or tr-TR
+	CurrencyLocale string
+)
+
+func lookupEnvInt(name string, fallback int) int {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return fallback
+	}
+
+	converted, convErr := strconv.Atoi(value)
+	if convErr != nil {
+		Logger.Printf("Invalid %s '%s', using %d", name, value, fallback)
+		return fallback
+	}
+
+	return converted
+}
+
+func lookupEnvString(name string, fallback string) string {
+	value, found := os.LookupEnv(name)
+	if !found || value == "" {
+		return fallback
+	}
+
+	return value
+}
+
+func lookupEnvFloat(name string, fallback float64) float64 {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return fallback
+	}
+
+	converted, convErr := strconv.ParseFloat(value, 64)
+	if convErr != nil {
+		Logger.Printf("Invalid %s '%s', using %v", name, value, fallback)
+		return fallback
+	}
+
+	return converted
+}
+
 func init() {
 	Logger = log.Default()
 
@@ -21,7 +102,8 @@ func init() {
 
 	dbConnStr, dbConnFound := os.LookupEnv("DB_URL")
 
-	if !dbConnFound {
+	// Tests don't connect to the database
+	if !dbConnFound && !testing.Testing() {
 		Logger.Fatal("Could not find database connection string")
 		os.Exit(-1)
 	}
@@ -36,4 +118,43 @@ func init() {
 	}
 
 	StoragePath = storagePath
+
+	TrashRetentionDays = lookupEnvInt("TRASH_RETENTION_DAYS", 30)
+
+	ClassSessionsDaysAhead = lookupEnvInt("CLASS_SESSIONS_DAYS_AHEAD", 28)
+	ClassCancelCutoffHours = lookupEnvInt("CLASS_CANCEL_CUTOFF_HOURS", 2)
+
+	PTSessionMinutes = lookupEnvInt("PT_SESSION_MINUTES", 60)
+	PTLateCancelHours = lookupEnvInt("PT_LATE_CANCEL_HOURS", 24)
+	PTLateCancelFee = MoneyFromFloat(lookupEnvFloat("PT_LATE_CANCEL_FEE", 0))
+	PTLateCancelConsumesSession = lookupEnvInt("PT_LATE_CANCEL_CONSUMES_SESSION", 1) != 0
+
+	CertificationReminderDays = lookupEnvInt("CERTIFICATION_REMINDER_DAYS", 30)
+
+	PayrollWeeklyHours = lookupEnvInt("PAYROLL_WEEKLY_HOURS", 40)
+	PayrollOvertimeMultiplier = lookupEnvFloat("PAYROLL_OVERTIME_MULTIPLIER", 1.5)
+
+	InvoiceLogoPath = os.Getenv("INVOICE_LOGO_PATH")
+	InvoiceFooter = os.Getenv("INVOICE_FOOTER")
+	InvoiceTaxRate = lookupEnvFloat("INVOICE_TAX_RATE", 0)
+
+	regularFont, boldFont, fontErr := loadPDFFonts(os.Getenv("PDF_FONT_PATH"), os.Getenv("PDF_BOLD_FONT_PATH"))
+	if fontErr != nil {
+		Logger.Fatalf("Could not load the PDF fonts: %v", fontErr)
+	}
+
+	PDFFont, PDFBoldFont = regularFont, boldFont
+
+	Currency = strings.ToUpper(os.Getenv("CURRENCY"))
+	CurrencyLocale = lookupEnvString("CURRENCY_LOCALE", "en-US")
+
+	PaymentSuccessURL = os.Getenv("PAYMENT_SUCCESS_URL")
+	PaymentCancelURL = os.Getenv("PAYMENT_CANCEL_URL")
+
+	provider, providerErr := newPaymentProvider(os.Getenv("PAYMENT_PROVIDER"))
+	if providerErr != nil {
+		Logger.Fatalf("Could not set up the payment provider: %v", providerErr)
+	}
+
+	Payments = provider
 }
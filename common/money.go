@@ -0,0 +1,260 @@
+package common
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in minor units (cents), so sums don't accumulate floating point errors.
+// It's stored in DECIMAL columns and encoded in JSON as a number with two decimals.
+type Money int64
+
+const moneyScale = 100
+
+// Rounds half away from zero to the nearest minor unit
+func MoneyFromFloat(value float64) Money {
+	return Money(math.Round(value * moneyScale))
+}
+
+// Parses a decimal such as "-12.345" exactly, rounding half away from zero past the minor units
+func ParseMoney(text string) (Money, error) {
+	text = strings.TrimSpace(text)
+
+	negative := strings.HasPrefix(text, "-")
+	digits := strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("invalid amount %q", text)
+	}
+
+	if strings.ContainsAny(fraction, "eE") || strings.ContainsAny(whole, "eE") {
+		// Exponents only come from float encoders
+		value, parseErr := strconv.ParseFloat(text, 64)
+		if parseErr != nil {
+			return 0, fmt.Errorf("invalid amount %q", text)
+		}
+
+		return MoneyFromFloat(value), nil
+	}
+
+	units := int64(0)
+
+	if whole != "" {
+		parsed, parseErr := strconv.ParseInt(whole, 10, 64)
+		if parseErr != nil || parsed < 0 {
+			return 0, fmt.Errorf("invalid amount %q", text)
+		}
+
+		units = parsed * moneyScale
+	}
+
+	for _, r := range fraction {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("invalid amount %q", text)
+		}
+	}
+
+	fraction += "000"
+
+	cents, _ := strconv.ParseInt(fraction[:2], 10, 64)
+	units += cents
+
+	if fraction[2] >= '5' {
+		units++
+	}
+
+	if negative {
+		units = -units
+	}
+
+	return Money(units), nil
+}
+
+func (m Money) Float() float64 {
+	return float64(m) / moneyScale
+}
+
+// Multiplies by a quantity or a rate, rounding to the nearest minor unit
+func (m Money) Mul(factor float64) Money {
+	return Money(math.Round(float64(m) * factor))
+}
+
+// The given percentage of the amount
+func (m Money) Percent(rate float64) Money {
+	return m.Mul(rate / 100)
+}
+
+// Plain decimal with two digits, such as -1234.50
+func (m Money) String() string {
+	sign := ""
+	units := int64(m)
+
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	return fmt.Sprintf("%s%d.%02d", sign, units/moneyScale, units%moneyScale)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Accepts a number or a string holding one
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+
+	if text == "null" {
+		return nil
+	}
+
+	parsed, parseErr := ParseMoney(text)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	*m = parsed
+
+	return nil
+}
+
+func (m *Money) Scan(src interface{}) error {
+	switch value := src.(type) {
+	case nil:
+		*m = 0
+	case []byte:
+		parsed, parseErr := ParseMoney(string(value))
+		if parseErr != nil {
+			return parseErr
+		}
+
+		*m = parsed
+	case string:
+		parsed, parseErr := ParseMoney(value)
+		if parseErr != nil {
+			return parseErr
+		}
+
+		*m = parsed
+	case int64:
+		*m = Money(value * moneyScale)
+	case float64:
+		*m = MoneyFromFloat(value)
+	default:
+		return fmt.Errorf("can't scan %T into money", src)
+	}
+
+	return nil
+}
+
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
+
+type moneyLocale struct {
+	decimal, group string
+	// Whether the symbol comes before the amount
+	symbolFirst bool
+}
+
+// By language; others are formatted as English
+var moneyLocales = map[string]moneyLocale{
+	"en": {".", ",", true},
+	"tr": {",", ".", false},
+	"de": {",", ".", false},
+	"nl": {",", ".", false},
+	"es": {",", ".", false},
+	"it": {",", ".", false},
+	"pt": {",", ".", false},
+	"id": {",", ".", false},
+	"fr": {",", " ", false},
+	"ru": {",", " ", false},
+	"pl": {",", " ", false},
+	"sv": {",", " ", false},
+	"fi": {",", " ", false},
+	"cs": {",", " ", false},
+	"nb": {",", " ", false},
+	"ar": {".", ",", true},
+}
+
+// Currencies without minor units, which are charged in whole units
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// Rounds half away from zero to the smallest unit the currency can be charged in
+func RoundToCurrency(m Money, currency string) Money {
+	if !zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return m
+	}
+
+	return m.Mul(1.0/moneyScale) * moneyScale
+}
+
+// Only symbols the PDF fonts can draw; other currencies are shown by code
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// Formats an amount with the gym's currency and locale (CURRENCY and CURRENCY_LOCALE),
+// such as $1,234.50 or 1.234,50 TRY
+func FormatMoney(m Money) string {
+	return FormatMoneyIn(m, Currency)
+}
+
+// Formats an amount in the given currency with the gym's locale
+func FormatMoneyIn(m Money, currency string) string {
+	language, _, _ := strings.Cut(strings.ToLower(CurrencyLocale), "-")
+	language, _, _ = strings.Cut(language, "_")
+
+	locale, found := moneyLocales[language]
+	if !found {
+		locale = moneyLocales["en"]
+	}
+
+	sign := ""
+	units := int64(m)
+
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+
+	whole := strconv.FormatInt(units/moneyScale, 10)
+
+	var grouped strings.Builder
+
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(locale.group)
+		}
+
+		grouped.WriteRune(digit)
+	}
+
+	amount := fmt.Sprintf("%s%s%s%02d", sign, grouped.String(), locale.decimal, units%moneyScale)
+
+	if currency == "" {
+		return amount
+	}
+
+	symbol, found := currencySymbols[currency]
+	if !found {
+		return amount + " " + currency
+	}
+
+	if locale.symbolFirst {
+		return sign + symbol + strings.TrimPrefix(amount, sign)
+	}
+
+	return amount + " " + symbol
+}
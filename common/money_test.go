@@ -0,0 +1,72 @@
+package common
+
+import "testing"
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		text string
+		want Money
+	}{
+		{"12", 1200},
+		{"12.3", 1230},
+		{"12.34", 1234},
+		{"1.005", 101},
+		{"1.004", 100},
+		{"-1.005", -101},
+		{"-0.5", -50},
+		{"+0.5", 50},
+		{".75", 75},
+		{" 3.10 ", 310},
+		{"1e3", 100000},
+		{"1.5E-1", 15},
+	}
+
+	for _, test := range tests {
+		got, err := ParseMoney(test.text)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) failed: %v", test.text, err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, text := range []string{"", "-", ".", "abc", "1.2x", "1,5", "--1", "1e"} {
+		if got, err := ParseMoney(text); err == nil {
+			t.Errorf("ParseMoney(%q) = %d, want an error", text, got)
+		}
+	}
+}
+
+func TestFormatMoneyIn(t *testing.T) {
+	defer func(locale string) { CurrencyLocale = locale }(CurrencyLocale)
+
+	tests := []struct {
+		locale   string
+		amount   Money
+		currency string
+		want     string
+	}{
+		{"en-US", 123456789, "USD", "$1,234,567.89"},
+		{"en-US", -123450, "USD", "-$1,234.50"},
+		{"en-US", 5, "", "0.05"},
+		{"tr-TR", 123456789, "TRY", "1.234.567,89 TRY"},
+		{"tr_TR", 123450, "EUR", "1.234,50 €"},
+		{"tr", -100000, "", "-1.000,00"},
+		{"fr-FR", 123456789, "EUR", "1\u00a0234\u00a0567,89 €"},
+		{"fr", 99999, "CHF", "999,99 CHF"},
+		{"xx", 123456, "GBP", "£1,234.56"},
+	}
+
+	for _, test := range tests {
+		CurrencyLocale = test.locale
+
+		if got := FormatMoneyIn(test.amount, test.currency); got != test.want {
+			t.Errorf("FormatMoneyIn(%d, %q) with %s = %q, want %q", test.amount, test.currency, test.locale, got, test.want)
+		}
+	}
+}
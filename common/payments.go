@@ -0,0 +1,214 @@
+package common
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Outcomes of a checkout reported by a provider's webhook
+const (
+	PaymentSucceeded = "succeeded"
+	PaymentFailed    = "failed"
+	PaymentExpired   = "expired"
+)
+
+var ErrInvalidWebhook = errors.New("invalid webhook signature")
+
+type CheckoutRequest struct {
+	// Our own ID for the payment, echoed back in webhooks
+	Reference string
+	Amount    Money
+	// ISO 4217 code
+	Currency    string
+	Description string
+	// Where the payer is sent back to after paying or giving up
+	SuccessURL string
+	CancelURL  string
+}
+
+// A hosted page the payer completes the payment on
+type CheckoutSession struct {
+	ID  string `json:"sessionId"`
+	URL string `json:"url"`
+}
+
+type PaymentEvent struct {
+	SessionID string
+	Reference string
+	// PaymentSucceeded, PaymentFailed or PaymentExpired
+	Status string
+	// The amount actually charged
+	Amount   Money
+	Currency string
+}
+
+// A card processor taking payments on hosted checkout pages and confirming them with signed webhooks
+type PaymentProvider interface {
+	Name() string
+	CreateCheckout(request CheckoutRequest) (*CheckoutSession, error)
+	// Verifies the signature of a webhook and returns the event it carries.
+	// Returns nil for events that don't settle a payment. Fails with ErrInvalidWebhook if the signature doesn't match.
+	ParseWebhook(payload []byte, header http.Header) (*PaymentEvent, error)
+}
+
+// Selected with PAYMENT_PROVIDER, stripe or fake. Nil unless set, which turns online payments off.
+var Payments PaymentProvider
+
+var (
+	// Payers are sent back to these after checkout, such as https://gym.example/payment/done
+	PaymentSuccessURL string
+	PaymentCancelURL  string
+)
+
+func newPaymentProvider(name string) (PaymentProvider, error) {
+	switch name {
+	case "":
+		return nil, nil
+	case "fake":
+		secret := lookupEnvString("FAKE_PAYMENT_SECRET", "")
+		if secret == "" {
+			return nil, errors.New("FAKE_PAYMENT_SECRET is required")
+		}
+
+		return NewFakeProvider(secret), nil
+	case "stripe":
+		provider := &StripeProvider{
+			SecretKey:     lookupEnvString("STRIPE_SECRET_KEY", ""),
+			WebhookSecret: lookupEnvString("STRIPE_WEBHOOK_SECRET", ""),
+		}
+
+		if provider.SecretKey == "" || provider.WebhookSecret == "" {
+			return nil, errors.New("STRIPE_SECRET_KEY and STRIPE_WEBHOOK_SECRET are required")
+		}
+
+		if Currency == "" || PaymentSuccessURL == "" || PaymentCancelURL == "" {
+			return nil, errors.New("CURRENCY, PAYMENT_SUCCESS_URL and PAYMENT_CANCEL_URL are required")
+		}
+
+		return provider, nil
+	default:
+		return nil, fmt.Errorf("unknown payment provider %q", name)
+	}
+}
+
+func hmacSHA256(secret string, parts ...[]byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+
+	for _, part := range parts {
+		mac.Write(part)
+	}
+
+	return mac.Sum(nil)
+}
+
+type fakeSession struct {
+	request CheckoutRequest
+	status  string
+}
+
+// A local provider for development and tests. Nothing is charged;
+// sessions are settled with Settle, which signs the webhook a processor would send.
+type FakeProvider struct {
+	secret string
+
+	mutex    sync.Mutex
+	sessions map[string]*fakeSession
+}
+
+const fakeSignatureHeader = "Fake-Signature"
+
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{secret: secret, sessions: map[string]*fakeSession{}}
+}
+
+// Whether secret is the one the provider signs its webhooks with
+func (p *FakeProvider) Authorized(secret string) bool {
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(p.secret)) == 1
+}
+
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *FakeProvider) CreateCheckout(request CheckoutRequest) (*CheckoutSession, error) {
+	random := make([]byte, 12)
+	if _, readErr := rand.Read(random); readErr != nil {
+		return nil, fmt.Errorf("failed to generate a session ID: %w", readErr)
+	}
+
+	id := "fake_" + hex.EncodeToString(random)
+
+	p.mutex.Lock()
+	p.sessions[id] = &fakeSession{request: request}
+	p.mutex.Unlock()
+
+	return &CheckoutSession{ID: id, URL: "/v1/payments/fake/" + id}, nil
+}
+
+// Returns the request a session was created with and its status, empty until it's settled
+func (p *FakeProvider) Session(id string) (CheckoutRequest, string, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	session, found := p.sessions[id]
+	if !found {
+		return CheckoutRequest{}, "", false
+	}
+
+	return session.request, session.status, true
+}
+
+// Settles a session with status, charging its full amount on success, and returns the signed webhook for it
+func (p *FakeProvider) Settle(id, status string) ([]byte, http.Header, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	session, found := p.sessions[id]
+	if !found {
+		return nil, nil, fmt.Errorf("unknown session %q", id)
+	}
+
+	if session.status != "" {
+		return nil, nil, fmt.Errorf("session %q is already %s", id, session.status)
+	}
+
+	session.status = status
+
+	event := PaymentEvent{SessionID: id, Reference: session.request.Reference, Status: status, Currency: session.request.Currency}
+	if status == PaymentSucceeded {
+		event.Amount = session.request.Amount
+	}
+
+	payload, marshalErr := json.Marshal(event)
+	if marshalErr != nil {
+		return nil, nil, marshalErr
+	}
+
+	header := http.Header{}
+	header.Set(fakeSignatureHeader, hex.EncodeToString(hmacSHA256(p.secret, payload)))
+
+	return payload, header, nil
+}
+
+func (p *FakeProvider) ParseWebhook(payload []byte, header http.Header) (*PaymentEvent, error) {
+	signature, decodeErr := hex.DecodeString(header.Get(fakeSignatureHeader))
+	if decodeErr != nil || !hmac.Equal(signature, hmacSHA256(p.secret, payload)) {
+		return nil, ErrInvalidWebhook
+	}
+
+	event := PaymentEvent{}
+
+	if unmarshalErr := json.Unmarshal(payload, &event); unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to parse webhook: %w", unmarshalErr)
+	}
+
+	return &event, nil
+}
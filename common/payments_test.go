@@ -0,0 +1,51 @@
+package common
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFakeProviderRoundTrip(t *testing.T) {
+	for _, status := range []string{PaymentSucceeded, PaymentFailed, PaymentExpired} {
+		t.Run(status, func(t *testing.T) {
+			provider := NewFakeProvider("secret")
+
+			session, err := provider.CreateCheckout(CheckoutRequest{Reference: "7", Amount: 4550, Currency: "EUR"})
+			if err != nil {
+				t.Fatalf("CreateCheckout() failed: %v", err)
+			}
+
+			payload, header, err := provider.Settle(session.ID, status)
+			if err != nil {
+				t.Fatalf("Settle() failed: %v", err)
+			}
+
+			event, err := provider.ParseWebhook(payload, header)
+			if err != nil {
+				t.Fatalf("ParseWebhook() failed: %v", err)
+			}
+
+			want := PaymentEvent{SessionID: session.ID, Reference: "7", Status: status, Currency: "EUR"}
+			if status == PaymentSucceeded {
+				want.Amount = 4550
+			}
+
+			if *event != want {
+				t.Errorf("ParseWebhook() = %+v, want %+v", *event, want)
+			}
+
+			if _, _, err := provider.Settle(session.ID, status); err == nil {
+				t.Error("settling a session twice succeeded")
+			}
+
+			if _, err := NewFakeProvider("other").ParseWebhook(payload, header); !errors.Is(err, ErrInvalidWebhook) {
+				t.Errorf("ParseWebhook() with another secret error = %v, want %v", err, ErrInvalidWebhook)
+			}
+
+			payload[len(payload)-2] ^= 1
+			if _, err := provider.ParseWebhook(payload, header); !errors.Is(err, ErrInvalidWebhook) {
+				t.Errorf("ParseWebhook() of a tampered payload error = %v, want %v", err, ErrInvalidWebhook)
+			}
+		})
+	}
+}
@@ -0,0 +1,358 @@
+package common
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	// Registers the decoders of the logo formats
+	_ "image/jpeg"
+	_ "image/png"
+)
+
+// A4 in points
+const (
+	PDFPageWidth  = 595.0
+	PDFPageHeight = 842.0
+)
+
+// Glyph widths of the ASCII range (32 to 126) in thousandths of the font size
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// Characters of WinAnsiEncoding outside Latin-1
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// Encodes text for the standard fonts (WinAnsiEncoding), which mostly cover Latin-1.
+// Other characters are replaced with '?'; PDFCheckText finds them beforehand.
+func pdfEncode(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+
+	for _, r := range text {
+		switch {
+		case r == '\t':
+			encoded = append(encoded, ' ')
+		case r >= 32 && r <= 126, r >= 0xA0 && r <= 0xFF:
+			encoded = append(encoded, byte(r))
+		case winAnsiExtras[r] != 0:
+			encoded = append(encoded, winAnsiExtras[r])
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+
+	return encoded
+}
+
+// Width of text in points when drawn in the configured font at the given size
+func PDFTextWidth(text string, size float64, bold bool) float64 {
+	if font, _ := pdfFontOf(bold); font != nil {
+		return font.textWidth(text, size)
+	}
+
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+
+	total := 0
+
+	for _, b := range pdfEncode(text) {
+		if b >= 32 && b <= 126 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+
+	return float64(total) * size / 1000
+}
+
+// Shortens text with an ellipsis so it fits within width
+func PDFTruncate(text string, width, size float64, bold bool) string {
+	if PDFTextWidth(text, size, bold) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+
+	for len(runes) > 0 && PDFTextWidth(string(runes)+"...", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+
+	return string(runes) + "..."
+}
+
+type pdfImage struct {
+	width, height int
+	data          []byte
+}
+
+// PDF is a minimal A4 document writer with the standard Helvetica fonts or PDFFont, lines and images,
+// enough to lay out invoices without an external dependency.
+// Coordinates are in points from the top-left corner of the page.
+type PDF struct {
+	pages  []*bytes.Buffer
+	images []pdfImage
+	// Glyphs drawn with each TrueType font, and the characters they stand for
+	used map[*TrueTypeFont]map[uint16]rune
+
+	// Drawn centered at the bottom of every page
+	Footer string
+}
+
+func NewPDF() *PDF {
+	pdf := &PDF{}
+	pdf.AddPage()
+
+	return pdf
+}
+
+func (p *PDF) AddPage() {
+	p.pages = append(p.pages, &bytes.Buffer{})
+}
+
+func (p *PDF) page() *bytes.Buffer {
+	return p.pages[len(p.pages)-1]
+}
+
+// The TrueType font text is drawn in and its resource name, nil when the standard fonts are used
+func pdfFontOf(bold bool) (*TrueTypeFont, string) {
+	if PDFFont == nil {
+		return nil, ""
+	}
+
+	if bold && PDFBoldFont != PDFFont {
+		return PDFBoldFont, "F4"
+	}
+
+	return PDFFont, "F3"
+}
+
+func (p *PDF) Text(x, y, size float64, bold bool, text string) {
+	if font, name := pdfFontOf(bold); font != nil {
+		if p.used == nil {
+			p.used = map[*TrueTypeFont]map[uint16]rune{}
+		}
+
+		if p.used[font] == nil {
+			p.used[font] = map[uint16]rune{}
+		}
+
+		var glyphs strings.Builder
+
+		for _, r := range text {
+			gid := font.glyph(r)
+			p.used[font][gid] = r
+
+			fmt.Fprintf(&glyphs, "%04X", gid)
+		}
+
+		fmt.Fprintf(p.page(), "BT /%s %.2f Tf %.2f %.2f Td <%s> Tj ET\n", name, size, x, PDFPageHeight-y, glyphs.String())
+		return
+	}
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+
+	var escaped bytes.Buffer
+
+	for _, b := range pdfEncode(text) {
+		if b == '(' || b == ')' || b == '\\' {
+			escaped.WriteByte('\\')
+		}
+
+		escaped.WriteByte(b)
+	}
+
+	fmt.Fprintf(p.page(), "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, PDFPageHeight-y, escaped.String())
+}
+
+// Draws text ending at x
+func (p *PDF) TextRight(x, y, size float64, bold bool, text string) {
+	p.Text(x-PDFTextWidth(text, size, bold), y, size, bold, text)
+}
+
+func (p *PDF) TextCenter(x, y, size float64, bold bool, text string) {
+	p.Text(x-PDFTextWidth(text, size, bold)/2, y, size, bold, text)
+}
+
+// Draws a gray line
+func (p *PDF) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(p.page(), "0.6 G %.2f w %.2f %.2f m %.2f %.2f l S 0 G\n", width, x1, PDFPageHeight-y1, x2, PDFPageHeight-y2)
+}
+
+// Draws img scaled to fit within the box at (x, y), keeping its aspect ratio.
+// Transparent pixels are drawn over white.
+func (p *PDF) Image(img image.Image, x, y, maxWidth, maxHeight float64) error {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width == 0 || height == 0 {
+		return fmt.Errorf("image is empty")
+	}
+
+	rgb := make([]byte, 0, width*height*3)
+
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			c := color.NRGBAModel.Convert(img.At(px, py)).(color.NRGBA)
+			alpha := uint32(c.A)
+
+			blend := func(v uint8) byte {
+				return byte((uint32(v)*alpha + 255*(255-alpha)) / 255)
+			}
+
+			rgb = append(rgb, blend(c.R), blend(c.G), blend(c.B))
+		}
+	}
+
+	var compressed bytes.Buffer
+
+	writer := zlib.NewWriter(&compressed)
+	if _, writeErr := writer.Write(rgb); writeErr != nil {
+		return fmt.Errorf("failed to compress image: %w", writeErr)
+	}
+
+	if closeErr := writer.Close(); closeErr != nil {
+		return fmt.Errorf("failed to compress image: %w", closeErr)
+	}
+
+	p.images = append(p.images, pdfImage{width: width, height: height, data: compressed.Bytes()})
+
+	scale := maxWidth / float64(width)
+	if heightScale := maxHeight / float64(height); heightScale < scale {
+		scale = heightScale
+	}
+
+	drawnWidth, drawnHeight := float64(width)*scale, float64(height)*scale
+
+	fmt.Fprintf(p.page(), "q %.2f 0 0 %.2f %.2f %.2f cm /Im%d Do Q\n", drawnWidth, drawnHeight, x, PDFPageHeight-y-drawnHeight, len(p.images))
+
+	return nil
+}
+
+// Serializes the document
+func (p *PDF) Bytes() ([]byte, error) {
+	var out bytes.Buffer
+	offsets := []int{}
+
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\n", len(offsets), body)
+
+		if stream != nil {
+			out.WriteString("stream\n")
+			out.Write(stream)
+			out.WriteString("\nendstream\n")
+		}
+
+		out.WriteString("endobj\n")
+	}
+
+	// Footers are laid out first so the glyphs they use are embedded
+	contents := make([][]byte, len(p.pages))
+
+	for i, page := range p.pages {
+		contents[i] = page.Bytes()
+
+		if p.Footer != "" {
+			footer := &PDF{pages: []*bytes.Buffer{{}}, used: p.used}
+			footer.TextCenter(PDFPageWidth/2, PDFPageHeight-30, 8, false, p.Footer)
+			p.used = footer.used
+			contents[i] = append(append([]byte{}, contents[i]...), footer.page().Bytes()...)
+		}
+	}
+
+	regular, _ := pdfFontOf(false)
+	bold, _ := pdfFontOf(true)
+
+	fonts := []*TrueTypeFont{}
+	if regular != nil {
+		fonts = append(fonts, regular)
+
+		if bold != regular {
+			fonts = append(fonts, bold)
+		}
+	}
+
+	out.WriteString("%PDF-1.4\n")
+
+	// Objects 1 to 4 are the catalog, the page tree and the standard fonts, followed by the images,
+	// the objects of each TrueType font, then a page object and its content stream per page
+	firstImage := 5
+	firstFont := firstImage + len(p.images)
+	firstPage := firstFont + len(fonts)*5
+
+	kids := []string{}
+	for i := range p.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", firstPage+i*2))
+	}
+
+	xObjects := []string{}
+	for i := range p.images {
+		xObjects = append(xObjects, fmt.Sprintf("/Im%d %d 0 R", i+1, firstImage+i))
+	}
+
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)), nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+
+	for _, img := range p.images {
+		object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			img.width, img.height, len(img.data)), img.data)
+	}
+
+	fontResources := []string{"/F1 3 0 R", "/F2 4 0 R"}
+
+	for i, font := range fonts {
+		if objectsErr := font.objects(firstFont+i*5, p.used[font], object); objectsErr != nil {
+			return nil, objectsErr
+		}
+
+		fontResources = append(fontResources, fmt.Sprintf("/F%d %d 0 R", 3+i, firstFont+i*5))
+	}
+
+	resources := fmt.Sprintf("<< /Font << %s >> /XObject << %s >> >>", strings.Join(fontResources, " "), strings.Join(xObjects, " "))
+
+	for i, content := range contents {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources %s /Contents %d 0 R >>",
+			PDFPageWidth, PDFPageHeight, resources, firstPage+i*2+1), nil)
+		object(fmt.Sprintf("<< /Length %d >>", len(content)), content)
+	}
+
+	xref := out.Len()
+
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes(), nil
+}
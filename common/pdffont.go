@@ -0,0 +1,386 @@
+package common
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// A TrueType font embedded whole in the documents that use it, covering any script it has glyphs for
+type TrueTypeFont struct {
+	name       string
+	data       []byte
+	unitsPerEm int
+	// Advance width of every glyph in font units
+	advances    []int
+	glyphs      map[rune]uint16
+	bbox        [4]int
+	ascent      int
+	descent     int
+	capHeight   int
+	italicAngle int
+}
+
+var (
+	// Set with PDF_FONT_PATH and PDF_BOLD_FONT_PATH, nil to use the standard Helvetica fonts.
+	// The bold font defaults to the regular one.
+	PDFFont     *TrueTypeFont
+	PDFBoldFont *TrueTypeFont
+)
+
+var ErrPDFUnprintable = errors.New("text can't be printed in PDF documents")
+
+var fontNameChars = regexp.MustCompile(`[^A-Za-z0-9-]`)
+
+func loadPDFFonts(regularPath, boldPath string) (*TrueTypeFont, *TrueTypeFont, error) {
+	if regularPath == "" {
+		return nil, nil, nil
+	}
+
+	regular, regularErr := LoadTrueTypeFont(regularPath)
+	if regularErr != nil {
+		return nil, nil, regularErr
+	}
+
+	if boldPath == "" {
+		return regular, regular, nil
+	}
+
+	bold, boldErr := LoadTrueTypeFont(boldPath)
+	if boldErr != nil {
+		return nil, nil, boldErr
+	}
+
+	return regular, bold, nil
+}
+
+func LoadTrueTypeFont(path string) (*TrueTypeFont, error) {
+	data, readErr := os.ReadFile(path)
+	if readErr != nil {
+		return nil, fmt.Errorf("failed to read font: %w", readErr)
+	}
+
+	name := fontNameChars.ReplaceAllString(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), "")
+
+	font, parseErr := ParseTrueTypeFont(name, data)
+	if parseErr != nil {
+		return nil, fmt.Errorf("failed to parse font (%s): %w", path, parseErr)
+	}
+
+	return font, nil
+}
+
+// Reads the tables needed to lay out and embed a font with TrueType outlines
+func ParseTrueTypeFont(name string, data []byte) (*TrueTypeFont, error) {
+	if len(data) < 12 {
+		return nil, errors.New("file is too short")
+	}
+
+	if version := binary.BigEndian.Uint32(data); version != 0x00010000 && version != 0x74727565 {
+		return nil, errors.New("not a TrueType font (OpenType CFF fonts and collections aren't supported)")
+	}
+
+	tables := map[string][]byte{}
+
+	numTables := int(binary.BigEndian.Uint16(data[4:]))
+	for i := 0; i < numTables; i++ {
+		record := 12 + i*16
+		if record+16 > len(data) {
+			return nil, errors.New("table directory is truncated")
+		}
+
+		offset := int(binary.BigEndian.Uint32(data[record+8:]))
+		length := int(binary.BigEndian.Uint32(data[record+12:]))
+
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return nil, fmt.Errorf("table %s is out of bounds", data[record:record+4])
+		}
+
+		tables[string(data[record:record+4])] = data[offset : offset+length]
+	}
+
+	for _, required := range []struct {
+		tag    string
+		length int
+	}{{"head", 54}, {"hhea", 36}, {"maxp", 6}, {"hmtx", 4}, {"cmap", 4}} {
+		if len(tables[required.tag]) < required.length {
+			return nil, fmt.Errorf("table %s is missing", required.tag)
+		}
+	}
+
+	u16 := func(table []byte, offset int) int { return int(binary.BigEndian.Uint16(table[offset:])) }
+	i16 := func(table []byte, offset int) int { return int(int16(binary.BigEndian.Uint16(table[offset:]))) }
+
+	head, hhea, hmtx := tables["head"], tables["hhea"], tables["hmtx"]
+
+	font := &TrueTypeFont{
+		name:       name,
+		data:       data,
+		unitsPerEm: u16(head, 18),
+		bbox:       [4]int{i16(head, 36), i16(head, 38), i16(head, 40), i16(head, 42)},
+		ascent:     i16(hhea, 4),
+		descent:    i16(hhea, 6),
+	}
+
+	if font.unitsPerEm == 0 {
+		return nil, errors.New("unitsPerEm is 0")
+	}
+
+	font.capHeight = font.ascent
+
+	if os2 := tables["OS/2"]; len(os2) >= 10 {
+		// Bit 1 of fsType forbids embedding
+		if u16(os2, 8)&0x000F == 0x0002 {
+			return nil, errors.New("the font's license doesn't allow embedding")
+		}
+
+		if u16(os2, 0) >= 2 && len(os2) >= 90 {
+			font.capHeight = i16(os2, 88)
+		}
+	}
+
+	if post := tables["post"]; len(post) >= 8 {
+		font.italicAngle = i16(post, 4)
+	}
+
+	numGlyphs := u16(tables["maxp"], 4)
+	numMetrics := u16(hhea, 34)
+
+	if numMetrics == 0 || len(hmtx) < numMetrics*4 {
+		return nil, errors.New("table hmtx is truncated")
+	}
+
+	font.advances = make([]int, numGlyphs)
+	for gid := range font.advances {
+		font.advances[gid] = u16(hmtx, min(gid, numMetrics-1)*4)
+	}
+
+	glyphs, cmapErr := parseCmap(tables["cmap"], numGlyphs)
+	if cmapErr != nil {
+		return nil, cmapErr
+	}
+
+	font.glyphs = glyphs
+
+	return font, nil
+}
+
+// Maps characters to glyphs with the Unicode subtable of a cmap, full repertoire (format 12) first
+func parseCmap(cmap []byte, numGlyphs int) (map[rune]uint16, error) {
+	u16 := func(offset int) int { return int(binary.BigEndian.Uint16(cmap[offset:])) }
+	u32 := func(offset int) int { return int(binary.BigEndian.Uint32(cmap[offset:])) }
+
+	bmp, full := -1, -1
+
+	numTables := u16(2)
+	for i := 0; i < numTables && 4+i*8+8 <= len(cmap); i++ {
+		platform, encoding, offset := u16(4+i*8), u16(4+i*8+2), u32(4+i*8+4)
+		if offset+2 > len(cmap) {
+			continue
+		}
+
+		switch format := u16(offset); {
+		case format == 12 && (platform == 3 && encoding == 10 || platform == 0):
+			full = offset
+		case format == 4 && (platform == 3 && encoding == 1 || platform == 0):
+			bmp = offset
+		}
+	}
+
+	glyphs := map[rune]uint16{}
+
+	add := func(r rune, gid int) {
+		if gid > 0 && gid < numGlyphs {
+			glyphs[r] = uint16(gid)
+		}
+	}
+
+	switch {
+	case full >= 0:
+		if full+16 > len(cmap) {
+			return nil, errors.New("cmap subtable is truncated")
+		}
+
+		groups := u32(full + 12)
+		if full+16+groups*12 > len(cmap) {
+			return nil, errors.New("cmap subtable is truncated")
+		}
+
+		for g := 0; g < groups; g++ {
+			group := full + 16 + g*12
+			start, end, startGlyph := u32(group), u32(group+4), u32(group+8)
+
+			for c := start; c <= end && c <= unicode.MaxRune; c++ {
+				add(rune(c), startGlyph+c-start)
+			}
+		}
+	case bmp >= 0:
+		if bmp+14 > len(cmap) {
+			return nil, errors.New("cmap subtable is truncated")
+		}
+
+		segments := u16(bmp+6) / 2
+		ends := bmp + 14
+		starts := ends + segments*2 + 2
+		deltas := starts + segments*2
+		rangeOffsets := deltas + segments*2
+
+		if rangeOffsets+segments*2 > len(cmap) {
+			return nil, errors.New("cmap subtable is truncated")
+		}
+
+		for s := 0; s < segments; s++ {
+			start, end := u16(starts+s*2), u16(ends+s*2)
+			delta, rangeOffset := u16(deltas+s*2), u16(rangeOffsets+s*2)
+
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				if rangeOffset == 0 {
+					add(rune(c), (c+delta)&0xFFFF)
+					continue
+				}
+
+				address := rangeOffsets + s*2 + rangeOffset + (c-start)*2
+				if address+2 > len(cmap) {
+					continue
+				}
+
+				if gid := u16(address); gid != 0 {
+					add(rune(c), (gid+delta)&0xFFFF)
+				}
+			}
+		}
+	default:
+		return nil, errors.New("font has no Unicode cmap")
+	}
+
+	return glyphs, nil
+}
+
+// Scripts drawn right to left or with joined letters, which need shaping the writer doesn't do
+var pdfShapedScripts = []*unicode.RangeTable{unicode.Arabic, unicode.Hebrew, unicode.Syriac, unicode.Thaana, unicode.Nko, unicode.Devanagari, unicode.Bengali, unicode.Thai}
+
+// Fails with ErrPDFUnprintable if a character of text would print as a placeholder
+// in the configured fonts, or can't be laid out correctly without shaping.
+func PDFCheckText(text string) error {
+	for _, r := range text {
+		if r == '\t' || r == '\n' {
+			continue
+		}
+
+		if unicode.IsOneOf(pdfShapedScripts, r) {
+			return fmt.Errorf("%w: '%c' needs text shaping", ErrPDFUnprintable, r)
+		}
+
+		printable := true
+
+		switch {
+		case PDFFont != nil:
+			_, regular := PDFFont.glyphs[r]
+			_, bold := PDFBoldFont.glyphs[r]
+			printable = regular && bold
+		default:
+			printable = r >= 32 && r <= 126 || r >= 0xA0 && r <= 0xFF || winAnsiExtras[r] != 0
+		}
+
+		if !printable {
+			return fmt.Errorf("%w: '%c' (set PDF_FONT_PATH to a font that has it)", ErrPDFUnprintable, r)
+		}
+	}
+
+	return nil
+}
+
+func (f *TrueTypeFont) glyph(r rune) uint16 {
+	if r == '\t' {
+		r = ' '
+	}
+
+	return f.glyphs[r]
+}
+
+// Width of text in points at the given size
+func (f *TrueTypeFont) textWidth(text string, size float64) float64 {
+	total := 0
+
+	for _, r := range text {
+		total += f.advances[f.glyph(r)]
+	}
+
+	return float64(total) * size / float64(f.unitsPerEm)
+}
+
+// Writes the font objects with the glyphs a document used, numbered from first:
+// the Type0 font, its CIDFont, the descriptor, the font file and the ToUnicode map.
+func (f *TrueTypeFont) objects(first int, used map[uint16]rune, object func(body string, stream []byte)) error {
+	scale := func(v int) int { return v * 1000 / f.unitsPerEm }
+
+	gids := make([]int, 0, len(used))
+	for gid := range used {
+		gids = append(gids, int(gid))
+	}
+
+	sort.Ints(gids)
+
+	var widths strings.Builder
+	for _, gid := range gids {
+		fmt.Fprintf(&widths, "%d [%d] ", gid, scale(f.advances[gid]))
+	}
+
+	var compressed bytes.Buffer
+
+	writer := zlib.NewWriter(&compressed)
+	if _, writeErr := writer.Write(f.data); writeErr != nil {
+		return fmt.Errorf("failed to compress font: %w", writeErr)
+	}
+
+	if closeErr := writer.Close(); closeErr != nil {
+		return fmt.Errorf("failed to compress font: %w", closeErr)
+	}
+
+	var cmap strings.Builder
+
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	// bfchar blocks hold at most 100 entries
+	for start := 0; start < len(gids); start += 100 {
+		block := gids[start:min(start+100, len(gids))]
+
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", len(block))
+
+		for _, gid := range block {
+			fmt.Fprintf(&cmap, "<%04X> <", gid)
+
+			for _, unit := range utf16.Encode([]rune{used[uint16(gid)]}) {
+				fmt.Fprintf(&cmap, "%04X", unit)
+			}
+
+			cmap.WriteString(">\n")
+		}
+
+		cmap.WriteString("endbfchar\n")
+	}
+
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend")
+
+	object(fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.name, first+1, first+4), nil)
+	object(fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> "+
+		"/FontDescriptor %d 0 R /CIDToGIDMap /Identity /DW 1000 /W [%s] >>", f.name, first+2, widths.String()), nil)
+	object(fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle %d /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.name, scale(f.bbox[0]), scale(f.bbox[1]), scale(f.bbox[2]), scale(f.bbox[3]), f.italicAngle, scale(f.ascent), scale(f.descent), scale(f.capHeight), first+3), nil)
+	object(fmt.Sprintf("<< /Length %d /Length1 %d /Filter /FlateDecode >>", compressed.Len(), len(f.data)), compressed.Bytes())
+	object(fmt.Sprintf("<< /Length %d >>", cmap.Len()), []byte(cmap.String()))
+
+	return nil
+}
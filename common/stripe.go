@@ -0,0 +1,188 @@
+package common
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeAPI = "https://api.stripe.com/v1"
+
+// Webhooks signed longer ago than this are rejected as replays
+const stripeWebhookTolerance = 5 * time.Minute
+
+// Takes card payments on Stripe Checkout
+type StripeProvider struct {
+	SecretKey string
+	// Signing secret of the webhook endpoint, such as whsec_...
+	WebhookSecret string
+}
+
+var stripeClient = &http.Client{Timeout: 30 * time.Second}
+
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+func stripeAmount(m Money, currency string) int64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return int64(RoundToCurrency(m, currency) / moneyScale)
+	}
+
+	return int64(m)
+}
+
+func moneyFromStripe(amount int64, currency string) Money {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return Money(amount * moneyScale)
+	}
+
+	return Money(amount)
+}
+
+func (p *StripeProvider) CreateCheckout(request CheckoutRequest) (*CheckoutSession, error) {
+	form := url.Values{}
+	form.Set("mode", "payment")
+	form.Set("client_reference_id", request.Reference)
+	form.Set("metadata[reference]", request.Reference)
+	form.Set("success_url", request.SuccessURL)
+	form.Set("cancel_url", request.CancelURL)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("line_items[0][price_data][currency]", strings.ToLower(request.Currency))
+	form.Set("line_items[0][price_data][unit_amount]", strconv.FormatInt(stripeAmount(request.Amount, request.Currency), 10))
+	form.Set("line_items[0][price_data][product_data][name]", request.Description)
+
+	httpRequest, requestErr := http.NewRequest(http.MethodPost, stripeAPI+"/checkout/sessions", strings.NewReader(form.Encode()))
+	if requestErr != nil {
+		return nil, fmt.Errorf("failed to create a checkout session: %w", requestErr)
+	}
+
+	httpRequest.Header.Set("Authorization", "Bearer "+p.SecretKey)
+	httpRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	// Retrying the same payment doesn't open a second session
+	httpRequest.Header.Set("Idempotency-Key", "checkout-"+request.Reference)
+
+	response, responseErr := stripeClient.Do(httpRequest)
+	if responseErr != nil {
+		return nil, fmt.Errorf("failed to create a checkout session: %w", responseErr)
+	}
+
+	defer response.Body.Close()
+
+	body := struct {
+		ID    string `json:"id"`
+		URL   string `json:"url"`
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}{}
+
+	if decodeErr := json.NewDecoder(response.Body).Decode(&body); decodeErr != nil {
+		return nil, fmt.Errorf("failed to create a checkout session (status %d): %w", response.StatusCode, decodeErr)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to create a checkout session (status %d): %s", response.StatusCode, body.Error.Message)
+	}
+
+	return &CheckoutSession{ID: body.ID, URL: body.URL}, nil
+}
+
+// Checks the Stripe-Signature header, such as t=1700000000,v1=5257a869...
+func (p *StripeProvider) verifySignature(payload []byte, header string) bool {
+	var timestamp string
+	var signatures [][]byte
+
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			if signature, decodeErr := hex.DecodeString(value); decodeErr == nil {
+				signatures = append(signatures, signature)
+			}
+		}
+	}
+
+	seconds, convErr := strconv.ParseInt(timestamp, 10, 64)
+	if convErr != nil {
+		return false
+	}
+
+	if age := time.Since(time.Unix(seconds, 0)); age > stripeWebhookTolerance || age < -stripeWebhookTolerance {
+		return false
+	}
+
+	expected := hmacSHA256(p.WebhookSecret, []byte(timestamp), []byte("."), payload)
+
+	for _, signature := range signatures {
+		if hmac.Equal(signature, expected) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header) (*PaymentEvent, error) {
+	if !p.verifySignature(payload, header.Get("Stripe-Signature")) {
+		return nil, ErrInvalidWebhook
+	}
+
+	body := struct {
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				ID                string `json:"id"`
+				ClientReferenceID string `json:"client_reference_id"`
+				AmountTotal       int64  `json:"amount_total"`
+				Currency          string `json:"currency"`
+				PaymentStatus     string `json:"payment_status"`
+			} `json:"object"`
+		} `json:"data"`
+	}{}
+
+	if unmarshalErr := json.Unmarshal(payload, &body); unmarshalErr != nil {
+		return nil, fmt.Errorf("failed to parse webhook: %w", unmarshalErr)
+	}
+
+	session := body.Data.Object
+
+	event := &PaymentEvent{
+		SessionID: session.ID,
+		Reference: session.ClientReferenceID,
+		Currency:  strings.ToUpper(session.Currency),
+	}
+
+	switch body.Type {
+	case "checkout.session.completed":
+		// Delayed methods such as bank debits complete unpaid and settle with an async event later
+		if session.PaymentStatus != "paid" {
+			return nil, nil
+		}
+
+		event.Status = PaymentSucceeded
+	case "checkout.session.async_payment_succeeded":
+		event.Status = PaymentSucceeded
+	case "checkout.session.async_payment_failed":
+		event.Status = PaymentFailed
+	case "checkout.session.expired":
+		event.Status = PaymentExpired
+	default:
+		return nil, nil
+	}
+
+	if event.Status == PaymentSucceeded {
+		event.Amount = moneyFromStripe(session.AmountTotal, session.Currency)
+	}
+
+	return event, nil
+}
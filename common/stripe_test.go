@@ -0,0 +1,147 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "whsec_test"
+
+func stripeSignature(secret string, timestamp int64, payload []byte) string {
+	stamp := strconv.FormatInt(timestamp, 10)
+	return hex.EncodeToString(hmacSHA256(secret, []byte(stamp), []byte("."), payload))
+}
+
+func stripeHeader(signature string) http.Header {
+	header := http.Header{}
+	header.Set("Stripe-Signature", signature)
+
+	return header
+}
+
+func TestStripeVerifySignature(t *testing.T) {
+	provider := &StripeProvider{WebhookSecret: testWebhookSecret}
+	payload := []byte(`{"type":"checkout.session.expired"}`)
+	now := time.Now().Unix()
+	valid := stripeSignature(testWebhookSecret, now, payload)
+
+	tests := []struct {
+		name    string
+		payload []byte
+		header  string
+		want    bool
+	}{
+		{"valid", payload, fmt.Sprintf("t=%d,v1=%s", now, valid), true},
+		{"spaces between parts", payload, fmt.Sprintf("t=%d, v1=%s", now, valid), true},
+		{"tampered payload", []byte(`{"type":"checkout.session.completed"}`), fmt.Sprintf("t=%d,v1=%s", now, valid), false},
+		{"wrong secret", payload, fmt.Sprintf("t=%d,v1=%s", now, stripeSignature("whsec_other", now, payload)), false},
+		{"timestamp not signed", payload, fmt.Sprintf("t=%d,v1=%s", now+1, valid), false},
+		{"stale timestamp", payload, fmt.Sprintf("t=%d,v1=%s", now-600, stripeSignature(testWebhookSecret, now-600, payload)), false},
+		{"future timestamp", payload, fmt.Sprintf("t=%d,v1=%s", now+600, stripeSignature(testWebhookSecret, now+600, payload)), false},
+		{"multiple v1 with a match", payload, fmt.Sprintf("t=%d,v1=%s,v1=%s", now, stripeSignature("whsec_old", now, payload), valid), true},
+		{"multiple v1 without a match", payload, fmt.Sprintf("t=%d,v1=%s,v1=00ff", now, stripeSignature("whsec_old", now, payload)), false},
+		{"v0 only", payload, fmt.Sprintf("t=%d,v0=%s", now, valid), false},
+		{"no timestamp", payload, "v1=" + valid, false},
+		{"empty", payload, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := provider.verifySignature(test.payload, test.header); got != test.want {
+				t.Errorf("verifySignature() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestStripeParseWebhook(t *testing.T) {
+	provider := &StripeProvider{WebhookSecret: testWebhookSecret}
+
+	tests := []struct {
+		name     string
+		event    string
+		status   string
+		amount   int64
+		currency string
+		// Nil when the event is ignored
+		want *PaymentEvent
+	}{
+		{"completed and paid", "checkout.session.completed", "paid", 1250, "usd", &PaymentEvent{Status: PaymentSucceeded, Amount: 1250, Currency: "USD"}},
+		{"completed unpaid", "checkout.session.completed", "unpaid", 1250, "usd", nil},
+		{"async succeeded", "checkout.session.async_payment_succeeded", "paid", 990, "eur", &PaymentEvent{Status: PaymentSucceeded, Amount: 990, Currency: "EUR"}},
+		{"async failed", "checkout.session.async_payment_failed", "unpaid", 990, "eur", &PaymentEvent{Status: PaymentFailed, Currency: "EUR"}},
+		{"expired", "checkout.session.expired", "unpaid", 990, "eur", &PaymentEvent{Status: PaymentExpired, Currency: "EUR"}},
+		{"zero-decimal currency", "checkout.session.completed", "paid", 1500, "jpy", &PaymentEvent{Status: PaymentSucceeded, Amount: 150000, Currency: "JPY"}},
+		{"unrelated event", "payment_intent.created", "", 0, "usd", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload := []byte(fmt.Sprintf(`{"type":%q,"data":{"object":{"id":"cs_1","client_reference_id":"42","amount_total":%d,"currency":%q,"payment_status":%q}}}`,
+				test.event, test.amount, test.currency, test.status))
+			now := time.Now().Unix()
+			header := stripeHeader(fmt.Sprintf("t=%d,v1=%s", now, stripeSignature(testWebhookSecret, now, payload)))
+
+			event, err := provider.ParseWebhook(payload, header)
+			if err != nil {
+				t.Fatalf("ParseWebhook() failed: %v", err)
+			}
+
+			if test.want == nil {
+				if event != nil {
+					t.Errorf("ParseWebhook() = %+v, want nil", *event)
+				}
+
+				return
+			}
+
+			want := *test.want
+			want.SessionID, want.Reference = "cs_1", "42"
+
+			if event == nil || *event != want {
+				t.Errorf("ParseWebhook() = %+v, want %+v", event, want)
+			}
+		})
+	}
+}
+
+func TestStripeParseWebhookRejectsBadSignature(t *testing.T) {
+	provider := &StripeProvider{WebhookSecret: testWebhookSecret}
+	payload := []byte(`{"type":"checkout.session.expired"}`)
+	now := time.Now().Unix()
+	header := stripeHeader(fmt.Sprintf("t=%d,v1=%s", now, stripeSignature("whsec_other", now, payload)))
+
+	if _, err := provider.ParseWebhook(payload, header); !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("ParseWebhook() error = %v, want %v", err, ErrInvalidWebhook)
+	}
+}
+
+func TestStripeAmount(t *testing.T) {
+	tests := []struct {
+		amount   Money
+		currency string
+		want     int64
+	}{
+		{1234, "USD", 1234},
+		{1234, "usd", 1234},
+		{150000, "JPY", 1500},
+		{150049, "JPY", 1500},
+		{150050, "jpy", 1501},
+	}
+
+	for _, test := range tests {
+		if got := stripeAmount(test.amount, test.currency); got != test.want {
+			t.Errorf("stripeAmount(%d, %q) = %d, want %d", test.amount, test.currency, got, test.want)
+		}
+
+		charged := RoundToCurrency(test.amount, test.currency)
+		if back := moneyFromStripe(test.want, test.currency); back != charged {
+			t.Errorf("moneyFromStripe(%d, %q) = %d, want %d", test.want, test.currency, back, charged)
+		}
+	}
+}
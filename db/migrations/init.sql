@@ -24,13 +24,25 @@ CREATE TABLE "Subscriber" (
     "gender" TEXT NOT NULL,
     "duration" INTEGER,
     "daysLeft" INTEGER,
-    "bucketPrice" DECIMAL NOT NULL,
-    "paymentAmount" DECIMAL NOT NULL,
+    "bucketPrice" DECIMAL(15,3) NOT NULL,
+    "paymentAmount" DECIMAL(15,3) NOT NULL,
     "startedAt" DATETIME NOT NULL,
     "endsAt" DATETIME NOT NULL,
     "createdAt" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     "updatedAt" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-    "deletedAt" DATETIME
+    "deletedAt" DATETIME,
+    "phone" TEXT NOT NULL DEFAULT '',
+    "email" TEXT NOT NULL DEFAULT '',
+    "address" TEXT NOT NULL DEFAULT '',
+    "emergencyContactName" TEXT NOT NULL DEFAULT '',
+    "emergencyContactPhone" TEXT NOT NULL DEFAULT '',
+    "dateOfBirth" DATE,
+    "marketingEmail" BOOLEAN NOT NULL DEFAULT false,
+    "marketingSms" BOOLEAN NOT NULL DEFAULT false,
+    "marketingWhatsapp" BOOLEAN NOT NULL DEFAULT false,
+    "serviceEmail" BOOLEAN NOT NULL DEFAULT false,
+    "serviceSms" BOOLEAN NOT NULL DEFAULT false,
+    "serviceWhatsapp" BOOLEAN NOT NULL DEFAULT false
 );
 
 -- CreateTable
@@ -83,7 +95,7 @@ CREATE TABLE "Plan" (
     "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
     "title" TEXT NOT NULL,
     "description" TEXT NOT NULL,
-    "price" DECIMAL NOT NULL,
+    "price" DECIMAL(15,3) NOT NULL,
     "duration" TEXT NOT NULL,
     "createdAt" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     "updatedAt" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -93,7 +105,8 @@ CREATE TABLE "Plan" (
 -- CreateTable
 CREATE TABLE "ProductCategory" (
     "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
-    "name" TEXT NOT NULL
+    "name" TEXT NOT NULL,
+    "position" INTEGER NOT NULL DEFAULT 0
 );
 
 -- CreateTable
@@ -101,12 +114,15 @@ CREATE TABLE "Product" (
     "id" INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
     "name" TEXT NOT NULL,
     "description" TEXT NOT NULL,
-    "price" DECIMAL NOT NULL,
+    "price" DECIMAL(15,3) NOT NULL,
     "marka" TEXT NOT NULL,
     "categoryId" INTEGER NOT NULL,
     "createdAt" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     "updatedAt" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
     "deletedAt" DATETIME,
+    "stock" INTEGER NOT NULL DEFAULT 0,
+    "lowStockThreshold" INTEGER NOT NULL DEFAULT 0,
+    "lowStockAlertedAt" DATETIME,
     CONSTRAINT "Product_categoryId_fkey" FOREIGN KEY ("categoryId") REFERENCES "ProductCategory" ("id") ON DELETE RESTRICT ON UPDATE CASCADE
 );
 
@@ -133,7 +149,10 @@ CREATE TABLE "Trainer" (
     "description" TEXT NOT NULL,
     "instagram" TEXT NOT NULL,
     "facebook" TEXT NOT NULL,
-    "twitter" TEXT NOT NULL
+    "twitter" TEXT NOT NULL,
+    "userId" INTEGER,
+    "displayOrder" INTEGER NOT NULL DEFAULT 0,
+    CONSTRAINT "Trainer_userId_fkey" FOREIGN KEY ("userId") REFERENCES "User" ("id") ON DELETE SET NULL ON UPDATE CASCADE
 );
 
 -- CreateTable
@@ -181,6 +200,652 @@ CREATE TABLE Blog (
     views INT NOT NULL
 );
 
+CREATE TABLE SubscriberAttachment (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    subscriberId INT NOT NULL,
+    uploadedById INT NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    fileName VARCHAR(255) NOT NULL,
+    contentType VARCHAR(100) NOT NULL,
+    size BIGINT NOT NULL,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT SubscriberAttachment_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT SubscriberAttachment_uploadedById_fkey FOREIGN KEY (uploadedById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE TABLE SubscriberMeasurement (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    subscriberId INT NOT NULL,
+    recordedById INT NOT NULL,
+    measuredAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    weight DOUBLE,
+    height DOUBLE,
+    bodyFat DOUBLE,
+    chest DOUBLE,
+    waist DOUBLE,
+    hips DOUBLE,
+    arm DOUBLE,
+    thigh DOUBLE,
+    notes TEXT NOT NULL,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT SubscriberMeasurement_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT SubscriberMeasurement_recordedById_fkey FOREIGN KEY (recordedById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE TABLE MeasurementMetric (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    measurementId INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    value DOUBLE NOT NULL,
+    unit VARCHAR(16) NOT NULL DEFAULT '',
+    CONSTRAINT MeasurementMetric_measurementId_fkey FOREIGN KEY (measurementId) REFERENCES SubscriberMeasurement (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX SubscriberMeasurement_subscriberId_measuredAt_idx ON SubscriberMeasurement (subscriberId, measuredAt);
+
+CREATE TABLE WorkoutProgram (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    createdById INT NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    weeks INT NOT NULL DEFAULT 1,
+    isTemplate BOOLEAN NOT NULL DEFAULT false,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT WorkoutProgram_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE TABLE WorkoutProgramDay (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    programId INT NOT NULL,
+    week INT NOT NULL,
+    day INT NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    CONSTRAINT WorkoutProgramDay_programId_fkey FOREIGN KEY (programId) REFERENCES WorkoutProgram (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT WorkoutProgramDay_programId_week_day_key UNIQUE (programId, week, day)
+);
+
+CREATE TABLE WorkoutProgramExercise (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    dayId INT NOT NULL,
+    exerciseId INT NOT NULL,
+    position INT NOT NULL,
+    sets INT NOT NULL,
+    reps INT NOT NULL,
+    restSeconds INT NOT NULL DEFAULT 0,
+    loadKg DOUBLE,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    CONSTRAINT WorkoutProgramExercise_dayId_fkey FOREIGN KEY (dayId) REFERENCES WorkoutProgramDay (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT WorkoutProgramExercise_exerciseId_fkey FOREIGN KEY (exerciseId) REFERENCES Excercise (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE TABLE WorkoutAssignment (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    programId INT NOT NULL,
+    subscriberId INT NOT NULL,
+    assignedById INT NOT NULL,
+    startDate DATE NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT true,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT WorkoutAssignment_programId_fkey FOREIGN KEY (programId) REFERENCES WorkoutProgram (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT WorkoutAssignment_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT WorkoutAssignment_assignedById_fkey FOREIGN KEY (assignedById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE TABLE WorkoutSet (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    subscriberId INT NOT NULL,
+    exerciseId INT NOT NULL,
+    loggedById INT NOT NULL,
+    performedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    reps INT NOT NULL,
+    weight DOUBLE NOT NULL DEFAULT 0,
+    rpe DOUBLE,
+    estimatedOneRepMax DOUBLE NOT NULL DEFAULT 0,
+    isPersonalRecord BOOLEAN NOT NULL DEFAULT false,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT WorkoutSet_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT WorkoutSet_exerciseId_fkey FOREIGN KEY (exerciseId) REFERENCES Excercise (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT WorkoutSet_loggedById_fkey FOREIGN KEY (loggedById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE INDEX WorkoutSet_subscriberId_exerciseId_performedAt_idx ON WorkoutSet (subscriberId, exerciseId, performedAt);
+
+CREATE TABLE ClassType (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL,
+    defaultCapacity INT NOT NULL,
+    durationMinutes INT NOT NULL,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE ClassSchedule (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    classTypeId INT NOT NULL,
+    trainerId INT,
+    weekday INT NOT NULL,
+    startTime CHAR(5) NOT NULL,
+    durationMinutes INT NOT NULL,
+    capacity INT NOT NULL,
+    validFrom DATE NOT NULL,
+    validUntil DATE,
+    active BOOLEAN NOT NULL DEFAULT true,
+    CONSTRAINT ClassSchedule_classTypeId_fkey FOREIGN KEY (classTypeId) REFERENCES ClassType (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT ClassSchedule_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE ClassScheduleException (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    scheduleId INT,
+    date DATE NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    CONSTRAINT ClassScheduleException_scheduleId_fkey FOREIGN KEY (scheduleId) REFERENCES ClassSchedule (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE ClassSession (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    scheduleId INT,
+    classTypeId INT NOT NULL,
+    trainerId INT,
+    startsAt DATETIME NOT NULL,
+    endsAt DATETIME NOT NULL,
+    capacity INT NOT NULL,
+    cancelled BOOLEAN NOT NULL DEFAULT false,
+    exceptionId INT,
+    CONSTRAINT ClassSession_scheduleId_fkey FOREIGN KEY (scheduleId) REFERENCES ClassSchedule (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT ClassSession_classTypeId_fkey FOREIGN KEY (classTypeId) REFERENCES ClassType (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT ClassSession_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT ClassSession_exceptionId_fkey FOREIGN KEY (exceptionId) REFERENCES ClassScheduleException (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT ClassSession_scheduleId_startsAt_key UNIQUE (scheduleId, startsAt)
+);
+
+CREATE TABLE ClassBooking (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    sessionId INT NOT NULL,
+    subscriberId INT NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT ClassBooking_sessionId_fkey FOREIGN KEY (sessionId) REFERENCES ClassSession (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT ClassBooking_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT ClassBooking_sessionId_subscriberId_key UNIQUE (sessionId, subscriberId)
+);
+
+CREATE TABLE TrainerAvailability (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    trainerId INT NOT NULL,
+    weekday INT NOT NULL,
+    startTime CHAR(5) NOT NULL,
+    endTime CHAR(5) NOT NULL,
+    CONSTRAINT TrainerAvailability_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE TrainerTimeBlock (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    trainerId INT NOT NULL,
+    startsAt DATETIME NOT NULL,
+    endsAt DATETIME NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    CONSTRAINT TrainerTimeBlock_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE PTPackage (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    subscriberId INT NOT NULL,
+    trainerId INT,
+    totalSessions INT NOT NULL,
+    remainingSessions INT NOT NULL,
+    sessionMinutes INT NOT NULL,
+    price DECIMAL(15,3) NOT NULL DEFAULT 0,
+    purchasedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expiresAt DATE,
+    CONSTRAINT PTPackage_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT PTPackage_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE PTSession (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    trainerId INT NOT NULL,
+    subscriberId INT NOT NULL,
+    packageId INT,
+    bookedById INT NOT NULL,
+    startsAt DATETIME NOT NULL,
+    endsAt DATETIME NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'booked',
+    lateCancelCharge DECIMAL(15,3) NOT NULL DEFAULT 0,
+    chargePaymentId INT,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT PTSession_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT PTSession_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT PTSession_packageId_fkey FOREIGN KEY (packageId) REFERENCES PTPackage (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PTSession_bookedById_fkey FOREIGN KEY (bookedById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT PTSession_chargePaymentId_fkey FOREIGN KEY (chargePaymentId) REFERENCES Payment (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX PTSession_trainerId_startsAt_idx ON PTSession (trainerId, startsAt);
+
+CREATE TABLE TrainerClient (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    trainerId INT NOT NULL,
+    subscriberId INT NOT NULL,
+    assignedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT TrainerClient_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT TrainerClient_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT TrainerClient_trainerId_subscriberId_key UNIQUE (trainerId, subscriberId)
+);
+
+CREATE TABLE TrainerSpecialty (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    trainerId INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    CONSTRAINT TrainerSpecialty_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT TrainerSpecialty_trainerId_name_key UNIQUE (trainerId, name)
+);
+
+CREATE TABLE TrainerCertification (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    trainerId INT NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    issuer VARCHAR(128) NOT NULL DEFAULT '',
+    issuedAt DATE,
+    expiresAt DATE,
+    reminderSentAt DATETIME,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT TrainerCertification_trainerId_fkey FOREIGN KEY (trainerId) REFERENCES Trainer (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX TrainerCertification_expiresAt_idx ON TrainerCertification (expiresAt);
+
+CREATE TABLE StaffPayRate (
+    userId INT NOT NULL PRIMARY KEY,
+    payType VARCHAR(16) NOT NULL DEFAULT 'monthly',
+    hourlyRate DECIMAL(15,3) NOT NULL DEFAULT 0,
+    updatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    CONSTRAINT StaffPayRate_userId_fkey FOREIGN KEY (userId) REFERENCES User (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE Shift (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    userId INT NOT NULL,
+    startsAt DATETIME NOT NULL,
+    endsAt DATETIME NOT NULL,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT Shift_userId_fkey FOREIGN KEY (userId) REFERENCES User (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX Shift_userId_startsAt_idx ON Shift (userId, startsAt);
+
+CREATE TABLE TimeEntry (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    userId INT NOT NULL,
+    shiftId INT,
+    clockIn DATETIME NOT NULL,
+    clockOut DATETIME,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    CONSTRAINT TimeEntry_userId_fkey FOREIGN KEY (userId) REFERENCES User (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT TimeEntry_shiftId_fkey FOREIGN KEY (shiftId) REFERENCES Shift (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX TimeEntry_userId_clockIn_idx ON TimeEntry (userId, clockIn);
+
+CREATE TABLE PayAdjustment (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    userId INT NOT NULL,
+    period CHAR(7) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    createdById INT NOT NULL,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT PayAdjustment_userId_fkey FOREIGN KEY (userId) REFERENCES User (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT PayAdjustment_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE
+);
+
+CREATE INDEX PayAdjustment_period_idx ON PayAdjustment (period);
+
+CREATE TABLE PayrollRun (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    period CHAR(7) NOT NULL,
+    total DECIMAL(15,3) NOT NULL,
+    createdById INT NOT NULL,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT PayrollRun_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT PayrollRun_period_key UNIQUE (period)
+);
+
+CREATE TABLE Payslip (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    runId INT NOT NULL,
+    userId INT,
+    userName VARCHAR(255) NOT NULL DEFAULT '',
+    payType VARCHAR(16) NOT NULL,
+    hoursWorked DECIMAL(10,2) NOT NULL DEFAULT 0,
+    regularHours DECIMAL(10,2) NOT NULL DEFAULT 0,
+    overtimeHours DECIMAL(10,2) NOT NULL DEFAULT 0,
+    basePay DECIMAL(15,3) NOT NULL DEFAULT 0,
+    overtimePay DECIMAL(15,3) NOT NULL DEFAULT 0,
+    bonuses DECIMAL(15,3) NOT NULL DEFAULT 0,
+    deductions DECIMAL(15,3) NOT NULL DEFAULT 0,
+    netPay DECIMAL(15,3) NOT NULL DEFAULT 0,
+    CONSTRAINT Payslip_runId_fkey FOREIGN KEY (runId) REFERENCES PayrollRun (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT Payslip_userId_fkey FOREIGN KEY (userId) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE Payment (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    source VARCHAR(16) NOT NULL,
+    subscriberId INT,
+    planId INT,
+    amount DECIMAL(15,3) NOT NULL,
+    method VARCHAR(16) NOT NULL DEFAULT 'cash',
+    paidAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    createdById INT,
+    CONSTRAINT Payment_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT Payment_planId_fkey FOREIGN KEY (planId) REFERENCES Plan (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT Payment_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX Payment_paidAt_idx ON Payment (paidAt);
+
+CREATE TABLE Expense (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    category VARCHAR(32) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    spentAt DATE NOT NULL,
+    createdById INT,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT Expense_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX Expense_spentAt_idx ON Expense (spentAt);
+
+CREATE TABLE InvoiceCounter (
+    series VARCHAR(8) NOT NULL,
+    issueYear INT NOT NULL,
+    lastNumber INT NOT NULL,
+    PRIMARY KEY (series, issueYear)
+);
+
+CREATE TABLE Invoice (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    series VARCHAR(8) NOT NULL,
+    issueYear INT NOT NULL,
+    seqNumber INT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'issued',
+    subscriberId INT,
+    paymentId INT,
+    creditedInvoiceId INT,
+    billedTo VARCHAR(255) NOT NULL DEFAULT '',
+    billedToDetails VARCHAR(512) NOT NULL DEFAULT '',
+    sellerName VARCHAR(255) NOT NULL DEFAULT '',
+    sellerDetails VARCHAR(512) NOT NULL DEFAULT '',
+    paymentMethod VARCHAR(16) NOT NULL DEFAULT '',
+    paidAt DATETIME,
+    subtotal DECIMAL(15,3) NOT NULL,
+    taxTotal DECIMAL(15,3) NOT NULL,
+    total DECIMAL(15,3) NOT NULL,
+    currency VARCHAR(8) NOT NULL DEFAULT '',
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    voidReason VARCHAR(255) NOT NULL DEFAULT '',
+    voidedAt DATETIME,
+    issuedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    createdById INT,
+    CONSTRAINT Invoice_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT Invoice_paymentId_fkey FOREIGN KEY (paymentId) REFERENCES Payment (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT Invoice_creditedInvoiceId_fkey FOREIGN KEY (creditedInvoiceId) REFERENCES Invoice (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT Invoice_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT Invoice_number_key UNIQUE (series, issueYear, seqNumber)
+);
+
+CREATE INDEX Invoice_issuedAt_idx ON Invoice (issuedAt);
+
+CREATE TABLE InvoiceLine (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    invoiceId INT NOT NULL,
+    description VARCHAR(255) NOT NULL,
+    quantity DECIMAL(10,3) NOT NULL,
+    unitPrice DECIMAL(15,3) NOT NULL,
+    taxRate DECIMAL(6,3) NOT NULL DEFAULT 0,
+    amount DECIMAL(15,3) NOT NULL,
+    taxAmount DECIMAL(15,3) NOT NULL,
+    CONSTRAINT InvoiceLine_invoiceId_fkey FOREIGN KEY (invoiceId) REFERENCES Invoice (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE PromoCode (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    code VARCHAR(64) NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    discountType VARCHAR(16) NOT NULL,
+    discountPercent DECIMAL(5,2) NOT NULL DEFAULT 0,
+    discountAmount DECIMAL(15,3) NOT NULL DEFAULT 0,
+    appliesTo VARCHAR(16) NOT NULL DEFAULT 'all',
+    validFrom DATETIME,
+    validUntil DATETIME,
+    maxUses INT NOT NULL DEFAULT 0,
+    maxUsesPerMember INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT PromoCode_code_key UNIQUE (code)
+);
+
+CREATE TABLE PromoCodePlan (
+    promoCodeId INT NOT NULL,
+    planId INT NOT NULL,
+    PRIMARY KEY (promoCodeId, planId),
+    CONSTRAINT PromoCodePlan_promoCodeId_fkey FOREIGN KEY (promoCodeId) REFERENCES PromoCode (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT PromoCodePlan_planId_fkey FOREIGN KEY (planId) REFERENCES Plan (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE PromoCodeCategory (
+    promoCodeId INT NOT NULL,
+    categoryId INT NOT NULL,
+    PRIMARY KEY (promoCodeId, categoryId),
+    CONSTRAINT PromoCodeCategory_promoCodeId_fkey FOREIGN KEY (promoCodeId) REFERENCES PromoCode (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT PromoCodeCategory_categoryId_fkey FOREIGN KEY (categoryId) REFERENCES ProductCategory (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE PromoRedemption (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    promoCodeId INT NOT NULL,
+    subscriberId INT,
+    userId INT,
+    target VARCHAR(16) NOT NULL,
+    originalAmount DECIMAL(15,3) NOT NULL,
+    discountAmount DECIMAL(15,3) NOT NULL,
+    redeemedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT PromoRedemption_promoCodeId_fkey FOREIGN KEY (promoCodeId) REFERENCES PromoCode (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT PromoRedemption_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PromoRedemption_userId_fkey FOREIGN KEY (userId) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE ProductVariant (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    productId INT NOT NULL,
+    name VARCHAR(128) NOT NULL,
+    sku VARCHAR(64),
+    price DECIMAL(15,3),
+    stock INT NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ProductVariant_sku_key UNIQUE (sku),
+    CONSTRAINT ProductVariant_productId_fkey FOREIGN KEY (productId) REFERENCES Product (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE ProductVariantAttribute (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    variantId INT NOT NULL,
+    attrName VARCHAR(64) NOT NULL,
+    attrValue VARCHAR(128) NOT NULL,
+    CONSTRAINT ProductVariantAttribute_variantId_attrName_key UNIQUE (variantId, attrName),
+    CONSTRAINT ProductVariantAttribute_variantId_fkey FOREIGN KEY (variantId) REFERENCES ProductVariant (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE ProductBasket (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    quantity INT NOT NULL DEFAULT 1,
+    customerId INT NOT NULL,
+    productId INT NOT NULL,
+    variantId INT,
+    CONSTRAINT ProductBasket_customerId_fkey FOREIGN KEY (customerId) REFERENCES User (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT ProductBasket_productId_fkey FOREIGN KEY (productId) REFERENCES Product (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT ProductBasket_variantId_fkey FOREIGN KEY (variantId) REFERENCES ProductVariant (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE TABLE ProductOrder (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    customerId INT,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    subtotal DECIMAL(15,3) NOT NULL,
+    discount DECIMAL(15,3) NOT NULL DEFAULT 0,
+    total DECIMAL(15,3) NOT NULL,
+    promoCodeId INT,
+    promoRedemptionId INT,
+    paymentId INT,
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    cancelReason VARCHAR(255) NOT NULL DEFAULT '',
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    paidAt DATETIME,
+    readyAt DATETIME,
+    completedAt DATETIME,
+    cancelledAt DATETIME,
+    CONSTRAINT ProductOrder_customerId_fkey FOREIGN KEY (customerId) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT ProductOrder_promoCodeId_fkey FOREIGN KEY (promoCodeId) REFERENCES PromoCode (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT ProductOrder_promoRedemptionId_fkey FOREIGN KEY (promoRedemptionId) REFERENCES PromoRedemption (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT ProductOrder_paymentId_fkey FOREIGN KEY (paymentId) REFERENCES Payment (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX ProductOrder_status_idx ON ProductOrder (status, createdAt);
+
+CREATE TABLE ProductOrderLine (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    orderId INT NOT NULL,
+    productId INT,
+    variantId INT,
+    productName VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL,
+    unitPrice DECIMAL(15,3) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    discounted BOOLEAN NOT NULL DEFAULT FALSE,
+    CONSTRAINT ProductOrderLine_orderId_fkey FOREIGN KEY (orderId) REFERENCES ProductOrder (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT ProductOrderLine_productId_fkey FOREIGN KEY (productId) REFERENCES Product (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT ProductOrderLine_variantId_fkey FOREIGN KEY (variantId) REFERENCES ProductVariant (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE StockMovement (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    productId INT NOT NULL,
+    variantId INT,
+    kind VARCHAR(16) NOT NULL,
+    quantity INT NOT NULL,
+    stockAfter INT NOT NULL,
+    reason VARCHAR(255) NOT NULL DEFAULT '',
+    orderId INT,
+    createdById INT,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT StockMovement_productId_fkey FOREIGN KEY (productId) REFERENCES Product (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT StockMovement_variantId_fkey FOREIGN KEY (variantId) REFERENCES ProductVariant (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT StockMovement_orderId_fkey FOREIGN KEY (orderId) REFERENCES ProductOrder (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT StockMovement_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE INDEX StockMovement_productId_idx ON StockMovement (productId, createdAt);
+
+CREATE TABLE ProductImage (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    productId INT NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    isPrimary BOOLEAN NOT NULL DEFAULT FALSE,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT ProductImage_productId_fkey FOREIGN KEY (productId) REFERENCES Product (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
+CREATE INDEX ProductImage_productId_idx ON ProductImage (productId, position);
+
+CREATE TABLE PaymentIntent (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    provider VARCHAR(16) NOT NULL,
+    sessionId VARCHAR(255),
+    checkoutUrl VARCHAR(1024) NOT NULL DEFAULT '',
+    purpose VARCHAR(16) NOT NULL,
+    subscriberId INT,
+    orderId INT,
+    amount DECIMAL(15,3) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    confirmedAmount DECIMAL(15,3),
+    paymentId INT,
+    createdById INT,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    settledAt DATETIME,
+    CONSTRAINT PaymentIntent_provider_sessionId_key UNIQUE (provider, sessionId),
+    CONSTRAINT PaymentIntent_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PaymentIntent_orderId_fkey FOREIGN KEY (orderId) REFERENCES ProductOrder (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PaymentIntent_paymentId_fkey FOREIGN KEY (paymentId) REFERENCES Payment (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PaymentIntent_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE CashDrawerSession (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    openingFloat DECIMAL(15,3) NOT NULL DEFAULT 0,
+    openedById INT,
+    openedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expectedCash DECIMAL(15,3),
+    countedCash DECIMAL(15,3),
+    notes VARCHAR(255) NOT NULL DEFAULT '',
+    closedById INT,
+    closedAt DATETIME,
+    CONSTRAINT CashDrawerSession_openedById_fkey FOREIGN KEY (openedById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT CashDrawerSession_closedById_fkey FOREIGN KEY (closedById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE PosSale (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    drawerSessionId INT NOT NULL,
+    subscriberId INT,
+    customerName VARCHAR(255) NOT NULL DEFAULT '',
+    total DECIMAL(15,3) NOT NULL,
+    changeGiven DECIMAL(15,3) NOT NULL DEFAULT 0,
+    createdById INT,
+    createdAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT PosSale_drawerSessionId_fkey FOREIGN KEY (drawerSessionId) REFERENCES CashDrawerSession (id) ON DELETE RESTRICT ON UPDATE CASCADE,
+    CONSTRAINT PosSale_subscriberId_fkey FOREIGN KEY (subscriberId) REFERENCES Subscriber (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PosSale_createdById_fkey FOREIGN KEY (createdById) REFERENCES User (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE PosSaleLine (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    saleId INT NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    productId INT,
+    variantId INT,
+    planId INT,
+    description VARCHAR(255) NOT NULL,
+    quantity INT NOT NULL DEFAULT 1,
+    unitPrice DECIMAL(15,3) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    days INT,
+    endsAt DATETIME,
+    CONSTRAINT PosSaleLine_saleId_fkey FOREIGN KEY (saleId) REFERENCES PosSale (id) ON DELETE CASCADE ON UPDATE CASCADE,
+    CONSTRAINT PosSaleLine_productId_fkey FOREIGN KEY (productId) REFERENCES Product (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PosSaleLine_variantId_fkey FOREIGN KEY (variantId) REFERENCES ProductVariant (id) ON DELETE SET NULL ON UPDATE CASCADE,
+    CONSTRAINT PosSaleLine_planId_fkey FOREIGN KEY (planId) REFERENCES Plan (id) ON DELETE SET NULL ON UPDATE CASCADE
+);
+
+CREATE TABLE PosSalePayment (
+    id INT NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    saleId INT NOT NULL,
+    method VARCHAR(16) NOT NULL,
+    amount DECIMAL(15,3) NOT NULL,
+    CONSTRAINT PosSalePayment_saleId_fkey FOREIGN KEY (saleId) REFERENCES PosSale (id) ON DELETE CASCADE ON UPDATE CASCADE
+);
+
 -- CreateIndex
 CREATE UNIQUE INDEX "User_email_key" ON "User"("email");
 
@@ -205,6 +870,9 @@ CREATE UNIQUE INDEX "ExcerciseCategory_name_key" ON "ExcerciseCategory"("name");
 -- CreateIndex
 CREATE UNIQUE INDEX "Excercise_name_key" ON "Excercise"("name");
 
+-- CreateIndex
+CREATE UNIQUE INDEX "Trainer_userId_key" ON "Trainer"("userId");
+
 insert into LandingPageData (
     title, 
     starterSentence, 
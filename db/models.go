@@ -1,5 +1,7 @@
 package db
 
+import "github.com/HenryMarkle/gmserver/common"
+
 type User struct {
 	StartDate  string
 	Email      string
@@ -61,20 +63,41 @@ type Contacts struct {
 }
 
 type Subscriber struct {
-	StartedAt     string  `json:"startedAt"`
-	Name          string  `json:"name"`
-	Surname       string  `json:"surname"`
-	DeletedAt     string  `json:"deletedAt" binding:"omitempty"`
-	UpdatedAt     string  `json:"updatedAt" binding:"omitempty"`
-	CreatedAt     string  `json:"createdAt" binding:"omitempty"`
-	EndsAt        string  `json:"endsAt"`
-	Gender        string  `json:"gender"`
-	Age           int     `json:"age"`
-	PaymentAmount float64 `json:"paymentAmount"`
-	BucketPrice   float64 `json:"bucketPrice"`
-	DaysLeft      int     `json:"daysLeft" binding:"omitempty"`
-	Duration      int     `json:"duration" binding:"omitempty"`
-	ID            int     `json:"id"`
+	StartedAt     string       `json:"startedAt"`
+	Name          string       `json:"name"`
+	Surname       string       `json:"surname"`
+	DeletedAt     string       `json:"deletedAt" binding:"omitempty"`
+	UpdatedAt     string       `json:"updatedAt" binding:"omitempty"`
+	CreatedAt     string       `json:"createdAt" binding:"omitempty"`
+	EndsAt        string       `json:"endsAt"`
+	Gender        string       `json:"gender"`
+	Age           int          `json:"age"`
+	PaymentAmount common.Money `json:"paymentAmount"`
+	BucketPrice   common.Money `json:"bucketPrice"`
+	DaysLeft      int          `json:"daysLeft" binding:"omitempty"`
+	Duration      int          `json:"duration" binding:"omitempty"`
+	ID            int          `json:"id"`
+
+	// E.164, such as +905551234567
+	Phone                 string `json:"phone" binding:"omitempty,e164"`
+	Email                 string `json:"email" binding:"omitempty,email"`
+	Address               string `json:"address"`
+	EmergencyContactName  string `json:"emergencyContactName"`
+	EmergencyContactPhone string `json:"emergencyContactPhone" binding:"omitempty,e164"`
+	DateOfBirth           string `json:"dateOfBirth" binding:"omitempty,datetime=2006-01-02"`
+
+	Preferences CommunicationPreferences `json:"preferences"`
+}
+
+// Opt-in flags per channel. Marketing messages are promotions,
+// service messages are about the membership itself (renewals, schedule changes).
+type CommunicationPreferences struct {
+	MarketingEmail    bool `json:"marketingEmail"`
+	MarketingSMS      bool `json:"marketingSms"`
+	MarketingWhatsApp bool `json:"marketingWhatsapp"`
+	ServiceEmail      bool `json:"serviceEmail"`
+	ServiceSMS        bool `json:"serviceSms"`
+	ServiceWhatsApp   bool `json:"serviceWhatsapp"`
 }
 
 type SubscriberComment struct {
@@ -109,6 +132,8 @@ type ProductCategory struct {
 	Name     string
 	Products []Product
 	ID       int64
+	// Categories are listed by it, lowest first
+	Position int
 }
 
 type Product struct {
@@ -120,8 +145,16 @@ type Product struct {
 	UpdatedAt   string
 	DeletedAt   string
 	ID          int64
-	Price       float64
+	Price       common.Money
 	CategoryID  int64
+	Stock       int
+	// Staff are alerted when stock falls to it; zero disables the alert
+	LowStockThreshold int
+	// URL of the primary image, empty without images
+	ImageURL string
+	Images   []ProductImage
+	// Active variants; a product with variants is sold by variant
+	Variants []ProductVariant
 }
 
 type ProductBasket struct {
@@ -133,6 +166,9 @@ type ProductBasket struct {
 
 	ProductID int64
 	Product   *Product
+
+	VariantID *int64
+	Variant   *ProductVariant
 }
 
 type ExcerciseCategory struct {
@@ -156,7 +192,22 @@ type Trainer struct {
 	Instigram   string `json:"instagram"`
 	Facebook    string `json:"facebook"`
 	Twitter     string `json:"twitter"`
-	ID          int64  `json:"id"`
+	// The staff account the trainer logs in with
+	UserID *int64 `json:"userId"`
+	// Trainers are listed in ascending order
+	DisplayOrder   int                    `json:"displayOrder"`
+	Specialties    []string               `json:"specialties"`
+	Certifications []TrainerCertification `json:"certifications,omitempty"`
+	ID             int64                  `json:"id"`
+}
+
+type TrainerCertification struct {
+	Name      string `json:"name"`
+	Issuer    string `json:"issuer"`
+	IssuedAt  string `json:"issuedAt"`
+	ExpiresAt string `json:"expiresAt"`
+	ID        int64  `json:"id"`
+	TrainerID int64  `json:"trainerId"`
 }
 
 type Plan struct {
@@ -168,7 +219,7 @@ type Plan struct {
 	DeletedAt   string
 	Features    []PlanFeature
 	ID          int64
-	Price       float64
+	Price       common.Money
 }
 
 type PlanFeature struct {
@@ -209,3 +260,733 @@ type Blog struct {
 	Description string `json:"description"`
 	Views       int    `json:"views"`
 }
+
+type SubscriberAttachment struct {
+	ID           int64 `json:"id"`
+	SubscriberID int64 `json:"subscriberId"`
+	UploadedByID int64 `json:"uploadedById"`
+	// One of "id", "medical", "form" or "other"
+	Kind        string `json:"kind"`
+	FileName    string `json:"fileName"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// Lengths are in centimeters, weight in kilograms and body fat in percent.
+// Fields left unmeasured are nil.
+type SubscriberMeasurement struct {
+	ID           int64    `json:"id"`
+	SubscriberID int64    `json:"subscriberId"`
+	RecordedByID int64    `json:"recordedById"`
+	MeasuredAt   string   `json:"measuredAt"`
+	Weight       *float64 `json:"weight"`
+	Height       *float64 `json:"height"`
+	BodyFat      *float64 `json:"bodyFat"`
+	Chest        *float64 `json:"chest"`
+	Waist        *float64 `json:"waist"`
+	Hips         *float64 `json:"hips"`
+	Arm          *float64 `json:"arm"`
+	Thigh        *float64 `json:"thigh"`
+	Notes        string   `json:"notes"`
+	CreatedAt    string   `json:"createdAt"`
+
+	Metrics []MeasurementMetric `json:"metrics"`
+
+	// Computed, not stored
+	BMI    *float64           `json:"bmi"`
+	Deltas map[string]float64 `json:"deltas"`
+}
+
+// Custom metric recorded alongside a measurement
+type MeasurementMetric struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// Programs with IsTemplate set are meant to be cloned rather than assigned directly.
+type WorkoutProgram struct {
+	ID          int64  `json:"id"`
+	CreatedByID int64  `json:"createdById"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Weeks       int    `json:"weeks"`
+	IsTemplate  bool   `json:"isTemplate"`
+	CreatedAt   string `json:"createdAt"`
+	UpdatedAt   string `json:"updatedAt"`
+
+	Days []WorkoutProgramDay `json:"days,omitempty"`
+}
+
+// Day is 1 to 7, counted from the start date of an assignment.
+// Days without a record are rest days.
+type WorkoutProgramDay struct {
+	ID        int64  `json:"id"`
+	ProgramID int64  `json:"programId"`
+	Week      int    `json:"week"`
+	Day       int    `json:"day"`
+	Title     string `json:"title"`
+
+	Exercises []WorkoutProgramExercise `json:"exercises"`
+}
+
+type WorkoutProgramExercise struct {
+	ID           int64    `json:"id"`
+	DayID        int64    `json:"dayId"`
+	ExerciseID   int64    `json:"exerciseId"`
+	ExerciseName string   `json:"exerciseName"`
+	Position     int      `json:"position"`
+	Sets         int      `json:"sets"`
+	Reps         int      `json:"reps"`
+	RestSeconds  int      `json:"restSeconds"`
+	Load         *float64 `json:"load"`
+	Notes        string   `json:"notes"`
+}
+
+type WorkoutAssignment struct {
+	ID           int64  `json:"id"`
+	ProgramID    int64  `json:"programId"`
+	ProgramName  string `json:"programName"`
+	SubscriberID int64  `json:"subscriberId"`
+	AssignedByID int64  `json:"assignedById"`
+	StartDate    string `json:"startDate"`
+	Active       bool   `json:"active"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+// The workout of a subscriber's active program on a date
+type TodayWorkout struct {
+	Assignment *WorkoutAssignment `json:"assignment"`
+	Week       int                `json:"week"`
+	Day        int                `json:"day"`
+	// Set once the date is past the last week of the program
+	Finished bool `json:"finished"`
+	// Nil on rest days
+	Workout *WorkoutProgramDay `json:"workout"`
+}
+
+// A single performed set. Weight is in kilograms and RPE on a scale of 1 to 10.
+type WorkoutSet struct {
+	ID                 int64    `json:"id"`
+	SubscriberID       int64    `json:"subscriberId"`
+	ExerciseID         int64    `json:"exerciseId"`
+	ExerciseName       string   `json:"exerciseName"`
+	LoggedByID         int64    `json:"loggedById"`
+	PerformedAt        string   `json:"performedAt"`
+	Reps               int      `json:"reps"`
+	Weight             float64  `json:"weight"`
+	RPE                *float64 `json:"rpe"`
+	EstimatedOneRepMax float64  `json:"estimatedOneRepMax"`
+	IsPersonalRecord   bool     `json:"isPersonalRecord"`
+	CreatedAt          string   `json:"createdAt"`
+}
+
+type PersonalRecord struct {
+	ExerciseID             int64   `json:"exerciseId"`
+	ExerciseName           string  `json:"exerciseName"`
+	BestEstimatedOneRepMax float64 `json:"bestEstimatedOneRepMax"`
+	HeaviestWeight         float64 `json:"heaviestWeight"`
+	MostReps               int     `json:"mostReps"`
+	LastPerformedAt        string  `json:"lastPerformedAt"`
+}
+
+// Volume is the sum of reps times weight
+type WeeklyVolume struct {
+	// Monday of the week
+	WeekStart    string  `json:"weekStart"`
+	CategoryID   int64   `json:"categoryId"`
+	CategoryName string  `json:"categoryName"`
+	Sets         int     `json:"sets"`
+	Reps         int     `json:"reps"`
+	Volume       float64 `json:"volume"`
+}
+
+type ExerciseHistoryPoint struct {
+	Date                   string  `json:"date"`
+	BestEstimatedOneRepMax float64 `json:"bestEstimatedOneRepMax"`
+	TopWeight              float64 `json:"topWeight"`
+	Sets                   int     `json:"sets"`
+	Volume                 float64 `json:"volume"`
+}
+
+type ClassType struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	DefaultCapacity int    `json:"defaultCapacity"`
+	DurationMinutes int    `json:"durationMinutes"`
+	CreatedAt       string `json:"createdAt"`
+}
+
+// A weekly recurring class. Weekday follows time.Weekday (0 is Sunday) and
+// StartTime is "15:04". Sessions are generated between ValidFrom and ValidUntil.
+type ClassSchedule struct {
+	ID              int64  `json:"id"`
+	ClassTypeID     int64  `json:"classTypeId"`
+	ClassTypeName   string `json:"classTypeName"`
+	TrainerID       *int64 `json:"trainerId"`
+	Weekday         int    `json:"weekday"`
+	StartTime       string `json:"startTime"`
+	DurationMinutes int    `json:"durationMinutes"`
+	Capacity        int    `json:"capacity"`
+	ValidFrom       string `json:"validFrom"`
+	ValidUntil      string `json:"validUntil"`
+	Active          bool   `json:"active"`
+}
+
+// A date on which no sessions are held; applies to every schedule when ScheduleID is nil.
+type ClassScheduleException struct {
+	ID         int64  `json:"id"`
+	ScheduleID *int64 `json:"scheduleId"`
+	Date       string `json:"date"`
+	Reason     string `json:"reason"`
+}
+
+type ClassSession struct {
+	ID            int64  `json:"id"`
+	ScheduleID    *int64 `json:"scheduleId"`
+	ClassTypeID   int64  `json:"classTypeId"`
+	ClassTypeName string `json:"classTypeName"`
+	TrainerID     *int64 `json:"trainerId"`
+	StartsAt      string `json:"startsAt"`
+	EndsAt        string `json:"endsAt"`
+	Capacity      int    `json:"capacity"`
+	Cancelled     bool   `json:"cancelled"`
+	Booked        int    `json:"booked"`
+	Waitlisted    int    `json:"waitlisted"`
+}
+
+const (
+	BookingBooked     = "booked"
+	BookingWaitlisted = "waitlisted"
+	BookingCancelled  = "cancelled"
+	BookingAttended   = "attended"
+	BookingNoShow     = "noshow"
+)
+
+type ClassBooking struct {
+	ID           int64  `json:"id"`
+	SessionID    int64  `json:"sessionId"`
+	SubscriberID int64  `json:"subscriberId"`
+	Status       string `json:"status"`
+	CreatedAt    string `json:"createdAt"`
+	UpdatedAt    string `json:"updatedAt"`
+
+	SubscriberName string `json:"subscriberName"`
+}
+
+type NoShowStats struct {
+	SubscriberID   int64   `json:"subscriberId"`
+	SubscriberName string  `json:"subscriberName"`
+	Attended       int     `json:"attended"`
+	NoShows        int     `json:"noShows"`
+	Cancelled      int     `json:"cancelled"`
+	NoShowRate     float64 `json:"noShowRate"`
+}
+
+// A weekly window in which a trainer takes personal training sessions.
+// Weekday follows time.Weekday and times are "15:04".
+type TrainerAvailability struct {
+	ID        int64  `json:"id"`
+	TrainerID int64  `json:"trainerId"`
+	Weekday   int    `json:"weekday"`
+	StartTime string `json:"startTime"`
+	EndTime   string `json:"endTime"`
+}
+
+// Time a trainer is unavailable, such as leave, overriding availability
+type TrainerTimeBlock struct {
+	ID        int64  `json:"id"`
+	TrainerID int64  `json:"trainerId"`
+	StartsAt  string `json:"startsAt"`
+	EndsAt    string `json:"endsAt"`
+	Reason    string `json:"reason"`
+}
+
+// A prepaid bundle of personal training sessions. RemainingSessions drops as
+// sessions are completed (or forfeited by late cancellations and no-shows).
+type PTPackage struct {
+	ID                int64        `json:"id"`
+	SubscriberID      int64        `json:"subscriberId"`
+	TrainerID         *int64       `json:"trainerId"`
+	TotalSessions     int          `json:"totalSessions"`
+	RemainingSessions int          `json:"remainingSessions"`
+	SessionMinutes    int          `json:"sessionMinutes"`
+	Price             common.Money `json:"price"`
+	PurchasedAt       string       `json:"purchasedAt"`
+	ExpiresAt         string       `json:"expiresAt"`
+}
+
+const (
+	PTSessionBooked        = "booked"
+	PTSessionCompleted     = "completed"
+	PTSessionCancelled     = "cancelled"
+	PTSessionLateCancelled = "latecancelled"
+	PTSessionNoShow        = "noshow"
+)
+
+type PTSession struct {
+	ID           int64  `json:"id"`
+	TrainerID    int64  `json:"trainerId"`
+	SubscriberID int64  `json:"subscriberId"`
+	PackageID    *int64 `json:"packageId"`
+	BookedByID   int64  `json:"bookedById"`
+	StartsAt     string `json:"startsAt"`
+	EndsAt       string `json:"endsAt"`
+	Status       string `json:"status"`
+	// Owed by the subscriber for a late cancellation or no-show
+	LateCancelCharge common.Money `json:"lateCancelCharge"`
+	// The income ledger entry of the charge, nil while it's owed
+	ChargePaymentID *int64 `json:"chargePaymentId"`
+	Notes           string `json:"notes"`
+	CreatedAt       string `json:"createdAt"`
+	UpdatedAt       string `json:"updatedAt"`
+}
+
+const (
+	PayMonthly = "monthly"
+	PayHourly  = "hourly"
+)
+
+// Staff without a pay rate are paid their monthly salary
+type StaffPayRate struct {
+	UserID     int64        `json:"userId"`
+	PayType    string       `json:"payType"`
+	HourlyRate common.Money `json:"hourlyRate"`
+}
+
+type Shift struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"userId"`
+	StartsAt  string `json:"startsAt"`
+	EndsAt    string `json:"endsAt"`
+	Notes     string `json:"notes"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type TimeEntry struct {
+	ID      int64  `json:"id"`
+	UserID  int64  `json:"userId"`
+	ShiftID *int64 `json:"shiftId"`
+	ClockIn string `json:"clockIn"`
+	// Empty while the user is still clocked in
+	ClockOut string `json:"clockOut"`
+	Notes    string `json:"notes"`
+}
+
+const (
+	AdjustmentBonus     = "bonus"
+	AdjustmentDeduction = "deduction"
+)
+
+type PayAdjustment struct {
+	ID     int64 `json:"id"`
+	UserID int64 `json:"userId"`
+	// Month the adjustment is paid in, as YYYY-MM
+	Period      string       `json:"period"`
+	Kind        string       `json:"kind"`
+	Amount      common.Money `json:"amount"`
+	Reason      string       `json:"reason"`
+	CreatedByID int64        `json:"createdById"`
+	CreatedAt   string       `json:"createdAt"`
+}
+
+type PayrollRun struct {
+	ID          int64        `json:"id"`
+	Period      string       `json:"period"`
+	Total       common.Money `json:"total"`
+	CreatedByID int64        `json:"createdById"`
+	CreatedAt   string       `json:"createdAt"`
+	Payslips    []Payslip    `json:"payslips,omitempty"`
+}
+
+// A payroll calculated without being stored
+type PayrollPreview struct {
+	Period   string       `json:"period"`
+	Total    common.Money `json:"total"`
+	Payslips []Payslip    `json:"payslips"`
+}
+
+type Payslip struct {
+	ID    int64 `json:"id"`
+	RunID int64 `json:"runId"`
+	// Nil once the user is permanently deleted, the name is kept
+	UserID        *int64       `json:"userId"`
+	UserName      string       `json:"userName"`
+	PayType       string       `json:"payType"`
+	HoursWorked   float64      `json:"hoursWorked"`
+	RegularHours  float64      `json:"regularHours"`
+	OvertimeHours float64      `json:"overtimeHours"`
+	BasePay       common.Money `json:"basePay"`
+	OvertimePay   common.Money `json:"overtimePay"`
+	Bonuses       common.Money `json:"bonuses"`
+	Deductions    common.Money `json:"deductions"`
+	NetPay        common.Money `json:"netPay"`
+}
+
+const (
+	PaymentMembership = "membership"
+	PaymentProduct    = "product"
+	PaymentPT         = "pt"
+	PaymentOther      = "other"
+)
+
+// An entry of the income ledger
+type Payment struct {
+	ID int64 `json:"id"`
+	// membership, product, pt or other
+	Source       string       `json:"source"`
+	SubscriberID *int64       `json:"subscriberId"`
+	PlanID       *int64       `json:"planId"`
+	Amount       common.Money `json:"amount"`
+	// cash, card, transfer, online or other
+	Method      string `json:"method"`
+	PaidAt      string `json:"paidAt"`
+	Notes       string `json:"notes"`
+	CreatedByID *int64 `json:"createdById"`
+}
+
+type Expense struct {
+	ID          int64        `json:"id"`
+	Category    string       `json:"category"`
+	Amount      common.Money `json:"amount"`
+	Description string       `json:"description"`
+	SpentAt     string       `json:"spentAt"`
+	CreatedByID *int64       `json:"createdById"`
+	CreatedAt   string       `json:"createdAt"`
+}
+
+// An amount summed over a report bucket (day, week or month) and a group such as the payment method
+type AmountBucket struct {
+	Period string       `json:"period"`
+	Key    string       `json:"key"`
+	Amount common.Money `json:"amount"`
+}
+
+// When a subscriber joined and left, leaving being the earlier of their membership ending and their deletion
+type MembershipSpan struct {
+	CreatedAt string
+	LeftAt    string
+}
+
+// Period to compute KPIs for, as DateTimeLayout strings: from Start up to At
+type KPIWindow struct {
+	Start string
+	At    string
+}
+
+type KPISnapshot struct {
+	// Members active at the window's point in time
+	ActiveMembers int
+	// Members that joined within the window
+	NewMembers int
+	// Active members whose membership ends within 7 days of the window's point in time
+	ExpiringSoon int
+	// Monthly value of the active memberships, each membership's price spread over its length
+	MonthlyRecurringRevenue common.Money
+	// Income recorded within the window
+	Income common.Money
+	// Members active at the window's start, and how many of them were still active at its point in time
+	RetentionBase int
+	Retained      int
+}
+
+// An invoice (series INV) or a credit note (series CN), numbered gaplessly per series and year
+type Invoice struct {
+	ID int64 `json:"id"`
+	// Such as INV-2026-000042
+	Number   string `json:"number"`
+	Series   string `json:"series"`
+	Year     int    `json:"year"`
+	Sequence int    `json:"sequence"`
+	// issued, void or credited
+	Status            string `json:"status"`
+	SubscriberID      *int64 `json:"subscriberId"`
+	PaymentID         *int64 `json:"paymentId"`
+	CreditedInvoiceID *int64 `json:"creditedInvoiceId"`
+	BilledTo          string `json:"billedTo"`
+	BilledToDetails   string `json:"billedToDetails"`
+	SellerName        string `json:"sellerName"`
+	SellerDetails     string `json:"sellerDetails"`
+	// Empty while unpaid
+	PaymentMethod string        `json:"paymentMethod"`
+	PaidAt        string        `json:"paidAt"`
+	Subtotal      common.Money  `json:"subtotal"`
+	TaxTotal      common.Money  `json:"taxTotal"`
+	Total         common.Money  `json:"total"`
+	Currency      string        `json:"currency"`
+	Notes         string        `json:"notes"`
+	VoidReason    string        `json:"voidReason"`
+	VoidedAt      string        `json:"voidedAt"`
+	IssuedAt      string        `json:"issuedAt"`
+	CreatedByID   *int64        `json:"createdById"`
+	Lines         []InvoiceLine `json:"lines,omitempty"`
+}
+
+// Amount is before tax
+type InvoiceLine struct {
+	ID          int64        `json:"id"`
+	InvoiceID   int64        `json:"invoiceId"`
+	Description string       `json:"description"`
+	Quantity    float64      `json:"quantity"`
+	UnitPrice   common.Money `json:"unitPrice"`
+	TaxRate     float64      `json:"taxRate"`
+	Amount      common.Money `json:"amount"`
+	TaxAmount   common.Money `json:"taxAmount"`
+}
+
+// Codes are stored uppercased. Empty plan and category lists apply the code to every plan or product.
+type PromoCode struct {
+	ID          int64  `json:"id"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	// percent or fixed
+	DiscountType string `json:"discountType"`
+	// 0 for fixed codes
+	DiscountPercent float64 `json:"discountPercent"`
+	// 0 for percent codes
+	DiscountAmount common.Money `json:"discountAmount"`
+	// all, plans or products
+	AppliesTo string `json:"appliesTo"`
+	// Empty when unbounded
+	ValidFrom  string `json:"validFrom"`
+	ValidUntil string `json:"validUntil"`
+	// 0 when unlimited
+	MaxUses          int     `json:"maxUses"`
+	MaxUsesPerMember int     `json:"maxUsesPerMember"`
+	Active           bool    `json:"active"`
+	CreatedAt        string  `json:"createdAt"`
+	PlanIDs          []int64 `json:"planIds"`
+	CategoryIDs      []int64 `json:"categoryIds"`
+	Uses             int     `json:"uses"`
+}
+
+const (
+	PromoTargetMembership = "membership"
+	PromoTargetProducts   = "products"
+)
+
+// Membership redemptions are made by a subscriber, product redemptions by a user
+type PromoRedemption struct {
+	ID           int64  `json:"id"`
+	PromoCodeID  int64  `json:"promoCodeId"`
+	SubscriberID *int64 `json:"subscriberId"`
+	UserID       *int64 `json:"userId"`
+	// membership or products
+	Target         string       `json:"target"`
+	OriginalAmount common.Money `json:"originalAmount"`
+	DiscountAmount common.Money `json:"discountAmount"`
+	RedeemedAt     string       `json:"redeemedAt"`
+}
+
+const (
+	OrderPending   = "pending"
+	OrderPaid      = "paid"
+	OrderReady     = "ready"
+	OrderCompleted = "completed"
+	OrderCancelled = "cancelled"
+)
+
+// A checked out basket. Lines keep the names and prices products had at checkout.
+type Order struct {
+	ID           int64  `json:"id"`
+	CustomerID   *int64 `json:"customerId"`
+	CustomerName string `json:"customerName"`
+	// pending, paid, ready, completed or cancelled
+	Status            string       `json:"status"`
+	Subtotal          common.Money `json:"subtotal"`
+	Discount          common.Money `json:"discount"`
+	Total             common.Money `json:"total"`
+	PromoCodeID       *int64       `json:"promoCodeId"`
+	PromoRedemptionID *int64       `json:"-"`
+	PaymentID         *int64       `json:"paymentId"`
+	Notes             string       `json:"notes"`
+	CancelReason      string       `json:"cancelReason"`
+	CreatedAt         string       `json:"createdAt"`
+	UpdatedAt         string       `json:"updatedAt"`
+	// Empty until the order reaches the status
+	PaidAt      string      `json:"paidAt"`
+	ReadyAt     string      `json:"readyAt"`
+	CompletedAt string      `json:"completedAt"`
+	CancelledAt string      `json:"cancelledAt"`
+	Lines       []OrderLine `json:"lines,omitempty"`
+}
+
+type OrderLine struct {
+	ID      int64 `json:"id"`
+	OrderID int64 `json:"orderId"`
+	// Nil once the product is purged
+	ProductID *int64 `json:"productId"`
+	VariantID *int64 `json:"variantId"`
+	// Includes the variant's name
+	ProductName string       `json:"productName"`
+	Quantity    int          `json:"quantity"`
+	UnitPrice   common.Money `json:"unitPrice"`
+	Amount      common.Money `json:"amount"`
+	// Whether the order's promo code applied to the line
+	Discounted bool `json:"discounted"`
+}
+
+const (
+	StockPurchase   = "purchase"
+	StockSale       = "sale"
+	StockAdjustment = "adjustment"
+	StockReturn     = "return"
+)
+
+// A change to a product's stock. Quantity is negative when stock goes out.
+// StockAfter is the variant's stock when the movement is of a variant.
+type StockMovement struct {
+	ID        int64  `json:"id"`
+	ProductID int64  `json:"productId"`
+	VariantID *int64 `json:"variantId"`
+	// purchase, sale, adjustment or return
+	Kind        string `json:"kind"`
+	Quantity    int    `json:"quantity"`
+	StockAfter  int    `json:"stockAfter"`
+	Reason      string `json:"reason"`
+	OrderID     *int64 `json:"orderId"`
+	CreatedByID *int64 `json:"createdById"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// Files are stored as STORAGE_PATH/products/<productId>/<id><ext>
+type ProductImage struct {
+	ID        int64 `json:"id"`
+	ProductID int64 `json:"productId"`
+	Position  int   `json:"position"`
+	// The image shown in listings; a product with images has exactly one
+	Primary   bool   `json:"primary"`
+	URL       string `json:"url"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// A sellable version of a product, such as a flavor or a size, with its own stock.
+// The product's stock is the total of its variants'.
+type ProductVariant struct {
+	ID        int64  `json:"id"`
+	ProductID int64  `json:"productId"`
+	Name      string `json:"name"`
+	SKU       string `json:"sku"`
+	// Nil sells the variant at the product's price
+	Price  *common.Money `json:"price"`
+	Stock  int           `json:"stock"`
+	Active bool          `json:"active"`
+	// Such as flavor: chocolate, size: 1kg
+	Attributes map[string]string `json:"attributes"`
+	CreatedAt  string            `json:"createdAt"`
+}
+
+const (
+	IntentMembership = "membership"
+	IntentOrder      = "order"
+)
+
+const (
+	IntentPending = "pending"
+	// Charged a different amount or currency than asked for, left for staff to resolve
+	IntentReview = "review"
+)
+
+// An online payment taken through the payment provider, for a subscriber's membership or an order
+type PaymentIntent struct {
+	ID          int64  `json:"id"`
+	Provider    string `json:"provider"`
+	SessionID   string `json:"sessionId"`
+	CheckoutURL string `json:"checkoutUrl"`
+	// membership or order
+	Purpose      string       `json:"purpose"`
+	SubscriberID *int64       `json:"subscriberId"`
+	OrderID      *int64       `json:"orderId"`
+	Amount       common.Money `json:"amount"`
+	Currency     string       `json:"currency"`
+	// pending, succeeded, failed, expired or review
+	Status string `json:"status"`
+	// What the provider actually charged, nil until it succeeds or is flagged for review
+	ConfirmedAmount *common.Money `json:"confirmedAmount"`
+	// The income ledger entry recorded for it
+	PaymentID   *int64 `json:"paymentId"`
+	CreatedByID *int64 `json:"createdById"`
+	CreatedAt   string `json:"createdAt"`
+	SettledAt   string `json:"settledAt"`
+}
+
+// A till shift from its opening float to the end of day count.
+// Expected cash is the float plus the cash taken by sales; it's stored once the drawer is closed.
+type CashDrawerSession struct {
+	ID           int64        `json:"id"`
+	OpeningFloat common.Money `json:"openingFloat"`
+	OpenedByID   *int64       `json:"openedById"`
+	OpenedAt     string       `json:"openedAt"`
+	ExpectedCash common.Money `json:"expectedCash"`
+	// Nil until the drawer is closed
+	CountedCash *common.Money `json:"countedCash"`
+	// Counted minus expected; negative when cash is missing
+	Difference *common.Money `json:"difference"`
+	Notes      string        `json:"notes"`
+	ClosedByID *int64        `json:"closedById"`
+	ClosedAt   string        `json:"closedAt"`
+	SaleCount  int           `json:"saleCount"`
+	// Taken by sales, by payment method
+	Totals map[string]common.Money `json:"totals"`
+}
+
+const (
+	PosLineProduct    = "product"
+	PosLineMembership = "membership"
+)
+
+// A counter sale to a subscriber or a walk-in customer
+type PosSale struct {
+	ID              int64  `json:"id"`
+	DrawerSessionID int64  `json:"drawerSessionId"`
+	SubscriberID    *int64 `json:"subscriberId"`
+	// The subscriber's name, or the walk-in's if given
+	CustomerName string       `json:"customerName"`
+	Total        common.Money `json:"total"`
+	// Handed back from cash paid over the total
+	ChangeGiven common.Money     `json:"changeGiven"`
+	CreatedByID *int64           `json:"createdById"`
+	CreatedAt   string           `json:"createdAt"`
+	Lines       []PosSaleLine    `json:"lines,omitempty"`
+	Payments    []PosSalePayment `json:"payments,omitempty"`
+}
+
+type PosSaleLine struct {
+	ID     int64 `json:"id"`
+	SaleID int64 `json:"saleId"`
+	// product or membership
+	Kind        string       `json:"kind"`
+	ProductID   *int64       `json:"productId"`
+	VariantID   *int64       `json:"variantId"`
+	PlanID      *int64       `json:"planId"`
+	Description string       `json:"description"`
+	Quantity    int          `json:"quantity"`
+	UnitPrice   common.Money `json:"unitPrice"`
+	Amount      common.Money `json:"amount"`
+	// Days a membership renewal adds, and the subscriber's endsAt after it
+	Days   *int   `json:"days"`
+	EndsAt string `json:"endsAt"`
+}
+
+// Part of a sale's total taken by a payment method, net of change
+type PosSalePayment struct {
+	ID     int64        `json:"id"`
+	SaleID int64        `json:"saleId"`
+	Method string       `json:"method"`
+	Amount common.Money `json:"amount"`
+}
+
+// The outcome of one item of a bulk change. Error says why the item couldn't be changed.
+type BulkItemResult struct {
+	ID    int64  `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Bulk changes apply to every item or to none; Applied is false when any item failed
+type BulkResult struct {
+	Applied bool             `json:"applied"`
+	Items   []BulkItemResult `json:"items"`
+}
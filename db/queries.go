@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/HenryMarkle/gmserver/common"
 	_ "github.com/go-sql-driver/mysql"
@@ -56,7 +57,7 @@ func AddAccount(
 }
 
 func GetUserByID(db *sql.DB, id int64) (*User, error) {
-	query := `SELECT email, name, password, session, lastLogin, age, salary, permission, gender, startDate FROM User WHERE id = ?`
+	query := `SELECT email, name, password, session, lastLogin, age, salary, permission, gender, startDate FROM User WHERE id = ? AND deletedAt IS NULL`
 
 	row := db.QueryRow(query, id)
 
@@ -75,7 +76,7 @@ func GetUserByID(db *sql.DB, id int64) (*User, error) {
 }
 
 func GetUserBySession(db *sql.DB, session string) (*User, error) {
-	query := `SELECT id, email, name, password, session, lastLogin, age, salary, permission, gender, startDate FROM User WHERE session = ?`
+	query := `SELECT id, email, name, password, session, lastLogin, age, salary, permission, gender, startDate FROM User WHERE session = ? AND deletedAt IS NULL`
 
 	row := db.QueryRow(query, session)
 
@@ -94,7 +95,7 @@ func GetUserBySession(db *sql.DB, session string) (*User, error) {
 }
 
 func GetUserByEmail(db *sql.DB, email string) (*User, error) {
-	query := `SELECT id, session, name, password, lastLogin, age, salary, permission, gender, startDate FROM User WHERE email = ?`
+	query := `SELECT id, session, name, password, lastLogin, age, salary, permission, gender, startDate FROM User WHERE email = ? AND deletedAt IS NULL`
 
 	row := db.QueryRow(query, email)
 
@@ -158,7 +159,7 @@ func DeleteUserByID(db *sql.DB, id int64) error {
 }
 
 func CountUsers(db *sql.DB) (int, error) {
-	query := `SELECT COUNT(*) FROM User`
+	query := `SELECT COUNT(*) FROM User WHERE deletedAt IS NULL`
 	var count int
 	scanErr := db.QueryRow(query).Scan(&count)
 	if scanErr != nil {
@@ -226,22 +227,10 @@ func GetAllUsers(db *sql.DB) ([]User, error) {
 	return users, nil
 }
 
-func GetTotalSalaries(db *sql.DB) (int, error) {
-	query := `SELECT SUM(salary) FROM User`
+func GetTotalSubscriberPaymentAmount(db *sql.DB) (common.Money, error) {
+	query := `SELECT COALESCE(SUM(paymentAmount), 0) as total FROM Subscriber WHERE deletedAt IS NULL`
 
-	sum := 0
-	scanErr := db.QueryRow(query).Scan(&sum)
-	if scanErr != nil {
-		return 0, fmt.Errorf("failed to sum the total salaries: %w", scanErr)
-	}
-
-	return sum, nil
-}
-
-func GetTotalSubscriberPaymentAmount(db *sql.DB) (float64, error) {
-	query := `SELECT COALESCE(SUM(paymentAmount), 0) as total FROM Subscriber`
-
-	var totalAmount float64
+	var totalAmount common.Money
 
 	err := db.QueryRow(query).Scan(&totalAmount)
 	if err != nil {
@@ -252,7 +241,7 @@ func GetTotalSubscriberPaymentAmount(db *sql.DB) (float64, error) {
 }
 
 func GetSubscriberCount(db *sql.DB) (int, error) {
-	query := `SELECT COUNT(*) FROM Subscriber`
+	query := `SELECT COUNT(*) FROM Subscriber WHERE deletedAt IS NULL`
 
 	var number int
 
@@ -266,7 +255,7 @@ func GetSubscriberCount(db *sql.DB) (int, error) {
 
 // / Time string must be of format '2024-11-21 12:00:00'
 func GetAllSubscribersEndingBefore(db *sql.DB, time string) (int, error) {
-	query := `SELECT COUNT(endsAt) FROM Subscriber WHERE endsAt < ?`
+	query := `SELECT COUNT(endsAt) FROM Subscriber WHERE endsAt < ? AND deletedAt IS NULL`
 
 	var number int
 
@@ -280,7 +269,7 @@ func GetAllSubscribersEndingBefore(db *sql.DB, time string) (int, error) {
 
 // / Time string must be of format '2024-11-21 12:00:00'
 func GetAllExpiredSubscribers(db *sql.DB) (int, error) {
-	query := `SELECT COUNT(endsAt) FROM Subscriber WHERE endsAt > CURRENT_TIMESTAMP`
+	query := `SELECT COUNT(endsAt) FROM Subscriber WHERE endsAt > CURRENT_TIMESTAMP AND deletedAt IS NULL`
 
 	var number int
 
@@ -292,37 +281,170 @@ func GetAllExpiredSubscribers(db *sql.DB) (int, error) {
 	return number, nil
 }
 
+// Records the membership payment in the same transaction when one is given
+// The payment and the promo code redemption are optional. A redemption beyond the code's
+// limits fails with ErrPromoCodeExhausted or ErrPromoCodeMemberLimit.
 func CreateSubscriber(db *sql.DB,
 	data Subscriber,
-) error {
+	payment *Payment,
+	redemption *PromoRedemption,
+) (int64, error) {
 	query := `
   INSERT INTO Subscriber 
-  (name, surname, age, gender, paymentAmount, startedAt, endsAt, bucketPrice) 
+  (name, surname, age, gender, paymentAmount, startedAt, endsAt, bucketPrice,
+  phone, email, address, emergencyContactName, emergencyContactPhone, dateOfBirth,
+  marketingEmail, marketingSms, marketingWhatsapp, serviceEmail, serviceSms, serviceWhatsapp) 
   VALUES 
-  (?, ?, ?, ?, ?, ?, ?, ?)`
+  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	prefs := data.Preferences
 
-	_, err := db.Exec(query, data.Name, data.Surname, data.Age, data.Gender, data.PaymentAmount, data.StartedAt, data.EndsAt, data.BucketPrice)
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create subscriber (failed to begin transaction): %w", txErr)
+	}
+
+	res, err := tx.Exec(query, data.Name, data.Surname, data.Age, data.Gender, data.PaymentAmount, data.StartedAt, data.EndsAt, data.BucketPrice,
+		data.Phone, data.Email, data.Address, data.EmergencyContactName, data.EmergencyContactPhone, nullIfEmpty(data.DateOfBirth),
+		prefs.MarketingEmail, prefs.MarketingSMS, prefs.MarketingWhatsApp, prefs.ServiceEmail, prefs.ServiceSMS, prefs.ServiceWhatsApp)
 	if err != nil {
-		return fmt.Errorf("failed to create subscriber: %w", err)
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create subscriber: %w", err)
 	}
 
-	return nil
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created subscriber ID: %w", idErr)
+	}
+
+	if payment != nil {
+		payment.SubscriberID = &id
+
+		if _, execErr := tx.Exec(createPaymentQuery, paymentArgs(payment)...); execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create subscriber (failed to record payment): %w", execErr)
+		}
+	}
+
+	if redemption != nil {
+		redemption.SubscriberID = &id
+
+		if redeemErr := redeemPromoCode(tx, redemption, data.Phone, data.Email); redeemErr != nil {
+			tx.Rollback()
+
+			if errors.Is(redeemErr, ErrPromoCodeExhausted) || errors.Is(redeemErr, ErrPromoCodeMemberLimit) {
+				return 0, redeemErr
+			}
+
+			return 0, fmt.Errorf("failed to create subscriber: %w", redeemErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create subscriber (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Selected after the base subscriber columns; scanned with subscriberContactDest
+const subscriberContactColumns = `phone, email, address, emergencyContactName, emergencyContactPhone, COALESCE(dateOfBirth, ''),
+  marketingEmail, marketingSms, marketingWhatsapp, serviceEmail, serviceSms, serviceWhatsapp`
+
+func subscriberContactDest(sub *Subscriber) []interface{} {
+	prefs := &sub.Preferences
+
+	return []interface{}{
+		&sub.Phone, &sub.Email, &sub.Address, &sub.EmergencyContactName, &sub.EmergencyContactPhone, &sub.DateOfBirth,
+		&prefs.MarketingEmail, &prefs.MarketingSMS, &prefs.MarketingWhatsApp, &prefs.ServiceEmail, &prefs.ServiceSMS, &prefs.ServiceWhatsApp,
+	}
 }
 
 func GetAllSubscribers(db *sql.DB, limit int) ([]Subscriber, error) {
-	query := `SELECT id, name, surname, age, gender, COALESCE(duration, 0), COALESCE(daysLeft, 0), bucketPrice, paymentAmount, startedAt, endsAt, createdAt, updatedAt, COALESCE(deletedAt, '') FROM Subscriber`
+	return GetSubscribersFiltered(db, SubscriberFilter{Limit: limit})
+}
+
+// Empty filter fields are ignored
+type SubscriberFilter struct {
+	Search string
+	Gender string
+	// "active" or "expired"
+	Status string
+	// Bounds on createdAt, in common.DateTimeLayout
+	From  string
+	To    string
+	Limit int
+	// Lists only soft-deleted subscribers instead of excluding them
+	Deleted bool
+	// Lists only subscribers assigned to this trainer
+	TrainerID int64
+}
+
+// Makes LIKE match wildcards in searches literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func GetSubscribersFiltered(db *sql.DB, filter SubscriberFilter) ([]Subscriber, error) {
+	query := `SELECT id, name, surname, age, gender, COALESCE(duration, 0), COALESCE(daysLeft, 0), bucketPrice, paymentAmount, startedAt, endsAt, createdAt, updatedAt, COALESCE(deletedAt, ''), ` + subscriberContactColumns + ` FROM Subscriber`
+
+	conditions := []string{"deletedAt IS NULL"}
+	args := []interface{}{}
+
+	if filter.Deleted {
+		conditions[0] = "deletedAt IS NOT NULL"
+	}
+
+	if filter.Search != "" {
+		pattern := "%" + likeEscaper.Replace(filter.Search) + "%"
+
+		conditions = append(conditions, "(name LIKE ? OR surname LIKE ?)")
+		args = append(args, pattern, pattern)
+	}
+
+	if filter.Gender != "" {
+		conditions = append(conditions, "gender = ?")
+		args = append(args, filter.Gender)
+	}
+
+	switch filter.Status {
+	case "active":
+		conditions = append(conditions, "endsAt >= CURRENT_TIMESTAMP")
+	case "expired":
+		conditions = append(conditions, "endsAt < CURRENT_TIMESTAMP")
+	}
+
+	if filter.TrainerID != 0 {
+		conditions = append(conditions, "id IN (SELECT subscriberId FROM TrainerClient WHERE trainerId = ?)")
+		args = append(args, filter.TrainerID)
+	}
+
+	if filter.From != "" {
+		conditions = append(conditions, "createdAt >= ?")
+		args = append(args, filter.From)
+	}
+
+	if filter.To != "" {
+		conditions = append(conditions, "createdAt <= ?")
+		args = append(args, filter.To)
+	}
+
+	query = fmt.Sprintf("%s WHERE %s", query, strings.Join(conditions, " AND "))
+
+	query += " ORDER BY id"
 
-	if limit > 0 {
-		query = fmt.Sprintf("%s %s %d", query, "LIMIT", limit)
+	if filter.Limit > 0 {
+		query = fmt.Sprintf("%s %s %d", query, "LIMIT", filter.Limit)
 	}
 
-	rows, err := db.Query(query)
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return []Subscriber{}, nil
 		}
 
-		return nil, fmt.Errorf("failed to get all subscribers: %w", err)
+		return nil, fmt.Errorf("failed to get subscribers: %w", err)
 	}
 
 	defer rows.Close()
@@ -332,35 +454,16 @@ func GetAllSubscribers(db *sql.DB, limit int) ([]Subscriber, error) {
 	counter := 0
 
 	for rows.Next() {
-		var (
-			id, age, duration, daysLeft int
-			name, surname, gender,
-			startedAt, endsAt, createdAt, updatedAt, deletedAt string
+		sub := Subscriber{}
 
-			bucketPrice, paymentAmount float64
-		)
+		dest := append([]interface{}{&sub.ID, &sub.Name, &sub.Surname, &sub.Age, &sub.Gender, &sub.Duration, &sub.DaysLeft, &sub.BucketPrice, &sub.PaymentAmount, &sub.StartedAt, &sub.EndsAt, &sub.CreatedAt, &sub.UpdatedAt, &sub.DeletedAt}, subscriberContactDest(&sub)...)
 
-		scanErr := rows.Scan(&id, &name, &surname, &age, &gender, &duration, &daysLeft, &bucketPrice, &paymentAmount, &startedAt, &endsAt, &createdAt, &updatedAt, &deletedAt)
+		scanErr := rows.Scan(dest...)
 
 		if scanErr != nil {
 			common.Logger.Printf("Failed to scan subscriber rows at row (%d): %v\n", counter, scanErr)
 		} else {
-			subs = append(subs, Subscriber{
-				ID:            id,
-				Name:          name,
-				Surname:       surname,
-				Age:           age,
-				Gender:        gender,
-				Duration:      duration,
-				DaysLeft:      daysLeft,
-				BucketPrice:   bucketPrice,
-				PaymentAmount: paymentAmount,
-				StartedAt:     startedAt,
-				EndsAt:        endsAt,
-				CreatedAt:     createdAt,
-				UpdatedAt:     updatedAt,
-				DeletedAt:     deletedAt,
-			})
+			subs = append(subs, sub)
 		}
 
 		counter++
@@ -369,13 +472,92 @@ func GetAllSubscribers(db *sql.DB, limit int) ([]Subscriber, error) {
 	return subs, nil
 }
 
+// Subscribers are identified by name and surname when importing.
+// Returns a nil ID when no undeleted subscriber matches.
+func FindSubscriberIDByNaturalKey(db *sql.DB, name, surname string) (*int64, error) {
+	query := `SELECT id FROM Subscriber WHERE name = ? AND surname = ? AND deletedAt IS NULL ORDER BY id LIMIT 1`
+
+	var id int64
+
+	scanErr := db.QueryRow(query, name, surname).Scan(&id)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to find a subscriber by natural key: %w", scanErr)
+	}
+
+	return &id, nil
+}
+
+// Creates and updates subscribers in a single transaction.
+// Updated subscribers only have their imported fields replaced.
+func ImportSubscribers(db *sql.DB, created, updated []Subscriber) error {
+	createQuery := `
+  INSERT INTO Subscriber 
+  (name, surname, age, gender, paymentAmount, startedAt, endsAt, bucketPrice,
+  phone, email, address, emergencyContactName, emergencyContactPhone, dateOfBirth) 
+  VALUES 
+  (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// Contact details left empty in the file keep their current values
+	updateQuery := `
+  UPDATE Subscriber 
+  SET name = ?, surname = ?, age = ?, gender = ?, paymentAmount = ?, startedAt = ?, endsAt = ?, bucketPrice = ?,
+    phone = COALESCE(NULLIF(?, ''), phone),
+    email = COALESCE(NULLIF(?, ''), email),
+    address = COALESCE(NULLIF(?, ''), address),
+    emergencyContactName = COALESCE(NULLIF(?, ''), emergencyContactName),
+    emergencyContactPhone = COALESCE(NULLIF(?, ''), emergencyContactPhone),
+    dateOfBirth = COALESCE(?, dateOfBirth),
+    updatedAt = CURRENT_TIMESTAMP 
+  WHERE id = ?`
+
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to import subscribers (failed to begin transaction): %w", txErr)
+	}
+
+	for _, data := range created {
+		_, execErr := tx.Exec(createQuery, data.Name, data.Surname, data.Age, data.Gender, data.PaymentAmount, data.StartedAt, data.EndsAt, data.BucketPrice,
+			data.Phone, data.Email, data.Address, data.EmergencyContactName, data.EmergencyContactPhone, nullIfEmpty(data.DateOfBirth))
+		if execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to import subscribers (failed to create '%s %s'): %w", data.Name, data.Surname, execErr)
+		}
+	}
+
+	for _, data := range updated {
+		_, execErr := tx.Exec(updateQuery, data.Name, data.Surname, data.Age, data.Gender, data.PaymentAmount, data.StartedAt, data.EndsAt, data.BucketPrice,
+			data.Phone, data.Email, data.Address, data.EmergencyContactName, data.EmergencyContactPhone, nullIfEmpty(data.DateOfBirth), data.ID)
+		if execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to import subscribers (failed to update id: %d): %w", data.ID, execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to import subscribers (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
 func GetSubscriberByID(db *sql.DB, id int64) (*Subscriber, error) {
-	query := `SELECT id, name, surname, age, gender, COALESCE(duration, 0), COALESCE(daysLeft, 0), bucketPrice, paymentAmount, startedAt, endsAt, createdAt, updatedAt FROM Subscriber WHERE id = ? AND deletedAt IS NULL`
+	query := `SELECT id, name, surname, age, gender, COALESCE(duration, 0), COALESCE(daysLeft, 0), bucketPrice, paymentAmount, startedAt, endsAt, createdAt, updatedAt, ` + subscriberContactColumns + ` FROM Subscriber WHERE id = ? AND deletedAt IS NULL`
 
 	sub := &Subscriber{}
-	scanErr := db.QueryRow(query, id).Scan(&sub.ID, &sub.Name, &sub.Surname, &sub.Age, &sub.Gender, &sub.Duration, &sub.DaysLeft, &sub.BucketPrice, &sub.PaymentAmount, &sub.StartedAt, &sub.EndsAt, &sub.CreatedAt, &sub.UpdatedAt)
+	dest := append([]interface{}{&sub.ID, &sub.Name, &sub.Surname, &sub.Age, &sub.Gender, &sub.Duration, &sub.DaysLeft, &sub.BucketPrice, &sub.PaymentAmount, &sub.StartedAt, &sub.EndsAt, &sub.CreatedAt, &sub.UpdatedAt}, subscriberContactDest(sub)...)
+	scanErr := db.QueryRow(query, id).Scan(dest...)
 
 	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
 		return nil, fmt.Errorf("failed to get subscriber ID: %w", scanErr)
 	}
 
@@ -383,10 +565,11 @@ func GetSubscriberByID(db *sql.DB, id int64) (*Subscriber, error) {
 }
 
 func GetSubscriberByIDWithDeleted(db *sql.DB, id int) (*Subscriber, error) {
-	query := `SELECT id, name, surname, age, gender, duration, daysLeft, bucketPrice, paymentAmount, startedAt, endsAt, createdAt, updatedAt, COALESCE(deletedAt, '') FROM Subscriber WHERE id = ?`
+	query := `SELECT id, name, surname, age, gender, COALESCE(duration, 0), COALESCE(daysLeft, 0), bucketPrice, paymentAmount, startedAt, endsAt, createdAt, updatedAt, COALESCE(deletedAt, ''), ` + subscriberContactColumns + ` FROM Subscriber WHERE id = ?`
 
 	sub := &Subscriber{}
-	scanErr := db.QueryRow(query, id).Scan(&sub.ID, &sub.Name, &sub.Surname, &sub.Age, &sub.Gender, &sub.Duration, &sub.DaysLeft, &sub.BucketPrice, &sub.PaymentAmount, &sub.StartedAt, &sub.EndsAt, &sub.CreatedAt, &sub.UpdatedAt, &sub.DeletedAt)
+	dest := append([]interface{}{&sub.ID, &sub.Name, &sub.Surname, &sub.Age, &sub.Gender, &sub.Duration, &sub.DaysLeft, &sub.BucketPrice, &sub.PaymentAmount, &sub.StartedAt, &sub.EndsAt, &sub.CreatedAt, &sub.UpdatedAt, &sub.DeletedAt}, subscriberContactDest(sub)...)
+	scanErr := db.QueryRow(query, id).Scan(dest...)
 
 	if scanErr != nil {
 		return nil, fmt.Errorf("failed to get subscriber ID: %w", scanErr)
@@ -396,18 +579,40 @@ func GetSubscriberByIDWithDeleted(db *sql.DB, id int) (*Subscriber, error) {
 }
 
 func DeleteSubscriberByID(db *sql.DB, id int64, permanent bool) error {
-	query := `DELETE FROM Subscriber WHERE id = ?`
-
 	if !permanent {
-		query = `UPDATE Subscriber SET deletedAt = CURRENT_TIMESTAMP WHERE id = ?`
+		query := `UPDATE Subscriber SET deletedAt = CURRENT_TIMESTAMP WHERE id = ?`
+
+		_, execErr := db.Exec(query, id)
+		if execErr != nil {
+			return fmt.Errorf("failed to delete a subscriber by ID: %w", execErr)
+		}
+
+		return nil
 	}
 
-	_, execErr := db.Exec(query, id)
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to delete a subscriber by ID (failed to begin transaction): %w", txErr)
+	}
 
-	if execErr != nil {
+	for _, dependent := range trashDependents["customers"] {
+		if _, execErr := tx.Exec(dependent, id); execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to delete a subscriber by ID (failed to delete dependents): %w", execErr)
+		}
+	}
+
+	if _, execErr := tx.Exec(`DELETE FROM Subscriber WHERE id = ?`, id); execErr != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to delete a subscriber by ID: %w", execErr)
 	}
 
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a subscriber by ID (failed to commit transaction): %w", commitErr)
+	}
+
 	return nil
 }
 
@@ -427,9 +632,23 @@ func UpdateSubscriber(db *sql.DB, data Subscriber) error {
     endsAt = ?,
     createdAt = ?,
     updatedAt = ?,
-    deletedAt = ?
+    deletedAt = ?,
+    phone = ?,
+    email = ?,
+    address = ?,
+    emergencyContactName = ?,
+    emergencyContactPhone = ?,
+    dateOfBirth = ?,
+    marketingEmail = ?,
+    marketingSms = ?,
+    marketingWhatsapp = ?,
+    serviceEmail = ?,
+    serviceSms = ?,
+    serviceWhatsapp = ?
   WHERE id = ?`
 
+	prefs := data.Preferences
+
 	_, execErr := db.Exec(query,
 		data.Name,
 		data.Surname,
@@ -444,6 +663,18 @@ func UpdateSubscriber(db *sql.DB, data Subscriber) error {
 		data.CreatedAt,
 		data.UpdatedAt,
 		data.DeletedAt,
+		data.Phone,
+		data.Email,
+		data.Address,
+		data.EmergencyContactName,
+		data.EmergencyContactPhone,
+		nullIfEmpty(data.DateOfBirth),
+		prefs.MarketingEmail,
+		prefs.MarketingSMS,
+		prefs.MarketingWhatsApp,
+		prefs.ServiceEmail,
+		prefs.ServiceSMS,
+		prefs.ServiceWhatsApp,
 		data.ID)
 
 	if execErr != nil {
@@ -453,6 +684,20 @@ func UpdateSubscriber(db *sql.DB, data Subscriber) error {
 	return nil
 }
 
+func UpdateSubscriberPreferences(db *sql.DB, id int64, prefs CommunicationPreferences) error {
+	query := `
+  UPDATE Subscriber 
+  SET marketingEmail = ?, marketingSms = ?, marketingWhatsapp = ?, serviceEmail = ?, serviceSms = ?, serviceWhatsapp = ?, updatedAt = CURRENT_TIMESTAMP 
+  WHERE id = ?`
+
+	_, execErr := db.Exec(query, prefs.MarketingEmail, prefs.MarketingSMS, prefs.MarketingWhatsApp, prefs.ServiceEmail, prefs.ServiceSMS, prefs.ServiceWhatsApp, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to update subscriber preferences (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
 func GetPlanFeatures(db *sql.DB, planID int64) ([]PlanFeature, error) {
 	query := `SELECT id, name FROM PlanFeature WHERE planId = ?`
 
@@ -576,7 +821,7 @@ func GetPlansWithDeleted(db *sql.DB) ([]Plan, error) {
 }
 
 func GetPlanByID(db *sql.DB, id int64) (*Plan, error) {
-	query := `SELECT title, description, price, duration, createdAt, updatedAt, COALESCE(deletedAt, '') FROM Plan WHERE id = ?`
+	query := `SELECT title, description, price, duration, createdAt, updatedAt, COALESCE(deletedAt, '') FROM Plan WHERE id = ? AND deletedAt IS NULL`
 
 	plan := &Plan{}
 
@@ -604,7 +849,7 @@ func CreatePlan(db *sql.DB, plan Plan) (int64, error) {
 }
 
 func DeletePlanByID(db *sql.DB, id int64) error {
-	query := `DELETE FROM Plan WHERE id = ?`
+	query := `UPDATE Plan SET deletedAt = CURRENT_TIMESTAMP WHERE id = ?`
 
 	_, err := db.Exec(query, id)
 	if err != nil {
@@ -650,7 +895,7 @@ func ReplacePlan(db *sql.DB, plan Plan) error {
 }
 
 func GetProducts(db *sql.DB) ([]Product, error) {
-	query := `SELECT id, name, description, price, marka, categoryId FROM Product`
+	query := `SELECT id, name, description, price, marka, categoryId, stock, lowStockThreshold FROM Product WHERE deletedAt IS NULL`
 
 	rows, queryErr := db.Query(query)
 
@@ -670,7 +915,7 @@ func GetProducts(db *sql.DB) ([]Product, error) {
 	for rows.Next() {
 		product := Product{}
 
-		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID)
+		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID, &product.Stock, &product.LowStockThreshold)
 		if scanErr != nil {
 			common.Logger.Printf("Failed to scan a product from rows at row (%d): %v\n", counter, scanErr)
 		} else {
@@ -685,7 +930,7 @@ func GetProducts(db *sql.DB) ([]Product, error) {
 
 // / Categories come with empty arrays
 func GetProductsWithCategories(db *sql.DB) ([]Product, error) {
-	query := `SELECT P.id, P.name, P.description, P.price, P.marka, P.categoryId, C.id, C.name FROM Product AS P LEFT JOIN ProductCategory AS C`
+	query := `SELECT P.id, P.name, P.description, P.price, P.marka, P.categoryId, P.stock, P.lowStockThreshold, C.id, C.name FROM Product AS P LEFT JOIN ProductCategory AS C ON C.id = P.categoryId WHERE P.deletedAt IS NULL`
 
 	rows, queryErr := db.Query(query)
 
@@ -705,7 +950,7 @@ func GetProductsWithCategories(db *sql.DB) ([]Product, error) {
 	for rows.Next() {
 		product := Product{Category: &ProductCategory{}}
 
-		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.Category.ID, &product.CategoryID, &product.Category.Name)
+		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID, &product.Stock, &product.LowStockThreshold, &product.Category.ID, &product.Category.Name)
 		if scanErr != nil {
 			common.Logger.Printf("Failed to scan a product from rows at row (%d): %v\n", counter, scanErr)
 		} else {
@@ -719,15 +964,15 @@ func GetProductsWithCategories(db *sql.DB) ([]Product, error) {
 }
 
 func GetProductByID(db *sql.DB, id int64) (*Product, error) {
-	query := `SELECT P.id, P.name, P.description, P.price, P.marka, P.categoryId FROM Product AS P WHERE id = ?`
+	query := `SELECT P.id, P.name, P.description, P.price, P.marka, P.categoryId, P.stock, P.lowStockThreshold FROM Product AS P WHERE id = ? AND deletedAt IS NULL`
 
 	product := &Product{}
 
-	scanErr := db.QueryRow(query, id).Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID)
+	scanErr := db.QueryRow(query, id).Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID, &product.Stock, &product.LowStockThreshold)
 
 	if scanErr != nil {
 		if scanErr == sql.ErrNoRows {
-			return nil, fmt.Errorf("product with ID (%d) not found", id)
+			return nil, fmt.Errorf("%w (id: %d)", ErrProductNotFound, id)
 		}
 
 		return nil, fmt.Errorf("failed to get a product by ID (%d): %w", id, scanErr)
@@ -738,11 +983,11 @@ func GetProductByID(db *sql.DB, id int64) (*Product, error) {
 
 // Category comes with an empty array
 func GetProductWithCategoryByID(db *sql.DB, id int64) (*Product, error) {
-	query := `SELECT P.id, P.name, P.description, P.price, P.marka, P.categoryId, C.id, C.name FROM Product AS P LEFT JOIN ProductCategory WHERE P.id = ?`
+	query := `SELECT P.id, P.name, P.description, P.price, P.marka, P.categoryId, P.stock, P.lowStockThreshold, C.id, C.name FROM Product AS P LEFT JOIN ProductCategory AS C ON C.id = P.categoryId WHERE P.id = ? AND P.deletedAt IS NULL`
 
 	product := &Product{Category: &ProductCategory{}}
 
-	scanErr := db.QueryRow(query, id).Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID, &product.Category.ID, &product.Category.Name)
+	scanErr := db.QueryRow(query, id).Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID, &product.Stock, &product.LowStockThreshold, &product.Category.ID, &product.Category.Name)
 
 	if scanErr != nil {
 		if scanErr == sql.ErrNoRows {
@@ -817,7 +1062,7 @@ func DeleteProductByID(db *sql.DB, id int64) error {
 }
 
 func GetProductCategories(db *sql.DB) ([]ProductCategory, error) {
-	query := `SELECT id, name FROM ProductCategory`
+	query := `SELECT id, name, position FROM ProductCategory ORDER BY position, id`
 
 	rows, queryErr := db.Query(query)
 	if queryErr != nil {
@@ -832,7 +1077,7 @@ func GetProductCategories(db *sql.DB) ([]ProductCategory, error) {
 	for rows.Next() {
 		category := ProductCategory{}
 
-		scanErr := rows.Scan(&category.ID, &category.Name)
+		scanErr := rows.Scan(&category.ID, &category.Name, &category.Position)
 		if scanErr != nil {
 			common.Logger.Printf("Failed to scan a product category from rows at row (%d): %v\n", counter, scanErr)
 		} else {
@@ -846,7 +1091,7 @@ func GetProductCategories(db *sql.DB) ([]ProductCategory, error) {
 }
 
 func GetProductsOfCategoryByID(db *sql.DB, id int64) ([]Product, error) {
-	query := `SELECT id, name, description, marka, price, categoryId, createdAt, updatedAt, COALESCE(deletedAt, '') FROM Product WHERE categoryId = ?`
+	query := `SELECT id, name, description, marka, price, categoryId, stock, lowStockThreshold, createdAt, updatedAt, COALESCE(deletedAt, '') FROM Product WHERE categoryId = ? AND deletedAt IS NULL`
 
 	rows, queryErr := db.Query(query, id)
 	if queryErr != nil {
@@ -865,7 +1110,7 @@ func GetProductsOfCategoryByID(db *sql.DB, id int64) ([]Product, error) {
 	for rows.Next() {
 		product := Product{}
 
-		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Marka, &product.Price, &product.CategoryID, &product.CreatedAt, &product.UpdatedAt, &product.DeletedAt)
+		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Marka, &product.Price, &product.CategoryID, &product.Stock, &product.LowStockThreshold, &product.CreatedAt, &product.UpdatedAt, &product.DeletedAt)
 		if scanErr != nil {
 			common.Logger.Printf("Failed to scan a product from rows at row (%d): %v\n", counter, scanErr)
 		} else {
@@ -879,7 +1124,7 @@ func GetProductsOfCategoryByID(db *sql.DB, id int64) ([]Product, error) {
 }
 
 func GetProductCategoriesWithProducts(db *sql.DB) ([]ProductCategory, error) {
-	query := `SELECT id, name FROM ProductCategory`
+	query := `SELECT id, name, position FROM ProductCategory ORDER BY position, id`
 
 	rows, queryErr := db.Query(query)
 	if queryErr != nil {
@@ -898,7 +1143,7 @@ func GetProductCategoriesWithProducts(db *sql.DB) ([]ProductCategory, error) {
 	for rows.Next() {
 		category := ProductCategory{}
 
-		scanErr := rows.Scan(&category.ID, &category.Name)
+		scanErr := rows.Scan(&category.ID, &category.Name, &category.Position)
 		if scanErr != nil {
 			common.Logger.Printf("Failed to scan a product category from rows at row (%d): %v\n", counter, scanErr)
 		} else {
@@ -919,11 +1164,11 @@ func GetProductCategoriesWithProducts(db *sql.DB) ([]ProductCategory, error) {
 }
 
 func GetProductCategoryByID(db *sql.DB, id int64) (*ProductCategory, error) {
-	query := `SELECT id, name FROM ProductCategory WHERE id = ?`
+	query := `SELECT id, name, position FROM ProductCategory WHERE id = ?`
 
 	category := ProductCategory{}
 
-	scanErr := db.QueryRow(query, id).Scan(&category.ID, &category.Name)
+	scanErr := db.QueryRow(query, id).Scan(&category.ID, &category.Name, &category.Position)
 	if scanErr != nil {
 		return nil, fmt.Errorf("Failed to scan category: %w\n", scanErr)
 	}
@@ -932,11 +1177,11 @@ func GetProductCategoryByID(db *sql.DB, id int64) (*ProductCategory, error) {
 }
 
 func GetProductCategoryByName(db *sql.DB, name string) (*ProductCategory, error) {
-	query := `SELECT id, name FROM ProductCategory WHERE name = ?`
+	query := `SELECT id, name, position FROM ProductCategory WHERE name = ?`
 
 	category := ProductCategory{}
 
-	scanErr := db.QueryRow(query, name).Scan(&category.ID, &category.Name)
+	scanErr := db.QueryRow(query, name).Scan(&category.ID, &category.Name, &category.Position)
 	if scanErr != nil {
 		return nil, fmt.Errorf("Failed to scan category: %w\n", scanErr)
 	}
@@ -945,7 +1190,7 @@ func GetProductCategoryByName(db *sql.DB, name string) (*ProductCategory, error)
 }
 
 func DeleteProductsOfCategoryByID(db *sql.DB, id int64) error {
-	query := `DELETE FROM Product WHERE categoryId = ?`
+	query := `UPDATE Product SET deletedAt = CURRENT_TIMESTAMP WHERE categoryId = ? AND deletedAt IS NULL`
 
 	_, queryErr := db.Exec(query, id)
 	if queryErr != nil {
@@ -956,7 +1201,7 @@ func DeleteProductsOfCategoryByID(db *sql.DB, id int64) error {
 }
 
 func ProductExistsUnderCategory(db *sql.DB, productId, categoryId int64) (bool, error) {
-	query := `SELECT 1 FROM Product WHERE id = ? AND categoryId = ?`
+	query := `SELECT 1 FROM Product WHERE id = ? AND categoryId = ? AND deletedAt IS NULL`
 
 	var exists bool
 	scanErr := db.QueryRow(query, productId, categoryId).Scan(exists)
@@ -986,7 +1231,8 @@ func GetLandingPageGeneralInfo(db *sql.DB) (*LandingPageGeneralData, error) {
 }
 
 func CreateProductCategory(db *sql.DB, name string) (int64, error) {
-	query := `INSERT INTO ProductCategory (name) VALUES (?)`
+	// New categories are listed last
+	query := `INSERT INTO ProductCategory (name, position) SELECT ?, COALESCE(MAX(position) + 1, 0) FROM ProductCategory`
 
 	res, queryErr := db.Exec(query, name)
 	if queryErr != nil {
@@ -1010,11 +1256,11 @@ func DeleteProductCategoryByID(db *sql.DB, id int64) error {
 }
 
 func GetProductBasketByID(db *sql.DB, id int64) (*ProductBasket, error) {
-	query := `SELECT * FROM ProductBasket WHERE id = ?`
+	query := `SELECT id, quantity, customerId, productId, variantId FROM ProductBasket WHERE id = ?`
 
 	basket := ProductBasket{}
 
-	scanErr := db.QueryRow(query, id).Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID)
+	scanErr := db.QueryRow(query, id).Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID, &basket.VariantID)
 	if scanErr != nil {
 		return nil, fmt.Errorf("failed to query or scan product basket: %w", scanErr)
 	}
@@ -1023,11 +1269,11 @@ func GetProductBasketByID(db *sql.DB, id int64) (*ProductBasket, error) {
 }
 
 func GetProductBasketByID_WithProduct(db *sql.DB, id int64) (*ProductBasket, error) {
-	query := `SELECT * FROM ProductBasket WHERE id = ?`
+	query := `SELECT id, quantity, customerId, productId, variantId FROM ProductBasket WHERE id = ?`
 
 	basket := ProductBasket{}
 
-	scanErr := db.QueryRow(query, id).Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID)
+	scanErr := db.QueryRow(query, id).Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID, &basket.VariantID)
 	if scanErr != nil {
 		if scanErr == sql.ErrNoRows {
 			return nil, nil
@@ -1041,13 +1287,22 @@ func GetProductBasketByID_WithProduct(db *sql.DB, id int64) (*ProductBasket, err
 		}
 
 		basket.Product = product
+
+		if basket.VariantID != nil {
+			variant, variantQueryErr := GetProductVariantByID(db, *basket.VariantID)
+			if variantQueryErr != nil {
+				return nil, fmt.Errorf("failed to fetch basket product variant: %w", variantQueryErr)
+			}
+
+			basket.Variant = variant
+		}
 	}
 
 	return &basket, nil
 }
 
 func GetAllBasketProductsOfUser(db *sql.DB, userID int64) ([]ProductBasket, error) {
-	query := `SELECT * FROM ProductBasket WHERE customerId = ?`
+	query := `SELECT id, quantity, customerId, productId, variantId FROM ProductBasket WHERE customerId = ?`
 
 	rows, queryErr := db.Query(query, userID)
 	if queryErr != nil {
@@ -1066,7 +1321,7 @@ func GetAllBasketProductsOfUser(db *sql.DB, userID int64) ([]ProductBasket, erro
 	for rows.Next() {
 		basket := ProductBasket{}
 
-		scanErr := rows.Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID)
+		scanErr := rows.Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID, &basket.VariantID)
 		if scanErr != nil {
 			common.Logger.Printf("failed to scan a basket product at row %d: %v", counter, scanErr)
 		} else {
@@ -1080,7 +1335,7 @@ func GetAllBasketProductsOfUser(db *sql.DB, userID int64) ([]ProductBasket, erro
 }
 
 func GetAllBasketProductsOfUser_WithProducts(db *sql.DB, userID int64) ([]ProductBasket, error) {
-	query := `SELECT * FROM ProductBasket WHERE customerId = ?`
+	query := `SELECT id, quantity, customerId, productId, variantId FROM ProductBasket WHERE customerId = ?`
 
 	rows, queryErr := db.Query(query, userID)
 	if queryErr != nil {
@@ -1099,7 +1354,7 @@ func GetAllBasketProductsOfUser_WithProducts(db *sql.DB, userID int64) ([]Produc
 	for rows.Next() {
 		basket := ProductBasket{}
 
-		scanErr := rows.Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID)
+		scanErr := rows.Scan(&basket.ID, &basket.Quantity, &basket.CustomerID, &basket.ProductID, &basket.VariantID)
 		if scanErr != nil {
 			common.Logger.Printf("failed to scan a basket product at row %d: %v", counter, scanErr)
 		} else {
@@ -1110,6 +1365,15 @@ func GetAllBasketProductsOfUser_WithProducts(db *sql.DB, userID int64) ([]Produc
 				basket.Product = product
 			}
 
+			if basket.VariantID != nil {
+				variant, variantQueryErr := GetProductVariantByID(db, *basket.VariantID)
+				if variantQueryErr != nil {
+					common.Logger.Printf("failed to fetch a product variant by ID for array at row %d: %v", counter, variantQueryErr)
+				} else {
+					basket.Variant = variant
+				}
+			}
+
 			baskets = append(baskets, basket)
 		}
 
@@ -1119,32 +1383,105 @@ func GetAllBasketProductsOfUser_WithProducts(db *sql.DB, userID int64) ([]Produc
 	return baskets, nil
 }
 
-func CreateProductBasket(db *sql.DB, userID, productID int64, quantity int) (int64, error) {
+// Locks the product, or its variant when variantID isn't nil, within tx and returns the stock it can be sold from.
+// Fails with ErrVariantRequired if a product with variants is sold without one.
+func lockSellableStock(tx *sql.Tx, productID int64, variantID *int64) (int, error) {
+	var stock int
+	var hasVariants bool
+
+	scanErr := tx.QueryRow(`SELECT stock, EXISTS (SELECT 1 FROM ProductVariant WHERE productId = P.id AND active) FROM Product AS P WHERE id = ? AND deletedAt IS NULL FOR UPDATE`, productID).Scan(&stock, &hasVariants)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return 0, ErrProductNotFound
+		}
+
+		return 0, fmt.Errorf("failed to lock product: %w", scanErr)
+	}
+
+	if variantID == nil {
+		if hasVariants {
+			return 0, ErrVariantRequired
+		}
+
+		return stock, nil
+	}
+
+	scanErr = tx.QueryRow(`SELECT stock FROM ProductVariant WHERE id = ? AND productId = ? AND active FOR UPDATE`, *variantID, productID).Scan(&stock)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return 0, ErrVariantNotFound
+		}
+
+		return 0, fmt.Errorf("failed to lock product variant: %w", scanErr)
+	}
+
+	return stock, nil
+}
+
+// Adds quantity of a product, or of one of its variants, to the user's basket.
+// Fails with ErrInsufficientStock if the basket would hold more than is in stock.
+func CreateProductBasket(db *sql.DB, userID, productID int64, variantID *int64, quantity int) (int64, error) {
 	if quantity < 1 {
 		return 0, errors.New("quantity must be a positive non-zero number")
 	}
 
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to add to basket (failed to begin transaction): %w", txErr)
+	}
+
+	stock, lockErr := lockSellableStock(tx, productID, variantID)
+	if lockErr != nil {
+		tx.Rollback()
+
+		if errors.Is(lockErr, ErrProductNotFound) || errors.Is(lockErr, ErrVariantNotFound) || errors.Is(lockErr, ErrVariantRequired) {
+			return 0, lockErr
+		}
+
+		return 0, fmt.Errorf("failed to add to basket: %w", lockErr)
+	}
+
 	var basketID int64
-	existsQuery := db.QueryRow(`SELECT id FROM ProductBasket WHERE userId = ? AND productId = ? LIMIT 1`, userID, productID).Scan(&basketID)
-	if existsQuery == nil {
-		_, incrementErr := db.Exec(`UPDATE ProductBasket SET quantity = quantity + 1 WHERE id = ?`, basketID)
+	var inBasket int
+
+	existsQuery := `SELECT id, quantity FROM ProductBasket WHERE customerId = ? AND productId = ? AND variantId <=> ? LIMIT 1`
+
+	existsErr := tx.QueryRow(existsQuery, userID, productID, variantID).Scan(&basketID, &inBasket)
+	if existsErr != nil && existsErr != sql.ErrNoRows {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to add to basket (failed to check basket): %w", existsErr)
+	}
+
+	if inBasket+quantity > stock {
+		tx.Rollback()
+		return 0, fmt.Errorf("%w (product: %d, in stock: %d)", ErrInsufficientStock, productID, stock)
+	}
+
+	if existsErr == nil {
+		_, incrementErr := tx.Exec(`UPDATE ProductBasket SET quantity = quantity + ? WHERE id = ?`, quantity, basketID)
 		if incrementErr != nil {
+			tx.Rollback()
 			return basketID, fmt.Errorf("failed to increment basket quantity upon check: %w", incrementErr)
 		}
+	} else {
+		query := `INSERT INTO ProductBasket (customerId, productId, variantId, quantity) values (?, ?, ?, ?)`
 
-		return basketID, nil
-	}
-
-	query := `INSERT INTO ProductBasket (customerId, productId, quantity) values (?, ?, ?)`
+		res, execError := tx.Exec(query, userID, productID, variantID, quantity)
+		if execError != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to insert a new product basket: %w", execError)
+		}
 
-	res, execError := db.Exec(query, userID, productID, quantity)
-	if execError != nil {
-		return 0, fmt.Errorf("failed to insert a new product basket: %w", execError)
+		basketID, _ = res.LastInsertId()
 	}
 
-	insertedID, _ := res.LastInsertId()
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to add to basket (failed to commit transaction): %w", commitErr)
+	}
 
-	return insertedID, nil
+	return basketID, nil
 }
 
 func DeleteProductBasketByID(db *sql.DB, id int64) error {
@@ -1158,14 +1495,47 @@ func DeleteProductBasketByID(db *sql.DB, id int64) error {
 	return nil
 }
 
+// Fails with ErrInsufficientStock if the basket already holds all of the product's (or variant's) stock
 func IncrementBasketProductQuantityByID(db *sql.DB, basketID int64) error {
-	query := `UPDATE ProductBasket SET quantity = quantity + 1 WHERE id = ?`
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to increment basket quantity (failed to begin transaction): %w", txErr)
+	}
 
-	_, execErr := db.Exec(query, basketID)
+	var productID int64
+	var quantity, stock int
+
+	query := `SELECT P.id, B.quantity, COALESCE(V.stock, P.stock) FROM ProductBasket AS B JOIN Product AS P ON P.id = B.productId
+  LEFT JOIN ProductVariant AS V ON V.id = B.variantId WHERE B.id = ? FOR UPDATE`
+
+	scanErr := tx.QueryRow(query, basketID).Scan(&productID, &quantity, &stock)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return nil
+		}
+
+		return fmt.Errorf("failed to increment basket quantity (failed to check stock): %w", scanErr)
+	}
+
+	if quantity+1 > stock {
+		tx.Rollback()
+		return fmt.Errorf("%w (product: %d, in stock: %d)", ErrInsufficientStock, productID, stock)
+	}
+
+	_, execErr := tx.Exec(`UPDATE ProductBasket SET quantity = quantity + 1 WHERE id = ?`, basketID)
 	if execErr != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to increment basket quantity: %w", execErr)
 	}
 
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to increment basket quantity (failed to commit transaction): %w", commitErr)
+	}
+
 	return nil
 }
 
@@ -1954,8 +2324,26 @@ func CreateComment(db *sql.DB, comment SubscriberComment) (int64, error) {
 	return id, nil
 }
 
+// Returns nil if the comment does not exist or is deleted
+func GetCommentByID(db *sql.DB, id int64) (*SubscriberComment, error) {
+	query := `SELECT id, text, createdAt, updatedAt, senderId, subscriberId FROM SubscriberComment WHERE id = ? AND deletedAt IS NULL`
+
+	comment := &SubscriberComment{}
+
+	scanErr := db.QueryRow(query, id).Scan(&comment.ID, &comment.Text, &comment.CreatedAt, &comment.UpdatedAt, &comment.SenderID, &comment.SubscriberID)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a comment by ID (id: %d): %w", id, scanErr)
+	}
+
+	return comment, nil
+}
+
 func DeleteCommentByID(db *sql.DB, id int64) error {
-	query := `DELETE FROM SubscriberComment WHERE id = ?`
+	query := `UPDATE SubscriberComment SET deletedAt = CURRENT_TIMESTAMP WHERE id = ?`
 
 	_, execErr := db.Exec(query, id)
 	if execErr != nil {
@@ -2142,8 +2530,53 @@ func MarkMessageAsRead(db *sql.DB, userId, messageId int64) error {
 	return nil
 }
 
+const trainerQuery = `SELECT id, name, job, description, instagram, facebook, twitter, userId, displayOrder FROM Trainer`
+
+func scanTrainer(scanner interface{ Scan(...interface{}) error }, trainer *Trainer) error {
+	return scanner.Scan(&trainer.ID, &trainer.Name, &trainer.Job, &trainer.Description, &trainer.Instigram, &trainer.Facebook, &trainer.Twitter, &trainer.UserID, &trainer.DisplayOrder)
+}
+
+func getTrainerSpecialties(db *sql.DB, trainerID int64) ([]string, error) {
+	rows, queryErr := db.Query(`SELECT name FROM TrainerSpecialty WHERE trainerId = ? ORDER BY name`, trainerID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get trainer specialties: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	specialties := []string{}
+	counter := 0
+
+	for rows.Next() {
+		var name string
+
+		scanErr := rows.Scan(&name)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a trainer specialty at row (%d): %v", counter, scanErr)
+		} else {
+			specialties = append(specialties, name)
+		}
+
+		counter++
+	}
+
+	return specialties, nil
+}
+
+func insertTrainerSpecialties(tx *sql.Tx, trainerID int64, specialties []string) error {
+	for _, name := range specialties {
+		_, execErr := tx.Exec(`INSERT IGNORE INTO TrainerSpecialty (trainerId, name) VALUES (?, ?)`, trainerID, name)
+		if execErr != nil {
+			return execErr
+		}
+	}
+
+	return nil
+}
+
+// Ordered by displayOrder
 func GetAllTrainers(db *sql.DB) ([]Trainer, error) {
-	query := `SELECT id, name, job, description, instagram, facebook, twitter FROM Trainer`
+	query := trainerQuery + ` ORDER BY displayOrder, id`
 
 	rows, queryErr := db.Query(query)
 	if queryErr != nil {
@@ -2159,9 +2592,9 @@ func GetAllTrainers(db *sql.DB) ([]Trainer, error) {
 	counter := 0
 
 	for rows.Next() {
-		trainer := Trainer{}
+		trainer := Trainer{Specialties: []string{}}
 
-		scanErr := rows.Scan(&trainer.ID, &trainer.Name, &trainer.Job, &trainer.Description, &trainer.Instigram, &trainer.Facebook, &trainer.Twitter)
+		scanErr := scanTrainer(rows, &trainer)
 		if scanErr != nil {
 			common.Logger.Printf("failed to scan a trainer from rows at row (%d): %v", counter, scanErr)
 		} else {
@@ -2171,18 +2604,99 @@ func GetAllTrainers(db *sql.DB) ([]Trainer, error) {
 		counter++
 	}
 
+	specialtyRows, queryErr := db.Query(`SELECT trainerId, name FROM TrainerSpecialty ORDER BY name`)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get all trainers (failed to get specialties): %w", queryErr)
+	}
+
+	defer specialtyRows.Close()
+
+	specialties := map[int64][]string{}
+	counter = 0
+
+	for specialtyRows.Next() {
+		var (
+			trainerID int64
+			name      string
+		)
+
+		scanErr := specialtyRows.Scan(&trainerID, &name)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a trainer specialty at row (%d): %v", counter, scanErr)
+		} else {
+			specialties[trainerID] = append(specialties[trainerID], name)
+		}
+
+		counter++
+	}
+
+	for i := range trainers {
+		if names, found := specialties[trainers[i].ID]; found {
+			trainers[i].Specialties = names
+		}
+	}
+
 	return trainers, nil
 }
 
+func GetTrainerByID(db *sql.DB, id int64) (*Trainer, error) {
+	query := trainerQuery + ` WHERE id = ?`
+
+	trainer := &Trainer{}
+
+	scanErr := scanTrainer(db.QueryRow(query, id), trainer)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a trainer by ID (id: %d): %w", id, scanErr)
+	}
+
+	specialties, queryErr := getTrainerSpecialties(db, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a trainer by ID (id: %d): %w", id, queryErr)
+	}
+
+	trainer.Specialties = specialties
+
+	return trainer, nil
+}
+
+// Returns nil if no trainer is linked to the user
+func GetTrainerByUserID(db *sql.DB, userID int64) (*Trainer, error) {
+	query := trainerQuery + ` WHERE userId = ?`
+
+	trainer := &Trainer{}
+
+	scanErr := scanTrainer(db.QueryRow(query, userID), trainer)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a trainer by user ID (user id: %d): %w", userID, scanErr)
+	}
+
+	specialties, queryErr := getTrainerSpecialties(db, trainer.ID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a trainer by user ID (user id: %d): %w", userID, queryErr)
+	}
+
+	trainer.Specialties = specialties
+
+	return trainer, nil
+}
+
 func CreateTrainer(db *sql.DB, data Trainer) (int64, error) {
-	query := `INSERT INTO Trainer (name, job, description, instagram, facebook, twitter) VALUES (?, ?, ?, ?, ?, ?)`
+	query := `INSERT INTO Trainer (name, job, description, instagram, facebook, twitter, displayOrder) VALUES (?, ?, ?, ?, ?, ?, ?)`
 
 	tx, txErr := db.Begin()
 	if txErr != nil {
 		return 0, fmt.Errorf("failed to create a trainer (failed to begin transaction): %w", txErr)
 	}
 
-	res, execErr := tx.Exec(query, data.Name, data.Job, data.Description, data.Instigram, data.Facebook, data.Twitter)
+	res, execErr := tx.Exec(query, data.Name, data.Job, data.Description, data.Instigram, data.Facebook, data.Twitter, data.DisplayOrder)
 	if execErr != nil {
 		rollErr := tx.Rollback()
 		if rollErr != nil {
@@ -2201,6 +2715,13 @@ func CreateTrainer(db *sql.DB, data Trainer) (int64, error) {
 
 		return 0, fmt.Errorf("failed to retrieve created trainer ID: %w", idErr)
 	}
+
+	specialtyErr := insertTrainerSpecialties(tx, id, data.Specialties)
+	if specialtyErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a trainer (failed to insert specialties): %w", specialtyErr)
+	}
+
 	commitErr := tx.Commit()
 	if commitErr != nil {
 		rollErr := tx.Rollback()
@@ -2212,17 +2733,281 @@ func CreateTrainer(db *sql.DB, data Trainer) (int64, error) {
 	return id, nil
 }
 
+// Replaces the trainer's specialties as well
 func UpdateTrainer(db *sql.DB, data Trainer) error {
-	query := `UPDATE Trainer SET name = ?, job = ?, description = ?, instagram = ?, facebook = ?, twitter = ? WHERE id = ?`
+	query := `UPDATE Trainer SET name = ?, job = ?, description = ?, instagram = ?, facebook = ?, twitter = ?, displayOrder = ? WHERE id = ?`
+
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to update a trainer (failed to begin transaction): %w", txErr)
+	}
 
-	_, execErr := db.Exec(query, data.Name, data.Job, data.Description, data.Instigram, data.Facebook, data.Twitter, data.ID)
+	_, execErr := tx.Exec(query, data.Name, data.Job, data.Description, data.Instigram, data.Facebook, data.Twitter, data.DisplayOrder, data.ID)
 	if execErr != nil {
+		tx.Rollback()
 		return fmt.Errorf("failed to update a trainer (id: %d): %w", data.ID, execErr)
 	}
 
+	_, execErr = tx.Exec(`DELETE FROM TrainerSpecialty WHERE trainerId = ?`, data.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a trainer (id: %d) (failed to clear specialties): %w", data.ID, execErr)
+	}
+
+	specialtyErr := insertTrainerSpecialties(tx, data.ID, data.Specialties)
+	if specialtyErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a trainer (id: %d) (failed to insert specialties): %w", data.ID, specialtyErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a trainer (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Sets displayOrder to each trainer's position in ids
+func ReorderTrainers(db *sql.DB, ids []int64) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to reorder trainers (failed to begin transaction): %w", txErr)
+	}
+
+	for i, id := range ids {
+		_, execErr := tx.Exec(`UPDATE Trainer SET displayOrder = ? WHERE id = ?`, i, id)
+		if execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to reorder trainers (id: %d): %w", id, execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to reorder trainers (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+const trainerCertificationQuery = `SELECT id, trainerId, name, issuer, COALESCE(issuedAt, ''), COALESCE(expiresAt, '') FROM TrainerCertification`
+
+func scanTrainerCertification(scanner interface{ Scan(...interface{}) error }, cert *TrainerCertification) error {
+	return scanner.Scan(&cert.ID, &cert.TrainerID, &cert.Name, &cert.Issuer, &cert.IssuedAt, &cert.ExpiresAt)
+}
+
+func queryTrainerCertifications(db *sql.DB, query string, args ...interface{}) ([]TrainerCertification, error) {
+	rows, queryErr := db.Query(query, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	defer rows.Close()
+
+	certs := []TrainerCertification{}
+	counter := 0
+
+	for rows.Next() {
+		cert := TrainerCertification{}
+
+		scanErr := scanTrainerCertification(rows, &cert)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a trainer certification at row (%d): %v", counter, scanErr)
+		} else {
+			certs = append(certs, cert)
+		}
+
+		counter++
+	}
+
+	return certs, nil
+}
+
+func CreateTrainerCertification(db *sql.DB, cert TrainerCertification) (int64, error) {
+	query := `INSERT INTO TrainerCertification (trainerId, name, issuer, issuedAt, expiresAt) VALUES (?, ?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, cert.TrainerID, cert.Name, cert.Issuer, nullIfEmpty(cert.IssuedAt), nullIfEmpty(cert.ExpiresAt))
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a trainer certification: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created trainer certification ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+// Ordered by expiry, certifications that don't expire last
+func GetTrainerCertifications(db *sql.DB, trainerID int64) ([]TrainerCertification, error) {
+	query := trainerCertificationQuery + ` WHERE trainerId = ? ORDER BY expiresAt IS NULL, expiresAt, name`
+
+	certs, queryErr := queryTrainerCertifications(db, query, trainerID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get trainer certifications (trainer id: %d): %w", trainerID, queryErr)
+	}
+
+	return certs, nil
+}
+
+func GetTrainerCertificationByID(db *sql.DB, id int64) (*TrainerCertification, error) {
+	cert := &TrainerCertification{}
+
+	scanErr := scanTrainerCertification(db.QueryRow(trainerCertificationQuery+` WHERE id = ?`, id), cert)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a trainer certification by ID (id: %d): %w", id, scanErr)
+	}
+
+	return cert, nil
+}
+
+// Changing the expiry date re-arms the expiry reminder
+func UpdateTrainerCertification(db *sql.DB, cert TrainerCertification) error {
+	query := `UPDATE TrainerCertification SET name = ?, issuer = ?, issuedAt = ?,
+  reminderSentAt = IF(expiresAt <=> ?, reminderSentAt, NULL), expiresAt = ? WHERE id = ?`
+
+	expiresAt := nullIfEmpty(cert.ExpiresAt)
+
+	_, execErr := db.Exec(query, cert.Name, cert.Issuer, nullIfEmpty(cert.IssuedAt), expiresAt, expiresAt, cert.ID)
+	if execErr != nil {
+		return fmt.Errorf("failed to update a trainer certification (id: %d): %w", cert.ID, execErr)
+	}
+
+	return nil
+}
+
+func DeleteTrainerCertificationByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM TrainerCertification WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a trainer certification (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+// Certifications expiring on or before the date that haven't been reminded of yet
+func GetCertificationsDueForReminder(db *sql.DB, before string) ([]TrainerCertification, error) {
+	query := trainerCertificationQuery + ` WHERE expiresAt IS NOT NULL AND expiresAt <= ? AND reminderSentAt IS NULL ORDER BY expiresAt`
+
+	certs, queryErr := queryTrainerCertifications(db, query, before)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get certifications due for reminder: %w", queryErr)
+	}
+
+	return certs, nil
+}
+
+func MarkCertificationReminderSent(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`UPDATE TrainerCertification SET reminderSentAt = CURRENT_TIMESTAMP WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to mark certification reminder as sent (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+func GetAdminUserIDs(db *sql.DB) ([]int64, error) {
+	rows, queryErr := db.Query(`SELECT id FROM User WHERE permission = 1 AND deletedAt IS NULL`)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get admin user IDs: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	ids := []int64{}
+	counter := 0
+
+	for rows.Next() {
+		var id int64
+
+		scanErr := rows.Scan(&id)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan an admin user ID at row (%d): %v", counter, scanErr)
+		} else {
+			ids = append(ids, id)
+		}
+
+		counter++
+	}
+
+	return ids, nil
+}
+
+// A nil userID unlinks the trainer from its user
+func SetTrainerUser(db *sql.DB, trainerID int64, userID *int64) error {
+	_, execErr := db.Exec(`UPDATE Trainer SET userId = ? WHERE id = ?`, userID, trainerID)
+	if execErr != nil {
+		return fmt.Errorf("failed to set trainer user (id: %d): %w", trainerID, execErr)
+	}
+
+	return nil
+}
+
+// Assigning an already assigned subscriber does nothing
+func AssignTrainerClient(db *sql.DB, trainerID, subscriberID int64) error {
+	_, execErr := db.Exec(`INSERT IGNORE INTO TrainerClient (trainerId, subscriberId) VALUES (?, ?)`, trainerID, subscriberID)
+	if execErr != nil {
+		return fmt.Errorf("failed to assign a client to a trainer: %w", execErr)
+	}
+
+	return nil
+}
+
+func UnassignTrainerClient(db *sql.DB, trainerID, subscriberID int64) error {
+	_, execErr := db.Exec(`DELETE FROM TrainerClient WHERE trainerId = ? AND subscriberId = ?`, trainerID, subscriberID)
+	if execErr != nil {
+		return fmt.Errorf("failed to unassign a client from a trainer: %w", execErr)
+	}
+
 	return nil
 }
 
+func IsTrainerClient(db *sql.DB, trainerID, subscriberID int64) (bool, error) {
+	var count int
+
+	scanErr := db.QueryRow(`SELECT COUNT(*) FROM TrainerClient WHERE trainerId = ? AND subscriberId = ?`, trainerID, subscriberID).Scan(&count)
+	if scanErr != nil {
+		return false, fmt.Errorf("failed to check trainer client: %w", scanErr)
+	}
+
+	return count > 0, nil
+}
+
+func GetTrainerClientIDs(db *sql.DB, trainerID int64) (map[int64]bool, error) {
+	rows, queryErr := db.Query(`SELECT subscriberId FROM TrainerClient WHERE trainerId = ?`, trainerID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get trainer client IDs: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	ids := map[int64]bool{}
+	counter := 0
+
+	for rows.Next() {
+		var id int64
+
+		scanErr := rows.Scan(&id)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a trainer client ID at row (%d): %v", counter, scanErr)
+		} else {
+			ids[id] = true
+		}
+
+		counter++
+	}
+
+	return ids, nil
+}
+
 func DeleteTrainerByID(db *sql.DB, id int64) error {
 	query := `DELETE FROM Trainer WHERE id = ?`
 	_, execErr := db.Exec(query, id)
@@ -2463,3 +3248,6077 @@ func DeleteBlogByID(db *sql.DB, id int64) error {
 
 	return nil
 }
+
+// Soft-deletable tables by their trash name
+var trashTables = map[string]string{
+	"customers": "Subscriber",
+	"users":     "User",
+	"plans":     "Plan",
+	"products":  "Product",
+	"comments":  "SubscriberComment",
+}
+
+// Rows referencing a purged row that have to be deleted before it, by trash name
+var trashDependents = map[string][]string{
+	"customers": {
+		`DELETE FROM SubscriberComment WHERE subscriberId = ?`,
+		`DELETE FROM SubscriberAttachment WHERE subscriberId = ?`,
+		`DELETE FROM MeasurementMetric WHERE measurementId IN (SELECT id FROM SubscriberMeasurement WHERE subscriberId = ?)`,
+		`DELETE FROM SubscriberMeasurement WHERE subscriberId = ?`,
+		`DELETE FROM WorkoutSet WHERE subscriberId = ?`,
+		`DELETE FROM WorkoutAssignment WHERE subscriberId = ?`,
+		`DELETE FROM ClassBooking WHERE subscriberId = ?`,
+		`DELETE FROM PTSession WHERE subscriberId = ?`,
+		`DELETE FROM PTPackage WHERE subscriberId = ?`,
+		`DELETE FROM TrainerClient WHERE subscriberId = ?`,
+	},
+	"plans":    {`DELETE FROM PlanFeature WHERE planId = ?`},
+	"products": {`DELETE FROM ProductBasket WHERE productId = ?`},
+}
+
+// Trash names in the order they are purged, dependents first
+var TrashEntities = []string{"comments", "customers", "plans", "products"}
+
+// Staff accounts author comments, measurements, payroll runs and other records that outlive them,
+// so deleted users can be restored but never purged
+var ErrNotPurgeable = errors.New("deleted users are kept for the records they authored and can't be purged")
+
+func IsTrashEntity(entity string) bool {
+	_, found := trashTables[entity]
+	return found
+}
+
+func GetDeletedUsers(db *sql.DB) ([]User, error) {
+	query := `SELECT id, email, name, gender, age, salary, startDate, permission, deletedAt FROM User WHERE deletedAt IS NOT NULL ORDER BY deletedAt DESC`
+
+	rows, queryErr := db.Query(query)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get deleted users: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	users := []User{}
+	counter := 0
+
+	for rows.Next() {
+		user := User{}
+		scanErr := rows.Scan(&user.ID, &user.Email, &user.Name, &user.Gender, &user.Age, &user.Salary, &user.StartDate, &user.Permission, &user.DeletedAt)
+
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a deleted user from rows at row (%d): %v", counter, scanErr)
+		} else {
+			users = append(users, user)
+		}
+
+		counter++
+	}
+
+	return users, nil
+}
+
+func GetDeletedPlans(db *sql.DB) ([]Plan, error) {
+	query := `SELECT id, title, description, price, duration, createdAt, updatedAt, deletedAt FROM Plan WHERE deletedAt IS NOT NULL ORDER BY deletedAt DESC`
+
+	rows, queryErr := db.Query(query)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get deleted plans: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	plans := []Plan{}
+	counter := 0
+
+	for rows.Next() {
+		plan := Plan{}
+
+		scanErr := rows.Scan(&plan.ID, &plan.Title, &plan.Description, &plan.Price, &plan.Duration, &plan.CreatedAt, &plan.UpdatedAt, &plan.DeletedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a deleted plan from rows at row (%d): %v", counter, scanErr)
+		} else {
+			plans = append(plans, plan)
+		}
+
+		counter++
+	}
+
+	return plans, nil
+}
+
+func GetDeletedProducts(db *sql.DB) ([]Product, error) {
+	query := `SELECT id, name, description, marka, price, categoryId, stock, lowStockThreshold, createdAt, updatedAt, deletedAt FROM Product WHERE deletedAt IS NOT NULL ORDER BY deletedAt DESC`
+
+	rows, queryErr := db.Query(query)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get deleted products: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	products := []Product{}
+	counter := 0
+
+	for rows.Next() {
+		product := Product{}
+
+		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Marka, &product.Price, &product.CategoryID, &product.Stock, &product.LowStockThreshold, &product.CreatedAt, &product.UpdatedAt, &product.DeletedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a deleted product from rows at row (%d): %v", counter, scanErr)
+		} else {
+			products = append(products, product)
+		}
+
+		counter++
+	}
+
+	return products, nil
+}
+
+func GetDeletedComments(db *sql.DB) ([]SubscriberComment, error) {
+	query := `SELECT id, text, createdAt, updatedAt, deletedAt, senderId, subscriberId FROM SubscriberComment WHERE deletedAt IS NOT NULL ORDER BY deletedAt DESC`
+
+	rows, queryErr := db.Query(query)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get deleted comments: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	comments := []SubscriberComment{}
+	counter := 0
+
+	for rows.Next() {
+		comment := SubscriberComment{}
+
+		scanErr := rows.Scan(&comment.ID, &comment.Text, &comment.CreatedAt, &comment.UpdatedAt, &comment.DeletedAt, &comment.SenderID, &comment.SubscriberID)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a deleted comment from rows at row (%d): %v", counter, scanErr)
+		} else {
+			comments = append(comments, comment)
+		}
+
+		counter++
+	}
+
+	return comments, nil
+}
+
+// Returns false if no soft-deleted row with the ID exists
+func RestoreDeleted(db *sql.DB, entity string, id int64) (bool, error) {
+	table, found := trashTables[entity]
+	if !found {
+		return false, fmt.Errorf("unknown trash entity '%s'", entity)
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET deletedAt = NULL WHERE id = ? AND deletedAt IS NOT NULL`, table)
+
+	res, execErr := db.Exec(query, id)
+	if execErr != nil {
+		return false, fmt.Errorf("failed to restore from trash (entity: %s, id: %d): %w", entity, id, execErr)
+	}
+
+	affected, _ := res.RowsAffected()
+
+	return affected > 0, nil
+}
+
+// Permanently deletes one soft-deleted row along with the rows depending on it.
+// Returns false if no soft-deleted row with the ID exists.
+func PurgeDeletedByID(db *sql.DB, entity string, id int64) (bool, error) {
+	purged, purgeErr := purgeDeleted(db, entity, `id = ?`, id)
+	if purgeErr != nil {
+		return false, purgeErr
+	}
+
+	return len(purged) > 0, nil
+}
+
+// Permanently deletes rows soft-deleted before the given time (common.DateTimeLayout).
+// Returns the IDs of the purged rows.
+func PurgeDeletedBefore(db *sql.DB, entity string, before string) ([]int64, error) {
+	return purgeDeleted(db, entity, `deletedAt < ?`, before)
+}
+
+func purgeDeleted(db *sql.DB, entity string, condition string, args ...interface{}) ([]int64, error) {
+	table, found := trashTables[entity]
+	if !found {
+		return nil, fmt.Errorf("unknown trash entity '%s'", entity)
+	}
+
+	if entity == "users" {
+		return nil, ErrNotPurgeable
+	}
+
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return nil, fmt.Errorf("failed to purge trash (failed to begin transaction): %w", txErr)
+	}
+
+	rows, queryErr := tx.Query(fmt.Sprintf(`SELECT id FROM %s WHERE deletedAt IS NOT NULL AND %s`, table, condition), args...)
+	if queryErr != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to purge trash (failed to select %s): %w", entity, queryErr)
+	}
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		if scanErr := rows.Scan(&id); scanErr != nil {
+			rows.Close()
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to purge trash (failed to scan %s id): %w", entity, scanErr)
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	for _, id := range ids {
+		for _, dependent := range trashDependents[entity] {
+			if _, execErr := tx.Exec(dependent, id); execErr != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to purge trash (failed to delete dependents of %s id: %d): %w", entity, id, execErr)
+			}
+		}
+
+		if _, execErr := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id = ?`, table), id); execErr != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to purge trash (failed to delete %s id: %d): %w", entity, id, execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to purge trash (failed to commit transaction): %w", commitErr)
+	}
+
+	return ids, nil
+}
+
+func CreateSubscriberAttachment(db *sql.DB, data SubscriberAttachment) (int64, error) {
+	query := `INSERT INTO SubscriberAttachment (subscriberId, uploadedById, kind, fileName, contentType, size) VALUES (?, ?, ?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, data.SubscriberID, data.UploadedByID, data.Kind, data.FileName, data.ContentType, data.Size)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a subscriber attachment: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created subscriber attachment ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+func GetSubscriberAttachmentByID(db *sql.DB, id int64) (*SubscriberAttachment, error) {
+	query := `SELECT id, subscriberId, uploadedById, kind, fileName, contentType, size, createdAt FROM SubscriberAttachment WHERE id = ?`
+
+	attachment := &SubscriberAttachment{}
+
+	scanErr := db.QueryRow(query, id).Scan(&attachment.ID, &attachment.SubscriberID, &attachment.UploadedByID, &attachment.Kind, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.CreatedAt)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a subscriber attachment by ID (id: %d): %w", id, scanErr)
+	}
+
+	return attachment, nil
+}
+
+func GetSubscriberAttachments(db *sql.DB, subscriberID int64) ([]SubscriberAttachment, error) {
+	query := `SELECT id, subscriberId, uploadedById, kind, fileName, contentType, size, createdAt FROM SubscriberAttachment WHERE subscriberId = ? ORDER BY createdAt DESC`
+
+	rows, queryErr := db.Query(query, subscriberID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get subscriber attachments: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	attachments := []SubscriberAttachment{}
+	counter := 0
+
+	for rows.Next() {
+		attachment := SubscriberAttachment{}
+
+		scanErr := rows.Scan(&attachment.ID, &attachment.SubscriberID, &attachment.UploadedByID, &attachment.Kind, &attachment.FileName, &attachment.ContentType, &attachment.Size, &attachment.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a subscriber attachment from rows at row (%d): %v", counter, scanErr)
+		} else {
+			attachments = append(attachments, attachment)
+		}
+
+		counter++
+	}
+
+	return attachments, nil
+}
+
+func DeleteSubscriberAttachmentByID(db *sql.DB, id int64) error {
+	query := `DELETE FROM SubscriberAttachment WHERE id = ?`
+
+	_, execErr := db.Exec(query, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a subscriber attachment (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+const measurementColumns = `id, subscriberId, recordedById, measuredAt, weight, height, bodyFat, chest, waist, hips, arm, thigh, notes, createdAt`
+
+func measurementDest(m *SubscriberMeasurement) []interface{} {
+	return []interface{}{&m.ID, &m.SubscriberID, &m.RecordedByID, &m.MeasuredAt, &m.Weight, &m.Height, &m.BodyFat, &m.Chest, &m.Waist, &m.Hips, &m.Arm, &m.Thigh, &m.Notes, &m.CreatedAt}
+}
+
+func insertMeasurementMetrics(tx *sql.Tx, measurementID int64, metrics []MeasurementMetric) error {
+	for _, metric := range metrics {
+		_, execErr := tx.Exec(`INSERT INTO MeasurementMetric (measurementId, name, value, unit) VALUES (?, ?, ?, ?)`, measurementID, metric.Name, metric.Value, metric.Unit)
+		if execErr != nil {
+			return execErr
+		}
+	}
+
+	return nil
+}
+
+func CreateSubscriberMeasurement(db *sql.DB, data SubscriberMeasurement) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a measurement (failed to begin transaction): %w", txErr)
+	}
+
+	query := `INSERT INTO SubscriberMeasurement (subscriberId, recordedById, measuredAt, weight, height, bodyFat, chest, waist, hips, arm, thigh, notes) VALUES (?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, data.SubscriberID, data.RecordedByID, nullIfEmpty(data.MeasuredAt), data.Weight, data.Height, data.BodyFat, data.Chest, data.Waist, data.Hips, data.Arm, data.Thigh, data.Notes)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a measurement: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created measurement ID: %w", idErr)
+	}
+
+	if metricsErr := insertMeasurementMetrics(tx, id, data.Metrics); metricsErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a measurement (failed to insert metrics): %w", metricsErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a measurement (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+func GetSubscriberMeasurementByID(db *sql.DB, id int64) (*SubscriberMeasurement, error) {
+	query := `SELECT ` + measurementColumns + ` FROM SubscriberMeasurement WHERE id = ?`
+
+	measurement := &SubscriberMeasurement{Metrics: []MeasurementMetric{}}
+
+	scanErr := db.QueryRow(query, id).Scan(measurementDest(measurement)...)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a measurement by ID (id: %d): %w", id, scanErr)
+	}
+
+	rows, queryErr := db.Query(`SELECT name, value, unit FROM MeasurementMetric WHERE measurementId = ? ORDER BY id`, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get metrics of a measurement (id: %d): %w", id, queryErr)
+	}
+
+	defer rows.Close()
+
+	counter := 0
+
+	for rows.Next() {
+		metric := MeasurementMetric{}
+
+		scanErr := rows.Scan(&metric.Name, &metric.Value, &metric.Unit)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a measurement metric from rows at row (%d): %v", counter, scanErr)
+		} else {
+			measurement.Metrics = append(measurement.Metrics, metric)
+		}
+
+		counter++
+	}
+
+	return measurement, nil
+}
+
+// Measurements are ordered from oldest to newest. Empty bounds are ignored.
+func GetSubscriberMeasurements(db *sql.DB, subscriberID int64, from, to string) ([]SubscriberMeasurement, error) {
+	query := `SELECT ` + measurementColumns + ` FROM SubscriberMeasurement WHERE subscriberId = ? AND (? = '' OR measuredAt >= ?) AND (? = '' OR measuredAt <= ?) ORDER BY measuredAt, id`
+
+	rows, queryErr := db.Query(query, subscriberID, from, from, to, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get measurements of a subscriber: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	measurements := []SubscriberMeasurement{}
+	indices := map[int64]int{}
+	counter := 0
+
+	for rows.Next() {
+		measurement := SubscriberMeasurement{Metrics: []MeasurementMetric{}}
+
+		scanErr := rows.Scan(measurementDest(&measurement)...)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a measurement from rows at row (%d): %v", counter, scanErr)
+		} else {
+			indices[measurement.ID] = len(measurements)
+			measurements = append(measurements, measurement)
+		}
+
+		counter++
+	}
+
+	rows.Close()
+
+	metricsQuery := `SELECT M.measurementId, M.name, M.value, M.unit FROM MeasurementMetric AS M INNER JOIN SubscriberMeasurement AS S ON S.id = M.measurementId WHERE S.subscriberId = ? ORDER BY M.id`
+
+	metricRows, metricsErr := db.Query(metricsQuery, subscriberID)
+	if metricsErr != nil {
+		return nil, fmt.Errorf("failed to get measurement metrics of a subscriber: %w", metricsErr)
+	}
+
+	defer metricRows.Close()
+
+	counter = 0
+
+	for metricRows.Next() {
+		var measurementID int64
+		metric := MeasurementMetric{}
+
+		scanErr := metricRows.Scan(&measurementID, &metric.Name, &metric.Value, &metric.Unit)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a measurement metric from rows at row (%d): %v", counter, scanErr)
+		} else if index, found := indices[measurementID]; found {
+			measurements[index].Metrics = append(measurements[index].Metrics, metric)
+		}
+
+		counter++
+	}
+
+	return measurements, nil
+}
+
+// Replaces all values and custom metrics of a measurement
+func UpdateSubscriberMeasurement(db *sql.DB, data SubscriberMeasurement) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to update a measurement (failed to begin transaction): %w", txErr)
+	}
+
+	query := `UPDATE SubscriberMeasurement SET measuredAt = COALESCE(?, measuredAt), weight = ?, height = ?, bodyFat = ?, chest = ?, waist = ?, hips = ?, arm = ?, thigh = ?, notes = ? WHERE id = ?`
+
+	_, execErr := tx.Exec(query, nullIfEmpty(data.MeasuredAt), data.Weight, data.Height, data.BodyFat, data.Chest, data.Waist, data.Hips, data.Arm, data.Thigh, data.Notes, data.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a measurement (id: %d): %w", data.ID, execErr)
+	}
+
+	_, execErr = tx.Exec(`DELETE FROM MeasurementMetric WHERE measurementId = ?`, data.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a measurement (failed to delete metrics): %w", execErr)
+	}
+
+	if metricsErr := insertMeasurementMetrics(tx, data.ID, data.Metrics); metricsErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a measurement (failed to insert metrics): %w", metricsErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a measurement (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+func DeleteSubscriberMeasurementByID(db *sql.DB, id int64) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to delete a measurement (failed to begin transaction): %w", txErr)
+	}
+
+	_, execErr := tx.Exec(`DELETE FROM MeasurementMetric WHERE measurementId = ?`, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete metrics of a measurement (id: %d): %w", id, execErr)
+	}
+
+	_, execErr = tx.Exec(`DELETE FROM SubscriberMeasurement WHERE id = ?`, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a measurement (id: %d): %w", id, execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a measurement (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+var ErrExerciseNotFound = errors.New("exercise not found")
+
+// Fails with ErrExerciseNotFound
+func checkExercise(tx *sql.Tx, id int64) error {
+	var found int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM Excercise WHERE id = ?`, id).Scan(&found)
+	if scanErr != nil {
+		if errors.Is(scanErr, sql.ErrNoRows) {
+			return fmt.Errorf("%w (id: %d)", ErrExerciseNotFound, id)
+		}
+
+		return scanErr
+	}
+
+	return nil
+}
+
+// Fails with ErrExerciseNotFound
+func insertWorkoutProgramDays(tx *sql.Tx, programID int64, days []WorkoutProgramDay) error {
+	for _, day := range days {
+		res, execErr := tx.Exec(`INSERT INTO WorkoutProgramDay (programId, week, day, title) VALUES (?, ?, ?, ?)`, programID, day.Week, day.Day, day.Title)
+		if execErr != nil {
+			return execErr
+		}
+
+		dayID, idErr := res.LastInsertId()
+		if idErr != nil {
+			return idErr
+		}
+
+		for position, exercise := range day.Exercises {
+			if checkErr := checkExercise(tx, exercise.ExerciseID); checkErr != nil {
+				return checkErr
+			}
+
+			query := `INSERT INTO WorkoutProgramExercise (dayId, exerciseId, position, sets, reps, restSeconds, loadKg, notes) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+			_, execErr := tx.Exec(query, dayID, exercise.ExerciseID, position+1, exercise.Sets, exercise.Reps, exercise.RestSeconds, exercise.Load, exercise.Notes)
+			if execErr != nil {
+				return execErr
+			}
+		}
+	}
+
+	return nil
+}
+
+// Creates a program with its days; exercises are positioned in the order given.
+// Fails with ErrExerciseNotFound.
+func CreateWorkoutProgram(db *sql.DB, program WorkoutProgram) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a workout program (failed to begin transaction): %w", txErr)
+	}
+
+	query := `INSERT INTO WorkoutProgram (createdById, name, description, weeks, isTemplate) VALUES (?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, program.CreatedByID, program.Name, program.Description, program.Weeks, program.IsTemplate)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a workout program: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created workout program ID: %w", idErr)
+	}
+
+	if daysErr := insertWorkoutProgramDays(tx, id, program.Days); daysErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a workout program (failed to insert days): %w", daysErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a workout program (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Replaces the details and all days of a program. Fails with ErrExerciseNotFound.
+func ReplaceWorkoutProgram(db *sql.DB, program WorkoutProgram) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to replace a workout program (failed to begin transaction): %w", txErr)
+	}
+
+	query := `UPDATE WorkoutProgram SET name = ?, description = ?, weeks = ?, isTemplate = ? WHERE id = ?`
+
+	_, execErr := tx.Exec(query, program.Name, program.Description, program.Weeks, program.IsTemplate, program.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace a workout program (id: %d): %w", program.ID, execErr)
+	}
+
+	_, execErr = tx.Exec(`DELETE FROM WorkoutProgramExercise WHERE dayId IN (SELECT id FROM WorkoutProgramDay WHERE programId = ?)`, program.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace a workout program (failed to delete exercises): %w", execErr)
+	}
+
+	_, execErr = tx.Exec(`DELETE FROM WorkoutProgramDay WHERE programId = ?`, program.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace a workout program (failed to delete days): %w", execErr)
+	}
+
+	if daysErr := insertWorkoutProgramDays(tx, program.ID, program.Days); daysErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace a workout program (failed to insert days): %w", daysErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace a workout program (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Lists programs without their days
+func GetWorkoutPrograms(db *sql.DB, templatesOnly bool) ([]WorkoutProgram, error) {
+	query := `SELECT id, createdById, name, description, weeks, isTemplate, createdAt, updatedAt FROM WorkoutProgram WHERE (? = false OR isTemplate = true) ORDER BY name`
+
+	rows, queryErr := db.Query(query, templatesOnly)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get workout programs: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	programs := []WorkoutProgram{}
+	counter := 0
+
+	for rows.Next() {
+		program := WorkoutProgram{}
+
+		scanErr := rows.Scan(&program.ID, &program.CreatedByID, &program.Name, &program.Description, &program.Weeks, &program.IsTemplate, &program.CreatedAt, &program.UpdatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a workout program from rows at row (%d): %v", counter, scanErr)
+		} else {
+			programs = append(programs, program)
+		}
+
+		counter++
+	}
+
+	return programs, nil
+}
+
+func GetWorkoutProgramByID(db *sql.DB, id int64) (*WorkoutProgram, error) {
+	query := `SELECT id, createdById, name, description, weeks, isTemplate, createdAt, updatedAt FROM WorkoutProgram WHERE id = ?`
+
+	program := &WorkoutProgram{Days: []WorkoutProgramDay{}}
+
+	scanErr := db.QueryRow(query, id).Scan(&program.ID, &program.CreatedByID, &program.Name, &program.Description, &program.Weeks, &program.IsTemplate, &program.CreatedAt, &program.UpdatedAt)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a workout program by ID (id: %d): %w", id, scanErr)
+	}
+
+	dayRows, daysErr := db.Query(`SELECT id, programId, week, day, title FROM WorkoutProgramDay WHERE programId = ? ORDER BY week, day`, id)
+	if daysErr != nil {
+		return nil, fmt.Errorf("failed to get days of a workout program (id: %d): %w", id, daysErr)
+	}
+
+	defer dayRows.Close()
+
+	indices := map[int64]int{}
+	counter := 0
+
+	for dayRows.Next() {
+		day := WorkoutProgramDay{Exercises: []WorkoutProgramExercise{}}
+
+		scanErr := dayRows.Scan(&day.ID, &day.ProgramID, &day.Week, &day.Day, &day.Title)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a workout program day from rows at row (%d): %v", counter, scanErr)
+		} else {
+			indices[day.ID] = len(program.Days)
+			program.Days = append(program.Days, day)
+		}
+
+		counter++
+	}
+
+	dayRows.Close()
+
+	exercisesQuery := `
+		SELECT W.id, W.dayId, W.exerciseId, E.name, W.position, W.sets, W.reps, W.restSeconds, W.loadKg, W.notes
+		FROM WorkoutProgramExercise AS W
+		INNER JOIN WorkoutProgramDay AS D ON D.id = W.dayId
+		INNER JOIN Excercise AS E ON E.id = W.exerciseId
+		WHERE D.programId = ?
+		ORDER BY W.dayId, W.position`
+
+	exerciseRows, exercisesErr := db.Query(exercisesQuery, id)
+	if exercisesErr != nil {
+		return nil, fmt.Errorf("failed to get exercises of a workout program (id: %d): %w", id, exercisesErr)
+	}
+
+	defer exerciseRows.Close()
+
+	counter = 0
+
+	for exerciseRows.Next() {
+		exercise := WorkoutProgramExercise{}
+
+		scanErr := exerciseRows.Scan(&exercise.ID, &exercise.DayID, &exercise.ExerciseID, &exercise.ExerciseName, &exercise.Position, &exercise.Sets, &exercise.Reps, &exercise.RestSeconds, &exercise.Load, &exercise.Notes)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a workout program exercise from rows at row (%d): %v", counter, scanErr)
+		} else if index, found := indices[exercise.DayID]; found {
+			program.Days[index].Exercises = append(program.Days[index].Exercises, exercise)
+		}
+
+		counter++
+	}
+
+	return program, nil
+}
+
+// Returns false if the program is still assigned to subscribers
+func DeleteWorkoutProgramByID(db *sql.DB, id int64) (bool, error) {
+	var assignments int
+
+	countErr := db.QueryRow(`SELECT COUNT(*) FROM WorkoutAssignment WHERE programId = ?`, id).Scan(&assignments)
+	if countErr != nil {
+		return false, fmt.Errorf("failed to count assignments of a workout program (id: %d): %w", id, countErr)
+	}
+
+	if assignments > 0 {
+		return false, nil
+	}
+
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return false, fmt.Errorf("failed to delete a workout program (failed to begin transaction): %w", txErr)
+	}
+
+	queries := []string{
+		`DELETE FROM WorkoutProgramExercise WHERE dayId IN (SELECT id FROM WorkoutProgramDay WHERE programId = ?)`,
+		`DELETE FROM WorkoutProgramDay WHERE programId = ?`,
+		`DELETE FROM WorkoutProgram WHERE id = ?`,
+	}
+
+	for _, query := range queries {
+		if _, execErr := tx.Exec(query, id); execErr != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("failed to delete a workout program (id: %d): %w", id, execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to delete a workout program (failed to commit transaction): %w", commitErr)
+	}
+
+	return true, nil
+}
+
+func AssignWorkoutProgram(db *sql.DB, assignment WorkoutAssignment) (int64, error) {
+	query := `INSERT INTO WorkoutAssignment (programId, subscriberId, assignedById, startDate) VALUES (?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, assignment.ProgramID, assignment.SubscriberID, assignment.AssignedByID, assignment.StartDate)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to assign a workout program: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created workout assignment ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+const workoutAssignmentQuery = `SELECT A.id, A.programId, P.name, A.subscriberId, A.assignedById, A.startDate, A.active, A.createdAt FROM WorkoutAssignment AS A INNER JOIN WorkoutProgram AS P ON P.id = A.programId`
+
+func GetSubscriberWorkoutAssignments(db *sql.DB, subscriberID int64) ([]WorkoutAssignment, error) {
+	rows, queryErr := db.Query(workoutAssignmentQuery+` WHERE A.subscriberId = ? ORDER BY A.startDate DESC`, subscriberID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get workout assignments of a subscriber: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	assignments := []WorkoutAssignment{}
+	counter := 0
+
+	for rows.Next() {
+		assignment := WorkoutAssignment{}
+
+		scanErr := rows.Scan(&assignment.ID, &assignment.ProgramID, &assignment.ProgramName, &assignment.SubscriberID, &assignment.AssignedByID, &assignment.StartDate, &assignment.Active, &assignment.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a workout assignment from rows at row (%d): %v", counter, scanErr)
+		} else {
+			assignments = append(assignments, assignment)
+		}
+
+		counter++
+	}
+
+	return assignments, nil
+}
+
+// Returns the most recently started active assignment that has started by date
+func GetActiveWorkoutAssignment(db *sql.DB, subscriberID int64, date string) (*WorkoutAssignment, error) {
+	query := workoutAssignmentQuery + ` WHERE A.subscriberId = ? AND A.active = true AND A.startDate <= ? ORDER BY A.startDate DESC, A.id DESC LIMIT 1`
+
+	assignment := &WorkoutAssignment{}
+
+	scanErr := db.QueryRow(query, subscriberID, date).Scan(&assignment.ID, &assignment.ProgramID, &assignment.ProgramName, &assignment.SubscriberID, &assignment.AssignedByID, &assignment.StartDate, &assignment.Active, &assignment.CreatedAt)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get active workout assignment of a subscriber: %w", scanErr)
+	}
+
+	return assignment, nil
+}
+
+func EndWorkoutAssignment(db *sql.DB, id int64) error {
+	query := `UPDATE WorkoutAssignment SET active = false WHERE id = ?`
+
+	_, execErr := db.Exec(query, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to end a workout assignment (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+// Flags the sets of a subscriber's exercise whose estimated one-rep max beats every set performed before them.
+// Run after sets are added or removed, as a backdated set can make or break later records.
+func refreshPersonalRecords(tx *sql.Tx, subscriberID, exerciseID int64) error {
+	rows, queryErr := tx.Query(`SELECT id, estimatedOneRepMax, isPersonalRecord FROM WorkoutSet WHERE subscriberId = ? AND exerciseId = ? ORDER BY performedAt, id FOR UPDATE`, subscriberID, exerciseID)
+	if queryErr != nil {
+		return queryErr
+	}
+
+	changed := map[int64]bool{}
+	best := 0.0
+
+	for rows.Next() {
+		var id int64
+		var estimated float64
+		var flagged bool
+
+		if scanErr := rows.Scan(&id, &estimated, &flagged); scanErr != nil {
+			rows.Close()
+			return scanErr
+		}
+
+		record := estimated > best
+		if record {
+			best = estimated
+		}
+
+		if record != flagged {
+			changed[id] = record
+		}
+	}
+
+	rows.Close()
+
+	for id, record := range changed {
+		if _, execErr := tx.Exec(`UPDATE WorkoutSet SET isPersonalRecord = ? WHERE id = ?`, record, id); execErr != nil {
+			return execErr
+		}
+	}
+
+	return nil
+}
+
+// Logs sets in one transaction. A set is flagged as a personal record when its estimated
+// one-rep max beats every set of the subscriber for that exercise performed before it.
+// Fails with ErrExerciseNotFound.
+func CreateWorkoutSets(db *sql.DB, sets []WorkoutSet) ([]WorkoutSet, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return nil, fmt.Errorf("failed to log workout sets (failed to begin transaction): %w", txErr)
+	}
+
+	insertQuery := `INSERT INTO WorkoutSet (subscriberId, exerciseId, loggedById, performedAt, reps, weight, rpe, estimatedOneRepMax, isPersonalRecord) VALUES (?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?, ?, ?, false)`
+
+	logged := make([]WorkoutSet, 0, len(sets))
+	refreshed := map[[2]int64]bool{}
+
+	for _, set := range sets {
+		if checkErr := checkExercise(tx, set.ExerciseID); checkErr != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to log workout sets: %w", checkErr)
+		}
+
+		res, execErr := tx.Exec(insertQuery, set.SubscriberID, set.ExerciseID, set.LoggedByID, nullIfEmpty(set.PerformedAt), set.Reps, set.Weight, set.RPE, set.EstimatedOneRepMax)
+		if execErr != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to log a workout set: %w", execErr)
+		}
+
+		id, idErr := res.LastInsertId()
+		if idErr != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to retrieve logged workout set ID: %w", idErr)
+		}
+
+		set.ID = id
+		logged = append(logged, set)
+	}
+
+	for _, set := range logged {
+		key := [2]int64{set.SubscriberID, set.ExerciseID}
+		if refreshed[key] {
+			continue
+		}
+
+		if refreshErr := refreshPersonalRecords(tx, set.SubscriberID, set.ExerciseID); refreshErr != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to log workout sets (failed to refresh personal records): %w", refreshErr)
+		}
+
+		refreshed[key] = true
+	}
+
+	for i := range logged {
+		scanErr := tx.QueryRow(`SELECT performedAt, isPersonalRecord, createdAt FROM WorkoutSet WHERE id = ?`, logged[i].ID).Scan(&logged[i].PerformedAt, &logged[i].IsPersonalRecord, &logged[i].CreatedAt)
+		if scanErr != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to log workout sets (failed to get logged set): %w", scanErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to log workout sets (failed to commit transaction): %w", commitErr)
+	}
+
+	return logged, nil
+}
+
+func GetWorkoutSetByID(db *sql.DB, id int64) (*WorkoutSet, error) {
+	query := `
+		SELECT S.id, S.subscriberId, S.exerciseId, E.name, S.loggedById, S.performedAt, S.reps, S.weight, S.rpe, S.estimatedOneRepMax, S.isPersonalRecord, S.createdAt
+		FROM WorkoutSet AS S
+		INNER JOIN Excercise AS E ON E.id = S.exerciseId
+		WHERE S.id = ?`
+
+	set := &WorkoutSet{}
+
+	scanErr := db.QueryRow(query, id).Scan(&set.ID, &set.SubscriberID, &set.ExerciseID, &set.ExerciseName, &set.LoggedByID, &set.PerformedAt, &set.Reps, &set.Weight, &set.RPE, &set.EstimatedOneRepMax, &set.IsPersonalRecord, &set.CreatedAt)
+	if scanErr != nil {
+		if errors.Is(scanErr, sql.ErrNoRows) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a workout set (id: %d): %w", id, scanErr)
+	}
+
+	return set, nil
+}
+
+// Sets are ordered from newest to oldest. A zero exercise ID and empty bounds are ignored.
+func GetWorkoutSets(db *sql.DB, subscriberID, exerciseID int64, from, to string) ([]WorkoutSet, error) {
+	query := `
+		SELECT S.id, S.subscriberId, S.exerciseId, E.name, S.loggedById, S.performedAt, S.reps, S.weight, S.rpe, S.estimatedOneRepMax, S.isPersonalRecord, S.createdAt
+		FROM WorkoutSet AS S
+		INNER JOIN Excercise AS E ON E.id = S.exerciseId
+		WHERE S.subscriberId = ?
+			AND (? = 0 OR S.exerciseId = ?)
+			AND (? = '' OR S.performedAt >= ?)
+			AND (? = '' OR S.performedAt <= ?)
+		ORDER BY S.performedAt DESC, S.id DESC`
+
+	rows, queryErr := db.Query(query, subscriberID, exerciseID, exerciseID, from, from, to, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get workout sets: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	sets := []WorkoutSet{}
+	counter := 0
+
+	for rows.Next() {
+		set := WorkoutSet{}
+
+		scanErr := rows.Scan(&set.ID, &set.SubscriberID, &set.ExerciseID, &set.ExerciseName, &set.LoggedByID, &set.PerformedAt, &set.Reps, &set.Weight, &set.RPE, &set.EstimatedOneRepMax, &set.IsPersonalRecord, &set.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a workout set from rows at row (%d): %v", counter, scanErr)
+		} else {
+			sets = append(sets, set)
+		}
+
+		counter++
+	}
+
+	return sets, nil
+}
+
+// Deletes a set and refreshes the personal records of its exercise
+func DeleteWorkoutSetByID(db *sql.DB, id int64) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to delete a workout set (failed to begin transaction): %w", txErr)
+	}
+
+	var subscriberID, exerciseID int64
+
+	scanErr := tx.QueryRow(`SELECT subscriberId, exerciseId FROM WorkoutSet WHERE id = ? FOR UPDATE`, id).Scan(&subscriberID, &exerciseID)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if errors.Is(scanErr, sql.ErrNoRows) {
+			return nil
+		}
+
+		return fmt.Errorf("failed to delete a workout set (id: %d): %w", id, scanErr)
+	}
+
+	if _, execErr := tx.Exec(`DELETE FROM WorkoutSet WHERE id = ?`, id); execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a workout set (id: %d): %w", id, execErr)
+	}
+
+	if refreshErr := refreshPersonalRecords(tx, subscriberID, exerciseID); refreshErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a workout set (failed to refresh personal records): %w", refreshErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a workout set (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+func GetPersonalRecords(db *sql.DB, subscriberID int64) ([]PersonalRecord, error) {
+	query := `
+		SELECT S.exerciseId, E.name, MAX(S.estimatedOneRepMax), MAX(S.weight), MAX(S.reps), MAX(S.performedAt)
+		FROM WorkoutSet AS S
+		INNER JOIN Excercise AS E ON E.id = S.exerciseId
+		WHERE S.subscriberId = ?
+		GROUP BY S.exerciseId, E.name
+		ORDER BY E.name`
+
+	rows, queryErr := db.Query(query, subscriberID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get personal records: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	records := []PersonalRecord{}
+	counter := 0
+
+	for rows.Next() {
+		record := PersonalRecord{}
+
+		scanErr := rows.Scan(&record.ExerciseID, &record.ExerciseName, &record.BestEstimatedOneRepMax, &record.HeaviestWeight, &record.MostReps, &record.LastPerformedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a personal record from rows at row (%d): %v", counter, scanErr)
+		} else {
+			records = append(records, record)
+		}
+
+		counter++
+	}
+
+	return records, nil
+}
+
+// Groups volume by the week (starting Monday) and the exercise category
+func GetWeeklyVolume(db *sql.DB, subscriberID int64, from, to string) ([]WeeklyVolume, error) {
+	query := `
+		SELECT DATE_FORMAT(DATE_SUB(DATE(S.performedAt), INTERVAL WEEKDAY(S.performedAt) DAY), '%Y-%m-%d') AS weekStart, C.id, C.name, COUNT(*), SUM(S.reps), SUM(S.reps * S.weight)
+		FROM WorkoutSet AS S
+		INNER JOIN Excercise AS E ON E.id = S.exerciseId
+		INNER JOIN ExcerciseCategory AS C ON C.id = E.categoryId
+		WHERE S.subscriberId = ?
+			AND (? = '' OR S.performedAt >= ?)
+			AND (? = '' OR S.performedAt <= ?)
+		GROUP BY weekStart, C.id, C.name
+		ORDER BY weekStart, C.name`
+
+	rows, queryErr := db.Query(query, subscriberID, from, from, to, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get weekly volume: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	volumes := []WeeklyVolume{}
+	counter := 0
+
+	for rows.Next() {
+		volume := WeeklyVolume{}
+
+		scanErr := rows.Scan(&volume.WeekStart, &volume.CategoryID, &volume.CategoryName, &volume.Sets, &volume.Reps, &volume.Volume)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan weekly volume from rows at row (%d): %v", counter, scanErr)
+		} else {
+			volumes = append(volumes, volume)
+		}
+
+		counter++
+	}
+
+	return volumes, nil
+}
+
+// Daily aggregates of one exercise, ordered from oldest to newest
+func GetExerciseHistory(db *sql.DB, subscriberID, exerciseID int64, from, to string) ([]ExerciseHistoryPoint, error) {
+	query := `
+		SELECT DATE_FORMAT(performedAt, '%Y-%m-%d') AS day, MAX(estimatedOneRepMax), MAX(weight), COUNT(*), SUM(reps * weight)
+		FROM WorkoutSet
+		WHERE subscriberId = ? AND exerciseId = ?
+			AND (? = '' OR performedAt >= ?)
+			AND (? = '' OR performedAt <= ?)
+		GROUP BY day
+		ORDER BY day`
+
+	rows, queryErr := db.Query(query, subscriberID, exerciseID, from, from, to, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get exercise history: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	points := []ExerciseHistoryPoint{}
+	counter := 0
+
+	for rows.Next() {
+		point := ExerciseHistoryPoint{}
+
+		scanErr := rows.Scan(&point.Date, &point.BestEstimatedOneRepMax, &point.TopWeight, &point.Sets, &point.Volume)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan exercise history from rows at row (%d): %v", counter, scanErr)
+		} else {
+			points = append(points, point)
+		}
+
+		counter++
+	}
+
+	return points, nil
+}
+
+func CreateClassType(db *sql.DB, classType ClassType) (int64, error) {
+	query := `INSERT INTO ClassType (name, description, defaultCapacity, durationMinutes) VALUES (?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, classType.Name, classType.Description, classType.DefaultCapacity, classType.DurationMinutes)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a class type: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created class type ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+func GetClassTypes(db *sql.DB) ([]ClassType, error) {
+	query := `SELECT id, name, description, defaultCapacity, durationMinutes, createdAt FROM ClassType ORDER BY name`
+
+	rows, queryErr := db.Query(query)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get class types: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	types := []ClassType{}
+	counter := 0
+
+	for rows.Next() {
+		classType := ClassType{}
+
+		scanErr := rows.Scan(&classType.ID, &classType.Name, &classType.Description, &classType.DefaultCapacity, &classType.DurationMinutes, &classType.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a class type from rows at row (%d): %v", counter, scanErr)
+		} else {
+			types = append(types, classType)
+		}
+
+		counter++
+	}
+
+	return types, nil
+}
+
+func GetClassTypeByID(db *sql.DB, id int64) (*ClassType, error) {
+	query := `SELECT id, name, description, defaultCapacity, durationMinutes, createdAt FROM ClassType WHERE id = ?`
+
+	classType := &ClassType{}
+
+	scanErr := db.QueryRow(query, id).Scan(&classType.ID, &classType.Name, &classType.Description, &classType.DefaultCapacity, &classType.DurationMinutes, &classType.CreatedAt)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a class type by ID (id: %d): %w", id, scanErr)
+	}
+
+	return classType, nil
+}
+
+func UpdateClassType(db *sql.DB, classType ClassType) error {
+	query := `UPDATE ClassType SET name = ?, description = ?, defaultCapacity = ?, durationMinutes = ? WHERE id = ?`
+
+	_, execErr := db.Exec(query, classType.Name, classType.Description, classType.DefaultCapacity, classType.DurationMinutes, classType.ID)
+	if execErr != nil {
+		return fmt.Errorf("failed to update a class type (id: %d): %w", classType.ID, execErr)
+	}
+
+	return nil
+}
+
+// Returns false if schedules or sessions still use the class type
+func DeleteClassTypeByID(db *sql.DB, id int64) (bool, error) {
+	var used int
+
+	query := `SELECT (SELECT COUNT(*) FROM ClassSchedule WHERE classTypeId = ?) + (SELECT COUNT(*) FROM ClassSession WHERE classTypeId = ?)`
+
+	scanErr := db.QueryRow(query, id, id).Scan(&used)
+	if scanErr != nil {
+		return false, fmt.Errorf("failed to count usages of a class type (id: %d): %w", id, scanErr)
+	}
+
+	if used > 0 {
+		return false, nil
+	}
+
+	_, execErr := db.Exec(`DELETE FROM ClassType WHERE id = ?`, id)
+	if execErr != nil {
+		return false, fmt.Errorf("failed to delete a class type (id: %d): %w", id, execErr)
+	}
+
+	return true, nil
+}
+
+func CreateClassSchedule(db *sql.DB, schedule ClassSchedule) (int64, error) {
+	query := `INSERT INTO ClassSchedule (classTypeId, trainerId, weekday, startTime, durationMinutes, capacity, validFrom, validUntil) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, schedule.ClassTypeID, schedule.TrainerID, schedule.Weekday, schedule.StartTime, schedule.DurationMinutes, schedule.Capacity, schedule.ValidFrom, nullIfEmpty(schedule.ValidUntil))
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a class schedule: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created class schedule ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+const classScheduleQuery = `SELECT S.id, S.classTypeId, T.name, S.trainerId, S.weekday, S.startTime, S.durationMinutes, S.capacity, S.validFrom, COALESCE(S.validUntil, ''), S.active FROM ClassSchedule AS S INNER JOIN ClassType AS T ON T.id = S.classTypeId`
+
+func scanClassSchedule(scanner interface{ Scan(...interface{}) error }, schedule *ClassSchedule) error {
+	return scanner.Scan(&schedule.ID, &schedule.ClassTypeID, &schedule.ClassTypeName, &schedule.TrainerID, &schedule.Weekday, &schedule.StartTime, &schedule.DurationMinutes, &schedule.Capacity, &schedule.ValidFrom, &schedule.ValidUntil, &schedule.Active)
+}
+
+func GetClassSchedules(db *sql.DB, activeOnly bool) ([]ClassSchedule, error) {
+	rows, queryErr := db.Query(classScheduleQuery+` WHERE (? = false OR S.active = true) ORDER BY S.weekday, S.startTime`, activeOnly)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get class schedules: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	schedules := []ClassSchedule{}
+	counter := 0
+
+	for rows.Next() {
+		schedule := ClassSchedule{}
+
+		scanErr := scanClassSchedule(rows, &schedule)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a class schedule from rows at row (%d): %v", counter, scanErr)
+		} else {
+			schedules = append(schedules, schedule)
+		}
+
+		counter++
+	}
+
+	return schedules, nil
+}
+
+func GetClassScheduleByID(db *sql.DB, id int64) (*ClassSchedule, error) {
+	schedule := &ClassSchedule{}
+
+	scanErr := scanClassSchedule(db.QueryRow(classScheduleQuery+` WHERE S.id = ?`, id), schedule)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a class schedule by ID (id: %d): %w", id, scanErr)
+	}
+
+	return schedule, nil
+}
+
+// Books waitlisted places of a session up to its capacity, first come first served
+func fillClassWaitlist(tx *sql.Tx, sessionID int64, capacity int) error {
+	var held int
+
+	scanErr := tx.QueryRow(`SELECT COUNT(*) FROM ClassBooking WHERE sessionId = ? AND status IN ('booked', 'attended', 'noshow')`, sessionID).Scan(&held)
+	if scanErr != nil {
+		return scanErr
+	}
+
+	if held >= capacity {
+		return nil
+	}
+
+	_, execErr := tx.Exec(`UPDATE ClassBooking SET status = ? WHERE sessionId = ? AND status = ? ORDER BY createdAt, id LIMIT ?`, BookingBooked, sessionID, BookingWaitlisted, capacity-held)
+
+	return execErr
+}
+
+// Releases the places of the sessions matched by condition, such as when they're cancelled
+func releaseClassBookings(tx *sql.Tx, condition string, args ...interface{}) error {
+	query := `UPDATE ClassBooking SET status = ? WHERE status IN ('booked', 'waitlisted') AND sessionId IN (SELECT id FROM ClassSession WHERE ` + condition + `)`
+
+	_, execErr := tx.Exec(query, append([]interface{}{BookingCancelled}, args...)...)
+
+	return execErr
+}
+
+// Upcoming sessions without bookings are removed to be generated again from the new schedule.
+// Booked ones that no longer fall on the schedule's slot or validity are cancelled, releasing their bookings
+// so members can book the new sessions; the rest take the new capacity and trainer.
+func UpdateClassSchedule(db *sql.DB, schedule ClassSchedule) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to update a class schedule (failed to begin transaction): %w", txErr)
+	}
+
+	query := `UPDATE ClassSchedule SET classTypeId = ?, trainerId = ?, weekday = ?, startTime = ?, durationMinutes = ?, capacity = ?, validFrom = ?, validUntil = ? WHERE id = ?`
+
+	_, execErr := tx.Exec(query, schedule.ClassTypeID, schedule.TrainerID, schedule.Weekday, schedule.StartTime, schedule.DurationMinutes, schedule.Capacity, schedule.ValidFrom, nullIfEmpty(schedule.ValidUntil), schedule.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a class schedule (id: %d): %w", schedule.ID, execErr)
+	}
+
+	deleteQuery := `DELETE FROM ClassSession WHERE scheduleId = ? AND startsAt > CURRENT_TIMESTAMP AND cancelled = false AND NOT EXISTS (SELECT 1 FROM ClassBooking AS B WHERE B.sessionId = ClassSession.id)`
+
+	_, execErr = tx.Exec(deleteQuery, schedule.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a class schedule (failed to delete unbooked sessions): %w", execErr)
+	}
+
+	// Sessions cancelled by an exception are included so removing the exception doesn't bring them back
+	moved := `scheduleId = ? AND startsAt > CURRENT_TIMESTAMP AND (cancelled = false OR exceptionId IS NOT NULL) AND
+  (classTypeId <> ? OR DAYOFWEEK(startsAt) - 1 <> ? OR TIME_FORMAT(startsAt, '%H:%i') <> ? OR TIMESTAMPDIFF(MINUTE, startsAt, endsAt) <> ?
+  OR DATE(startsAt) < ? OR DATE(startsAt) > COALESCE(?, DATE(startsAt)))`
+	movedArgs := []interface{}{schedule.ID, schedule.ClassTypeID, schedule.Weekday, schedule.StartTime, schedule.DurationMinutes, schedule.ValidFrom, nullIfEmpty(schedule.ValidUntil)}
+
+	if releaseErr := releaseClassBookings(tx, moved, movedArgs...); releaseErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a class schedule (failed to release bookings of moved sessions): %w", releaseErr)
+	}
+
+	_, execErr = tx.Exec(`UPDATE ClassSession SET cancelled = true, exceptionId = NULL WHERE `+moved, movedArgs...)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a class schedule (failed to cancel moved sessions): %w", execErr)
+	}
+
+	_, execErr = tx.Exec(`UPDATE ClassSession SET capacity = ?, trainerId = ? WHERE scheduleId = ? AND startsAt > CURRENT_TIMESTAMP`, schedule.Capacity, schedule.TrainerID, schedule.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a class schedule (failed to update booked sessions): %w", execErr)
+	}
+
+	rows, queryErr := tx.Query(`SELECT id FROM ClassSession WHERE scheduleId = ? AND startsAt > CURRENT_TIMESTAMP AND cancelled = false FOR UPDATE`, schedule.ID)
+	if queryErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a class schedule (failed to get booked sessions): %w", queryErr)
+	}
+
+	sessionIDs := []int64{}
+
+	for rows.Next() {
+		var sessionID int64
+
+		if scanErr := rows.Scan(&sessionID); scanErr != nil {
+			rows.Close()
+			tx.Rollback()
+			return fmt.Errorf("failed to update a class schedule (failed to scan a session): %w", scanErr)
+		}
+
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+
+	rows.Close()
+
+	for _, sessionID := range sessionIDs {
+		if fillErr := fillClassWaitlist(tx, sessionID, schedule.Capacity); fillErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update a class schedule (failed to promote waitlist of session id: %d): %w", sessionID, fillErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a class schedule (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Stops generating sessions for a schedule and cancels its upcoming sessions, releasing their bookings
+func DeactivateClassSchedule(db *sql.DB, id int64) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to deactivate a class schedule (failed to begin transaction): %w", txErr)
+	}
+
+	_, execErr := tx.Exec(`UPDATE ClassSchedule SET active = false WHERE id = ?`, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to deactivate a class schedule (id: %d): %w", id, execErr)
+	}
+
+	if releaseErr := releaseClassBookings(tx, `scheduleId = ? AND startsAt > CURRENT_TIMESTAMP`, id); releaseErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to deactivate a class schedule (failed to release bookings): %w", releaseErr)
+	}
+
+	_, execErr = tx.Exec(`UPDATE ClassSession SET cancelled = true, exceptionId = NULL WHERE scheduleId = ? AND startsAt > CURRENT_TIMESTAMP`, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to deactivate a class schedule (failed to cancel sessions): %w", execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to deactivate a class schedule (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Records an exception and cancels the sessions already generated on that date
+func CreateClassScheduleException(db *sql.DB, exception ClassScheduleException) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a class schedule exception (failed to begin transaction): %w", txErr)
+	}
+
+	res, execErr := tx.Exec(`INSERT INTO ClassScheduleException (scheduleId, date, reason) VALUES (?, ?, ?)`, exception.ScheduleID, exception.Date, exception.Reason)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a class schedule exception: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created class schedule exception ID: %w", idErr)
+	}
+
+	// Sessions remember the exception that cancelled them so deleting it brings them back
+	cancelQuery := `UPDATE ClassSession SET cancelled = true, exceptionId = ? WHERE DATE(startsAt) = ? AND cancelled = false AND scheduleId IS NOT NULL AND (? IS NULL OR scheduleId = ?)`
+
+	_, execErr = tx.Exec(cancelQuery, id, exception.Date, exception.ScheduleID, exception.ScheduleID)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a class schedule exception (failed to cancel sessions): %w", execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a class schedule exception (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Exceptions on or after a date, or all of them if the date is empty
+func GetClassScheduleExceptions(db *sql.DB, from string) ([]ClassScheduleException, error) {
+	query := `SELECT id, scheduleId, date, reason FROM ClassScheduleException WHERE (? = '' OR date >= ?) ORDER BY date`
+
+	rows, queryErr := db.Query(query, from, from)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get class schedule exceptions: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	exceptions := []ClassScheduleException{}
+	counter := 0
+
+	for rows.Next() {
+		exception := ClassScheduleException{}
+
+		scanErr := rows.Scan(&exception.ID, &exception.ScheduleID, &exception.Date, &exception.Reason)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a class schedule exception from rows at row (%d): %v", counter, scanErr)
+		} else {
+			exceptions = append(exceptions, exception)
+		}
+
+		counter++
+	}
+
+	return exceptions, nil
+}
+
+// Upcoming sessions the exception cancelled are restored, unless another exception
+// covers them or their schedule was deactivated.
+func DeleteClassScheduleExceptionByID(db *sql.DB, id int64) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to delete a class schedule exception (failed to begin transaction): %w", txErr)
+	}
+
+	coveringQuery := `
+		SELECT E.id FROM ClassScheduleException AS E
+		WHERE E.id <> ? AND E.date = DATE(ClassSession.startsAt) AND (E.scheduleId IS NULL OR E.scheduleId = ClassSession.scheduleId)
+		LIMIT 1`
+
+	_, execErr := tx.Exec(`UPDATE ClassSession SET exceptionId = (`+coveringQuery+`) WHERE exceptionId = ? AND EXISTS (`+coveringQuery+`)`, id, id, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a class schedule exception (failed to hand sessions to other exceptions): %w", execErr)
+	}
+
+	restoreQuery := `
+		UPDATE ClassSession SET cancelled = false, exceptionId = NULL
+		WHERE exceptionId = ? AND startsAt > CURRENT_TIMESTAMP
+			AND scheduleId IN (SELECT S.id FROM ClassSchedule AS S WHERE S.active = true)`
+
+	_, execErr = tx.Exec(restoreQuery, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a class schedule exception (failed to restore sessions): %w", execErr)
+	}
+
+	_, execErr = tx.Exec(`DELETE FROM ClassScheduleException WHERE id = ?`, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a class schedule exception (id: %d): %w", id, execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to delete a class schedule exception (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Inserts generated sessions, skipping those that already exist for a schedule and start time.
+// Returns the number of sessions created.
+func CreateClassSessions(db *sql.DB, sessions []ClassSession) (int, error) {
+	query := `INSERT IGNORE INTO ClassSession (scheduleId, classTypeId, trainerId, startsAt, endsAt, capacity) VALUES (?, ?, ?, ?, ?, ?)`
+
+	created := 0
+
+	for _, session := range sessions {
+		res, execErr := db.Exec(query, session.ScheduleID, session.ClassTypeID, session.TrainerID, session.StartsAt, session.EndsAt, session.Capacity)
+		if execErr != nil {
+			return created, fmt.Errorf("failed to create a class session: %w", execErr)
+		}
+
+		affected, _ := res.RowsAffected()
+		created += int(affected)
+	}
+
+	return created, nil
+}
+
+const classSessionQuery = `
+	SELECT C.id, C.scheduleId, C.classTypeId, T.name, C.trainerId, C.startsAt, C.endsAt, C.capacity, C.cancelled,
+		(SELECT COUNT(*) FROM ClassBooking AS B WHERE B.sessionId = C.id AND B.status IN ('booked', 'attended', 'noshow')),
+		(SELECT COUNT(*) FROM ClassBooking AS B WHERE B.sessionId = C.id AND B.status = 'waitlisted')
+	FROM ClassSession AS C
+	INNER JOIN ClassType AS T ON T.id = C.classTypeId`
+
+func scanClassSession(scanner interface{ Scan(...interface{}) error }, session *ClassSession) error {
+	return scanner.Scan(&session.ID, &session.ScheduleID, &session.ClassTypeID, &session.ClassTypeName, &session.TrainerID, &session.StartsAt, &session.EndsAt, &session.Capacity, &session.Cancelled, &session.Booked, &session.Waitlisted)
+}
+
+func GetClassSessions(db *sql.DB, from, to string) ([]ClassSession, error) {
+	rows, queryErr := db.Query(classSessionQuery+` WHERE C.startsAt >= ? AND C.startsAt <= ? ORDER BY C.startsAt`, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get class sessions: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	sessions := []ClassSession{}
+	counter := 0
+
+	for rows.Next() {
+		session := ClassSession{}
+
+		scanErr := scanClassSession(rows, &session)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a class session from rows at row (%d): %v", counter, scanErr)
+		} else {
+			sessions = append(sessions, session)
+		}
+
+		counter++
+	}
+
+	return sessions, nil
+}
+
+func GetClassSessionByID(db *sql.DB, id int64) (*ClassSession, error) {
+	session := &ClassSession{}
+
+	scanErr := scanClassSession(db.QueryRow(classSessionQuery+` WHERE C.id = ?`, id), session)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a class session by ID (id: %d): %w", id, scanErr)
+	}
+
+	return session, nil
+}
+
+// Cancels a session and releases its bookings. Returns false if there is no such session.
+func CancelClassSession(db *sql.DB, id int64) (bool, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return false, fmt.Errorf("failed to cancel a class session (failed to begin transaction): %w", txErr)
+	}
+
+	var exists int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM ClassSession WHERE id = ? FOR UPDATE`, id).Scan(&exists)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to cancel a class session (failed to lock session): %w", scanErr)
+	}
+
+	if releaseErr := releaseClassBookings(tx, `id = ?`, id); releaseErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to cancel a class session (failed to release bookings): %w", releaseErr)
+	}
+
+	// Removing an exception that covers the session doesn't bring it back
+	_, execErr := tx.Exec(`UPDATE ClassSession SET cancelled = true, exceptionId = NULL WHERE id = ?`, id)
+	if execErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to cancel a class session (id: %d): %w", id, execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to cancel a class session (failed to commit transaction): %w", commitErr)
+	}
+
+	return true, nil
+}
+
+const classBookingQuery = `SELECT B.id, B.sessionId, B.subscriberId, B.status, B.createdAt, B.updatedAt, CONCAT(S.name, ' ', S.surname) FROM ClassBooking AS B INNER JOIN Subscriber AS S ON S.id = B.subscriberId`
+
+func scanClassBooking(scanner interface{ Scan(...interface{}) error }, booking *ClassBooking) error {
+	return scanner.Scan(&booking.ID, &booking.SessionID, &booking.SubscriberID, &booking.Status, &booking.CreatedAt, &booking.UpdatedAt, &booking.SubscriberName)
+}
+
+// Bookings of a session; the waitlist is in promotion order
+func GetClassSessionBookings(db *sql.DB, sessionID int64) ([]ClassBooking, error) {
+	rows, queryErr := db.Query(classBookingQuery+` WHERE B.sessionId = ? ORDER BY B.createdAt, B.id`, sessionID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get class session bookings: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	bookings := []ClassBooking{}
+	counter := 0
+
+	for rows.Next() {
+		booking := ClassBooking{}
+
+		scanErr := scanClassBooking(rows, &booking)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a class booking from rows at row (%d): %v", counter, scanErr)
+		} else {
+			bookings = append(bookings, booking)
+		}
+
+		counter++
+	}
+
+	return bookings, nil
+}
+
+func GetClassBookingByID(db *sql.DB, id int64) (*ClassBooking, error) {
+	booking := &ClassBooking{}
+
+	scanErr := scanClassBooking(db.QueryRow(classBookingQuery+` WHERE B.id = ?`, id), booking)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a class booking by ID (id: %d): %w", id, scanErr)
+	}
+
+	return booking, nil
+}
+
+// Books a subscriber into a session, or onto its waitlist when it's full.
+// Booking again after a cancellation reuses the booking and joins the end of the waitlist.
+// Returns the booking ID and its status.
+func BookClassSession(db *sql.DB, sessionID, subscriberID int64) (int64, string, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, "", fmt.Errorf("failed to book a class session (failed to begin transaction): %w", txErr)
+	}
+
+	var capacity, taken int
+
+	scanErr := tx.QueryRow(`SELECT capacity FROM ClassSession WHERE id = ? FOR UPDATE`, sessionID).Scan(&capacity)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, "", fmt.Errorf("failed to book a class session (failed to lock session): %w", scanErr)
+	}
+
+	scanErr = tx.QueryRow(`SELECT COUNT(*) FROM ClassBooking WHERE sessionId = ? AND status IN ('booked', 'attended', 'noshow')`, sessionID).Scan(&taken)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, "", fmt.Errorf("failed to book a class session (failed to count bookings): %w", scanErr)
+	}
+
+	var (
+		existingID     int64
+		existingStatus string
+	)
+
+	scanErr = tx.QueryRow(`SELECT id, status FROM ClassBooking WHERE sessionId = ? AND subscriberId = ?`, sessionID, subscriberID).Scan(&existingID, &existingStatus)
+	if scanErr != nil && scanErr != sql.ErrNoRows {
+		tx.Rollback()
+		return 0, "", fmt.Errorf("failed to book a class session (failed to get existing booking): %w", scanErr)
+	}
+
+	if scanErr == nil && existingStatus != BookingCancelled {
+		tx.Rollback()
+		return existingID, existingStatus, nil
+	}
+
+	status := BookingBooked
+	if taken >= capacity {
+		status = BookingWaitlisted
+	}
+
+	id := existingID
+
+	if scanErr == nil {
+		_, execErr := tx.Exec(`UPDATE ClassBooking SET status = ?, createdAt = CURRENT_TIMESTAMP WHERE id = ?`, status, existingID)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, "", fmt.Errorf("failed to book a class session (failed to rebook): %w", execErr)
+		}
+	} else {
+		res, execErr := tx.Exec(`INSERT INTO ClassBooking (sessionId, subscriberId, status) VALUES (?, ?, ?)`, sessionID, subscriberID, status)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, "", fmt.Errorf("failed to book a class session: %w", execErr)
+		}
+
+		insertedID, idErr := res.LastInsertId()
+		if idErr != nil {
+			tx.Rollback()
+			return 0, "", fmt.Errorf("failed to retrieve created class booking ID: %w", idErr)
+		}
+
+		id = insertedID
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, "", fmt.Errorf("failed to book a class session (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, status, nil
+}
+
+// Cancels a booking and, if it held a place, promotes the first waitlisted booking.
+// Returns the ID of the promoted booking, if any.
+func CancelClassBooking(db *sql.DB, id int64) (*int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return nil, fmt.Errorf("failed to cancel a class booking (failed to begin transaction): %w", txErr)
+	}
+
+	var (
+		sessionID int64
+		status    string
+	)
+
+	scanErr := tx.QueryRow(`SELECT sessionId, status FROM ClassBooking WHERE id = ? FOR UPDATE`, id).Scan(&sessionID, &status)
+	if scanErr != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to cancel a class booking (id: %d): %w", id, scanErr)
+	}
+
+	_, execErr := tx.Exec(`UPDATE ClassBooking SET status = ? WHERE id = ?`, BookingCancelled, id)
+	if execErr != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to cancel a class booking (id: %d): %w", id, execErr)
+	}
+
+	var promoted *int64
+
+	if status == BookingBooked {
+		var nextID int64
+
+		scanErr := tx.QueryRow(`SELECT id FROM ClassBooking WHERE sessionId = ? AND status = 'waitlisted' ORDER BY createdAt, id LIMIT 1 FOR UPDATE`, sessionID).Scan(&nextID)
+		if scanErr != nil && scanErr != sql.ErrNoRows {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to cancel a class booking (failed to get waitlist): %w", scanErr)
+		}
+
+		if scanErr == nil {
+			_, execErr := tx.Exec(`UPDATE ClassBooking SET status = ? WHERE id = ?`, BookingBooked, nextID)
+			if execErr != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to cancel a class booking (failed to promote waitlist): %w", execErr)
+			}
+
+			promoted = &nextID
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to cancel a class booking (failed to commit transaction): %w", commitErr)
+	}
+
+	return promoted, nil
+}
+
+// Marks the listed bookings of a session as attended and every other held place as a no-show.
+// Can be repeated to correct a previous mark-off.
+func MarkClassAttendance(db *sql.DB, sessionID int64, attended []int64) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to mark class attendance (failed to begin transaction): %w", txErr)
+	}
+
+	_, execErr := tx.Exec(`UPDATE ClassBooking SET status = 'noshow' WHERE sessionId = ? AND status IN ('booked', 'attended', 'noshow')`, sessionID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to mark class attendance (failed to reset bookings): %w", execErr)
+	}
+
+	for _, bookingID := range attended {
+		_, execErr := tx.Exec(`UPDATE ClassBooking SET status = 'attended' WHERE id = ? AND sessionId = ? AND status = 'noshow'`, bookingID, sessionID)
+		if execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to mark class attendance (booking id: %d): %w", bookingID, execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to mark class attendance (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Attendance per subscriber for sessions starting between from and to, worst no-show rate first.
+// Cancelled sessions never ran and aren't counted.
+func GetNoShowStats(db *sql.DB, from, to string) ([]NoShowStats, error) {
+	query := `
+		SELECT B.subscriberId, CONCAT(S.name, ' ', S.surname),
+			SUM(B.status = 'attended'), SUM(B.status = 'noshow'), SUM(B.status = 'cancelled')
+		FROM ClassBooking AS B
+		INNER JOIN ClassSession AS C ON C.id = B.sessionId
+		INNER JOIN Subscriber AS S ON S.id = B.subscriberId
+		WHERE C.startsAt >= ? AND C.startsAt <= ? AND C.cancelled = false
+		GROUP BY B.subscriberId, S.name, S.surname
+		HAVING SUM(B.status IN ('attended', 'noshow')) > 0
+		ORDER BY SUM(B.status = 'noshow') / SUM(B.status IN ('attended', 'noshow')) DESC`
+
+	rows, queryErr := db.Query(query, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get no-show stats: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	stats := []NoShowStats{}
+	counter := 0
+
+	for rows.Next() {
+		stat := NoShowStats{}
+
+		scanErr := rows.Scan(&stat.SubscriberID, &stat.SubscriberName, &stat.Attended, &stat.NoShows, &stat.Cancelled)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan no-show stats from rows at row (%d): %v", counter, scanErr)
+		} else {
+			stat.NoShowRate = float64(stat.NoShows) / float64(stat.Attended+stat.NoShows)
+			stats = append(stats, stat)
+		}
+
+		counter++
+	}
+
+	return stats, nil
+}
+
+func GetTrainerAvailability(db *sql.DB, trainerID int64) ([]TrainerAvailability, error) {
+	query := `SELECT id, trainerId, weekday, startTime, endTime FROM TrainerAvailability WHERE trainerId = ? ORDER BY weekday, startTime`
+
+	rows, queryErr := db.Query(query, trainerID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get trainer availability: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	windows := []TrainerAvailability{}
+	counter := 0
+
+	for rows.Next() {
+		window := TrainerAvailability{}
+
+		scanErr := rows.Scan(&window.ID, &window.TrainerID, &window.Weekday, &window.StartTime, &window.EndTime)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan trainer availability from rows at row (%d): %v", counter, scanErr)
+		} else {
+			windows = append(windows, window)
+		}
+
+		counter++
+	}
+
+	return windows, nil
+}
+
+// Replaces the weekly availability of a trainer
+func ReplaceTrainerAvailability(db *sql.DB, trainerID int64, windows []TrainerAvailability) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to replace trainer availability (failed to begin transaction): %w", txErr)
+	}
+
+	_, execErr := tx.Exec(`DELETE FROM TrainerAvailability WHERE trainerId = ?`, trainerID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace trainer availability (failed to delete previous): %w", execErr)
+	}
+
+	for _, window := range windows {
+		_, execErr := tx.Exec(`INSERT INTO TrainerAvailability (trainerId, weekday, startTime, endTime) VALUES (?, ?, ?, ?)`, trainerID, window.Weekday, window.StartTime, window.EndTime)
+		if execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to replace trainer availability: %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to replace trainer availability (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+func CreateTrainerTimeBlock(db *sql.DB, block TrainerTimeBlock) (int64, error) {
+	query := `INSERT INTO TrainerTimeBlock (trainerId, startsAt, endsAt, reason) VALUES (?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, block.TrainerID, block.StartsAt, block.EndsAt, block.Reason)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a trainer time block: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created trainer time block ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+// Blocks overlapping the range between from and to
+func GetTrainerTimeBlocks(db *sql.DB, trainerID int64, from, to string) ([]TrainerTimeBlock, error) {
+	query := `SELECT id, trainerId, startsAt, endsAt, reason FROM TrainerTimeBlock WHERE trainerId = ? AND endsAt > ? AND startsAt < ? ORDER BY startsAt`
+
+	rows, queryErr := db.Query(query, trainerID, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get trainer time blocks: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	blocks := []TrainerTimeBlock{}
+	counter := 0
+
+	for rows.Next() {
+		block := TrainerTimeBlock{}
+
+		scanErr := rows.Scan(&block.ID, &block.TrainerID, &block.StartsAt, &block.EndsAt, &block.Reason)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a trainer time block from rows at row (%d): %v", counter, scanErr)
+		} else {
+			blocks = append(blocks, block)
+		}
+
+		counter++
+	}
+
+	return blocks, nil
+}
+
+func DeleteTrainerTimeBlockByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM TrainerTimeBlock WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a trainer time block (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+// Records payment, if any, for the package in the same transaction
+func CreatePTPackage(db *sql.DB, pkg PTPackage, payment *Payment) (int64, error) {
+	query := `INSERT INTO PTPackage (subscriberId, trainerId, totalSessions, remainingSessions, sessionMinutes, price, expiresAt) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a PT package (failed to begin transaction): %w", txErr)
+	}
+
+	res, execErr := tx.Exec(query, pkg.SubscriberID, pkg.TrainerID, pkg.TotalSessions, pkg.TotalSessions, pkg.SessionMinutes, pkg.Price, nullIfEmpty(pkg.ExpiresAt))
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a PT package: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created PT package ID: %w", idErr)
+	}
+
+	if payment != nil {
+		payment.SubscriberID = &pkg.SubscriberID
+		payment.Notes = fmt.Sprintf("PT package #%d", id)
+
+		if _, execErr := tx.Exec(createPaymentQuery, paymentArgs(payment)...); execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create a PT package (failed to record payment): %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a PT package (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+const ptPackageQuery = `SELECT id, subscriberId, trainerId, totalSessions, remainingSessions, sessionMinutes, price, purchasedAt, COALESCE(expiresAt, '') FROM PTPackage`
+
+func scanPTPackage(scanner interface{ Scan(...interface{}) error }, pkg *PTPackage) error {
+	return scanner.Scan(&pkg.ID, &pkg.SubscriberID, &pkg.TrainerID, &pkg.TotalSessions, &pkg.RemainingSessions, &pkg.SessionMinutes, &pkg.Price, &pkg.PurchasedAt, &pkg.ExpiresAt)
+}
+
+func GetSubscriberPTPackages(db *sql.DB, subscriberID int64) ([]PTPackage, error) {
+	rows, queryErr := db.Query(ptPackageQuery+` WHERE subscriberId = ? ORDER BY purchasedAt DESC`, subscriberID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get PT packages: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	packages := []PTPackage{}
+	counter := 0
+
+	for rows.Next() {
+		pkg := PTPackage{}
+
+		scanErr := scanPTPackage(rows, &pkg)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a PT package from rows at row (%d): %v", counter, scanErr)
+		} else {
+			packages = append(packages, pkg)
+		}
+
+		counter++
+	}
+
+	return packages, nil
+}
+
+func GetPTPackageByID(db *sql.DB, id int64) (*PTPackage, error) {
+	pkg := &PTPackage{}
+
+	scanErr := scanPTPackage(db.QueryRow(ptPackageQuery+` WHERE id = ?`, id), pkg)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a PT package by ID (id: %d): %w", id, scanErr)
+	}
+
+	return pkg, nil
+}
+
+// Reasons a personal training session can't be booked
+var (
+	ErrPTTrainerBlocked   = errors.New("trainer is blocked at that time")
+	ErrPTTrainerBusy      = errors.New("trainer has another session at that time")
+	ErrPTSubscriberBusy   = errors.New("subscriber has another session at that time")
+	ErrPTPackageExhausted = errors.New("package has no sessions left to book")
+)
+
+var ErrPTChargeNotOwed = errors.New("session has no unpaid charge")
+
+// Books a session after checking, under locks on the trainer and subscriber, that it doesn't
+// overlap blocked time or other sessions of the trainer or subscriber, and that
+// the package has sessions left that aren't already booked.
+// Availability windows are checked by the caller.
+func CreatePTSession(db *sql.DB, session PTSession) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to book a PT session (failed to begin transaction): %w", txErr)
+	}
+
+	var locked int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM Trainer WHERE id = ? FOR UPDATE`, session.TrainerID).Scan(&locked)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to book a PT session (failed to lock trainer): %w", scanErr)
+	}
+
+	scanErr = tx.QueryRow(`SELECT id FROM Subscriber WHERE id = ? FOR UPDATE`, session.SubscriberID).Scan(&locked)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to book a PT session (failed to lock subscriber): %w", scanErr)
+	}
+
+	checks := []struct {
+		query string
+		args  []interface{}
+		err   error
+	}{
+		{
+			`SELECT COUNT(*) FROM TrainerTimeBlock WHERE trainerId = ? AND startsAt < ? AND endsAt > ?`,
+			[]interface{}{session.TrainerID, session.EndsAt, session.StartsAt},
+			ErrPTTrainerBlocked,
+		},
+		{
+			`SELECT COUNT(*) FROM PTSession WHERE trainerId = ? AND status IN ('booked', 'completed') AND startsAt < ? AND endsAt > ?`,
+			[]interface{}{session.TrainerID, session.EndsAt, session.StartsAt},
+			ErrPTTrainerBusy,
+		},
+		{
+			`SELECT COUNT(*) FROM PTSession WHERE subscriberId = ? AND status IN ('booked', 'completed') AND startsAt < ? AND endsAt > ?`,
+			[]interface{}{session.SubscriberID, session.EndsAt, session.StartsAt},
+			ErrPTSubscriberBusy,
+		},
+	}
+
+	if session.PackageID != nil {
+		checks = append(checks, struct {
+			query string
+			args  []interface{}
+			err   error
+		}{
+			`SELECT (SELECT COUNT(*) FROM PTSession WHERE packageId = ? AND status = 'booked') >= COALESCE((SELECT remainingSessions FROM PTPackage WHERE id = ?), 0)`,
+			[]interface{}{*session.PackageID, *session.PackageID},
+			ErrPTPackageExhausted,
+		})
+	}
+
+	for _, check := range checks {
+		var conflicts int
+
+		scanErr := tx.QueryRow(check.query, check.args...).Scan(&conflicts)
+		if scanErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to book a PT session (failed to check conflicts): %w", scanErr)
+		}
+
+		if conflicts > 0 {
+			tx.Rollback()
+			return 0, check.err
+		}
+	}
+
+	query := `INSERT INTO PTSession (trainerId, subscriberId, packageId, bookedById, startsAt, endsAt, notes) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, session.TrainerID, session.SubscriberID, session.PackageID, session.BookedByID, session.StartsAt, session.EndsAt, session.Notes)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to book a PT session: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created PT session ID: %w", idErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to book a PT session (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+const ptSessionQuery = `SELECT id, trainerId, subscriberId, packageId, bookedById, startsAt, endsAt, status, lateCancelCharge, chargePaymentId, notes, createdAt, updatedAt FROM PTSession`
+
+func scanPTSession(scanner interface{ Scan(...interface{}) error }, session *PTSession) error {
+	return scanner.Scan(&session.ID, &session.TrainerID, &session.SubscriberID, &session.PackageID, &session.BookedByID, &session.StartsAt, &session.EndsAt, &session.Status, &session.LateCancelCharge, &session.ChargePaymentID, &session.Notes, &session.CreatedAt, &session.UpdatedAt)
+}
+
+// Zero IDs are ignored
+func GetPTSessions(db *sql.DB, trainerID, subscriberID int64, from, to string) ([]PTSession, error) {
+	query := ptSessionQuery + ` WHERE (? = 0 OR trainerId = ?) AND (? = 0 OR subscriberId = ?) AND startsAt >= ? AND startsAt <= ? ORDER BY startsAt`
+
+	rows, queryErr := db.Query(query, trainerID, trainerID, subscriberID, subscriberID, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get PT sessions: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	sessions := []PTSession{}
+	counter := 0
+
+	for rows.Next() {
+		session := PTSession{}
+
+		scanErr := scanPTSession(rows, &session)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a PT session from rows at row (%d): %v", counter, scanErr)
+		} else {
+			sessions = append(sessions, session)
+		}
+
+		counter++
+	}
+
+	return sessions, nil
+}
+
+func GetPTSessionByID(db *sql.DB, id int64) (*PTSession, error) {
+	session := &PTSession{}
+
+	scanErr := scanPTSession(db.QueryRow(ptSessionQuery+` WHERE id = ?`, id), session)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a PT session by ID (id: %d): %w", id, scanErr)
+	}
+
+	return session, nil
+}
+
+// Moves a booked session to its final status, charging and using up a
+// package session as requested. A charge is owed until PayPTSessionCharge records it.
+// Returns false if the session wasn't booked.
+func ClosePTSession(db *sql.DB, id int64, status string, charge common.Money, consumeSession bool) (bool, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return false, fmt.Errorf("failed to close a PT session (failed to begin transaction): %w", txErr)
+	}
+
+	var packageID *int64
+
+	scanErr := tx.QueryRow(`SELECT packageId FROM PTSession WHERE id = ? AND status = 'booked' FOR UPDATE`, id).Scan(&packageID)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to close a PT session (id: %d): %w", id, scanErr)
+	}
+
+	_, execErr := tx.Exec(`UPDATE PTSession SET status = ?, lateCancelCharge = ? WHERE id = ?`, status, charge, id)
+	if execErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to close a PT session (id: %d): %w", id, execErr)
+	}
+
+	if consumeSession && packageID != nil {
+		_, execErr := tx.Exec(`UPDATE PTPackage SET remainingSessions = remainingSessions - 1 WHERE id = ? AND remainingSessions > 0`, *packageID)
+		if execErr != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("failed to close a PT session (failed to use package session): %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to close a PT session (failed to commit transaction): %w", commitErr)
+	}
+
+	return true, nil
+}
+
+// Records the payment of a session's charge in the income ledger, for the charged amount.
+// Returns false if there is no such session; fails with ErrPTChargeNotOwed if nothing is owed.
+func PayPTSessionCharge(db *sql.DB, id int64, payment Payment) (bool, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return false, fmt.Errorf("failed to pay a PT session charge (failed to begin transaction): %w", txErr)
+	}
+
+	var subscriberID int64
+	var charge common.Money
+	var paymentID *int64
+
+	scanErr := tx.QueryRow(`SELECT subscriberId, lateCancelCharge, chargePaymentId FROM PTSession WHERE id = ? FOR UPDATE`, id).Scan(&subscriberID, &charge, &paymentID)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to pay a PT session charge (failed to lock session): %w", scanErr)
+	}
+
+	if charge <= 0 || paymentID != nil {
+		tx.Rollback()
+		return true, ErrPTChargeNotOwed
+	}
+
+	payment.Source = PaymentPT
+	payment.SubscriberID = &subscriberID
+	payment.Amount = charge
+	payment.Notes = fmt.Sprintf("Late cancellation fee of PT session %d", id)
+
+	res, execErr := tx.Exec(createPaymentQuery, paymentArgs(&payment)...)
+	if execErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to pay a PT session charge (failed to record payment): %w", execErr)
+	}
+
+	createdID, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to retrieve recorded payment ID: %w", idErr)
+	}
+
+	_, execErr = tx.Exec(`UPDATE PTSession SET chargePaymentId = ? WHERE id = ?`, createdID, id)
+	if execErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to pay a PT session charge (id: %d): %w", id, execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to pay a PT session charge (failed to commit transaction): %w", commitErr)
+	}
+
+	return true, nil
+}
+
+func GetStaffPayRates(db *sql.DB) (map[int64]StaffPayRate, error) {
+	rows, queryErr := db.Query(`SELECT userId, payType, hourlyRate FROM StaffPayRate`)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get staff pay rates: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	rates := map[int64]StaffPayRate{}
+	counter := 0
+
+	for rows.Next() {
+		rate := StaffPayRate{}
+
+		scanErr := rows.Scan(&rate.UserID, &rate.PayType, &rate.HourlyRate)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a staff pay rate at row (%d): %v", counter, scanErr)
+		} else {
+			rates[rate.UserID] = rate
+		}
+
+		counter++
+	}
+
+	return rates, nil
+}
+
+func SetStaffPayRate(db *sql.DB, rate StaffPayRate) error {
+	query := `INSERT INTO StaffPayRate (userId, payType, hourlyRate) VALUES (?, ?, ?)
+  ON DUPLICATE KEY UPDATE payType = VALUES(payType), hourlyRate = VALUES(hourlyRate)`
+
+	_, execErr := db.Exec(query, rate.UserID, rate.PayType, rate.HourlyRate)
+	if execErr != nil {
+		return fmt.Errorf("failed to set staff pay rate (user id: %d): %w", rate.UserID, execErr)
+	}
+
+	return nil
+}
+
+var (
+	ErrShiftOverlap     = errors.New("shift overlaps another shift of the same user")
+	ErrAlreadyClockedIn = errors.New("user is already clocked in")
+	ErrPayrollRunExists = errors.New("payroll has already been run for that period")
+)
+
+// Creates the shift when id is 0, updates it otherwise, rejecting shifts that overlap the user's other shifts
+func saveShift(db *sql.DB, shift Shift) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to save a shift (failed to begin transaction): %w", txErr)
+	}
+
+	var locked int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM User WHERE id = ? FOR UPDATE`, shift.UserID).Scan(&locked)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to save a shift (failed to lock user): %w", scanErr)
+	}
+
+	var overlaps int
+
+	scanErr = tx.QueryRow(`SELECT COUNT(*) FROM Shift WHERE userId = ? AND id != ? AND startsAt < ? AND endsAt > ?`,
+		shift.UserID, shift.ID, shift.EndsAt, shift.StartsAt).Scan(&overlaps)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to save a shift (failed to check overlaps): %w", scanErr)
+	}
+
+	if overlaps > 0 {
+		tx.Rollback()
+		return 0, ErrShiftOverlap
+	}
+
+	id := shift.ID
+
+	if id == 0 {
+		res, execErr := tx.Exec(`INSERT INTO Shift (userId, startsAt, endsAt, notes) VALUES (?, ?, ?, ?)`, shift.UserID, shift.StartsAt, shift.EndsAt, shift.Notes)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create a shift: %w", execErr)
+		}
+
+		var idErr error
+
+		id, idErr = res.LastInsertId()
+		if idErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to retrieve created shift ID: %w", idErr)
+		}
+	} else {
+		_, execErr := tx.Exec(`UPDATE Shift SET userId = ?, startsAt = ?, endsAt = ?, notes = ? WHERE id = ?`, shift.UserID, shift.StartsAt, shift.EndsAt, shift.Notes, id)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to update a shift (id: %d): %w", id, execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to save a shift (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+func CreateShift(db *sql.DB, shift Shift) (int64, error) {
+	shift.ID = 0
+	return saveShift(db, shift)
+}
+
+func UpdateShift(db *sql.DB, shift Shift) error {
+	_, saveErr := saveShift(db, shift)
+	return saveErr
+}
+
+const shiftQuery = `SELECT id, userId, startsAt, endsAt, notes, createdAt FROM Shift`
+
+func scanShift(scanner interface{ Scan(...interface{}) error }, shift *Shift) error {
+	return scanner.Scan(&shift.ID, &shift.UserID, &shift.StartsAt, &shift.EndsAt, &shift.Notes, &shift.CreatedAt)
+}
+
+// A userID of 0 lists the shifts of all users
+func GetShifts(db *sql.DB, userID int64, from, to string) ([]Shift, error) {
+	query := shiftQuery + ` WHERE (? = 0 OR userId = ?) AND endsAt > ? AND startsAt < ? ORDER BY startsAt`
+
+	rows, queryErr := db.Query(query, userID, userID, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get shifts: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	shifts := []Shift{}
+	counter := 0
+
+	for rows.Next() {
+		shift := Shift{}
+
+		scanErr := scanShift(rows, &shift)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a shift at row (%d): %v", counter, scanErr)
+		} else {
+			shifts = append(shifts, shift)
+		}
+
+		counter++
+	}
+
+	return shifts, nil
+}
+
+func GetShiftByID(db *sql.DB, id int64) (*Shift, error) {
+	shift := &Shift{}
+
+	scanErr := scanShift(db.QueryRow(shiftQuery+` WHERE id = ?`, id), shift)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a shift by ID (id: %d): %w", id, scanErr)
+	}
+
+	return shift, nil
+}
+
+func DeleteShiftByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM Shift WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a shift (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+// Links the entry to the user's shift running at the given time, or starting within the next 30 minutes
+func ClockIn(db *sql.DB, userID int64, at, notes string) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to clock in (failed to begin transaction): %w", txErr)
+	}
+
+	var locked int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM User WHERE id = ? FOR UPDATE`, userID).Scan(&locked)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to clock in (failed to lock user): %w", scanErr)
+	}
+
+	var open int
+
+	scanErr = tx.QueryRow(`SELECT COUNT(*) FROM TimeEntry WHERE userId = ? AND clockOut IS NULL`, userID).Scan(&open)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to clock in (failed to check open entries): %w", scanErr)
+	}
+
+	if open > 0 {
+		tx.Rollback()
+		return 0, ErrAlreadyClockedIn
+	}
+
+	var shiftID *int64
+
+	scanErr = tx.QueryRow(`SELECT id FROM Shift WHERE userId = ? AND startsAt <= DATE_ADD(?, INTERVAL 30 MINUTE) AND endsAt > ? ORDER BY startsAt LIMIT 1`,
+		userID, at, at).Scan(&shiftID)
+	if scanErr != nil && scanErr != sql.ErrNoRows {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to clock in (failed to find shift): %w", scanErr)
+	}
+
+	res, execErr := tx.Exec(`INSERT INTO TimeEntry (userId, shiftId, clockIn, notes) VALUES (?, ?, ?, ?)`, userID, shiftID, at, notes)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to clock in: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created time entry ID: %w", idErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to clock in (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Returns false if the user wasn't clocked in
+func ClockOut(db *sql.DB, userID int64, at string) (bool, error) {
+	res, execErr := db.Exec(`UPDATE TimeEntry SET clockOut = ? WHERE userId = ? AND clockOut IS NULL`, at, userID)
+	if execErr != nil {
+		return false, fmt.Errorf("failed to clock out (user id: %d): %w", userID, execErr)
+	}
+
+	affected, affectedErr := res.RowsAffected()
+	if affectedErr != nil {
+		return false, fmt.Errorf("failed to clock out (failed to get affected rows): %w", affectedErr)
+	}
+
+	return affected > 0, nil
+}
+
+const timeEntryQuery = `SELECT id, userId, shiftId, clockIn, COALESCE(clockOut, ''), notes FROM TimeEntry`
+
+func scanTimeEntry(scanner interface{ Scan(...interface{}) error }, entry *TimeEntry) error {
+	return scanner.Scan(&entry.ID, &entry.UserID, &entry.ShiftID, &entry.ClockIn, &entry.ClockOut, &entry.Notes)
+}
+
+// Returns nil if the user is not clocked in
+func GetOpenTimeEntry(db *sql.DB, userID int64) (*TimeEntry, error) {
+	entry := &TimeEntry{}
+
+	scanErr := scanTimeEntry(db.QueryRow(timeEntryQuery+` WHERE userId = ? AND clockOut IS NULL`, userID), entry)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get open time entry (user id: %d): %w", userID, scanErr)
+	}
+
+	return entry, nil
+}
+
+// Entries clocked in within [from, to). A userID of 0 lists the entries of all users.
+func GetTimeEntries(db *sql.DB, userID int64, from, to string) ([]TimeEntry, error) {
+	query := timeEntryQuery + ` WHERE (? = 0 OR userId = ?) AND clockIn >= ? AND clockIn < ? ORDER BY clockIn`
+
+	rows, queryErr := db.Query(query, userID, userID, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get time entries: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	entries := []TimeEntry{}
+	counter := 0
+
+	for rows.Next() {
+		entry := TimeEntry{}
+
+		scanErr := scanTimeEntry(rows, &entry)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a time entry at row (%d): %v", counter, scanErr)
+		} else {
+			entries = append(entries, entry)
+		}
+
+		counter++
+	}
+
+	return entries, nil
+}
+
+func GetTimeEntryByID(db *sql.DB, id int64) (*TimeEntry, error) {
+	entry := &TimeEntry{}
+
+	scanErr := scanTimeEntry(db.QueryRow(timeEntryQuery+` WHERE id = ?`, id), entry)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a time entry by ID (id: %d): %w", id, scanErr)
+	}
+
+	return entry, nil
+}
+
+// Corrects the times of an entry, an empty clockOut reopens it
+func UpdateTimeEntry(db *sql.DB, entry TimeEntry) error {
+	_, execErr := db.Exec(`UPDATE TimeEntry SET clockIn = ?, clockOut = ?, notes = ? WHERE id = ?`, entry.ClockIn, nullIfEmpty(entry.ClockOut), entry.Notes, entry.ID)
+	if execErr != nil {
+		return fmt.Errorf("failed to update a time entry (id: %d): %w", entry.ID, execErr)
+	}
+
+	return nil
+}
+
+func DeleteTimeEntryByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM TimeEntry WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a time entry (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+func CreatePayAdjustment(db *sql.DB, adjustment PayAdjustment) (int64, error) {
+	query := `INSERT INTO PayAdjustment (userId, period, kind, amount, reason, createdById) VALUES (?, ?, ?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, adjustment.UserID, adjustment.Period, adjustment.Kind, adjustment.Amount, adjustment.Reason, adjustment.CreatedByID)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a pay adjustment: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created pay adjustment ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+// A userID of 0 lists the adjustments of all users
+func GetPayAdjustments(db *sql.DB, period string, userID int64) ([]PayAdjustment, error) {
+	query := `SELECT id, userId, period, kind, amount, reason, createdById, createdAt FROM PayAdjustment WHERE period = ? AND (? = 0 OR userId = ?) ORDER BY id`
+
+	rows, queryErr := db.Query(query, period, userID, userID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get pay adjustments: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	adjustments := []PayAdjustment{}
+	counter := 0
+
+	for rows.Next() {
+		adjustment := PayAdjustment{}
+
+		scanErr := rows.Scan(&adjustment.ID, &adjustment.UserID, &adjustment.Period, &adjustment.Kind, &adjustment.Amount, &adjustment.Reason, &adjustment.CreatedByID, &adjustment.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a pay adjustment at row (%d): %v", counter, scanErr)
+		} else {
+			adjustments = append(adjustments, adjustment)
+		}
+
+		counter++
+	}
+
+	return adjustments, nil
+}
+
+func DeletePayAdjustmentByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM PayAdjustment WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a pay adjustment (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+func PayrollRunExists(db *sql.DB, period string) (bool, error) {
+	var count int
+
+	scanErr := db.QueryRow(`SELECT COUNT(*) FROM PayrollRun WHERE period = ?`, period).Scan(&count)
+	if scanErr != nil {
+		return false, fmt.Errorf("failed to check payroll run (period: %s): %w", period, scanErr)
+	}
+
+	return count > 0, nil
+}
+
+// Stores the run with its payslips, failing with ErrPayrollRunExists if the period was already run
+func CreatePayrollRun(db *sql.DB, run PayrollRun) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a payroll run (failed to begin transaction): %w", txErr)
+	}
+
+	var count int
+
+	scanErr := tx.QueryRow(`SELECT COUNT(*) FROM PayrollRun WHERE period = ? FOR UPDATE`, run.Period).Scan(&count)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a payroll run (failed to check period): %w", scanErr)
+	}
+
+	if count > 0 {
+		tx.Rollback()
+		return 0, ErrPayrollRunExists
+	}
+
+	res, execErr := tx.Exec(`INSERT INTO PayrollRun (period, total, createdById) VALUES (?, ?, ?)`, run.Period, run.Total, run.CreatedByID)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a payroll run: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created payroll run ID: %w", idErr)
+	}
+
+	slipQuery := `INSERT INTO Payslip (runId, userId, userName, payType, hoursWorked, regularHours, overtimeHours, basePay, overtimePay, bonuses, deductions, netPay)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	for _, slip := range run.Payslips {
+		_, execErr := tx.Exec(slipQuery, id, slip.UserID, slip.UserName, slip.PayType, slip.HoursWorked, slip.RegularHours, slip.OvertimeHours,
+			slip.BasePay, slip.OvertimePay, slip.Bonuses, slip.Deductions, slip.NetPay)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create a payroll run (failed to insert payslip): %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a payroll run (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Without payslips, latest period first
+func GetPayrollRuns(db *sql.DB) ([]PayrollRun, error) {
+	rows, queryErr := db.Query(`SELECT id, period, total, createdById, createdAt FROM PayrollRun ORDER BY period DESC`)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get payroll runs: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	runs := []PayrollRun{}
+	counter := 0
+
+	for rows.Next() {
+		run := PayrollRun{}
+
+		scanErr := rows.Scan(&run.ID, &run.Period, &run.Total, &run.CreatedByID, &run.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a payroll run at row (%d): %v", counter, scanErr)
+		} else {
+			runs = append(runs, run)
+		}
+
+		counter++
+	}
+
+	return runs, nil
+}
+
+// Includes the payslips
+func GetPayrollRunByID(db *sql.DB, id int64) (*PayrollRun, error) {
+	run := &PayrollRun{}
+
+	scanErr := db.QueryRow(`SELECT id, period, total, createdById, createdAt FROM PayrollRun WHERE id = ?`, id).Scan(&run.ID, &run.Period, &run.Total, &run.CreatedByID, &run.CreatedAt)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a payroll run by ID (id: %d): %w", id, scanErr)
+	}
+
+	query := `SELECT id, runId, userId, userName, payType, hoursWorked, regularHours, overtimeHours, basePay, overtimePay, bonuses, deductions, netPay
+  FROM Payslip WHERE runId = ? ORDER BY userName`
+
+	rows, queryErr := db.Query(query, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a payroll run by ID (failed to get payslips): %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	run.Payslips = []Payslip{}
+	counter := 0
+
+	for rows.Next() {
+		slip := Payslip{}
+
+		scanErr := rows.Scan(&slip.ID, &slip.RunID, &slip.UserID, &slip.UserName, &slip.PayType, &slip.HoursWorked, &slip.RegularHours, &slip.OvertimeHours,
+			&slip.BasePay, &slip.OvertimePay, &slip.Bonuses, &slip.Deductions, &slip.NetPay)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a payslip at row (%d): %v", counter, scanErr)
+		} else {
+			run.Payslips = append(run.Payslips, slip)
+		}
+
+		counter++
+	}
+
+	return run, nil
+}
+
+func DeletePayrollRunByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM PayrollRun WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a payroll run (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+const createPaymentQuery = `INSERT INTO Payment (source, subscriberId, planId, amount, method, paidAt, notes, createdById) VALUES (?, ?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP), ?, ?)`
+
+func paymentArgs(payment *Payment) []interface{} {
+	return []interface{}{payment.Source, payment.SubscriberID, payment.PlanID, payment.Amount, payment.Method, nullIfEmpty(payment.PaidAt), payment.Notes, payment.CreatedByID}
+}
+
+// An empty paidAt records the payment as of now
+func CreatePayment(db *sql.DB, payment Payment) (int64, error) {
+	res, execErr := db.Exec(createPaymentQuery, paymentArgs(&payment)...)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a payment: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created payment ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+// Payments within the dates, both inclusive. A subscriberID of 0 lists the payments of everyone.
+func GetPayments(db *sql.DB, from, to string, subscriberID int64) ([]Payment, error) {
+	query := `SELECT id, source, subscriberId, planId, amount, method, paidAt, notes, createdById FROM Payment
+  WHERE paidAt >= ? AND paidAt < DATE_ADD(?, INTERVAL 1 DAY) AND (? = 0 OR subscriberId = ?) ORDER BY paidAt DESC`
+
+	rows, queryErr := db.Query(query, from, to, subscriberID, subscriberID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get payments: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	payments := []Payment{}
+	counter := 0
+
+	for rows.Next() {
+		payment := Payment{}
+
+		scanErr := rows.Scan(&payment.ID, &payment.Source, &payment.SubscriberID, &payment.PlanID, &payment.Amount, &payment.Method, &payment.PaidAt, &payment.Notes, &payment.CreatedByID)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a payment at row (%d): %v", counter, scanErr)
+		} else {
+			payments = append(payments, payment)
+		}
+
+		counter++
+	}
+
+	return payments, nil
+}
+
+func DeletePaymentByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM Payment WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a payment (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+func CreateExpense(db *sql.DB, expense Expense) (int64, error) {
+	query := `INSERT INTO Expense (category, amount, description, spentAt, createdById) VALUES (?, ?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, expense.Category, expense.Amount, expense.Description, expense.SpentAt, expense.CreatedByID)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create an expense: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created expense ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+const expenseQuery = `SELECT id, category, amount, description, spentAt, createdById, createdAt FROM Expense`
+
+func scanExpense(scanner interface{ Scan(...interface{}) error }, expense *Expense) error {
+	return scanner.Scan(&expense.ID, &expense.Category, &expense.Amount, &expense.Description, &expense.SpentAt, &expense.CreatedByID, &expense.CreatedAt)
+}
+
+// Expenses within the dates, both inclusive. An empty category lists all categories.
+func GetExpenses(db *sql.DB, from, to, category string) ([]Expense, error) {
+	query := expenseQuery + ` WHERE spentAt >= ? AND spentAt <= ? AND (? = '' OR category = ?) ORDER BY spentAt DESC, id DESC`
+
+	rows, queryErr := db.Query(query, from, to, category, category)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get expenses: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	expenses := []Expense{}
+	counter := 0
+
+	for rows.Next() {
+		expense := Expense{}
+
+		scanErr := scanExpense(rows, &expense)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan an expense at row (%d): %v", counter, scanErr)
+		} else {
+			expenses = append(expenses, expense)
+		}
+
+		counter++
+	}
+
+	return expenses, nil
+}
+
+func GetExpenseByID(db *sql.DB, id int64) (*Expense, error) {
+	expense := &Expense{}
+
+	scanErr := scanExpense(db.QueryRow(expenseQuery+` WHERE id = ?`, id), expense)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get an expense by ID (id: %d): %w", id, scanErr)
+	}
+
+	return expense, nil
+}
+
+func UpdateExpense(db *sql.DB, expense Expense) error {
+	query := `UPDATE Expense SET category = ?, amount = ?, description = ?, spentAt = ? WHERE id = ?`
+
+	_, execErr := db.Exec(query, expense.Category, expense.Amount, expense.Description, expense.SpentAt, expense.ID)
+	if execErr != nil {
+		return fmt.Errorf("failed to update an expense (id: %d): %w", expense.ID, execErr)
+	}
+
+	return nil
+}
+
+func DeleteExpenseByID(db *sql.DB, id int64) error {
+	_, execErr := db.Exec(`DELETE FROM Expense WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete an expense (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+// SQL expression formatting a date column as its report bucket:
+// the day, the Monday of its week, or its month (YYYY-MM)
+func bucketExpression(bucket, column string) (string, error) {
+	switch bucket {
+	case "day":
+		return "DATE_FORMAT(" + column + ", '%Y-%m-%d')", nil
+	case "week":
+		return "DATE_FORMAT(DATE_SUB(" + column + ", INTERVAL WEEKDAY(" + column + ") DAY), '%Y-%m-%d')", nil
+	case "month":
+		return "DATE_FORMAT(" + column + ", '%Y-%m')", nil
+	}
+
+	return "", fmt.Errorf("invalid bucket '%s'", bucket)
+}
+
+var incomeGroups = map[string]string{
+	"source": "P.source",
+	"method": "P.method",
+	"plan":   "COALESCE(PL.title, 'none')",
+}
+
+func queryAmountBuckets(db *sql.DB, query string, args ...interface{}) ([]AmountBucket, error) {
+	rows, queryErr := db.Query(query, args...)
+	if queryErr != nil {
+		return nil, queryErr
+	}
+
+	defer rows.Close()
+
+	buckets := []AmountBucket{}
+	counter := 0
+
+	for rows.Next() {
+		bucket := AmountBucket{}
+
+		scanErr := rows.Scan(&bucket.Period, &bucket.Key, &bucket.Amount)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan an amount bucket at row (%d): %v", counter, scanErr)
+		} else {
+			buckets = append(buckets, bucket)
+		}
+
+		counter++
+	}
+
+	return buckets, nil
+}
+
+// Income within the dates (both inclusive) summed per bucket (day, week or month)
+// and group (source, method or plan)
+func GetIncomeBuckets(db *sql.DB, from, to, bucket, group string) ([]AmountBucket, error) {
+	period, bucketErr := bucketExpression(bucket, "P.paidAt")
+	if bucketErr != nil {
+		return nil, bucketErr
+	}
+
+	key, found := incomeGroups[group]
+	if !found {
+		return nil, fmt.Errorf("invalid group '%s'", group)
+	}
+
+	query := `SELECT ` + period + ` AS period, ` + key + ` AS groupKey, SUM(P.amount) FROM Payment AS P
+  LEFT JOIN Plan AS PL ON PL.id = P.planId
+  WHERE P.paidAt >= ? AND P.paidAt < DATE_ADD(?, INTERVAL 1 DAY)
+  GROUP BY period, groupKey ORDER BY period, groupKey`
+
+	buckets, queryErr := queryAmountBuckets(db, query, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get income buckets: %w", queryErr)
+	}
+
+	return buckets, nil
+}
+
+// Expenses within the dates (both inclusive) summed per bucket (day, week or month) and category
+func GetExpenseBuckets(db *sql.DB, from, to, bucket string) ([]AmountBucket, error) {
+	period, bucketErr := bucketExpression(bucket, "spentAt")
+	if bucketErr != nil {
+		return nil, bucketErr
+	}
+
+	query := `SELECT ` + period + ` AS period, category, SUM(amount) FROM Expense
+  WHERE spentAt >= ? AND spentAt <= ?
+  GROUP BY period, category ORDER BY period, category`
+
+	buckets, queryErr := queryAmountBuckets(db, query, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get expense buckets: %w", queryErr)
+	}
+
+	return buckets, nil
+}
+
+// Totals of the payroll runs of the periods (YYYY-MM) within the bounds, both inclusive
+func GetPayrollRunTotals(db *sql.DB, fromPeriod, toPeriod string) (map[string]common.Money, error) {
+	rows, queryErr := db.Query(`SELECT period, total FROM PayrollRun WHERE period >= ? AND period <= ?`, fromPeriod, toPeriod)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get payroll run totals: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	totals := map[string]common.Money{}
+	counter := 0
+
+	for rows.Next() {
+		var (
+			period string
+			total  common.Money
+		)
+
+		scanErr := rows.Scan(&period, &total)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a payroll run total at row (%d): %v", counter, scanErr)
+		} else {
+			totals[period] = total
+		}
+
+		counter++
+	}
+
+	return totals, nil
+}
+
+// Memberships, deleted ones included, that started before 'before' and were not over by 'after'
+func GetMembershipSpans(db *sql.DB, after, before string) ([]MembershipSpan, error) {
+	query := `SELECT createdAt, LEAST(endsAt, COALESCE(deletedAt, endsAt)) AS leftAt FROM Subscriber
+  WHERE createdAt < ? AND LEAST(endsAt, COALESCE(deletedAt, endsAt)) >= ?`
+
+	rows, queryErr := db.Query(query, before, after)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get membership spans: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	spans := []MembershipSpan{}
+	counter := 0
+
+	for rows.Next() {
+		span := MembershipSpan{}
+
+		scanErr := rows.Scan(&span.CreatedAt, &span.LeftAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a membership span at row (%d): %v", counter, scanErr)
+		} else {
+			spans = append(spans, span)
+		}
+
+		counter++
+	}
+
+	return spans, nil
+}
+
+// Computes the KPIs of both windows in a single query
+func GetKPIs(db *sql.DB, current, previous KPIWindow) (KPISnapshot, KPISnapshot, error) {
+	// A member leaves when their membership ends or they are deleted, whichever comes first
+	const leftAt = `LEAST(endsAt, COALESCE(deletedAt, endsAt))`
+
+	snapshot := `COALESCE(SUM(createdAt <= ? AND ` + leftAt + ` > ?), 0),
+  COALESCE(SUM(createdAt >= ? AND createdAt <= ?), 0),
+  COALESCE(SUM(createdAt <= ? AND ` + leftAt + ` > ? AND endsAt <= DATE_ADD(?, INTERVAL 7 DAY)), 0),
+  COALESCE(SUM(IF(createdAt <= ? AND ` + leftAt + ` > ?, bucketPrice * 30 / GREATEST(DATEDIFF(endsAt, startedAt), 1), 0)), 0),
+  (SELECT COALESCE(SUM(amount), 0) FROM Payment WHERE paidAt >= ? AND paidAt <= ?),
+  COALESCE(SUM(createdAt <= ? AND ` + leftAt + ` > ?), 0),
+  COALESCE(SUM(createdAt <= ? AND ` + leftAt + ` > ?), 0)`
+
+	query := `SELECT ` + snapshot + `, ` + snapshot + ` FROM Subscriber`
+
+	args := []interface{}{}
+	for _, window := range []KPIWindow{current, previous} {
+		args = append(args,
+			window.At, window.At,
+			window.Start, window.At,
+			window.At, window.At, window.At,
+			window.At, window.At,
+			window.Start, window.At,
+			window.Start, window.Start,
+			window.Start, window.At,
+		)
+	}
+
+	cur, prev := KPISnapshot{}, KPISnapshot{}
+
+	scanErr := db.QueryRow(query, args...).Scan(
+		&cur.ActiveMembers, &cur.NewMembers, &cur.ExpiringSoon, &cur.MonthlyRecurringRevenue, &cur.Income, &cur.RetentionBase, &cur.Retained,
+		&prev.ActiveMembers, &prev.NewMembers, &prev.ExpiringSoon, &prev.MonthlyRecurringRevenue, &prev.Income, &prev.RetentionBase, &prev.Retained,
+	)
+	if scanErr != nil {
+		return cur, prev, fmt.Errorf("failed to get KPIs: %w", scanErr)
+	}
+
+	return cur, prev, nil
+}
+
+func GetPaymentByID(db *sql.DB, id int64) (*Payment, error) {
+	query := `SELECT id, source, subscriberId, planId, amount, method, paidAt, notes, createdById FROM Payment WHERE id = ?`
+
+	payment := &Payment{}
+
+	scanErr := db.QueryRow(query, id).Scan(&payment.ID, &payment.Source, &payment.SubscriberID, &payment.PlanID, &payment.Amount, &payment.Method, &payment.PaidAt, &payment.Notes, &payment.CreatedByID)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a payment by ID (id: %d): %w", id, scanErr)
+	}
+
+	return payment, nil
+}
+
+var (
+	ErrInvoiceNotIssued = errors.New("invoice is void or already credited")
+	ErrPaymentInvoiced  = errors.New("payment already has an invoice")
+)
+
+const invoiceQuery = `SELECT id, series, issueYear, seqNumber, status, subscriberId, paymentId, creditedInvoiceId, billedTo, billedToDetails, sellerName, sellerDetails,
+  paymentMethod, COALESCE(paidAt, ''), subtotal, taxTotal, total, currency, notes, voidReason, COALESCE(voidedAt, ''), issuedAt, createdById FROM Invoice`
+
+func scanInvoice(scanner interface{ Scan(...interface{}) error }, invoice *Invoice) error {
+	scanErr := scanner.Scan(&invoice.ID, &invoice.Series, &invoice.Year, &invoice.Sequence, &invoice.Status, &invoice.SubscriberID, &invoice.PaymentID, &invoice.CreditedInvoiceID,
+		&invoice.BilledTo, &invoice.BilledToDetails, &invoice.SellerName, &invoice.SellerDetails, &invoice.PaymentMethod, &invoice.PaidAt,
+		&invoice.Subtotal, &invoice.TaxTotal, &invoice.Total, &invoice.Currency, &invoice.Notes, &invoice.VoidReason, &invoice.VoidedAt, &invoice.IssuedAt, &invoice.CreatedByID)
+	if scanErr != nil {
+		return scanErr
+	}
+
+	invoice.Number = fmt.Sprintf("%s-%d-%06d", invoice.Series, invoice.Year, invoice.Sequence)
+
+	return nil
+}
+
+// Issues an invoice with its lines, taking the next number of its series in invoice.Year.
+// The counter row stays locked until the transaction ends, so a failed issue doesn't leave a gap.
+// Issuing a credit note marks the credited invoice as credited.
+func CreateInvoice(db *sql.DB, invoice Invoice) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create an invoice (failed to begin transaction): %w", txErr)
+	}
+
+	if invoice.PaymentID != nil && invoice.Series == "INV" {
+		var count int
+
+		scanErr := tx.QueryRow(`SELECT COUNT(*) FROM Invoice WHERE paymentId = ? AND series = 'INV' AND status <> 'void' FOR UPDATE`, *invoice.PaymentID).Scan(&count)
+		if scanErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create an invoice (failed to check payment): %w", scanErr)
+		}
+
+		if count > 0 {
+			tx.Rollback()
+			return 0, ErrPaymentInvoiced
+		}
+	}
+
+	if invoice.CreditedInvoiceID != nil {
+		res, execErr := tx.Exec(`UPDATE Invoice SET status = 'credited' WHERE id = ? AND series = 'INV' AND status = 'issued'`, *invoice.CreditedInvoiceID)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create an invoice (failed to mark credited invoice): %w", execErr)
+		}
+
+		affected, affectedErr := res.RowsAffected()
+		if affectedErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create an invoice (failed to mark credited invoice): %w", affectedErr)
+		}
+
+		if affected == 0 {
+			tx.Rollback()
+			return 0, ErrInvoiceNotIssued
+		}
+	}
+
+	year := invoice.Year
+
+	_, execErr := tx.Exec(`INSERT INTO InvoiceCounter (series, issueYear, lastNumber) VALUES (?, ?, 1) ON DUPLICATE KEY UPDATE lastNumber = lastNumber + 1`, invoice.Series, year)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create an invoice (failed to increment counter): %w", execErr)
+	}
+
+	var sequence int
+
+	scanErr := tx.QueryRow(`SELECT lastNumber FROM InvoiceCounter WHERE series = ? AND issueYear = ?`, invoice.Series, year).Scan(&sequence)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create an invoice (failed to get number): %w", scanErr)
+	}
+
+	query := `INSERT INTO Invoice (series, issueYear, seqNumber, subscriberId, paymentId, creditedInvoiceId, billedTo, billedToDetails, sellerName, sellerDetails,
+  paymentMethod, paidAt, subtotal, taxTotal, total, currency, notes, issuedAt, createdById) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, invoice.Series, year, sequence, invoice.SubscriberID, invoice.PaymentID, invoice.CreditedInvoiceID,
+		invoice.BilledTo, invoice.BilledToDetails, invoice.SellerName, invoice.SellerDetails, invoice.PaymentMethod, nullIfEmpty(invoice.PaidAt),
+		invoice.Subtotal, invoice.TaxTotal, invoice.Total, invoice.Currency, invoice.Notes, invoice.IssuedAt, invoice.CreatedByID)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create an invoice: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created invoice ID: %w", idErr)
+	}
+
+	lineQuery := `INSERT INTO InvoiceLine (invoiceId, description, quantity, unitPrice, taxRate, amount, taxAmount) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	for _, line := range invoice.Lines {
+		_, execErr := tx.Exec(lineQuery, id, line.Description, line.Quantity, line.UnitPrice, line.TaxRate, line.Amount, line.TaxAmount)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create an invoice (failed to insert line): %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create an invoice (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// With lines
+func GetInvoiceByID(db *sql.DB, id int64) (*Invoice, error) {
+	invoice := &Invoice{}
+
+	scanErr := scanInvoice(db.QueryRow(invoiceQuery+` WHERE id = ?`, id), invoice)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get an invoice by ID (id: %d): %w", id, scanErr)
+	}
+
+	rows, queryErr := db.Query(`SELECT id, invoiceId, description, quantity, unitPrice, taxRate, amount, taxAmount FROM InvoiceLine WHERE invoiceId = ? ORDER BY id`, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get an invoice by ID (failed to get lines): %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	invoice.Lines = []InvoiceLine{}
+	counter := 0
+
+	for rows.Next() {
+		line := InvoiceLine{}
+
+		scanErr := rows.Scan(&line.ID, &line.InvoiceID, &line.Description, &line.Quantity, &line.UnitPrice, &line.TaxRate, &line.Amount, &line.TaxAmount)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan an invoice line at row (%d): %v", counter, scanErr)
+		} else {
+			invoice.Lines = append(invoice.Lines, line)
+		}
+
+		counter++
+	}
+
+	return invoice, nil
+}
+
+// Without lines, latest first. A subscriberID of 0 doesn't filter.
+func GetInvoices(db *sql.DB, from, to string, subscriberID int64) ([]Invoice, error) {
+	query := invoiceQuery + ` WHERE issuedAt >= ? AND issuedAt < DATE_ADD(?, INTERVAL 1 DAY) AND (? = 0 OR subscriberId = ?) ORDER BY issuedAt DESC, id DESC`
+
+	rows, queryErr := db.Query(query, from, to, subscriberID, subscriberID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get invoices: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	invoices := []Invoice{}
+	counter := 0
+
+	for rows.Next() {
+		invoice := Invoice{}
+
+		scanErr := scanInvoice(rows, &invoice)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan an invoice at row (%d): %v", counter, scanErr)
+		} else {
+			invoices = append(invoices, invoice)
+		}
+
+		counter++
+	}
+
+	return invoices, nil
+}
+
+// Only issued invoices can be voided; their number stays taken
+func VoidInvoice(db *sql.DB, id int64, reason string) error {
+	res, execErr := db.Exec(`UPDATE Invoice SET status = 'void', voidReason = ?, voidedAt = CURRENT_TIMESTAMP WHERE id = ? AND series = 'INV' AND status = 'issued'`, reason, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to void an invoice (id: %d): %w", id, execErr)
+	}
+
+	affected, affectedErr := res.RowsAffected()
+	if affectedErr != nil {
+		return fmt.Errorf("failed to void an invoice (id: %d): %w", id, affectedErr)
+	}
+
+	if affected == 0 {
+		return ErrInvoiceNotIssued
+	}
+
+	return nil
+}
+
+var (
+	ErrPromoCodeExhausted   = errors.New("promo code has reached its usage limit")
+	ErrPromoCodeMemberLimit = errors.New("member has reached the usage limit of the promo code")
+	ErrPromoCodeRedeemed    = errors.New("promo code has been redeemed and can only be deactivated")
+	ErrPromoCodeTarget      = errors.New("plan or product category not found")
+)
+
+const promoCodeQuery = `SELECT P.id, P.code, P.description, P.discountType, P.discountPercent, P.discountAmount, P.appliesTo, COALESCE(P.validFrom, ''), COALESCE(P.validUntil, ''),
+  P.maxUses, P.maxUsesPerMember, P.active, P.createdAt, (SELECT COUNT(*) FROM PromoRedemption AS R WHERE R.promoCodeId = P.id) FROM PromoCode AS P`
+
+func scanPromoCode(scanner interface{ Scan(...interface{}) error }, promo *PromoCode) error {
+	return scanner.Scan(&promo.ID, &promo.Code, &promo.Description, &promo.DiscountType, &promo.DiscountPercent, &promo.DiscountAmount, &promo.AppliesTo, &promo.ValidFrom, &promo.ValidUntil,
+		&promo.MaxUses, &promo.MaxUsesPerMember, &promo.Active, &promo.CreatedAt, &promo.Uses)
+}
+
+func getPromoCodeTargets(db *sql.DB, promo *PromoCode) error {
+	promo.PlanIDs = []int64{}
+	promo.CategoryIDs = []int64{}
+
+	for _, target := range []struct {
+		query string
+		ids   *[]int64
+	}{
+		{`SELECT planId FROM PromoCodePlan WHERE promoCodeId = ? ORDER BY planId`, &promo.PlanIDs},
+		{`SELECT categoryId FROM PromoCodeCategory WHERE promoCodeId = ? ORDER BY categoryId`, &promo.CategoryIDs},
+	} {
+		rows, queryErr := db.Query(target.query, promo.ID)
+		if queryErr != nil {
+			return queryErr
+		}
+
+		for rows.Next() {
+			var id int64
+
+			if scanErr := rows.Scan(&id); scanErr != nil {
+				rows.Close()
+				return scanErr
+			}
+
+			*target.ids = append(*target.ids, id)
+		}
+
+		rows.Close()
+	}
+
+	return nil
+}
+
+// Replaces the plans and categories of a promo code; ErrPromoCodeTarget if one doesn't exist
+func insertPromoCodeTargets(tx *sql.Tx, promo *PromoCode) error {
+	for _, query := range []string{`DELETE FROM PromoCodePlan WHERE promoCodeId = ?`, `DELETE FROM PromoCodeCategory WHERE promoCodeId = ?`} {
+		if _, execErr := tx.Exec(query, promo.ID); execErr != nil {
+			return execErr
+		}
+	}
+
+	for _, target := range []struct {
+		query string
+		ids   []int64
+	}{
+		{`INSERT INTO PromoCodePlan (promoCodeId, planId) SELECT ?, id FROM Plan WHERE id = ? AND deletedAt IS NULL`, promo.PlanIDs},
+		{`INSERT INTO PromoCodeCategory (promoCodeId, categoryId) SELECT ?, id FROM ProductCategory WHERE id = ?`, promo.CategoryIDs},
+	} {
+		inserted := map[int64]bool{}
+
+		for _, id := range target.ids {
+			if inserted[id] {
+				continue
+			}
+
+			res, execErr := tx.Exec(target.query, promo.ID, id)
+			if execErr != nil {
+				return execErr
+			}
+
+			if affected, _ := res.RowsAffected(); affected == 0 {
+				return ErrPromoCodeTarget
+			}
+
+			inserted[id] = true
+		}
+	}
+
+	return nil
+}
+
+func CreatePromoCode(db *sql.DB, promo PromoCode) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a promo code (failed to begin transaction): %w", txErr)
+	}
+
+	query := `INSERT INTO PromoCode (code, description, discountType, discountPercent, discountAmount, appliesTo, validFrom, validUntil, maxUses, maxUsesPerMember, active)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, promo.Code, promo.Description, promo.DiscountType, promo.DiscountPercent, promo.DiscountAmount, promo.AppliesTo,
+		nullIfEmpty(promo.ValidFrom), nullIfEmpty(promo.ValidUntil), promo.MaxUses, promo.MaxUsesPerMember, promo.Active)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a promo code: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created promo code ID: %w", idErr)
+	}
+
+	promo.ID = id
+
+	if targetErr := insertPromoCodeTargets(tx, &promo); targetErr != nil {
+		tx.Rollback()
+
+		if errors.Is(targetErr, ErrPromoCodeTarget) {
+			return 0, targetErr
+		}
+
+		return 0, fmt.Errorf("failed to create a promo code (failed to insert plans and categories): %w", targetErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a promo code (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+func UpdatePromoCode(db *sql.DB, promo PromoCode) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to update a promo code (failed to begin transaction): %w", txErr)
+	}
+
+	query := `UPDATE PromoCode SET code = ?, description = ?, discountType = ?, discountPercent = ?, discountAmount = ?, appliesTo = ?, validFrom = ?, validUntil = ?,
+  maxUses = ?, maxUsesPerMember = ?, active = ? WHERE id = ?`
+
+	_, execErr := tx.Exec(query, promo.Code, promo.Description, promo.DiscountType, promo.DiscountPercent, promo.DiscountAmount, promo.AppliesTo,
+		nullIfEmpty(promo.ValidFrom), nullIfEmpty(promo.ValidUntil), promo.MaxUses, promo.MaxUsesPerMember, promo.Active, promo.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a promo code (id: %d): %w", promo.ID, execErr)
+	}
+
+	if targetErr := insertPromoCodeTargets(tx, &promo); targetErr != nil {
+		tx.Rollback()
+
+		if errors.Is(targetErr, ErrPromoCodeTarget) {
+			return targetErr
+		}
+
+		return fmt.Errorf("failed to update a promo code (failed to replace plans and categories): %w", targetErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a promo code (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+func GetPromoCodes(db *sql.DB) ([]PromoCode, error) {
+	rows, queryErr := db.Query(promoCodeQuery + ` ORDER BY P.createdAt DESC`)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get promo codes: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	promos := []PromoCode{}
+	counter := 0
+
+	for rows.Next() {
+		promo := PromoCode{}
+
+		scanErr := scanPromoCode(rows, &promo)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a promo code at row (%d): %v", counter, scanErr)
+		} else {
+			promos = append(promos, promo)
+		}
+
+		counter++
+	}
+
+	rows.Close()
+
+	for i := range promos {
+		if targetErr := getPromoCodeTargets(db, &promos[i]); targetErr != nil {
+			return nil, fmt.Errorf("failed to get promo codes (failed to get plans and categories): %w", targetErr)
+		}
+	}
+
+	return promos, nil
+}
+
+func getPromoCode(db *sql.DB, condition string, arg interface{}) (*PromoCode, error) {
+	promo := &PromoCode{}
+
+	scanErr := scanPromoCode(db.QueryRow(promoCodeQuery+` WHERE `+condition, arg), promo)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, scanErr
+	}
+
+	if targetErr := getPromoCodeTargets(db, promo); targetErr != nil {
+		return nil, targetErr
+	}
+
+	return promo, nil
+}
+
+func GetPromoCodeByID(db *sql.DB, id int64) (*PromoCode, error) {
+	promo, queryErr := getPromoCode(db, `P.id = ?`, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a promo code by ID (id: %d): %w", id, queryErr)
+	}
+
+	return promo, nil
+}
+
+// code must be uppercased
+func GetPromoCodeByCode(db *sql.DB, code string) (*PromoCode, error) {
+	promo, queryErr := getPromoCode(db, `P.code = ?`, code)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a promo code by code (code: %s): %w", code, queryErr)
+	}
+
+	return promo, nil
+}
+
+// Codes that were redeemed are kept for their history; ErrPromoCodeRedeemed
+func DeletePromoCodeByID(db *sql.DB, id int64) error {
+	var uses int
+
+	scanErr := db.QueryRow(`SELECT COUNT(*) FROM PromoRedemption WHERE promoCodeId = ?`, id).Scan(&uses)
+	if scanErr != nil {
+		return fmt.Errorf("failed to delete a promo code (failed to count redemptions): %w", scanErr)
+	}
+
+	if uses > 0 {
+		return ErrPromoCodeRedeemed
+	}
+
+	_, execErr := db.Exec(`DELETE FROM PromoCode WHERE id = ?`, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to delete a promo code (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+// Latest first
+func GetPromoRedemptions(db *sql.DB, promoCodeID int64) ([]PromoRedemption, error) {
+	query := `SELECT id, promoCodeId, subscriberId, userId, target, originalAmount, discountAmount, redeemedAt FROM PromoRedemption
+  WHERE promoCodeId = ? ORDER BY redeemedAt DESC, id DESC`
+
+	rows, queryErr := db.Query(query, promoCodeID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get promo redemptions: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	redemptions := []PromoRedemption{}
+	counter := 0
+
+	for rows.Next() {
+		r := PromoRedemption{}
+
+		scanErr := rows.Scan(&r.ID, &r.PromoCodeID, &r.SubscriberID, &r.UserID, &r.Target, &r.OriginalAmount, &r.DiscountAmount, &r.RedeemedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a promo redemption at row (%d): %v", counter, scanErr)
+		} else {
+			redemptions = append(redemptions, r)
+		}
+
+		counter++
+	}
+
+	return redemptions, nil
+}
+
+// Records a redemption within tx once the usage limits allow it, locking the code so concurrent
+// redemptions can't exceed them. Subscribers count as the same member when they share a phone number
+// or an email address, so a code can't be reused by registering again.
+func redeemPromoCode(tx *sql.Tx, redemption *PromoRedemption, phone, email string) error {
+	var maxUses, maxUsesPerMember int
+
+	scanErr := tx.QueryRow(`SELECT maxUses, maxUsesPerMember FROM PromoCode WHERE id = ? FOR UPDATE`, redemption.PromoCodeID).Scan(&maxUses, &maxUsesPerMember)
+	if scanErr != nil {
+		return fmt.Errorf("failed to lock promo code: %w", scanErr)
+	}
+
+	if maxUses > 0 {
+		var uses int
+
+		scanErr := tx.QueryRow(`SELECT COUNT(*) FROM PromoRedemption WHERE promoCodeId = ?`, redemption.PromoCodeID).Scan(&uses)
+		if scanErr != nil {
+			return fmt.Errorf("failed to count promo code redemptions: %w", scanErr)
+		}
+
+		if uses >= maxUses {
+			return ErrPromoCodeExhausted
+		}
+	}
+
+	if maxUsesPerMember > 0 {
+		var uses int
+
+		query := `SELECT COUNT(*) FROM PromoRedemption AS R LEFT JOIN Subscriber AS S ON S.id = R.subscriberId
+  WHERE R.promoCodeId = ? AND ((R.userId IS NOT NULL AND R.userId = ?) OR (R.subscriberId IS NOT NULL AND R.subscriberId = ?)
+  OR (? <> '' AND S.phone = ?) OR (? <> '' AND S.email = ?))`
+
+		scanErr := tx.QueryRow(query, redemption.PromoCodeID, redemption.UserID, redemption.SubscriberID, phone, phone, email, email).Scan(&uses)
+		if scanErr != nil {
+			return fmt.Errorf("failed to count member's promo code redemptions: %w", scanErr)
+		}
+
+		if uses >= maxUsesPerMember {
+			return ErrPromoCodeMemberLimit
+		}
+	}
+
+	query := `INSERT INTO PromoRedemption (promoCodeId, subscriberId, userId, target, originalAmount, discountAmount) VALUES (?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, redemption.PromoCodeID, redemption.SubscriberID, redemption.UserID, redemption.Target, redemption.OriginalAmount, redemption.DiscountAmount)
+	if execErr != nil {
+		return fmt.Errorf("failed to record promo code redemption: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return fmt.Errorf("failed to retrieve recorded promo code redemption ID: %w", idErr)
+	}
+
+	redemption.ID = id
+
+	return nil
+}
+
+var (
+	ErrBasketChanged = errors.New("basket changed during checkout")
+	ErrOrderStatus   = errors.New("order can't move to that status")
+)
+
+// The statuses an order can move to from each status
+var orderTransitions = map[string][]string{
+	OrderPending: {OrderPaid, OrderCancelled},
+	OrderPaid:    {OrderReady, OrderCancelled},
+	OrderReady:   {OrderCompleted, OrderCancelled},
+}
+
+const orderQuery = `SELECT O.id, O.customerId, COALESCE(U.name, ''), O.status, O.subtotal, O.discount, O.total, O.promoCodeId, O.promoRedemptionId, O.paymentId,
+  O.notes, O.cancelReason, O.createdAt, O.updatedAt, COALESCE(O.paidAt, ''), COALESCE(O.readyAt, ''), COALESCE(O.completedAt, ''), COALESCE(O.cancelledAt, '')
+  FROM ProductOrder AS O LEFT JOIN User AS U ON U.id = O.customerId`
+
+func scanOrder(scanner interface{ Scan(...interface{}) error }, order *Order) error {
+	return scanner.Scan(&order.ID, &order.CustomerID, &order.CustomerName, &order.Status, &order.Subtotal, &order.Discount, &order.Total,
+		&order.PromoCodeID, &order.PromoRedemptionID, &order.PaymentID, &order.Notes, &order.CancelReason, &order.CreatedAt, &order.UpdatedAt,
+		&order.PaidAt, &order.ReadyAt, &order.CompletedAt, &order.CancelledAt)
+}
+
+// Places a pending order and empties the checked out basket rows. Fails with ErrBasketChanged
+// if any of them was removed or changed since it was priced, so a basket can't be checked out twice,
+// and with ErrInsufficientStock if a product sold out in the meantime.
+// A non-nil redemption is recorded for the customer along with the order.
+func CreateOrder(db *sql.DB, order Order, basket []ProductBasket, redemption *PromoRedemption) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create an order (failed to begin transaction): %w", txErr)
+	}
+
+	for _, item := range basket {
+		query := `DELETE FROM ProductBasket WHERE id = ? AND customerId = ? AND productId = ? AND variantId <=> ? AND quantity = ?`
+
+		res, execErr := tx.Exec(query, item.ID, order.CustomerID, item.ProductID, item.VariantID, item.Quantity)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create an order (failed to empty basket): %w", execErr)
+		}
+
+		affected, affectedErr := res.RowsAffected()
+		if affectedErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create an order (failed to empty basket): %w", affectedErr)
+		}
+
+		if affected == 0 {
+			tx.Rollback()
+			return 0, ErrBasketChanged
+		}
+	}
+
+	if redemption != nil {
+		redemption.UserID = order.CustomerID
+
+		if redeemErr := redeemPromoCode(tx, redemption, "", ""); redeemErr != nil {
+			tx.Rollback()
+
+			if errors.Is(redeemErr, ErrPromoCodeExhausted) || errors.Is(redeemErr, ErrPromoCodeMemberLimit) {
+				return 0, redeemErr
+			}
+
+			return 0, fmt.Errorf("failed to create an order: %w", redeemErr)
+		}
+
+		order.PromoCodeID = &redemption.PromoCodeID
+		order.PromoRedemptionID = &redemption.ID
+	}
+
+	query := `INSERT INTO ProductOrder (customerId, subtotal, discount, total, promoCodeId, promoRedemptionId, notes) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, order.CustomerID, order.Subtotal, order.Discount, order.Total, order.PromoCodeID, order.PromoRedemptionID, order.Notes)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create an order: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created order ID: %w", idErr)
+	}
+
+	lineQuery := `INSERT INTO ProductOrderLine (orderId, productId, variantId, productName, quantity, unitPrice, amount, discounted) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	for _, line := range order.Lines {
+		_, execErr := tx.Exec(lineQuery, id, line.ProductID, line.VariantID, line.ProductName, line.Quantity, line.UnitPrice, line.Amount, line.Discounted)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create an order (failed to insert line): %w", execErr)
+		}
+
+		if line.ProductID == nil {
+			continue
+		}
+
+		movement := StockMovement{ProductID: *line.ProductID, VariantID: line.VariantID, Kind: StockSale, Quantity: -line.Quantity, Reason: fmt.Sprintf("Order #%d", id), OrderID: &id, CreatedByID: order.CustomerID}
+
+		if moveErr := moveStock(tx, &movement); moveErr != nil {
+			tx.Rollback()
+
+			if errors.Is(moveErr, ErrInsufficientStock) || errors.Is(moveErr, ErrProductNotFound) || errors.Is(moveErr, ErrVariantNotFound) || errors.Is(moveErr, ErrVariantRequired) {
+				return 0, moveErr
+			}
+
+			return 0, fmt.Errorf("failed to create an order: %w", moveErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create an order (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// With lines
+func GetOrderByID(db *sql.DB, id int64) (*Order, error) {
+	order := &Order{}
+
+	scanErr := scanOrder(db.QueryRow(orderQuery+` WHERE O.id = ?`, id), order)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get an order by ID (id: %d): %w", id, scanErr)
+	}
+
+	rows, queryErr := db.Query(`SELECT id, orderId, productId, variantId, productName, quantity, unitPrice, amount, discounted FROM ProductOrderLine WHERE orderId = ? ORDER BY id`, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get an order by ID (failed to get lines): %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	order.Lines = []OrderLine{}
+	counter := 0
+
+	for rows.Next() {
+		line := OrderLine{}
+
+		scanErr := rows.Scan(&line.ID, &line.OrderID, &line.ProductID, &line.VariantID, &line.ProductName, &line.Quantity, &line.UnitPrice, &line.Amount, &line.Discounted)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan an order line at row (%d): %v", counter, scanErr)
+		} else {
+			order.Lines = append(order.Lines, line)
+		}
+
+		counter++
+	}
+
+	return order, nil
+}
+
+func getOrders(db *sql.DB, query string, args ...interface{}) ([]Order, error) {
+	rows, queryErr := db.Query(query, args...)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get orders: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	orders := []Order{}
+	counter := 0
+
+	for rows.Next() {
+		order := Order{}
+
+		scanErr := scanOrder(rows, &order)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan an order at row (%d): %v", counter, scanErr)
+		} else {
+			orders = append(orders, order)
+		}
+
+		counter++
+	}
+
+	return orders, nil
+}
+
+// Without lines, latest first
+func GetOrdersOfCustomer(db *sql.DB, customerID int64) ([]Order, error) {
+	return getOrders(db, orderQuery+` WHERE O.customerId = ? ORDER BY O.createdAt DESC, O.id DESC`, customerID)
+}
+
+// Orders in the given statuses without lines, oldest first so they're handled in turn
+func GetOrderQueue(db *sql.DB, statuses ...string) ([]Order, error) {
+	if len(statuses) == 0 {
+		return []Order{}, nil
+	}
+
+	args := make([]interface{}, len(statuses))
+	for i, status := range statuses {
+		args[i] = status
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(statuses)), ", ")
+
+	return getOrders(db, orderQuery+` WHERE O.status IN (`+placeholders+`) ORDER BY O.createdAt, O.id`, args...)
+}
+
+// Moves an order along its workflow, failing with ErrOrderStatus if it can't move to status from its current one.
+// Paying records payment in the income ledger for the order's total. Cancelling a paid order records a refund
+// with payment instead. Cancelling returns the products to stock and frees the order's promo code redemption.
+func SetOrderStatus(db *sql.DB, id int64, status string, payment *Payment, reason string) error {
+	return setOrderStatusFrom(db, id, "", status, payment, reason)
+}
+
+// Cancels an order only while it's pending, failing with ErrOrderStatus otherwise
+func CancelPendingOrder(db *sql.DB, id int64, reason string) error {
+	return setOrderStatusFrom(db, id, OrderPending, OrderCancelled, nil, reason)
+}
+
+func setOrderStatusFrom(db *sql.DB, id int64, from string, status string, payment *Payment, reason string) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to set order status (failed to begin transaction): %w", txErr)
+	}
+
+	if _, statusErr := setOrderStatus(tx, id, from, status, payment, reason); statusErr != nil {
+		tx.Rollback()
+
+		if errors.Is(statusErr, ErrOrderStatus) {
+			return statusErr
+		}
+
+		return fmt.Errorf("failed to set order status: %w", statusErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to set order status (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// SetOrderStatus within tx, from the status from unless it's empty. payment may be nil.
+// A non-zero payment.Amount is recorded instead of the order's total when paying.
+// Returns the ID of the income ledger entry recorded when paying.
+func setOrderStatus(tx *sql.Tx, id int64, from string, status string, payment *Payment, reason string) (int64, error) {
+	var current string
+	var total common.Money
+	var redemptionID, paymentID *int64
+
+	scanErr := tx.QueryRow(`SELECT status, total, promoRedemptionId, paymentId FROM ProductOrder WHERE id = ? FOR UPDATE`, id).Scan(&current, &total, &redemptionID, &paymentID)
+	if scanErr != nil {
+		return 0, fmt.Errorf("failed to lock order: %w", scanErr)
+	}
+
+	allowed := false
+	for _, next := range orderTransitions[current] {
+		allowed = allowed || next == status
+	}
+
+	if !allowed || (from != "" && from != current) {
+		return 0, ErrOrderStatus
+	}
+
+	if payment == nil {
+		payment = &Payment{}
+	}
+
+	recordPayment := func(amount common.Money, method string, notes string) (int64, error) {
+		if method == "" {
+			method = "other"
+		}
+
+		entry := Payment{Source: PaymentProduct, Amount: amount, Method: method, Notes: notes, CreatedByID: payment.CreatedByID}
+
+		res, execErr := tx.Exec(createPaymentQuery, paymentArgs(&entry)...)
+		if execErr != nil {
+			return 0, execErr
+		}
+
+		return res.LastInsertId()
+	}
+
+	var newPaymentID int64
+	var execErr error
+
+	switch status {
+	case OrderPaid:
+		amount := total
+		if payment.Amount != 0 {
+			amount = payment.Amount
+		}
+
+		var payErr error
+
+		newPaymentID, payErr = recordPayment(amount, payment.Method, fmt.Sprintf("Order #%d", id))
+		if payErr != nil {
+			return 0, fmt.Errorf("failed to record payment: %w", payErr)
+		}
+
+		_, execErr = tx.Exec(`UPDATE ProductOrder SET status = ?, paymentId = ?, paidAt = CURRENT_TIMESTAMP WHERE id = ?`, status, newPaymentID, id)
+	case OrderReady:
+		_, execErr = tx.Exec(`UPDATE ProductOrder SET status = ?, readyAt = CURRENT_TIMESTAMP WHERE id = ?`, status, id)
+	case OrderCompleted:
+		_, execErr = tx.Exec(`UPDATE ProductOrder SET status = ?, completedAt = CURRENT_TIMESTAMP WHERE id = ?`, status, id)
+	case OrderCancelled:
+		if paymentID != nil {
+			// Refunds what was actually taken, which can differ from the total
+			var paid common.Money
+			var method string
+
+			scanErr := tx.QueryRow(`SELECT amount, method FROM Payment WHERE id = ?`, *paymentID).Scan(&paid, &method)
+			if scanErr != nil && !errors.Is(scanErr, sql.ErrNoRows) {
+				return 0, fmt.Errorf("failed to get the order's payment: %w", scanErr)
+			}
+
+			if payment.Method != "" {
+				method = payment.Method
+			}
+
+			if paid != 0 {
+				if _, refundErr := recordPayment(-paid, method, fmt.Sprintf("Refund of order #%d", id)); refundErr != nil {
+					return 0, fmt.Errorf("failed to record refund: %w", refundErr)
+				}
+			}
+		}
+
+		returns, returnsErr := orderStockReturns(tx, id)
+		if returnsErr != nil {
+			return 0, returnsErr
+		}
+
+		for _, movement := range returns {
+			movement.CreatedByID = payment.CreatedByID
+
+			if moveErr := moveStock(tx, &movement); moveErr != nil {
+				return 0, fmt.Errorf("failed to return stock: %w", moveErr)
+			}
+		}
+
+		if redemptionID != nil {
+			if _, deleteErr := tx.Exec(`DELETE FROM PromoRedemption WHERE id = ?`, *redemptionID); deleteErr != nil {
+				return 0, fmt.Errorf("failed to free promo code redemption: %w", deleteErr)
+			}
+		}
+
+		_, execErr = tx.Exec(`UPDATE ProductOrder SET status = ?, cancelReason = ?, cancelledAt = CURRENT_TIMESTAMP WHERE id = ?`, status, reason, id)
+	}
+
+	if execErr != nil {
+		return 0, execErr
+	}
+
+	return newPaymentID, nil
+}
+
+var (
+	ErrProductNotFound   = errors.New("product not found")
+	ErrInsufficientStock = errors.New("not enough stock")
+)
+
+// Applies movement.Quantity to the product's stock within tx and records the movement,
+// failing with ErrInsufficientStock if stock would go negative. A variant's movement sets the product's stock
+// to the total of its variants'. Movements of a product with variants must be of a variant,
+// except returns of orders placed before it had any.
+// The product's low stock alert is rearmed once its stock rises above the threshold.
+func moveStock(tx *sql.Tx, movement *StockMovement) error {
+	var stock int
+	var hasVariants bool
+
+	query := `SELECT stock, EXISTS (SELECT 1 FROM ProductVariant WHERE productId = P.id AND active) FROM Product AS P WHERE id = ? FOR UPDATE`
+
+	scanErr := tx.QueryRow(query, movement.ProductID).Scan(&stock, &hasVariants)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return ErrProductNotFound
+		}
+
+		return fmt.Errorf("failed to lock product stock: %w", scanErr)
+	}
+
+	movement.StockAfter = stock + movement.Quantity
+
+	var execErr error
+
+	if movement.VariantID != nil {
+		var variantStock int
+
+		scanErr := tx.QueryRow(`SELECT stock FROM ProductVariant WHERE id = ? AND productId = ? FOR UPDATE`, *movement.VariantID, movement.ProductID).Scan(&variantStock)
+		if scanErr != nil {
+			if scanErr == sql.ErrNoRows {
+				return ErrVariantNotFound
+			}
+
+			return fmt.Errorf("failed to lock variant stock: %w", scanErr)
+		}
+
+		if variantStock+movement.Quantity < 0 {
+			return fmt.Errorf("%w (product: %d, variant: %d, in stock: %d)", ErrInsufficientStock, movement.ProductID, *movement.VariantID, variantStock)
+		}
+
+		_, execErr = tx.Exec(`UPDATE ProductVariant SET stock = ? WHERE id = ?`, variantStock+movement.Quantity, *movement.VariantID)
+		if execErr != nil {
+			return fmt.Errorf("failed to update variant stock: %w", execErr)
+		}
+
+		movement.StockAfter = variantStock + movement.Quantity
+
+		if syncErr := syncProductStock(tx, movement.ProductID); syncErr != nil {
+			return syncErr
+		}
+	} else {
+		if hasVariants && movement.Kind != StockReturn {
+			return ErrVariantRequired
+		}
+
+		if movement.StockAfter < 0 {
+			return fmt.Errorf("%w (product: %d, in stock: %d)", ErrInsufficientStock, movement.ProductID, stock)
+		}
+
+		_, execErr = tx.Exec(`UPDATE Product SET stock = ?, lowStockAlertedAt = IF(stock > lowStockThreshold, NULL, lowStockAlertedAt) WHERE id = ?`, movement.StockAfter, movement.ProductID)
+		if execErr != nil {
+			return fmt.Errorf("failed to update product stock: %w", execErr)
+		}
+	}
+
+	query = `INSERT INTO StockMovement (productId, variantId, kind, quantity, stockAfter, reason, orderId, createdById) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, movement.ProductID, movement.VariantID, movement.Kind, movement.Quantity, movement.StockAfter, movement.Reason, movement.OrderID, movement.CreatedByID)
+	if execErr != nil {
+		return fmt.Errorf("failed to record stock movement: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return fmt.Errorf("failed to retrieve recorded stock movement ID: %w", idErr)
+	}
+
+	movement.ID = id
+
+	return nil
+}
+
+// The stock of a product sold by variant is the sum of its active variants
+func syncProductStock(tx *sql.Tx, productID int64) error {
+	_, execErr := tx.Exec(`UPDATE Product SET stock = (SELECT COALESCE(SUM(stock), 0) FROM ProductVariant WHERE productId = ? AND active),
+  lowStockAlertedAt = IF(stock > lowStockThreshold, NULL, lowStockAlertedAt) WHERE id = ?`, productID, productID)
+	if execErr != nil {
+		return fmt.Errorf("failed to update product stock: %w", execErr)
+	}
+
+	return nil
+}
+
+// Return movements putting the products of an order back in stock
+func orderStockReturns(tx *sql.Tx, orderID int64) ([]StockMovement, error) {
+	rows, queryErr := tx.Query(`SELECT productId, variantId, quantity FROM ProductOrderLine WHERE orderId = ? AND productId IS NOT NULL`, orderID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get order lines: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	returns := []StockMovement{}
+
+	for rows.Next() {
+		movement := StockMovement{Kind: StockReturn, Reason: fmt.Sprintf("Order #%d cancelled", orderID), OrderID: &orderID}
+
+		scanErr := rows.Scan(&movement.ProductID, &movement.VariantID, &movement.Quantity)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan an order line: %w", scanErr)
+		}
+
+		returns = append(returns, movement)
+	}
+
+	return returns, nil
+}
+
+// Records a movement and returns it with its ID and the resulting stock.
+// Fails with ErrProductNotFound, ErrVariantNotFound, ErrVariantRequired or ErrInsufficientStock.
+func CreateStockMovement(db *sql.DB, movement StockMovement) (*StockMovement, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return nil, fmt.Errorf("failed to create a stock movement (failed to begin transaction): %w", txErr)
+	}
+
+	if moveErr := moveStock(tx, &movement); moveErr != nil {
+		tx.Rollback()
+
+		if errors.Is(moveErr, ErrProductNotFound) || errors.Is(moveErr, ErrVariantNotFound) || errors.Is(moveErr, ErrVariantRequired) || errors.Is(moveErr, ErrInsufficientStock) {
+			return nil, moveErr
+		}
+
+		return nil, fmt.Errorf("failed to create a stock movement: %w", moveErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to create a stock movement (failed to commit transaction): %w", commitErr)
+	}
+
+	return &movement, nil
+}
+
+// Latest first
+func GetStockMovements(db *sql.DB, productID int64) ([]StockMovement, error) {
+	query := `SELECT id, productId, variantId, kind, quantity, stockAfter, reason, orderId, createdById, createdAt FROM StockMovement WHERE productId = ? ORDER BY createdAt DESC, id DESC`
+
+	rows, queryErr := db.Query(query, productID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get stock movements: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	movements := []StockMovement{}
+	counter := 0
+
+	for rows.Next() {
+		movement := StockMovement{}
+
+		scanErr := rows.Scan(&movement.ID, &movement.ProductID, &movement.VariantID, &movement.Kind, &movement.Quantity, &movement.StockAfter, &movement.Reason, &movement.OrderID, &movement.CreatedByID, &movement.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a stock movement at row (%d): %v", counter, scanErr)
+		} else {
+			movements = append(movements, movement)
+		}
+
+		counter++
+	}
+
+	return movements, nil
+}
+
+// Resets the product's alert so its stock is checked against the new threshold
+func SetProductLowStockThreshold(db *sql.DB, productID int64, threshold int) error {
+	_, execErr := db.Exec(`UPDATE Product SET lowStockThreshold = ?, lowStockAlertedAt = NULL WHERE id = ?`, threshold, productID)
+	if execErr != nil {
+		return fmt.Errorf("failed to set low stock threshold (product: %d): %w", productID, execErr)
+	}
+
+	return nil
+}
+
+func queryLowStockProducts(db *sql.DB, query string) ([]Product, error) {
+	rows, queryErr := db.Query(query)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get low stock products: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	products := []Product{}
+	counter := 0
+
+	for rows.Next() {
+		product := Product{}
+
+		scanErr := rows.Scan(&product.ID, &product.Name, &product.Description, &product.Price, &product.Marka, &product.CategoryID, &product.Stock, &product.LowStockThreshold)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a low stock product at row (%d): %v", counter, scanErr)
+		} else {
+			products = append(products, product)
+		}
+
+		counter++
+	}
+
+	return products, nil
+}
+
+const lowStockQuery = `SELECT id, name, description, price, marka, categoryId, stock, lowStockThreshold FROM Product
+  WHERE deletedAt IS NULL AND lowStockThreshold > 0 AND stock <= lowStockThreshold`
+
+// Products at or below their threshold, emptiest first
+func GetLowStockProducts(db *sql.DB) ([]Product, error) {
+	return queryLowStockProducts(db, lowStockQuery+` ORDER BY stock, name`)
+}
+
+// Low stock products staff haven't been alerted of since they fell to their threshold
+func GetProductsDueForLowStockAlert(db *sql.DB) ([]Product, error) {
+	return queryLowStockProducts(db, lowStockQuery+` AND lowStockAlertedAt IS NULL ORDER BY stock, name`)
+}
+
+func MarkLowStockAlertSent(db *sql.DB, productID int64) error {
+	_, execErr := db.Exec(`UPDATE Product SET lowStockAlertedAt = CURRENT_TIMESTAMP WHERE id = ?`, productID)
+	if execErr != nil {
+		return fmt.Errorf("failed to mark low stock alert as sent (product: %d): %w", productID, execErr)
+	}
+
+	return nil
+}
+
+var ErrProductImageOrder = errors.New("order must list every image of the product once")
+
+const productImageQuery = `SELECT id, productId, position, isPrimary, createdAt FROM ProductImage`
+
+func queryProductImages(db *sql.DB, query string, args ...interface{}) ([]ProductImage, error) {
+	rows, queryErr := db.Query(query, args...)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get product images: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	images := []ProductImage{}
+	counter := 0
+
+	for rows.Next() {
+		image := ProductImage{}
+
+		scanErr := rows.Scan(&image.ID, &image.ProductID, &image.Position, &image.Primary, &image.CreatedAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a product image at row (%d): %v", counter, scanErr)
+		} else {
+			images = append(images, image)
+		}
+
+		counter++
+	}
+
+	return images, nil
+}
+
+// In display order
+func GetProductImages(db *sql.DB, productID int64) ([]ProductImage, error) {
+	return queryProductImages(db, productImageQuery+` WHERE productId = ? ORDER BY position, id`, productID)
+}
+
+// Fills in Images of each product
+func AttachProductImages(db *sql.DB, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(products))
+	for i, product := range products {
+		args[i] = product.ID
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(products)), ", ")
+
+	images, queryErr := queryProductImages(db, productImageQuery+` WHERE productId IN (`+placeholders+`) ORDER BY productId, position, id`, args...)
+	if queryErr != nil {
+		return queryErr
+	}
+
+	byProduct := map[int64][]ProductImage{}
+	for _, image := range images {
+		byProduct[image.ProductID] = append(byProduct[image.ProductID], image)
+	}
+
+	for i := range products {
+		products[i].Images = byProduct[products[i].ID]
+		if products[i].Images == nil {
+			products[i].Images = []ProductImage{}
+		}
+	}
+
+	return nil
+}
+
+// Adds an image after the product's other images. The first image of a product becomes its primary one.
+func CreateProductImage(db *sql.DB, productID int64) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a product image (failed to begin transaction): %w", txErr)
+	}
+
+	var exists int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM Product WHERE id = ? AND deletedAt IS NULL FOR UPDATE`, productID).Scan(&exists)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return 0, ErrProductNotFound
+		}
+
+		return 0, fmt.Errorf("failed to create a product image (failed to lock product): %w", scanErr)
+	}
+
+	var position, count int
+
+	scanErr = tx.QueryRow(`SELECT COALESCE(MAX(position) + 1, 0), COUNT(*) FROM ProductImage WHERE productId = ?`, productID).Scan(&position, &count)
+	if scanErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a product image (failed to get position): %w", scanErr)
+	}
+
+	res, execErr := tx.Exec(`INSERT INTO ProductImage (productId, position, isPrimary) VALUES (?, ?, ?)`, productID, position, count == 0)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a product image: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created product image ID: %w", idErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a product image (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Returns false if the product has no such image. Deleting the primary image promotes the next one.
+func DeleteProductImage(db *sql.DB, productID, imageID int64) (bool, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return false, fmt.Errorf("failed to delete a product image (failed to begin transaction): %w", txErr)
+	}
+
+	var primary bool
+
+	scanErr := tx.QueryRow(`SELECT isPrimary FROM ProductImage WHERE id = ? AND productId = ? FOR UPDATE`, imageID, productID).Scan(&primary)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("failed to delete a product image (id: %d): %w", imageID, scanErr)
+	}
+
+	if _, execErr := tx.Exec(`DELETE FROM ProductImage WHERE id = ?`, imageID); execErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to delete a product image (id: %d): %w", imageID, execErr)
+	}
+
+	if primary {
+		_, execErr := tx.Exec(`UPDATE ProductImage SET isPrimary = TRUE WHERE productId = ? ORDER BY position, id LIMIT 1`, productID)
+		if execErr != nil {
+			tx.Rollback()
+			return false, fmt.Errorf("failed to delete a product image (failed to promote next image): %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return false, fmt.Errorf("failed to delete a product image (failed to commit transaction): %w", commitErr)
+	}
+
+	return true, nil
+}
+
+// Returns false if the product has no such image
+func SetPrimaryProductImage(db *sql.DB, productID, imageID int64) (bool, error) {
+	var exists bool
+
+	scanErr := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM ProductImage WHERE id = ? AND productId = ?)`, imageID, productID).Scan(&exists)
+	if scanErr != nil {
+		return false, fmt.Errorf("failed to set primary product image (id: %d): %w", imageID, scanErr)
+	}
+
+	if !exists {
+		return false, nil
+	}
+
+	_, execErr := db.Exec(`UPDATE ProductImage SET isPrimary = (id = ?) WHERE productId = ?`, imageID, productID)
+	if execErr != nil {
+		return false, fmt.Errorf("failed to set primary product image (id: %d): %w", imageID, execErr)
+	}
+
+	return true, nil
+}
+
+// Orders the product's images as listed, failing with ErrProductImageOrder unless imageIDs lists each of them once
+func ReorderProductImages(db *sql.DB, productID int64, imageIDs []int64) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to reorder product images (failed to begin transaction): %w", txErr)
+	}
+
+	rows, queryErr := tx.Query(`SELECT id FROM ProductImage WHERE productId = ? FOR UPDATE`, productID)
+	if queryErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to reorder product images (failed to lock images): %w", queryErr)
+	}
+
+	unlisted := map[int64]bool{}
+
+	for rows.Next() {
+		var id int64
+
+		if scanErr := rows.Scan(&id); scanErr != nil {
+			rows.Close()
+			tx.Rollback()
+			return fmt.Errorf("failed to reorder product images (failed to scan an image): %w", scanErr)
+		}
+
+		unlisted[id] = true
+	}
+
+	rows.Close()
+
+	for _, imageID := range imageIDs {
+		if !unlisted[imageID] {
+			tx.Rollback()
+			return ErrProductImageOrder
+		}
+
+		delete(unlisted, imageID)
+	}
+
+	if len(unlisted) > 0 {
+		tx.Rollback()
+		return ErrProductImageOrder
+	}
+
+	for position, imageID := range imageIDs {
+		_, execErr := tx.Exec(`UPDATE ProductImage SET position = ? WHERE id = ?`, position, imageID)
+		if execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to reorder product images: %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to reorder product images (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+var (
+	ErrVariantNotFound = errors.New("product variant not found")
+	ErrVariantRequired = errors.New("product is sold by variant")
+	ErrVariantSKUTaken = errors.New("SKU is already taken")
+	ErrProductHasStock = errors.New("product has stock outside of variants, adjust it to 0 first")
+)
+
+const productVariantQuery = `SELECT id, productId, name, COALESCE(sku, ''), price, stock, active, createdAt FROM ProductVariant`
+
+func scanProductVariant(scanner interface{ Scan(...interface{}) error }, variant *ProductVariant) error {
+	return scanner.Scan(&variant.ID, &variant.ProductID, &variant.Name, &variant.SKU, &variant.Price, &variant.Stock, &variant.Active, &variant.CreatedAt)
+}
+
+// Fills in Attributes of each variant
+func attachVariantAttributes(db *sql.DB, variants []ProductVariant) error {
+	if len(variants) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(variants))
+	byID := map[int64]*ProductVariant{}
+
+	for i := range variants {
+		args[i] = variants[i].ID
+		variants[i].Attributes = map[string]string{}
+		byID[variants[i].ID] = &variants[i]
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(variants)), ", ")
+
+	rows, queryErr := db.Query(`SELECT variantId, attrName, attrValue FROM ProductVariantAttribute WHERE variantId IN (`+placeholders+`)`, args...)
+	if queryErr != nil {
+		return fmt.Errorf("failed to get variant attributes: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	counter := 0
+
+	for rows.Next() {
+		var variantID int64
+		var name, value string
+
+		scanErr := rows.Scan(&variantID, &name, &value)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a variant attribute at row (%d): %v", counter, scanErr)
+		} else if variant, found := byID[variantID]; found {
+			variant.Attributes[name] = value
+		}
+
+		counter++
+	}
+
+	return nil
+}
+
+func queryProductVariants(db *sql.DB, query string, args ...interface{}) ([]ProductVariant, error) {
+	rows, queryErr := db.Query(query, args...)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get product variants: %w", queryErr)
+	}
+
+	variants := []ProductVariant{}
+	counter := 0
+
+	for rows.Next() {
+		variant := ProductVariant{}
+
+		scanErr := scanProductVariant(rows, &variant)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a product variant at row (%d): %v", counter, scanErr)
+		} else {
+			variants = append(variants, variant)
+		}
+
+		counter++
+	}
+
+	rows.Close()
+
+	if attachErr := attachVariantAttributes(db, variants); attachErr != nil {
+		return nil, attachErr
+	}
+
+	return variants, nil
+}
+
+// Inactive variants are only included with inactive set
+func GetProductVariants(db *sql.DB, productID int64, inactive bool) ([]ProductVariant, error) {
+	return queryProductVariants(db, productVariantQuery+` WHERE productId = ? AND (? OR active) ORDER BY id`, productID, inactive)
+}
+
+// Including inactive variants
+func GetProductVariantByID(db *sql.DB, id int64) (*ProductVariant, error) {
+	variants, queryErr := queryProductVariants(db, productVariantQuery+` WHERE id = ?`, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a product variant by ID (id: %d): %w", id, queryErr)
+	}
+
+	if len(variants) == 0 {
+		return nil, nil
+	}
+
+	return &variants[0], nil
+}
+
+// Fills in the active Variants of each product
+func AttachProductVariants(db *sql.DB, products []Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, len(products))
+	for i, product := range products {
+		args[i] = product.ID
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(products)), ", ")
+
+	variants, queryErr := queryProductVariants(db, productVariantQuery+` WHERE active AND productId IN (`+placeholders+`) ORDER BY productId, id`, args...)
+	if queryErr != nil {
+		return queryErr
+	}
+
+	byProduct := map[int64][]ProductVariant{}
+	for _, variant := range variants {
+		byProduct[variant.ProductID] = append(byProduct[variant.ProductID], variant)
+	}
+
+	for i := range products {
+		products[i].Variants = byProduct[products[i].ID]
+		if products[i].Variants == nil {
+			products[i].Variants = []ProductVariant{}
+		}
+	}
+
+	return nil
+}
+
+func insertVariantAttributes(tx *sql.Tx, variantID int64, attributes map[string]string) error {
+	for name, value := range attributes {
+		_, execErr := tx.Exec(`INSERT INTO ProductVariantAttribute (variantId, attrName, attrValue) VALUES (?, ?, ?)`, variantID, name, value)
+		if execErr != nil {
+			return fmt.Errorf("failed to insert variant attribute: %w", execErr)
+		}
+	}
+
+	return nil
+}
+
+func skuTaken(tx *sql.Tx, sku string, exceptID int64) (bool, error) {
+	if sku == "" {
+		return false, nil
+	}
+
+	var taken bool
+
+	scanErr := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM ProductVariant WHERE sku = ? AND id <> ?)`, sku, exceptID).Scan(&taken)
+	if scanErr != nil {
+		return false, fmt.Errorf("failed to check SKU: %w", scanErr)
+	}
+
+	return taken, nil
+}
+
+// Variants start out of stock; stock is added with stock movements.
+// Fails with ErrProductNotFound, ErrVariantSKUTaken or ErrProductHasStock.
+func CreateProductVariant(db *sql.DB, variant ProductVariant) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a product variant (failed to begin transaction): %w", txErr)
+	}
+
+	var stock int
+	var hasVariants bool
+
+	query := `SELECT stock, EXISTS (SELECT 1 FROM ProductVariant WHERE productId = P.id AND active) FROM Product AS P WHERE id = ? AND deletedAt IS NULL FOR UPDATE`
+
+	scanErr := tx.QueryRow(query, variant.ProductID).Scan(&stock, &hasVariants)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return 0, ErrProductNotFound
+		}
+
+		return 0, fmt.Errorf("failed to create a product variant (failed to lock product): %w", scanErr)
+	}
+
+	// The product's stock would be replaced by the sum of its variants
+	if variant.Active && !hasVariants && stock > 0 {
+		tx.Rollback()
+		return 0, ErrProductHasStock
+	}
+
+	taken, checkErr := skuTaken(tx, variant.SKU, 0)
+	if checkErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a product variant: %w", checkErr)
+	}
+
+	if taken {
+		tx.Rollback()
+		return 0, ErrVariantSKUTaken
+	}
+
+	res, execErr := tx.Exec(`INSERT INTO ProductVariant (productId, name, sku, price, active) VALUES (?, ?, ?, ?, ?)`,
+		variant.ProductID, variant.Name, nullIfEmpty(variant.SKU), variant.Price, variant.Active)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a product variant: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created product variant ID: %w", idErr)
+	}
+
+	if attrErr := insertVariantAttributes(tx, id, variant.Attributes); attrErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a product variant: %w", attrErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a product variant (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Replaces everything but the stock of a variant of variant.ProductID. Deactivating a variant removes it from baskets.
+// Fails with ErrVariantNotFound, ErrVariantSKUTaken or ErrProductHasStock.
+func UpdateProductVariant(db *sql.DB, variant ProductVariant) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to update a product variant (failed to begin transaction): %w", txErr)
+	}
+
+	var stock int
+	var hasOtherVariants bool
+
+	// The product is locked first, like moveStock does
+	query := `SELECT stock, EXISTS (SELECT 1 FROM ProductVariant WHERE productId = P.id AND active AND id <> ?) FROM Product AS P WHERE id = ? FOR UPDATE`
+
+	scanErr := tx.QueryRow(query, variant.ID, variant.ProductID).Scan(&stock, &hasOtherVariants)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return ErrVariantNotFound
+		}
+
+		return fmt.Errorf("failed to update a product variant (failed to lock product): %w", scanErr)
+	}
+
+	var wasActive bool
+
+	scanErr = tx.QueryRow(`SELECT active FROM ProductVariant WHERE id = ? AND productId = ? FOR UPDATE`, variant.ID, variant.ProductID).Scan(&wasActive)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return ErrVariantNotFound
+		}
+
+		return fmt.Errorf("failed to update a product variant (failed to lock variant): %w", scanErr)
+	}
+
+	if variant.Active && !wasActive && !hasOtherVariants && stock > 0 {
+		tx.Rollback()
+		return ErrProductHasStock
+	}
+
+	taken, checkErr := skuTaken(tx, variant.SKU, variant.ID)
+	if checkErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a product variant: %w", checkErr)
+	}
+
+	if taken {
+		tx.Rollback()
+		return ErrVariantSKUTaken
+	}
+
+	_, execErr := tx.Exec(`UPDATE ProductVariant SET name = ?, sku = ?, price = ?, active = ? WHERE id = ?`,
+		variant.Name, nullIfEmpty(variant.SKU), variant.Price, variant.Active, variant.ID)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a product variant (id: %d): %w", variant.ID, execErr)
+	}
+
+	if _, execErr := tx.Exec(`DELETE FROM ProductVariantAttribute WHERE variantId = ?`, variant.ID); execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a product variant (failed to clear attributes): %w", execErr)
+	}
+
+	if attrErr := insertVariantAttributes(tx, variant.ID, variant.Attributes); attrErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a product variant: %w", attrErr)
+	}
+
+	if !variant.Active {
+		if _, execErr := tx.Exec(`DELETE FROM ProductBasket WHERE variantId = ?`, variant.ID); execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update a product variant (failed to remove from baskets): %w", execErr)
+		}
+	}
+
+	if variant.Active != wasActive {
+		if syncErr := syncProductStock(tx, variant.ProductID); syncErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update a product variant: %w", syncErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to update a product variant (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Variants are deactivated rather than deleted so orders and stock history keep referring to them.
+// Returns false if the product has no such variant.
+func DeactivateProductVariant(db *sql.DB, productID, variantID int64) (bool, error) {
+	variant, queryErr := GetProductVariantByID(db, variantID)
+	if queryErr != nil {
+		return false, queryErr
+	}
+
+	if variant == nil || variant.ProductID != productID {
+		return false, nil
+	}
+
+	variant.Active = false
+
+	if updateErr := UpdateProductVariant(db, *variant); updateErr != nil {
+		return false, updateErr
+	}
+
+	return true, nil
+}
+
+var ErrPaymentIntentSettled = errors.New("payment is already settled")
+
+const paymentIntentQuery = `SELECT id, provider, COALESCE(sessionId, ''), checkoutUrl, purpose, subscriberId, orderId, amount, currency, status,
+  confirmedAmount, paymentId, createdById, createdAt, COALESCE(settledAt, '') FROM PaymentIntent`
+
+func scanPaymentIntent(scanner interface{ Scan(...interface{}) error }, intent *PaymentIntent) error {
+	return scanner.Scan(&intent.ID, &intent.Provider, &intent.SessionID, &intent.CheckoutURL, &intent.Purpose, &intent.SubscriberID, &intent.OrderID, &intent.Amount, &intent.Currency, &intent.Status,
+		&intent.ConfirmedAmount, &intent.PaymentID, &intent.CreatedByID, &intent.CreatedAt, &intent.SettledAt)
+}
+
+// Records a pending intent before its checkout session is created, so its ID can be given to the provider
+func CreatePaymentIntent(db *sql.DB, intent PaymentIntent) (int64, error) {
+	query := `INSERT INTO PaymentIntent (provider, purpose, subscriberId, orderId, amount, currency, createdById) VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	res, execErr := db.Exec(query, intent.Provider, intent.Purpose, intent.SubscriberID, intent.OrderID, intent.Amount, intent.Currency, intent.CreatedByID)
+	if execErr != nil {
+		return 0, fmt.Errorf("failed to create a payment intent: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		return 0, fmt.Errorf("failed to retrieve created payment intent ID: %w", idErr)
+	}
+
+	return id, nil
+}
+
+func SetPaymentIntentSession(db *sql.DB, id int64, session common.CheckoutSession) error {
+	_, execErr := db.Exec(`UPDATE PaymentIntent SET sessionId = ?, checkoutUrl = ? WHERE id = ?`, session.ID, session.URL, id)
+	if execErr != nil {
+		return fmt.Errorf("failed to set payment intent session (id: %d): %w", id, execErr)
+	}
+
+	return nil
+}
+
+func GetPaymentIntentByID(db *sql.DB, id int64) (*PaymentIntent, error) {
+	intent := PaymentIntent{}
+
+	scanErr := scanPaymentIntent(db.QueryRow(paymentIntentQuery+` WHERE id = ?`, id), &intent)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a payment intent by ID (id: %d): %w", id, scanErr)
+	}
+
+	return &intent, nil
+}
+
+func GetPaymentIntentBySession(db *sql.DB, provider, sessionID string) (*PaymentIntent, error) {
+	intent := PaymentIntent{}
+
+	scanErr := scanPaymentIntent(db.QueryRow(paymentIntentQuery+` WHERE provider = ? AND sessionId = ?`, provider, sessionID), &intent)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a payment intent by session (session: %s): %w", sessionID, scanErr)
+	}
+
+	return &intent, nil
+}
+
+// Latest first. An empty status lists intents of every status.
+func GetPaymentIntents(db *sql.DB, status string) ([]PaymentIntent, error) {
+	rows, queryErr := db.Query(paymentIntentQuery+` WHERE (? = '' OR status = ?) ORDER BY createdAt DESC, id DESC`, status, status)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get payment intents: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	intents := []PaymentIntent{}
+	counter := 0
+
+	for rows.Next() {
+		intent := PaymentIntent{}
+
+		scanErr := scanPaymentIntent(rows, &intent)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a payment intent at row (%d): %v", counter, scanErr)
+		} else {
+			intents = append(intents, intent)
+		}
+
+		counter++
+	}
+
+	return intents, nil
+}
+
+// Settles a pending intent with the outcome reported by the provider, failing with ErrPaymentIntentSettled
+// if it was already settled, such as when a webhook is delivered twice.
+// A successful payment is recorded in the income ledger for the confirmed amount. A membership payment is added
+// to the subscriber's paymentAmount; an order is marked paid. Money received for an order that's no longer pending
+// is still recorded, to be refunded by staff. An intent flagged with IntentReview keeps the confirmed amount
+// but records nothing else.
+func SettlePaymentIntent(db *sql.DB, id int64, status string, confirmed common.Money) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to settle a payment intent (failed to begin transaction): %w", txErr)
+	}
+
+	var current, purpose string
+	var subscriberID, orderID *int64
+
+	scanErr := tx.QueryRow(`SELECT status, purpose, subscriberId, orderId FROM PaymentIntent WHERE id = ? FOR UPDATE`, id).Scan(&current, &purpose, &subscriberID, &orderID)
+	if scanErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to settle a payment intent (failed to lock intent): %w", scanErr)
+	}
+
+	if current != IntentPending {
+		tx.Rollback()
+		return ErrPaymentIntentSettled
+	}
+
+	if status != common.PaymentSucceeded {
+		var confirmedAmount *common.Money
+
+		if status == IntentReview {
+			confirmedAmount = &confirmed
+		}
+
+		_, execErr := tx.Exec(`UPDATE PaymentIntent SET status = ?, confirmedAmount = ?, settledAt = CURRENT_TIMESTAMP WHERE id = ?`, status, confirmedAmount, id)
+		if execErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to settle a payment intent (id: %d): %w", id, execErr)
+		}
+
+		commitErr := tx.Commit()
+		if commitErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to settle a payment intent (failed to commit transaction): %w", commitErr)
+		}
+
+		return nil
+	}
+
+	recordPayment := func(payment Payment) (int64, error) {
+		res, execErr := tx.Exec(createPaymentQuery, paymentArgs(&payment)...)
+		if execErr != nil {
+			return 0, execErr
+		}
+
+		return res.LastInsertId()
+	}
+
+	var paymentID int64
+
+	switch purpose {
+	case IntentMembership:
+		var payErr error
+
+		paymentID, payErr = recordPayment(Payment{Source: PaymentMembership, SubscriberID: subscriberID, Amount: confirmed, Method: "online", Notes: fmt.Sprintf("Online payment #%d", id)})
+		if payErr != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to settle a payment intent (failed to record payment): %w", payErr)
+		}
+
+		if subscriberID != nil {
+			_, execErr := tx.Exec(`UPDATE Subscriber SET paymentAmount = paymentAmount + ? WHERE id = ?`, confirmed, *subscriberID)
+			if execErr != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to settle a payment intent (failed to update subscriber payment amount): %w", execErr)
+			}
+		}
+	case IntentOrder:
+		statusErr := ErrOrderStatus
+
+		if orderID != nil {
+			paymentID, statusErr = setOrderStatus(tx, *orderID, "", OrderPaid, &Payment{Method: "online", Amount: confirmed}, "")
+		}
+
+		if statusErr != nil && !errors.Is(statusErr, ErrOrderStatus) {
+			tx.Rollback()
+			return fmt.Errorf("failed to settle a payment intent (failed to mark order paid): %w", statusErr)
+		}
+
+		if statusErr != nil {
+			var payErr error
+
+			paymentID, payErr = recordPayment(Payment{Source: PaymentProduct, Amount: confirmed, Method: "online", Notes: fmt.Sprintf("Online payment #%d for an order that's no longer pending", id)})
+			if payErr != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to settle a payment intent (failed to record payment): %w", payErr)
+			}
+		}
+	}
+
+	query := `UPDATE PaymentIntent SET status = ?, confirmedAmount = ?, paymentId = ?, settledAt = CURRENT_TIMESTAMP WHERE id = ?`
+
+	_, execErr := tx.Exec(query, status, confirmed, paymentID, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to settle a payment intent (id: %d): %w", id, execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to settle a payment intent (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+var (
+	ErrDrawerOpen         = errors.New("a cash drawer session is already open")
+	ErrNoOpenDrawer       = errors.New("no cash drawer session is open")
+	ErrSubscriberNotFound = errors.New("subscriber not found")
+)
+
+const cashDrawerQuery = `SELECT D.id, D.openingFloat, D.openedById, D.openedAt, D.expectedCash, D.countedCash, D.notes, D.closedById, COALESCE(D.closedAt, ''),
+  (SELECT COUNT(*) FROM PosSale WHERE drawerSessionId = D.id) FROM CashDrawerSession AS D`
+
+// Fills in the sale totals by payment method and the expected cash of a drawer still open
+func scanCashDrawer(db *sql.DB, row *sql.Row) (*CashDrawerSession, error) {
+	session := CashDrawerSession{Totals: map[string]common.Money{}}
+
+	var expected *common.Money
+
+	scanErr := row.Scan(&session.ID, &session.OpeningFloat, &session.OpenedByID, &session.OpenedAt, &expected, &session.CountedCash, &session.Notes, &session.ClosedByID, &session.ClosedAt, &session.SaleCount)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, scanErr
+	}
+
+	query := `SELECT P.method, SUM(P.amount) FROM PosSalePayment AS P JOIN PosSale AS S ON S.id = P.saleId WHERE S.drawerSessionId = ? GROUP BY P.method`
+
+	rows, queryErr := db.Query(query, session.ID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get drawer totals: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	counter := 0
+
+	for rows.Next() {
+		var method string
+		var amount common.Money
+
+		if scanErr := rows.Scan(&method, &amount); scanErr != nil {
+			common.Logger.Printf("failed to scan a drawer total at row (%d): %v", counter, scanErr)
+		} else {
+			session.Totals[method] = amount
+		}
+
+		counter++
+	}
+
+	if expected != nil {
+		session.ExpectedCash = *expected
+	} else {
+		session.ExpectedCash = session.OpeningFloat + session.Totals["cash"]
+	}
+
+	if session.CountedCash != nil {
+		difference := *session.CountedCash - session.ExpectedCash
+		session.Difference = &difference
+	}
+
+	return &session, nil
+}
+
+// Fails with ErrDrawerOpen unless the previous session was closed
+func OpenCashDrawer(db *sql.DB, openedByID int64, openingFloat common.Money) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to open cash drawer (failed to begin transaction): %w", txErr)
+	}
+
+	var openID int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM CashDrawerSession WHERE closedAt IS NULL LIMIT 1 FOR UPDATE`).Scan(&openID)
+	if scanErr == nil {
+		tx.Rollback()
+		return 0, ErrDrawerOpen
+	}
+
+	if scanErr != sql.ErrNoRows {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to open cash drawer (failed to check open session): %w", scanErr)
+	}
+
+	res, execErr := tx.Exec(`INSERT INTO CashDrawerSession (openingFloat, openedById) VALUES (?, ?)`, openingFloat, openedByID)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to open cash drawer: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve opened cash drawer session ID: %w", idErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to open cash drawer (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+// Returns nil if the drawer is closed
+func GetOpenCashDrawer(db *sql.DB) (*CashDrawerSession, error) {
+	session, queryErr := scanCashDrawer(db, db.QueryRow(cashDrawerQuery+` WHERE D.closedAt IS NULL ORDER BY D.id LIMIT 1`))
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get the open cash drawer session: %w", queryErr)
+	}
+
+	return session, nil
+}
+
+func GetCashDrawerSessionByID(db *sql.DB, id int64) (*CashDrawerSession, error) {
+	session, queryErr := scanCashDrawer(db, db.QueryRow(cashDrawerQuery+` WHERE D.id = ?`, id))
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a cash drawer session by ID (id: %d): %w", id, queryErr)
+	}
+
+	return session, nil
+}
+
+// Sessions opened within the dates, both inclusive, latest first. Totals aren't filled in.
+func GetCashDrawerSessions(db *sql.DB, from, to string) ([]CashDrawerSession, error) {
+	query := `SELECT id FROM CashDrawerSession WHERE openedAt >= ? AND openedAt < DATE_ADD(?, INTERVAL 1 DAY) ORDER BY openedAt DESC, id DESC`
+
+	rows, queryErr := db.Query(query, from, to)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get cash drawer sessions: %w", queryErr)
+	}
+
+	ids := []int64{}
+
+	for rows.Next() {
+		var id int64
+
+		if scanErr := rows.Scan(&id); scanErr == nil {
+			ids = append(ids, id)
+		}
+	}
+
+	rows.Close()
+
+	sessions := []CashDrawerSession{}
+
+	for _, id := range ids {
+		session, queryErr := GetCashDrawerSessionByID(db, id)
+		if queryErr != nil {
+			return nil, queryErr
+		}
+
+		if session != nil {
+			sessions = append(sessions, *session)
+		}
+	}
+
+	return sessions, nil
+}
+
+// Closes out the open session, storing the expected cash against the counted one.
+// Fails with ErrNoOpenDrawer if the session isn't open.
+func CloseCashDrawer(db *sql.DB, id, closedByID int64, countedCash common.Money, notes string) error {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return fmt.Errorf("failed to close cash drawer (failed to begin transaction): %w", txErr)
+	}
+
+	var openingFloat common.Money
+
+	scanErr := tx.QueryRow(`SELECT openingFloat FROM CashDrawerSession WHERE id = ? AND closedAt IS NULL FOR UPDATE`, id).Scan(&openingFloat)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return ErrNoOpenDrawer
+		}
+
+		return fmt.Errorf("failed to close cash drawer (failed to lock session): %w", scanErr)
+	}
+
+	var cash common.Money
+
+	query := `SELECT COALESCE(SUM(P.amount), 0) FROM PosSalePayment AS P JOIN PosSale AS S ON S.id = P.saleId WHERE S.drawerSessionId = ? AND P.method = 'cash'`
+
+	scanErr = tx.QueryRow(query, id).Scan(&cash)
+	if scanErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to close cash drawer (failed to sum cash): %w", scanErr)
+	}
+
+	query = `UPDATE CashDrawerSession SET expectedCash = ?, countedCash = ?, notes = ?, closedById = ?, closedAt = CURRENT_TIMESTAMP WHERE id = ?`
+
+	_, execErr := tx.Exec(query, openingFloat+cash, countedCash, notes, closedByID, id)
+	if execErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to close cash drawer (id: %d): %w", id, execErr)
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to close cash drawer (failed to commit transaction): %w", commitErr)
+	}
+
+	return nil
+}
+
+// Income ledger entries of a sale: its payments split between membership and product income,
+// membership first, in the order they were taken
+func posLedgerEntries(sale *PosSale) []Payment {
+	owed := map[string]common.Money{}
+	var planID *int64
+
+	for _, line := range sale.Lines {
+		if line.Kind == PosLineMembership {
+			owed[PaymentMembership] += line.Amount
+
+			if planID == nil {
+				planID = line.PlanID
+			}
+		} else {
+			owed[PaymentProduct] += line.Amount
+		}
+	}
+
+	entries := []Payment{}
+	remaining := make([]common.Money, len(sale.Payments))
+
+	for i, payment := range sale.Payments {
+		remaining[i] = payment.Amount
+	}
+
+	for _, source := range []string{PaymentMembership, PaymentProduct} {
+		for i := range sale.Payments {
+			taken := min(owed[source], remaining[i])
+			if taken <= 0 {
+				continue
+			}
+
+			owed[source] -= taken
+			remaining[i] -= taken
+
+			entry := Payment{Source: source, Amount: taken, Method: sale.Payments[i].Method, Notes: fmt.Sprintf("POS sale #%d", sale.ID), CreatedByID: sale.CreatedByID}
+
+			if source == PaymentMembership {
+				entry.SubscriberID = sale.SubscriberID
+				entry.PlanID = planID
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries
+}
+
+// Records a sale in the open drawer session. Products are taken out of stock and membership renewals
+// extend the subscriber's endsAt from today if it already passed. Payments are recorded in the income ledger.
+// Fails with ErrNoOpenDrawer, ErrSubscriberNotFound or the errors of stock movements.
+func CreatePosSale(db *sql.DB, sale PosSale) (int64, error) {
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return 0, fmt.Errorf("failed to create a sale (failed to begin transaction): %w", txErr)
+	}
+
+	scanErr := tx.QueryRow(`SELECT id FROM CashDrawerSession WHERE closedAt IS NULL ORDER BY id LIMIT 1 FOR UPDATE`).Scan(&sale.DrawerSessionID)
+	if scanErr != nil {
+		tx.Rollback()
+
+		if scanErr == sql.ErrNoRows {
+			return 0, ErrNoOpenDrawer
+		}
+
+		return 0, fmt.Errorf("failed to create a sale (failed to lock drawer): %w", scanErr)
+	}
+
+	query := `INSERT INTO PosSale (drawerSessionId, subscriberId, customerName, total, changeGiven, createdById) VALUES (?, ?, ?, ?, ?, ?)`
+
+	res, execErr := tx.Exec(query, sale.DrawerSessionID, sale.SubscriberID, sale.CustomerName, sale.Total, sale.ChangeGiven, sale.CreatedByID)
+	if execErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a sale: %w", execErr)
+	}
+
+	id, idErr := res.LastInsertId()
+	if idErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to retrieve created sale ID: %w", idErr)
+	}
+
+	sale.ID = id
+
+	lineQuery := `INSERT INTO PosSaleLine (saleId, kind, productId, variantId, planId, description, quantity, unitPrice, amount, days, endsAt)
+  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	for _, line := range sale.Lines {
+		switch line.Kind {
+		case PosLineProduct:
+			movement := StockMovement{ProductID: *line.ProductID, VariantID: line.VariantID, Kind: StockSale, Quantity: -line.Quantity, Reason: fmt.Sprintf("POS sale #%d", id), CreatedByID: sale.CreatedByID}
+
+			if moveErr := moveStock(tx, &movement); moveErr != nil {
+				tx.Rollback()
+
+				if errors.Is(moveErr, ErrInsufficientStock) || errors.Is(moveErr, ErrProductNotFound) || errors.Is(moveErr, ErrVariantNotFound) || errors.Is(moveErr, ErrVariantRequired) {
+					return 0, moveErr
+				}
+
+				return 0, fmt.Errorf("failed to create a sale (failed to take stock): %w", moveErr)
+			}
+		case PosLineMembership:
+			if sale.SubscriberID == nil {
+				tx.Rollback()
+				return 0, ErrSubscriberNotFound
+			}
+
+			var subscriberID int64
+
+			scanErr := tx.QueryRow(`SELECT id FROM Subscriber WHERE id = ? AND deletedAt IS NULL FOR UPDATE`, *sale.SubscriberID).Scan(&subscriberID)
+			if scanErr != nil {
+				tx.Rollback()
+
+				if scanErr == sql.ErrNoRows {
+					return 0, ErrSubscriberNotFound
+				}
+
+				return 0, fmt.Errorf("failed to create a sale (failed to lock subscriber): %w", scanErr)
+			}
+
+			query := `UPDATE Subscriber SET endsAt = DATE_ADD(GREATEST(endsAt, CURRENT_TIMESTAMP), INTERVAL ? DAY), duration = COALESCE(duration, 0) + ?,
+  daysLeft = DATEDIFF(endsAt, CURRENT_TIMESTAMP), paymentAmount = paymentAmount + ? WHERE id = ?`
+
+			_, execErr := tx.Exec(query, *line.Days, *line.Days, line.Amount, subscriberID)
+			if execErr != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("failed to create a sale (failed to renew membership): %w", execErr)
+			}
+
+			scanErr = tx.QueryRow(`SELECT endsAt FROM Subscriber WHERE id = ?`, subscriberID).Scan(&line.EndsAt)
+			if scanErr != nil {
+				tx.Rollback()
+				return 0, fmt.Errorf("failed to create a sale (failed to get renewed membership): %w", scanErr)
+			}
+		}
+
+		_, execErr := tx.Exec(lineQuery, id, line.Kind, line.ProductID, line.VariantID, line.PlanID, line.Description, line.Quantity, line.UnitPrice, line.Amount, line.Days, nullIfEmpty(line.EndsAt))
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create a sale (failed to insert line): %w", execErr)
+		}
+	}
+
+	for _, payment := range sale.Payments {
+		_, execErr := tx.Exec(`INSERT INTO PosSalePayment (saleId, method, amount) VALUES (?, ?, ?)`, id, payment.Method, payment.Amount)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create a sale (failed to insert payment): %w", execErr)
+		}
+	}
+
+	for _, entry := range posLedgerEntries(&sale) {
+		_, execErr := tx.Exec(createPaymentQuery, paymentArgs(&entry)...)
+		if execErr != nil {
+			tx.Rollback()
+			return 0, fmt.Errorf("failed to create a sale (failed to record payment): %w", execErr)
+		}
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		tx.Rollback()
+		return 0, fmt.Errorf("failed to create a sale (failed to commit transaction): %w", commitErr)
+	}
+
+	return id, nil
+}
+
+const posSaleQuery = `SELECT id, drawerSessionId, subscriberId, customerName, total, changeGiven, createdById, createdAt FROM PosSale`
+
+func scanPosSale(scanner interface{ Scan(...interface{}) error }, sale *PosSale) error {
+	return scanner.Scan(&sale.ID, &sale.DrawerSessionID, &sale.SubscriberID, &sale.CustomerName, &sale.Total, &sale.ChangeGiven, &sale.CreatedByID, &sale.CreatedAt)
+}
+
+// With its lines and payments
+func GetPosSaleByID(db *sql.DB, id int64) (*PosSale, error) {
+	sale := PosSale{Lines: []PosSaleLine{}, Payments: []PosSalePayment{}}
+
+	scanErr := scanPosSale(db.QueryRow(posSaleQuery+` WHERE id = ?`, id), &sale)
+	if scanErr != nil {
+		if scanErr == sql.ErrNoRows {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to get a sale by ID (id: %d): %w", id, scanErr)
+	}
+
+	query := `SELECT id, saleId, kind, productId, variantId, planId, description, quantity, unitPrice, amount, days, COALESCE(endsAt, '') FROM PosSaleLine WHERE saleId = ? ORDER BY id`
+
+	rows, queryErr := db.Query(query, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a sale by ID (failed to get lines): %w", queryErr)
+	}
+
+	counter := 0
+
+	for rows.Next() {
+		line := PosSaleLine{}
+
+		scanErr := rows.Scan(&line.ID, &line.SaleID, &line.Kind, &line.ProductID, &line.VariantID, &line.PlanID, &line.Description, &line.Quantity, &line.UnitPrice, &line.Amount, &line.Days, &line.EndsAt)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a sale line at row (%d): %v", counter, scanErr)
+		} else {
+			sale.Lines = append(sale.Lines, line)
+		}
+
+		counter++
+	}
+
+	rows.Close()
+
+	rows, queryErr = db.Query(`SELECT id, saleId, method, amount FROM PosSalePayment WHERE saleId = ? ORDER BY id`, id)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get a sale by ID (failed to get payments): %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	counter = 0
+
+	for rows.Next() {
+		payment := PosSalePayment{}
+
+		scanErr := rows.Scan(&payment.ID, &payment.SaleID, &payment.Method, &payment.Amount)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a sale payment at row (%d): %v", counter, scanErr)
+		} else {
+			sale.Payments = append(sale.Payments, payment)
+		}
+
+		counter++
+	}
+
+	return &sale, nil
+}
+
+// Without lines and payments, latest first
+func GetPosSalesOfDrawer(db *sql.DB, drawerSessionID int64) ([]PosSale, error) {
+	rows, queryErr := db.Query(posSaleQuery+` WHERE drawerSessionId = ? ORDER BY createdAt DESC, id DESC`, drawerSessionID)
+	if queryErr != nil {
+		return nil, fmt.Errorf("failed to get sales of cash drawer session: %w", queryErr)
+	}
+
+	defer rows.Close()
+
+	sales := []PosSale{}
+	counter := 0
+
+	for rows.Next() {
+		sale := PosSale{}
+
+		scanErr := scanPosSale(rows, &sale)
+		if scanErr != nil {
+			common.Logger.Printf("failed to scan a sale at row (%d): %v", counter, scanErr)
+		} else {
+			sales = append(sales, sale)
+		}
+
+		counter++
+	}
+
+	return sales, nil
+}
+
+var (
+	ErrCategoryNotFound = errors.New("product category not found")
+	ErrBulkEmpty        = errors.New("no items to change")
+)
+
+// Ways of changing prices in bulk
+const (
+	// Changes prices by a percentage of themselves, such as 10 or -25
+	PriceChangePercent = "percent"
+	// Changes prices by a fixed amount, such as 5.00 or -2.50
+	PriceChangeFixed = "fixed"
+)
+
+type PriceChange struct {
+	// PriceChangePercent or PriceChangeFixed
+	Mode    string
+	Percent float64
+	Amount  common.Money
+}
+
+func (c PriceChange) apply(price common.Money) common.Money {
+	if c.Mode == PriceChangePercent {
+		return price + price.Percent(c.Percent)
+	}
+
+	return price + c.Amount
+}
+
+// Applies change to each ID in one transaction, committing only when every item succeeds.
+// change returns why an item can't be changed, or an error which aborts the whole batch.
+func runBulk(db *sql.DB, action string, ids []int64, change func(tx *sql.Tx, id int64) (string, error)) (*BulkResult, error) {
+	return runBulkThen(db, action, ids, change, nil)
+}
+
+// Like runBulk, running finish in the same transaction once every item succeeded
+func runBulkThen(db *sql.DB, action string, ids []int64, change func(tx *sql.Tx, id int64) (string, error), finish func(tx *sql.Tx) error) (*BulkResult, error) {
+	if len(ids) == 0 {
+		return nil, ErrBulkEmpty
+	}
+
+	tx, txErr := db.Begin()
+	if txErr != nil {
+		return nil, fmt.Errorf("failed to %s (failed to begin transaction): %w", action, txErr)
+	}
+
+	result := &BulkResult{Applied: true, Items: make([]BulkItemResult, 0, len(ids))}
+	seen := map[int64]bool{}
+
+	for _, id := range ids {
+		item := BulkItemResult{ID: id}
+
+		if seen[id] {
+			item.Error = "listed more than once"
+		} else {
+			reason, changeErr := change(tx, id)
+			if changeErr != nil {
+				tx.Rollback()
+				return nil, fmt.Errorf("failed to %s (id: %d): %w", action, id, changeErr)
+			}
+
+			item.Error = reason
+		}
+
+		seen[id] = true
+		item.OK = item.Error == ""
+
+		if !item.OK {
+			result.Applied = false
+		}
+
+		result.Items = append(result.Items, item)
+	}
+
+	if !result.Applied {
+		tx.Rollback()
+		return result, nil
+	}
+
+	if finish != nil {
+		if finishErr := finish(tx); finishErr != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to %s: %w", action, finishErr)
+		}
+	}
+
+	if commitErr := tx.Commit(); commitErr != nil {
+		return nil, fmt.Errorf("failed to %s (failed to commit transaction): %w", action, commitErr)
+	}
+
+	return result, nil
+}
+
+// Returns an empty reason when the product exists and isn't deleted, locking it
+func lockLiveProduct(tx *sql.Tx, id int64) (string, error) {
+	var found int64
+
+	scanErr := tx.QueryRow(`SELECT id FROM Product WHERE id = ? AND deletedAt IS NULL FOR UPDATE`, id).Scan(&found)
+	if scanErr != nil {
+		if errors.Is(scanErr, sql.ErrNoRows) {
+			return ErrProductNotFound.Error(), nil
+		}
+
+		return "", scanErr
+	}
+
+	return "", nil
+}
+
+// Fails with ErrCategoryNotFound or ErrBulkEmpty
+func MoveProductsToCategory(db *sql.DB, productIDs []int64, categoryID int64) (*BulkResult, error) {
+	category, queryErr := GetProductCategoryByID(db, categoryID)
+	if queryErr != nil && !errors.Is(queryErr, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to move products to a category: %w", queryErr)
+	}
+
+	if category == nil {
+		return nil, ErrCategoryNotFound
+	}
+
+	return runBulk(db, "move products to a category", productIDs, func(tx *sql.Tx, id int64) (string, error) {
+		reason, lockErr := lockLiveProduct(tx, id)
+		if lockErr != nil || reason != "" {
+			return reason, lockErr
+		}
+
+		_, execErr := tx.Exec(`UPDATE Product SET categoryId = ?, updatedAt = CURRENT_TIMESTAMP WHERE id = ?`, categoryID, id)
+
+		return "", execErr
+	})
+}
+
+// Changes the price of each product and the price overrides of its variants.
+// An item fails if any of its prices would fall below zero. Fails with ErrBulkEmpty.
+func UpdateProductPrices(db *sql.DB, productIDs []int64, change PriceChange) (*BulkResult, error) {
+	return runBulk(db, "update product prices", productIDs, func(tx *sql.Tx, id int64) (string, error) {
+		var price common.Money
+
+		scanErr := tx.QueryRow(`SELECT price FROM Product WHERE id = ? AND deletedAt IS NULL FOR UPDATE`, id).Scan(&price)
+		if scanErr != nil {
+			if errors.Is(scanErr, sql.ErrNoRows) {
+				return ErrProductNotFound.Error(), nil
+			}
+
+			return "", scanErr
+		}
+
+		newPrices := map[int64]common.Money{}
+
+		rows, queryErr := tx.Query(`SELECT id, price FROM ProductVariant WHERE productId = ? AND price IS NOT NULL FOR UPDATE`, id)
+		if queryErr != nil {
+			return "", queryErr
+		}
+
+		for rows.Next() {
+			var variantID int64
+			var variantPrice common.Money
+
+			if scanErr := rows.Scan(&variantID, &variantPrice); scanErr != nil {
+				rows.Close()
+				return "", scanErr
+			}
+
+			newPrices[variantID] = change.apply(variantPrice)
+		}
+
+		rows.Close()
+
+		newPrice := change.apply(price)
+
+		if newPrice < 0 {
+			return "price would fall below zero", nil
+		}
+
+		for variantID, variantPrice := range newPrices {
+			if variantPrice < 0 {
+				return fmt.Sprintf("price of variant (id: %d) would fall below zero", variantID), nil
+			}
+		}
+
+		if _, execErr := tx.Exec(`UPDATE Product SET price = ?, updatedAt = CURRENT_TIMESTAMP WHERE id = ?`, newPrice, id); execErr != nil {
+			return "", execErr
+		}
+
+		for variantID, variantPrice := range newPrices {
+			if _, execErr := tx.Exec(`UPDATE ProductVariant SET price = ? WHERE id = ?`, variantPrice, variantID); execErr != nil {
+				return "", execErr
+			}
+		}
+
+		return "", nil
+	})
+}
+
+// Moves products to the trash. Fails with ErrBulkEmpty.
+func DeleteProducts(db *sql.DB, productIDs []int64) (*BulkResult, error) {
+	return runBulk(db, "delete products", productIDs, func(tx *sql.Tx, id int64) (string, error) {
+		reason, lockErr := lockLiveProduct(tx, id)
+		if lockErr != nil || reason != "" {
+			return reason, lockErr
+		}
+
+		_, execErr := tx.Exec(`UPDATE Product SET deletedAt = CURRENT_TIMESTAMP WHERE id = ?`, id)
+
+		return "", execErr
+	})
+}
+
+// Brings products back from the trash. Fails with ErrBulkEmpty.
+func RestoreProducts(db *sql.DB, productIDs []int64) (*BulkResult, error) {
+	return runBulk(db, "restore products", productIDs, func(tx *sql.Tx, id int64) (string, error) {
+		res, execErr := tx.Exec(`UPDATE Product SET deletedAt = NULL WHERE id = ? AND deletedAt IS NOT NULL`, id)
+		if execErr != nil {
+			return "", execErr
+		}
+
+		if affected, _ := res.RowsAffected(); affected == 0 {
+			return "product not found in trash", nil
+		}
+
+		return "", nil
+	})
+}
+
+// Renames categories by ID to their Name. Names are checked against the names categories end up with,
+// so categories can swap names. An item fails if another category keeps or gets its new name. Fails with ErrBulkEmpty.
+func RenameProductCategories(db *sql.DB, categories []ProductCategory) (*BulkResult, error) {
+	ids := make([]int64, 0, len(categories))
+	names := map[int64]string{}
+
+	for _, category := range categories {
+		ids = append(ids, category.ID)
+
+		if _, found := names[category.ID]; !found {
+			names[category.ID] = category.Name
+		}
+	}
+
+	change := func(tx *sql.Tx, id int64) (string, error) {
+		var found int64
+
+		scanErr := tx.QueryRow(`SELECT id FROM ProductCategory WHERE id = ? FOR UPDATE`, id).Scan(&found)
+		if scanErr != nil {
+			if errors.Is(scanErr, sql.ErrNoRows) {
+				return ErrCategoryNotFound.Error(), nil
+			}
+
+			return "", scanErr
+		}
+
+		for other, name := range names {
+			if other != id && strings.EqualFold(name, names[id]) {
+				return fmt.Sprintf("name '%s' is given to more than one category", names[id]), nil
+			}
+		}
+
+		var holder int64
+
+		scanErr = tx.QueryRow(`SELECT id FROM ProductCategory WHERE name = ? AND id <> ?`, names[id], id).Scan(&holder)
+		if scanErr != nil && !errors.Is(scanErr, sql.ErrNoRows) {
+			return "", scanErr
+		}
+
+		// A category being renamed gives its name up
+		if _, renamed := names[holder]; scanErr == nil && !renamed {
+			return fmt.Sprintf("name '%s' is taken", names[id]), nil
+		}
+
+		// Frees the old name for the category that takes it; the final names are set once every item is checked
+		_, execErr := tx.Exec(`UPDATE ProductCategory SET name = ? WHERE id = ?`, fmt.Sprintf("\x1frenaming %d", id), id)
+
+		return "", execErr
+	}
+
+	return runBulkThen(db, "rename product categories", ids, change, func(tx *sql.Tx) error {
+		for id, name := range names {
+			if _, execErr := tx.Exec(`UPDATE ProductCategory SET name = ? WHERE id = ?`, name, id); execErr != nil {
+				return execErr
+			}
+		}
+
+		return nil
+	})
+}
+
+// Lists categories in the given order. Categories left out keep their order after the listed ones.
+// Fails with ErrBulkEmpty.
+func ReorderProductCategories(db *sql.DB, categoryIDs []int64) (*BulkResult, error) {
+	position := 0
+
+	return runBulk(db, "reorder product categories", categoryIDs, func(tx *sql.Tx, id int64) (string, error) {
+		if position == 0 {
+			// Shifting every category first leaves the unlisted ones after the listed ones
+			if _, execErr := tx.Exec(`UPDATE ProductCategory SET position = position + ?`, len(categoryIDs)); execErr != nil {
+				return "", execErr
+			}
+		}
+
+		var found int64
+
+		scanErr := tx.QueryRow(`SELECT id FROM ProductCategory WHERE id = ? FOR UPDATE`, id).Scan(&found)
+		if scanErr != nil {
+			if errors.Is(scanErr, sql.ErrNoRows) {
+				return ErrCategoryNotFound.Error(), nil
+			}
+
+			return "", scanErr
+		}
+
+		_, execErr := tx.Exec(`UPDATE ProductCategory SET position = ? WHERE id = ?`, position, id)
+		position++
+
+		return "", execErr
+	})
+}
@@ -50,3 +50,12 @@ func MapRows(row *sql.Rows, dest interface{}) error {
 
 	return nil
 }
+
+// Maps empty strings to NULL for nullable columns
+func nullIfEmpty(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+
+	return value
+}
@@ -0,0 +1,41 @@
+package dto
+
+type ClassType_Req struct {
+	Name            string `json:"name" binding:"required,max=255"`
+	Description     string `json:"description"`
+	DefaultCapacity int    `json:"defaultCapacity" binding:"required,gte=1"`
+	DurationMinutes int    `json:"durationMinutes" binding:"required,gte=1"`
+}
+
+// Duration and capacity default to those of the class type
+type ClassSchedule_Req struct {
+	ClassTypeID     int64  `json:"classTypeId" binding:"required"`
+	TrainerID       *int64 `json:"trainerId"`
+	Weekday         int    `json:"weekday" binding:"gte=0,lte=6"`
+	StartTime       string `json:"startTime" binding:"required,datetime=15:04"`
+	DurationMinutes int    `json:"durationMinutes" binding:"omitempty,gte=1"`
+	Capacity        int    `json:"capacity" binding:"omitempty,gte=1"`
+	ValidFrom       string `json:"validFrom" binding:"required,datetime=2006-01-02"`
+	ValidUntil      string `json:"validUntil" binding:"omitempty,datetime=2006-01-02"`
+}
+
+type ClassScheduleException_Req struct {
+	// Applies to every schedule when omitted
+	ScheduleID *int64 `json:"scheduleId"`
+	Date       string `json:"date" binding:"required,datetime=2006-01-02"`
+	Reason     string `json:"reason" binding:"max=255"`
+}
+
+type BookClass_Req struct {
+	SubscriberID int64 `json:"subscriberId" binding:"required"`
+}
+
+type BookClass_Res struct {
+	ID     int64  `json:"id"`
+	Status string `json:"status"`
+}
+
+type ClassAttendance_Req struct {
+	// Booking IDs of those present; every other held place is a no-show
+	Attended []int64 `json:"attended"`
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "github.com/HenryMarkle/gmserver/common"
+
 type UpdateLandingPageGeneralInfo_Req struct {
 	Title                 string `json:"title"`
 	StarterSentence       string `json:"starterSentence"`
@@ -8,18 +10,18 @@ type UpdateLandingPageGeneralInfo_Req struct {
 }
 
 type CreatePlan_Req struct {
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Duration    string  `json:"duration"`
-	Price       float64 `json:"price"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Duration    string       `json:"duration"`
+	Price       common.Money `json:"price"`
 }
 
 type ReplacePlan_Req struct {
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Duration    string  `json:"duration"`
-	Price       float64 `json:"price"`
-	ID          int64   `json:"id"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Duration    string       `json:"duration"`
+	Price       common.Money `json:"price"`
+	ID          int64        `json:"id"`
 }
 
 type UpdateAdsInfo_Req struct {
@@ -28,27 +30,76 @@ type UpdateAdsInfo_Req struct {
 }
 
 type CreateProduct_Req struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Marka       string  `json:"marka"`
-	Price       float64 `json:"price"`
-	CategoryID  int64   `json:"categoryId"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Marka       string       `json:"marka"`
+	Price       common.Money `json:"price"`
+	CategoryID  int64        `json:"categoryId"`
 }
 
 type UpdateProduct_Req struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Marka       string  `json:"marka"`
-	Price       float64 `json:"price"`
-	CategoryID  int64   `json:"categoryId"`
-	ID          int64   `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Marka       string       `json:"marka"`
+	Price       common.Money `json:"price"`
+	CategoryID  int64        `json:"categoryId"`
+	ID          int64        `json:"id"`
+}
+
+// Lists every image of the product once, in display order
+type ReorderProductImages_Req struct {
+	ImageIDs []int64 `json:"imageIds" binding:"required"`
 }
 
 type CreateProductCategory_Req struct {
 	Name string `json:"name"`
 }
 
+type MoveProduct_Req struct {
+	CategoryID int64 `json:"categoryId" binding:"required"`
+}
+
+type ProductIDs_Req struct {
+	ProductIDs []int64 `json:"productIds" binding:"required,min=1"`
+}
+
+type MoveProducts_Req struct {
+	ProductIDs []int64 `json:"productIds" binding:"required,min=1"`
+	CategoryID int64   `json:"categoryId" binding:"required"`
+}
+
+// Percent and Amount may be negative to lower prices
+type UpdateProductPrices_Req struct {
+	ProductIDs []int64      `json:"productIds" binding:"required,min=1"`
+	Mode       string       `json:"mode" binding:"required,oneof=percent fixed"`
+	Percent    float64      `json:"percent" binding:"required_if=Mode percent,gte=-100"`
+	Amount     common.Money `json:"amount" binding:"required_if=Mode fixed"`
+}
+
+type RenameProductCategory_Req struct {
+	ID   int64  `json:"id" binding:"required"`
+	Name string `json:"name" binding:"required,max=128"`
+}
+
+type RenameProductCategories_Req struct {
+	Categories []RenameProductCategory_Req `json:"categories" binding:"required,min=1,dive"`
+}
+
+// Categories left out are listed after the given ones
+type ReorderProductCategories_Req struct {
+	CategoryIDs []int64 `json:"categoryIds" binding:"required,min=1"`
+}
+
 type CreateQNA_Req struct {
 	Question string `json:"question"`
 	Answer   string `json:"answer"`
 }
+
+// Price left out sells the variant at the product's price. Variants are active unless Active is false.
+type ProductVariant_Req struct {
+	Name       string            `json:"name" binding:"required,max=128"`
+	SKU        string            `json:"sku" binding:"max=64"`
+	Price      *common.Money     `json:"price" binding:"omitempty,gte=0"`
+	Active     *bool             `json:"active"`
+	Attributes map[string]string `json:"attributes" binding:"dive,keys,required,max=64,endkeys,max=128"`
+}
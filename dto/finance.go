@@ -0,0 +1,22 @@
+package dto
+
+import "github.com/HenryMarkle/gmserver/common"
+
+// An empty paidAt records the payment as of now, an empty method as cash
+type Payment_Req struct {
+	Source       string       `json:"source" binding:"required,oneof=membership product pt other"`
+	SubscriberID *int64       `json:"subscriberId"`
+	PlanID       *int64       `json:"planId"`
+	Amount       common.Money `json:"amount" binding:"gt=0"`
+	Method       string       `json:"method" binding:"omitempty,oneof=cash card transfer online other"`
+	PaidAt       string       `json:"paidAt" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	Notes        string       `json:"notes" binding:"max=255"`
+}
+
+// Salaries are not entered as expenses, they come from payroll
+type Expense_Req struct {
+	Category    string       `json:"category" binding:"required,oneof=rent utilities purchases maintenance marketing other"`
+	Amount      common.Money `json:"amount" binding:"gt=0"`
+	Description string       `json:"description" binding:"max=255"`
+	SpentAt     string       `json:"spentAt" binding:"required,datetime=2006-01-02"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+// Purchases, sales and returns take a positive quantity; adjustments a signed one and a reason.
+// Stock of products with variants moves by variant.
+type StockMovement_Req struct {
+	Kind      string `json:"kind" binding:"required,oneof=purchase sale adjustment return"`
+	Quantity  int    `json:"quantity" binding:"required"`
+	Reason    string `json:"reason" binding:"max=255"`
+	VariantID *int64 `json:"variantId"`
+}
+
+// Zero disables low stock alerts of the product
+type LowStockThreshold_Req struct {
+	Threshold int `json:"threshold" binding:"gte=0"`
+}
@@ -0,0 +1,31 @@
+package dto
+
+import "github.com/HenryMarkle/gmserver/common"
+
+// Tax rate is a percentage, defaulting to INVOICE_TAX_RATE
+type InvoiceLine_Req struct {
+	Description string       `json:"description" binding:"required,max=255"`
+	Quantity    float64      `json:"quantity" binding:"gt=0"`
+	UnitPrice   common.Money `json:"unitPrice" binding:"gte=0"`
+	TaxRate     *float64     `json:"taxRate" binding:"omitempty,gte=0,lte=100"`
+}
+
+// billedTo defaults to the subscriber's name. An empty payment method issues the invoice unpaid,
+// an empty paidAt with a method marks it paid as of now.
+type Invoice_Req struct {
+	SubscriberID    *int64            `json:"subscriberId"`
+	BilledTo        string            `json:"billedTo" binding:"max=255"`
+	BilledToDetails string            `json:"billedToDetails" binding:"max=512"`
+	Lines           []InvoiceLine_Req `json:"lines" binding:"required,min=1,dive"`
+	PaymentMethod   string            `json:"paymentMethod" binding:"omitempty,oneof=cash card transfer online other"`
+	PaidAt          string            `json:"paidAt" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	Notes           string            `json:"notes" binding:"max=255"`
+}
+
+type VoidInvoice_Req struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+type CreditNote_Req struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
@@ -0,0 +1,36 @@
+package dto
+
+// Names of the standard fields and bmi are reserved for their own chart series
+type MeasurementMetric_Req struct {
+	Name  string  `json:"name" binding:"required,max=64,ne_ignore_case=weight,ne_ignore_case=height,ne_ignore_case=bodyFat,ne_ignore_case=chest,ne_ignore_case=waist,ne_ignore_case=hips,ne_ignore_case=arm,ne_ignore_case=thigh,ne_ignore_case=bmi"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit" binding:"max=16"`
+}
+
+// Lengths are in centimeters, weight in kilograms and body fat in percent.
+type SubscriberMeasurement_Req struct {
+	MeasuredAt string   `json:"measuredAt" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	Weight     *float64 `json:"weight" binding:"omitempty,gt=0"`
+	Height     *float64 `json:"height" binding:"omitempty,gt=0"`
+	BodyFat    *float64 `json:"bodyFat" binding:"omitempty,gte=0,lte=100"`
+	Chest      *float64 `json:"chest" binding:"omitempty,gt=0"`
+	Waist      *float64 `json:"waist" binding:"omitempty,gt=0"`
+	Hips       *float64 `json:"hips" binding:"omitempty,gt=0"`
+	Arm        *float64 `json:"arm" binding:"omitempty,gt=0"`
+	Thigh      *float64 `json:"thigh" binding:"omitempty,gt=0"`
+	Notes      string   `json:"notes"`
+
+	Metrics []MeasurementMetric_Req `json:"metrics" binding:"dive"`
+}
+
+type ChartPoint_Res struct {
+	Date  string  `json:"date"`
+	Value float64 `json:"value"`
+}
+
+type MeasurementChart_Res struct {
+	// Keyed by metric: the standard fields, "bmi" and custom metric names
+	Series map[string][]ChartPoint_Res `json:"series"`
+	// Change between the first and last point of each series
+	Change map[string]float64 `json:"change"`
+}
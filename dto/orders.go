@@ -0,0 +1,17 @@
+package dto
+
+type Checkout_Req struct {
+	PromoCode string `json:"promoCode" binding:"max=64"`
+	Notes     string `json:"notes" binding:"max=255"`
+}
+
+// Paying needs a payment method, defaulting to cash. Cancelling a paid order refunds it with the method.
+type OrderStatus_Req struct {
+	Status        string `json:"status" binding:"required,oneof=paid ready completed cancelled"`
+	PaymentMethod string `json:"paymentMethod" binding:"omitempty,oneof=cash card transfer online other"`
+	Reason        string `json:"reason" binding:"max=255"`
+}
+
+type CancelOrder_Req struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
@@ -0,0 +1,13 @@
+package dto
+
+// Where to pay an online payment
+type OnlinePayment_Res struct {
+	IntentID  int64  `json:"intentId"`
+	SessionID string `json:"sessionId"`
+	URL       string `json:"url"`
+}
+
+// Settles a session of the fake payment provider
+type FakePayment_Req struct {
+	Status string `json:"status" binding:"required,oneof=succeeded failed expired"`
+}
@@ -0,0 +1,38 @@
+package dto
+
+import "github.com/HenryMarkle/gmserver/common"
+
+type StaffPayRate_Req struct {
+	PayType    string       `json:"payType" binding:"required,oneof=monthly hourly"`
+	HourlyRate common.Money `json:"hourlyRate" binding:"gte=0"`
+}
+
+type Shift_Req struct {
+	UserID   int64  `json:"userId" binding:"required"`
+	StartsAt string `json:"startsAt" binding:"required,datetime=2006-01-02 15:04:05"`
+	EndsAt   string `json:"endsAt" binding:"required,datetime=2006-01-02 15:04:05"`
+	Notes    string `json:"notes" binding:"max=255"`
+}
+
+type ClockIn_Req struct {
+	Notes string `json:"notes" binding:"max=255"`
+}
+
+// An empty clockOut leaves the user clocked in
+type TimeEntry_Req struct {
+	ClockIn  string `json:"clockIn" binding:"required,datetime=2006-01-02 15:04:05"`
+	ClockOut string `json:"clockOut" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	Notes    string `json:"notes" binding:"max=255"`
+}
+
+type PayAdjustment_Req struct {
+	UserID int64        `json:"userId" binding:"required"`
+	Period string       `json:"period" binding:"required,datetime=2006-01"`
+	Kind   string       `json:"kind" binding:"required,oneof=bonus deduction"`
+	Amount common.Money `json:"amount" binding:"gt=0"`
+	Reason string       `json:"reason" binding:"max=255"`
+}
+
+type PayrollRun_Req struct {
+	Period string `json:"period" binding:"required,datetime=2006-01"`
+}
@@ -0,0 +1,38 @@
+package dto
+
+import "github.com/HenryMarkle/gmserver/common"
+
+type OpenCashDrawer_Req struct {
+	OpeningFloat common.Money `json:"openingFloat" binding:"gte=0"`
+}
+
+type CloseCashDrawer_Req struct {
+	CountedCash common.Money `json:"countedCash" binding:"gte=0"`
+	Notes       string       `json:"notes" binding:"max=255"`
+}
+
+// A product, one of its variants, or a membership renewal of the sale's subscriber.
+// Renewals are priced at the plan's price, or the subscriber's bucketPrice without a plan, unless Price is given.
+type PosItem_Req struct {
+	Kind      string        `json:"kind" binding:"required,oneof=product membership"`
+	ProductID *int64        `json:"productId" binding:"required_if=Kind product"`
+	VariantID *int64        `json:"variantId"`
+	Quantity  int           `json:"quantity" binding:"gte=0"`
+	PlanID    *int64        `json:"planId"`
+	Days      int           `json:"days" binding:"required_if=Kind membership,gte=0"`
+	Price     *common.Money `json:"price" binding:"omitempty,gte=0"`
+}
+
+// Cash may be tendered over the total; the difference is given back as change
+type PosPayment_Req struct {
+	Method string       `json:"method" binding:"required,oneof=cash card transfer other"`
+	Amount common.Money `json:"amount" binding:"gt=0"`
+}
+
+// Sold to a subscriber, or to a walk-in when SubscriberID is left out
+type PosSale_Req struct {
+	SubscriberID *int64           `json:"subscriberId"`
+	CustomerName string           `json:"customerName" binding:"max=255"`
+	Items        []PosItem_Req    `json:"items" binding:"required,min=1,dive"`
+	Payments     []PosPayment_Req `json:"payments" binding:"dive"`
+}
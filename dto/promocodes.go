@@ -0,0 +1,44 @@
+package dto
+
+import "github.com/HenryMarkle/gmserver/common"
+
+// Codes are matched case-insensitively. Zero limits are unlimited; empty plan and category
+// lists apply the code to every plan or product.
+type PromoCode_Req struct {
+	Code         string `json:"code" binding:"required,max=64"`
+	Description  string `json:"description" binding:"max=255"`
+	DiscountType string `json:"discountType" binding:"required,oneof=percent fixed"`
+	// Set for percent codes
+	DiscountPercent float64 `json:"discountPercent" binding:"gte=0,lte=100"`
+	// Set for fixed codes
+	DiscountAmount   common.Money `json:"discountAmount" binding:"gte=0"`
+	AppliesTo        string       `json:"appliesTo" binding:"omitempty,oneof=all plans products"`
+	ValidFrom        string       `json:"validFrom" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	ValidUntil       string       `json:"validUntil" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	MaxUses          int          `json:"maxUses" binding:"gte=0"`
+	MaxUsesPerMember int          `json:"maxUsesPerMember" binding:"gte=0"`
+	// Active by default
+	Active      *bool   `json:"active"`
+	PlanIDs     []int64 `json:"planIds"`
+	CategoryIDs []int64 `json:"categoryIds"`
+}
+
+type BasketPriceLine_Res struct {
+	BasketID  int64        `json:"basketId"`
+	ProductID int64        `json:"productId"`
+	VariantID *int64       `json:"variantId"`
+	Name      string       `json:"name"`
+	Quantity  int          `json:"quantity"`
+	UnitPrice common.Money `json:"unitPrice"`
+	Amount    common.Money `json:"amount"`
+	// Whether the promo code applies to the line
+	Discounted bool `json:"discounted"`
+}
+
+type BasketPrice_Res struct {
+	Lines     []BasketPriceLine_Res `json:"lines"`
+	Subtotal  common.Money          `json:"subtotal"`
+	Discount  common.Money          `json:"discount"`
+	Total     common.Money          `json:"total"`
+	PromoCode string                `json:"promoCode"`
+}
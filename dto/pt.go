@@ -0,0 +1,52 @@
+package dto
+
+import "github.com/HenryMarkle/gmserver/common"
+
+type TrainerAvailability_Req struct {
+	Weekday   int    `json:"weekday" binding:"gte=0,lte=6"`
+	StartTime string `json:"startTime" binding:"required,datetime=15:04"`
+	EndTime   string `json:"endTime" binding:"required,datetime=15:04"`
+}
+
+type ReplaceTrainerAvailability_Req struct {
+	Windows []TrainerAvailability_Req `json:"windows" binding:"dive"`
+}
+
+type TrainerTimeBlock_Req struct {
+	StartsAt string `json:"startsAt" binding:"required,datetime=2006-01-02 15:04:05"`
+	EndsAt   string `json:"endsAt" binding:"required,datetime=2006-01-02 15:04:05"`
+	Reason   string `json:"reason" binding:"max=255"`
+}
+
+// Session length defaults to PT_SESSION_MINUTES
+type PTPackage_Req struct {
+	SubscriberID   int64        `json:"subscriberId" binding:"required"`
+	TrainerID      *int64       `json:"trainerId"`
+	TotalSessions  int          `json:"totalSessions" binding:"required,gte=1"`
+	SessionMinutes int          `json:"sessionMinutes" binding:"omitempty,gte=15,lte=480"`
+	Price          common.Money `json:"price" binding:"gte=0"`
+	ExpiresAt      string       `json:"expiresAt" binding:"omitempty,datetime=2006-01-02"`
+	// How the price was paid, cash by default
+	PaymentMethod string `json:"paymentMethod" binding:"omitempty,oneof=cash card transfer online other"`
+}
+
+// Duration defaults to the package's session length, or PT_SESSION_MINUTES without a package
+type BookPTSession_Req struct {
+	TrainerID       int64  `json:"trainerId" binding:"required"`
+	SubscriberID    int64  `json:"subscriberId" binding:"required"`
+	PackageID       *int64 `json:"packageId"`
+	StartsAt        string `json:"startsAt" binding:"required,datetime=2006-01-02 15:04:05"`
+	DurationMinutes int    `json:"durationMinutes" binding:"omitempty,gte=15,lte=480"`
+	Notes           string `json:"notes" binding:"max=255"`
+}
+
+type PayPTSessionCharge_Req struct {
+	// Cash by default
+	Method string `json:"method" binding:"omitempty,oneof=cash card transfer online other"`
+}
+
+type CancelPTSession_Res struct {
+	Late            bool         `json:"late"`
+	Charge          common.Money `json:"charge"`
+	SessionConsumed bool         `json:"sessionConsumed"`
+}
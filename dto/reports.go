@@ -0,0 +1,82 @@
+package dto
+
+import "github.com/HenryMarkle/gmserver/common"
+
+type ReportBucket_Res struct {
+	// The day, the Monday of the week, or the month (YYYY-MM)
+	Period    string                  `json:"period"`
+	Total     common.Money            `json:"total"`
+	Breakdown map[string]common.Money `json:"breakdown"`
+	// Total in the gym's currency and locale
+	FormattedTotal string `json:"formattedTotal"`
+}
+
+type ProfitLossBucket_Res struct {
+	Period   string       `json:"period"`
+	Income   common.Money `json:"income"`
+	Expenses common.Money `json:"expenses"`
+	Profit   common.Money `json:"profit"`
+	// Profit in the gym's currency and locale
+	FormattedProfit string `json:"formattedProfit"`
+}
+
+type ProfitLoss_Res struct {
+	From               string                  `json:"from"`
+	To                 string                  `json:"to"`
+	Income             common.Money            `json:"income"`
+	Expenses           common.Money            `json:"expenses"`
+	Profit             common.Money            `json:"profit"`
+	IncomeBySource     map[string]common.Money `json:"incomeBySource"`
+	ExpensesByCategory map[string]common.Money `json:"expensesByCategory"`
+	Buckets            []ProfitLossBucket_Res  `json:"buckets"`
+	Currency           string                  `json:"currency"`
+	// Totals in the gym's currency and locale
+	FormattedIncome   string `json:"formattedIncome"`
+	FormattedExpenses string `json:"formattedExpenses"`
+	FormattedProfit   string `json:"formattedProfit"`
+}
+
+type Currency_Res struct {
+	// ISO 4217 code, empty if not configured
+	Code   string `json:"code"`
+	Locale string `json:"locale"`
+	// How 1234.5 is displayed
+	Example string `json:"example"`
+}
+
+type MemberGrowthPoint_Res struct {
+	// The day, the Monday of the week, or the month (YYYY-MM)
+	Period string `json:"period"`
+	New    int    `json:"new"`
+	// Active members at the end of the period
+	Active int `json:"active"`
+}
+
+type MemberChurnPoint_Res struct {
+	Period        string `json:"period"`
+	Left          int    `json:"left"`
+	ActiveAtStart int    `json:"activeAtStart"`
+	// Percentage of the members active at the start of the period that left during it
+	ChurnRate float64 `json:"churnRate"`
+}
+
+type KPI_Res struct {
+	Value    float64 `json:"value"`
+	Previous float64 `json:"previous"`
+	// Percentage change from the previous period, null when the previous value is 0
+	Change *float64 `json:"change"`
+}
+
+// The current period is the month so far, the previous one the same span of the previous month
+type KPIs_Res struct {
+	PeriodStart             string  `json:"periodStart"`
+	PreviousPeriodStart     string  `json:"previousPeriodStart"`
+	ActiveMembers           KPI_Res `json:"activeMembers"`
+	NewMembers              KPI_Res `json:"newMembers"`
+	ExpiringIn7Days         KPI_Res `json:"expiringIn7Days"`
+	MonthlyRecurringRevenue KPI_Res `json:"monthlyRecurringRevenue"`
+	AverageRevenuePerMember KPI_Res `json:"averageRevenuePerMember"`
+	// Percentage of the members active at the start of the period that still are
+	RetentionRate KPI_Res `json:"retentionRate"`
+	Income        KPI_Res `json:"income"`
+}
@@ -1,16 +1,76 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/HenryMarkle/gmserver/common"
+)
+
 type CreateSubscriber_Req struct {
-	Name          string  `json:"name"`
-	Surname       string  `json:"surname"`
-	StartedAt     string  `json:"startedAt"`
-	DeletedAt     string  `json:"deletedAt"`
-	UpdatedAt     string  `json:"updatedAt"`
-	EndsAt        string  `json:"endsAt"`
-	Gender        string  `json:"gender"`
-	Age           int     `json:"age"`
-	PaymentAmount float64 `json:"paymentAmount"`
-	BucketPrice   float64 `json:"bucketPrice"`
-	DaysLeft      int     `json:"daysLeft"`
-	Duration      int     `json:"duration"`
+	Name          string       `json:"name"`
+	Surname       string       `json:"surname"`
+	StartedAt     string       `json:"startedAt"`
+	DeletedAt     string       `json:"deletedAt"`
+	UpdatedAt     string       `json:"updatedAt"`
+	EndsAt        string       `json:"endsAt"`
+	Gender        string       `json:"gender"`
+	Age           int          `json:"age"`
+	PaymentAmount common.Money `json:"paymentAmount"`
+	BucketPrice   common.Money `json:"bucketPrice"`
+	DaysLeft      int          `json:"daysLeft"`
+	Duration      int          `json:"duration"`
+
+	Phone                 string `json:"phone" binding:"omitempty,e164"`
+	Email                 string `json:"email" binding:"omitempty,email"`
+	Address               string `json:"address"`
+	EmergencyContactName  string `json:"emergencyContactName"`
+	EmergencyContactPhone string `json:"emergencyContactPhone" binding:"omitempty,e164"`
+	DateOfBirth           string `json:"dateOfBirth" binding:"omitempty,datetime=2006-01-02"`
+
+	Preferences CommunicationPreferences_Req `json:"preferences"`
+
+	// Recorded with the payment amount in the income ledger
+	PlanID        *int64 `json:"planId"`
+	PaymentMethod string `json:"paymentMethod" binding:"omitempty,oneof=cash card transfer online other"`
+	// Reduces the bucket price; codes restricted to plans need planId
+	PromoCode string `json:"promoCode"`
+}
+
+type CommunicationPreferences_Req struct {
+	MarketingEmail    bool `json:"marketingEmail"`
+	MarketingSMS      bool `json:"marketingSms"`
+	MarketingWhatsApp bool `json:"marketingWhatsapp"`
+	ServiceEmail      bool `json:"serviceEmail"`
+	ServiceSMS        bool `json:"serviceSms"`
+	ServiceWhatsApp   bool `json:"serviceWhatsapp"`
+}
+
+// A row of an import file. Rows are held to more than CreateSubscriber_Req requires, as nobody reviews them before
+// they're saved; these fields replace the embedded ones, whose contact details are validated as well.
+type ImportSubscriberRow_Req struct {
+	CreateSubscriber_Req
+
+	Name          string       `binding:"required"`
+	Surname       string       `binding:"required"`
+	Gender        string       `binding:"required"`
+	StartedAt     time.Time    `binding:"required"`
+	EndsAt        time.Time    `binding:"required,gtefield=StartedAt"`
+	Age           int          `binding:"gte=0,lte=150"`
+	PaymentAmount common.Money `binding:"gte=0"`
+	BucketPrice   common.Money `binding:"gte=0"`
+}
+
+type ImportSubscribersRowError_Res struct {
+	// Spreadsheet row number, counting the header as row 1
+	Row    int      `json:"row"`
+	Errors []string `json:"errors"`
+}
+
+type ImportSubscribers_Res struct {
+	DryRun  bool                            `json:"dryRun"`
+	Rows    int                             `json:"rows"`
+	Created int                             `json:"created"`
+	Updated int                             `json:"updated"`
+	Failed  int                             `json:"failed"`
+	Errors  []ImportSubscribersRowError_Res `json:"errors"`
 }
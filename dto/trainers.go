@@ -7,6 +7,9 @@ type CreateTrainer_Req struct {
 	Instigram   string `json:"instigram"`
 	Facebook    string `json:"facebook"`
 	Twitter     string `json:"twitter"`
+	// Lower comes first on the public site
+	DisplayOrder int      `json:"displayOrder"`
+	Specialties  []string `json:"specialties"`
 }
 
 type UpdateTrainer_Req struct {
@@ -16,5 +19,30 @@ type UpdateTrainer_Req struct {
 	Instigram   string `json:"instigram"`
 	Facebook    string `json:"facebook"`
 	Twitter     string `json:"twitter"`
-	ID          int64  `json:"id"`
+	// Lower comes first on the public site
+	DisplayOrder int      `json:"displayOrder"`
+	Specialties  []string `json:"specialties"`
+	ID           int64    `json:"id"`
+}
+
+// A null userId unlinks the trainer
+type LinkTrainerUser_Req struct {
+	UserID *int64 `json:"userId"`
+}
+
+type AssignTrainerClient_Req struct {
+	SubscriberID int64 `json:"subscriberId" binding:"required"`
+}
+
+// Dates are in common.DateLayout, expiresAt may be empty for certifications that don't expire
+type TrainerCertification_Req struct {
+	Name      string `json:"name" binding:"required"`
+	Issuer    string `json:"issuer"`
+	IssuedAt  string `json:"issuedAt"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// Trainer IDs in the order they should be displayed
+type ReorderTrainers_Req struct {
+	IDs []int64 `json:"ids" binding:"required"`
 }
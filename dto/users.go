@@ -34,3 +34,8 @@ type MarkMessageAsRead_Req struct {
 	MessageID int64 `json:"messageId"`
 	UserID    int64 `json:"userId"`
 }
+
+type DeletedUser_Res struct {
+	User_Res
+	DeletedAt string `json:"deletedAt"`
+}
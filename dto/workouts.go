@@ -0,0 +1,49 @@
+package dto
+
+type WorkoutProgramExercise_Req struct {
+	ExerciseID  int64    `json:"exerciseId" binding:"required"`
+	Sets        int      `json:"sets" binding:"required,gte=1"`
+	Reps        int      `json:"reps" binding:"gte=0"`
+	RestSeconds int      `json:"restSeconds" binding:"gte=0"`
+	Load        *float64 `json:"load" binding:"omitempty,gte=0"`
+	Notes       string   `json:"notes" binding:"max=255"`
+}
+
+type WorkoutProgramDay_Req struct {
+	Week      int                          `json:"week" binding:"required,gte=1"`
+	Day       int                          `json:"day" binding:"required,gte=1,lte=7"`
+	Title     string                       `json:"title" binding:"max=255"`
+	Exercises []WorkoutProgramExercise_Req `json:"exercises" binding:"dive"`
+}
+
+type WorkoutProgram_Req struct {
+	Name        string                  `json:"name" binding:"required,max=255"`
+	Description string                  `json:"description"`
+	Weeks       int                     `json:"weeks" binding:"required,gte=1,lte=52"`
+	IsTemplate  bool                    `json:"isTemplate"`
+	Days        []WorkoutProgramDay_Req `json:"days" binding:"dive"`
+}
+
+type CloneWorkoutProgram_Req struct {
+	// Defaults to the name of the original
+	Name       string `json:"name" binding:"max=255"`
+	IsTemplate bool   `json:"isTemplate"`
+}
+
+type AssignWorkoutProgram_Req struct {
+	ProgramID    int64  `json:"programId" binding:"required"`
+	SubscriberID int64  `json:"subscriberId" binding:"required"`
+	StartDate    string `json:"startDate" binding:"required,datetime=2006-01-02"`
+}
+
+type WorkoutSet_Req struct {
+	Reps   int      `json:"reps" binding:"required,gte=1"`
+	Weight float64  `json:"weight" binding:"gte=0"`
+	RPE    *float64 `json:"rpe" binding:"omitempty,gte=1,lte=10"`
+}
+
+type LogWorkoutSets_Req struct {
+	ExerciseID  int64            `json:"exerciseId" binding:"required"`
+	PerformedAt string           `json:"performedAt" binding:"omitempty,datetime=2006-01-02 15:04:05"`
+	Sets        []WorkoutSet_Req `json:"sets" binding:"required,min=1,dive"`
+}
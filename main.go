@@ -9,6 +9,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/HenryMarkle/gmserver/api"
 	"github.com/HenryMarkle/gmserver/db"
@@ -17,6 +18,11 @@ import (
 
 func main() {
 	defer db.DB.Close()
+
+	go api.RunTrashPurge(time.Hour)
+	go api.RunClassSessionGeneration(time.Hour)
+	go api.RunCertificationReminders(time.Hour)
+
 	server := gin.Default()
 
 	server.Use(api.CORS())
@@ -30,6 +36,9 @@ func main() {
 	{
 		v1 := server.Group("/v1")
 		v1.POST("/signin", api.SignIn)
+		v1.POST("/payments/webhook", api.PaymentWebhook)
+		v1.GET("/payments/fake/:sessionId", api.GetFakeCheckout)
+		v1.POST("/payments/fake/:sessionId", api.SettleFakeCheckout)
 
 		{
 			auth := v1.Group("/auth")
@@ -41,6 +50,7 @@ func main() {
 
 			{
 				comments := auth.Group("/comments")
+				comments.Use(api.Auth())
 
 				_ = comments.GET("/all", api.GetAllComments)
 				_ = comments.GET("/user/:id", api.GetAllCommentsOfManager)
@@ -57,10 +67,13 @@ func main() {
 				_ = customers.GET("/count-expired", api.CountCustomersExpiring)
 				_ = customers.POST("/new", api.CreateCustomer)
 				_ = customers.GET("/all", api.GetAllCustomers)
+				_ = customers.GET("/export", api.ExportCustomers)
+				_ = customers.POST("/import", api.ImportCustomers)
 				_ = customers.GET("/:id", api.GetCustomerByID)
 				_ = customers.DELETE("/:id", api.DeleteCustomerByID)
 				_ = customers.DELETE("/delist/:id", api.MarkCustomerAsDeleted)
 				_ = customers.PATCH("/", api.UpdateCustomerByID)
+				_ = customers.PATCH("/preferences/:id", api.UpdateCustomerPreferences)
 			}
 			{
 				events := auth.Group("/events")
@@ -73,6 +86,16 @@ func main() {
 			{
 				_ = auth.Group("/users")
 			}
+			{
+				trainers := v1.Group("/trainers")
+
+				_ = trainers.GET("", api.GetTrainers)
+				_ = trainers.GET("/:id", api.GetTrainerProfile)
+				_ = trainers.GET("/:id/photo", api.GetTrainerPhoto)
+			}
+			{
+				_ = v1.GET("/currency", api.GetCurrency)
+			}
 			{
 				trainers := auth.Group("/trainers")
 
@@ -81,6 +104,23 @@ func main() {
 				_ = trainers.PATCH("/update", api.ReplaceTrainerById)
 				_ = trainers.DELETE("/:id", api.DeleteTrainerById)
 			}
+			{
+				trainers := auth.Group("/trainers")
+				trainers.Use(api.Auth())
+
+				_ = trainers.PATCH("/:id/user", api.AdminOnly(), api.LinkTrainerUser)
+				_ = trainers.GET("/:id/clients", api.AdminOnly(), api.GetTrainerClients)
+				_ = trainers.POST("/:id/clients", api.AdminOnly(), api.AssignTrainerClient)
+				_ = trainers.DELETE("/:id/clients/:subscriberId", api.AdminOnly(), api.UnassignTrainerClient)
+				_ = trainers.GET("/my-clients", api.GetMyClients)
+				_ = trainers.PUT("/order", api.AdminOnly(), api.ReorderTrainers)
+				_ = trainers.POST("/:id/photo", api.AdminOnly(), api.UploadTrainerPhoto)
+				_ = trainers.DELETE("/:id/photo", api.AdminOnly(), api.DeleteTrainerPhoto)
+				_ = trainers.GET("/:id/certifications", api.GetTrainerCertifications)
+				_ = trainers.POST("/:id/certifications", api.AdminOnly(), api.CreateTrainerCertification)
+				_ = trainers.PATCH("/certifications/:id", api.AdminOnly(), api.UpdateTrainerCertification)
+				_ = trainers.DELETE("/certifications/:id", api.AdminOnly(), api.DeleteTrainerCertification)
+			}
 			{
 				exercises := v1.Group("/exercises")
 
@@ -113,6 +153,9 @@ func main() {
 				_ = dash.GET("/ads", api.GetAdsInfo)
 				_ = dash.GET("/products", api.GetHomeProducts)
 				_ = dash.GET("/product/:id", api.GetProductByID)
+				_ = dash.GET("/product/:id/images", api.GetProductImages)
+				_ = dash.GET("/product/:id/images/:imageId", api.GetProductImage)
+				_ = dash.GET("/product/:id/variants", api.GetProductVariants)
 				_ = dash.GET("/product/categories", api.GetProductCategories)
 				_ = dash.GET("/products-in-categories", api.GetCategoryProducts)
 				_ = dash.GET("/products/category", api.GetProductsOfCategory)
@@ -132,9 +175,23 @@ func main() {
 				_ = dash.POST("/product/new", api.CreateHomeProduct)
 				_ = dash.DELETE("/product/:id", api.DeleteHomeProductByID)
 				_ = dash.PATCH("/product", api.UpdateHomeProduct)
+				_ = dash.POST("/product/:id/images", api.Auth(), api.AdminOnly(), api.UploadProductImage)
+				_ = dash.PATCH("/product/:id/images/order", api.Auth(), api.AdminOnly(), api.ReorderProductImages)
+				_ = dash.PATCH("/product/:id/images/:imageId/primary", api.Auth(), api.AdminOnly(), api.SetPrimaryProductImage)
+				_ = dash.DELETE("/product/:id/images/:imageId", api.Auth(), api.AdminOnly(), api.DeleteProductImage)
+				_ = dash.POST("/product/:id/variants", api.Auth(), api.AdminOnly(), api.CreateProductVariant)
+				_ = dash.PATCH("/product/:id/variants/:variantId", api.Auth(), api.AdminOnly(), api.UpdateProductVariant)
+				_ = dash.DELETE("/product/:id/variants/:variantId", api.Auth(), api.AdminOnly(), api.DeleteProductVariant)
 				_ = dash.POST("/product-category/new", api.CreateProductCategory)
 				_ = dash.DELETE("/product-category/:id", api.DeleteProductCategoryByID)
 				_ = dash.DELETE("/products-of-category/:id", api.DeleteProductsOfCategory)
+				_ = dash.PATCH("/product/:id/category", api.Auth(), api.AdminOnly(), api.MoveProductToCategory)
+				_ = dash.POST("/products/delete", api.Auth(), api.AdminOnly(), api.DeleteHomeProduct)
+				_ = dash.POST("/products/restore", api.Auth(), api.AdminOnly(), api.RestoreHomeProducts)
+				_ = dash.POST("/products/move", api.Auth(), api.AdminOnly(), api.MoveProductsToCategory)
+				_ = dash.POST("/products/prices", api.Auth(), api.AdminOnly(), api.UpdateProductPrices)
+				_ = dash.PATCH("/product-categories/names", api.Auth(), api.AdminOnly(), api.RenameProductCategories)
+				_ = dash.PATCH("/product-categories/order", api.Auth(), api.AdminOnly(), api.ReorderProductCategories)
 				_ = dash.PATCH("/contacts", api.UpdateContacts)
 				_ = dash.POST("/qna", api.AddQNA)
 				_ = dash.DELETE("/qna/:id", api.DeleteQNA)
@@ -143,11 +200,17 @@ func main() {
 				basket := auth.Group("/basket")
 
 				_ = basket.GET("", api.GetUserBasket)
+				_ = basket.GET("/price", api.Auth(), api.GetUserBasketPrice)
 				_ = basket.GET("/:basketId", api.GetUserBasketByID)
 				_ = basket.POST("/", api.AddToUserBasket)
 				_ = basket.PATCH("/increment", api.IncrementBasketQuantity)
 				_ = basket.PATCH("/decrement", api.DecrementBasketQuantity)
 				_ = basket.DELETE("/", api.DeleteBasket)
+				_ = basket.POST("/checkout", api.Auth(), api.Checkout)
+				_ = basket.GET("/orders", api.Auth(), api.GetUserOrders)
+				_ = basket.GET("/orders/:id", api.Auth(), api.GetUserOrder)
+				_ = basket.POST("/orders/:id/cancel", api.Auth(), api.CancelUserOrder)
+				_ = basket.POST("/orders/:id/pay", api.Auth(), api.PayUserOrder)
 			}
 			{
 				advice := v1.GET("/advice")
@@ -178,6 +241,226 @@ func main() {
 
 				_ = blog.POST("/image/:id", api.UploadBlogImage)
 			}
+			{
+				files := auth.Group("/subscriber-files")
+				files.Use(api.Auth())
+
+				_ = files.GET("/photo/:id", api.GetSubscriberPhoto)
+				_ = files.POST("/photo/:id", api.UploadSubscriberPhoto)
+				_ = files.DELETE("/photo/:id", api.DeleteSubscriberPhoto)
+				_ = files.GET("/documents/:id", api.GetSubscriberDocuments)
+				_ = files.POST("/documents/:id", api.UploadSubscriberDocument)
+				_ = files.GET("/document/:attachmentId", api.GetSubscriberDocument)
+				_ = files.DELETE("/document/:attachmentId", api.DeleteSubscriberDocument)
+			}
+			{
+				measurements := auth.Group("/measurements")
+				measurements.Use(api.Auth())
+
+				_ = measurements.GET("/sub/:id", api.GetSubscriberMeasurements)
+				_ = measurements.GET("/sub/:id/chart", api.GetSubscriberMeasurementChart)
+				_ = measurements.POST("/sub/:id", api.CreateSubscriberMeasurement)
+				_ = measurements.PATCH("/:id", api.UpdateSubscriberMeasurement)
+				_ = measurements.DELETE("/:id", api.DeleteSubscriberMeasurement)
+			}
+			{
+				workouts := auth.Group("/workouts")
+				workouts.Use(api.Auth())
+
+				_ = workouts.GET("/programs", api.GetWorkoutPrograms)
+				_ = workouts.GET("/programs/:id", api.GetWorkoutProgramByID)
+				_ = workouts.POST("/programs", api.CreateWorkoutProgram)
+				_ = workouts.PUT("/programs/:id", api.ReplaceWorkoutProgram)
+				_ = workouts.DELETE("/programs/:id", api.DeleteWorkoutProgram)
+				_ = workouts.POST("/programs/:id/clone", api.CloneWorkoutProgram)
+				_ = workouts.POST("/assignments", api.AssignWorkoutProgram)
+				_ = workouts.GET("/assignments/sub/:id", api.GetSubscriberWorkoutAssignments)
+				_ = workouts.DELETE("/assignments/:id", api.EndWorkoutAssignment)
+				_ = workouts.GET("/today/sub/:id", api.GetTodayWorkout)
+			}
+			{
+				logs := auth.Group("/workout-logs")
+				logs.Use(api.Auth())
+
+				_ = logs.GET("/sub/:id", api.GetWorkoutSets)
+				_ = logs.POST("/sub/:id", api.LogWorkoutSets)
+				_ = logs.GET("/sub/:id/records", api.GetPersonalRecords)
+				_ = logs.GET("/sub/:id/volume", api.GetWeeklyVolume)
+				_ = logs.GET("/sub/:id/history/:exerciseId", api.GetExerciseHistory)
+				_ = logs.DELETE("/:id", api.DeleteWorkoutSet)
+			}
+			{
+				classes := auth.Group("/classes")
+				classes.Use(api.Auth())
+
+				_ = classes.GET("/types", api.GetClassTypes)
+				_ = classes.POST("/types", api.CreateClassType)
+				_ = classes.PATCH("/types/:id", api.UpdateClassType)
+				_ = classes.DELETE("/types/:id", api.DeleteClassType)
+				_ = classes.GET("/schedules", api.GetClassSchedules)
+				_ = classes.POST("/schedules", api.CreateClassSchedule)
+				_ = classes.PATCH("/schedules/:id", api.UpdateClassSchedule)
+				_ = classes.DELETE("/schedules/:id", api.DeactivateClassSchedule)
+				_ = classes.GET("/exceptions", api.GetClassScheduleExceptions)
+				_ = classes.POST("/exceptions", api.CreateClassScheduleException)
+				_ = classes.DELETE("/exceptions/:id", api.DeleteClassScheduleException)
+				_ = classes.GET("/sessions", api.GetClassSessions)
+				_ = classes.PATCH("/sessions/:id/cancel", api.CancelClassSession)
+				_ = classes.GET("/sessions/:id/bookings", api.GetClassSessionBookings)
+				_ = classes.POST("/sessions/:id/book", api.BookClassSession)
+				_ = classes.PATCH("/sessions/:id/attendance", api.MarkClassAttendance)
+				_ = classes.DELETE("/bookings/:id", api.CancelClassBooking)
+				_ = classes.GET("/no-shows", api.GetClassNoShowStats)
+			}
+			{
+				pt := auth.Group("/pt")
+				pt.Use(api.Auth())
+
+				_ = pt.GET("/trainers/:id/availability", api.GetTrainerAvailability)
+				_ = pt.PUT("/trainers/:id/availability", api.ReplaceTrainerAvailability)
+				_ = pt.GET("/trainers/:id/blocks", api.GetTrainerTimeBlocks)
+				_ = pt.POST("/trainers/:id/blocks", api.CreateTrainerTimeBlock)
+				_ = pt.GET("/trainers/:id/slots", api.GetTrainerFreeSlots)
+				_ = pt.DELETE("/blocks/:id", api.DeleteTrainerTimeBlock)
+				_ = pt.GET("/packages/sub/:id", api.GetSubscriberPTPackages)
+				_ = pt.POST("/packages", api.CreatePTPackage)
+				_ = pt.GET("/sessions", api.GetPTSessions)
+				_ = pt.POST("/sessions", api.BookPTSession)
+				_ = pt.PATCH("/sessions/:id/complete", api.CompletePTSession)
+				_ = pt.PATCH("/sessions/:id/no-show", api.MarkPTSessionNoShow)
+				_ = pt.DELETE("/sessions/:id", api.CancelPTSession)
+				_ = pt.POST("/sessions/:id/charge", api.PayPTSessionCharge)
+			}
+			{
+				shifts := auth.Group("/shifts")
+				shifts.Use(api.Auth())
+
+				_ = shifts.GET("", api.GetShifts)
+				_ = shifts.GET("/mine", api.GetMyShifts)
+				_ = shifts.POST("", api.AdminOnly(), api.CreateShift)
+				_ = shifts.PATCH("/:id", api.AdminOnly(), api.UpdateShift)
+				_ = shifts.DELETE("/:id", api.AdminOnly(), api.DeleteShift)
+			}
+			{
+				clock := auth.Group("/timeclock")
+				clock.Use(api.Auth())
+
+				_ = clock.POST("/in", api.ClockIn)
+				_ = clock.POST("/out", api.ClockOut)
+				_ = clock.GET("/status", api.GetClockStatus)
+				_ = clock.GET("/entries", api.GetTimeEntries)
+				_ = clock.PATCH("/entries/:id", api.AdminOnly(), api.UpdateTimeEntry)
+				_ = clock.DELETE("/entries/:id", api.AdminOnly(), api.DeleteTimeEntry)
+			}
+			{
+				payroll := auth.Group("/payroll")
+				payroll.Use(api.Auth(), api.AdminOnly())
+
+				_ = payroll.GET("/total", api.GetTotalSalaries)
+				_ = payroll.GET("/rates", api.GetStaffPayRates)
+				_ = payroll.PUT("/rates/:id", api.SetStaffPayRate)
+				_ = payroll.GET("/adjustments", api.GetPayAdjustments)
+				_ = payroll.POST("/adjustments", api.CreatePayAdjustment)
+				_ = payroll.DELETE("/adjustments/:id", api.DeletePayAdjustment)
+				_ = payroll.GET("/preview", api.GetPayrollPreview)
+				_ = payroll.GET("/runs", api.GetPayrollRuns)
+				_ = payroll.POST("/runs", api.CreatePayrollRun)
+				_ = payroll.GET("/runs/:id", api.GetPayrollRunByID)
+				_ = payroll.DELETE("/runs/:id", api.DeletePayrollRun)
+			}
+			{
+				finance := auth.Group("/finance")
+				finance.Use(api.Auth(), api.AdminOnly())
+
+				_ = finance.GET("/payments", api.GetPayments)
+				_ = finance.POST("/payments", api.CreatePayment)
+				_ = finance.DELETE("/payments/:id", api.DeletePayment)
+				_ = finance.POST("/payments/:id/invoice", api.CreatePaymentInvoice)
+				_ = finance.GET("/expenses", api.GetExpenses)
+				_ = finance.POST("/expenses", api.CreateExpense)
+				_ = finance.PATCH("/expenses/:id", api.UpdateExpense)
+				_ = finance.DELETE("/expenses/:id", api.DeleteExpense)
+			}
+			{
+				payments := auth.Group("/payments")
+				payments.Use(api.Auth(), api.AdminOnly())
+
+				_ = payments.GET("/intents", api.GetPaymentIntents)
+				_ = payments.GET("/intents/:id", api.GetPaymentIntent)
+				_ = payments.POST("/subscribers/:id/checkout", api.CreateMembershipCheckout)
+			}
+			{
+				promoCodes := auth.Group("/promo-codes")
+				promoCodes.Use(api.Auth(), api.AdminOnly())
+
+				_ = promoCodes.GET("", api.GetPromoCodes)
+				_ = promoCodes.POST("", api.CreatePromoCode)
+				_ = promoCodes.GET("/:id", api.GetPromoCode)
+				_ = promoCodes.PATCH("/:id", api.UpdatePromoCode)
+				_ = promoCodes.DELETE("/:id", api.DeletePromoCode)
+				_ = promoCodes.GET("/:id/redemptions", api.GetPromoRedemptions)
+			}
+			{
+				inventory := auth.Group("/inventory")
+				inventory.Use(api.Auth(), api.AdminOnly())
+
+				_ = inventory.GET("/low-stock", api.GetLowStockProducts)
+				_ = inventory.GET("/products/:id/movements", api.GetStockMovements)
+				_ = inventory.POST("/products/:id/movements", api.CreateStockMovement)
+				_ = inventory.PATCH("/products/:id/threshold", api.SetLowStockThreshold)
+			}
+			{
+				orders := auth.Group("/orders")
+				orders.Use(api.Auth(), api.AdminOnly())
+
+				_ = orders.GET("", api.GetOrderQueue)
+				_ = orders.GET("/:id", api.GetOrder)
+				_ = orders.PATCH("/:id/status", api.UpdateOrderStatus)
+			}
+			{
+				pos := auth.Group("/pos")
+				pos.Use(api.Auth(), api.AdminOnly())
+
+				_ = pos.GET("/drawer", api.GetOpenCashDrawer)
+				_ = pos.POST("/drawer/open", api.OpenCashDrawer)
+				_ = pos.POST("/drawer/close", api.CloseCashDrawer)
+				_ = pos.GET("/drawer/sessions", api.GetCashDrawerSessions)
+				_ = pos.GET("/drawer/sessions/:id", api.GetCashDrawerSession)
+				_ = pos.GET("/drawer/sessions/:id/sales", api.GetCashDrawerSales)
+				_ = pos.POST("/sales", api.CreatePosSale)
+				_ = pos.GET("/sales/:id", api.GetPosSale)
+				_ = pos.GET("/sales/:id/receipt", api.GetPosSaleReceipt)
+			}
+			{
+				invoices := auth.Group("/invoices")
+				invoices.Use(api.Auth(), api.AdminOnly())
+
+				_ = invoices.GET("", api.GetInvoices)
+				_ = invoices.POST("", api.CreateInvoice)
+				_ = invoices.GET("/:id", api.GetInvoice)
+				_ = invoices.GET("/:id/pdf", api.GetInvoicePDF)
+				_ = invoices.POST("/:id/void", api.VoidInvoice)
+				_ = invoices.POST("/:id/credit-note", api.CreateCreditNote)
+			}
+			{
+				reports := auth.Group("/reports")
+				reports.Use(api.Auth(), api.AdminOnly())
+
+				_ = reports.GET("/income", api.GetIncomeReport)
+				_ = reports.GET("/expenses", api.GetExpenseReport)
+				_ = reports.GET("/profit-loss", api.GetProfitLossReport)
+				_ = reports.GET("/members/growth", api.GetUsersCreatedChartData)
+				_ = reports.GET("/members/churn", api.GetUsersLeftChartData)
+				_ = reports.GET("/kpis", api.GetKPIs)
+			}
+			{
+				trash := auth.Group("/trash")
+				trash.Use(api.Auth(), api.AdminOnly())
+
+				_ = trash.GET("/:entity", api.GetTrash)
+				_ = trash.PATCH("/:entity/:id/restore", api.RestoreTrashItem)
+				_ = trash.DELETE("/:entity/:id", api.PurgeTrashItem)
+			}
 			{
 				admin := auth.Group("/admin")
 				admin.Use(api.AdminOnly())